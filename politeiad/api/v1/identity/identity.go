@@ -12,17 +12,28 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 
 	"github.com/agl/ed25519"
 	"github.com/agl/ed25519/extra25519"
 	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
 )
 
 var (
 	prng = rand.Reader
 
 	ErrNotEqual = errors.New("not equal")
+
+	// ErrPassphraseRequired is returned when an encrypted identity is
+	// loaded without a passphrase.
+	ErrPassphraseRequired = errors.New("identity is encrypted")
+
+	// ErrDecrypt is returned when an encrypted identity can not be
+	// decrypted, usually because the passphrase is wrong.
+	ErrDecrypt = errors.New("could not decrypt identity")
 )
 
 const (
@@ -30,6 +41,16 @@ const (
 	SignatureSize = ed25519.SignatureSize
 	pubKeySize    = ed25519.PublicKeySize
 	IdentitySize  = 32
+
+	// Encrypted identity parameters.
+	encryptedVersion = 1
+	kdfScrypt        = "scrypt"
+	scryptN          = 1 << 15
+	scryptR          = 8
+	scryptP          = 1
+	saltSize         = 32
+	secretKeySize    = 32
+	nonceSize        = 24
 )
 
 type FullIdentity struct {
@@ -87,11 +108,31 @@ func New(name, nick string) (*FullIdentity, error) {
 	return &fi, nil
 }
 
-func LoadFullIdentity(filename string) (*FullIdentity, error) {
+// PassphraseFunc returns the passphrase of an encrypted identity.  It is only
+// called when the identity that is being loaded is encrypted.
+type PassphraseFunc func() ([]byte, error)
+
+// LoadFullIdentity loads a plaintext or an encrypted identity from filename.
+// The passphrase of an encrypted identity is obtained from passphrase, which
+// may be nil when the identity is known to be plaintext.
+func LoadFullIdentity(filename string, passphrase PassphraseFunc) (*FullIdentity, error) {
 	idx, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return nil, err
 	}
+
+	if IsEncrypted(idx) {
+		if passphrase == nil {
+			return nil, ErrPassphraseRequired
+		}
+		pass, err := passphrase()
+		if err != nil {
+			return nil, err
+		}
+		defer zero(pass)
+		return UnmarshalEncryptedFullIdentity(idx, pass)
+	}
+
 	id, err := UnmarshalFullIdentity(idx)
 	if err != nil {
 		return nil, fmt.Errorf("could not unmarshal identity")
@@ -108,6 +149,119 @@ func (fi *FullIdentity) Save(filename string) error {
 	return ioutil.WriteFile(filename, id, 0600)
 }
 
+// encryptedIdentity is the on disk format of an encrypted FullIdentity.  The
+// marshaled identity is sealed with secretbox using a key that is derived
+// from the passphrase.  The KDF parameters are stored so that they can be
+// raised without breaking existing files.
+type encryptedIdentity struct {
+	Version uint            `json:"version"`
+	KDF     string          `json:"kdf"`
+	Salt    []byte          `json:"salt"`
+	N       int             `json:"n"`
+	R       int             `json:"r"`
+	P       int             `json:"p"`
+	Nonce   [nonceSize]byte `json:"nonce"`
+	Box     []byte          `json:"box"`
+}
+
+// deriveKey returns the secretbox key for passphrase.
+func (ei *encryptedIdentity) deriveKey(passphrase []byte) (*[secretKeySize]byte, error) {
+	if ei.KDF != kdfScrypt {
+		return nil, fmt.Errorf("unsupported kdf: %v", ei.KDF)
+	}
+	k, err := scrypt.Key(passphrase, ei.Salt, ei.N, ei.R, ei.P,
+		secretKeySize)
+	if err != nil {
+		return nil, err
+	}
+	var key [secretKeySize]byte
+	copy(key[:], k)
+	zero(k)
+
+	return &key, nil
+}
+
+// IsEncrypted returns true if data is an encrypted identity.
+func IsEncrypted(data []byte) bool {
+	var ei encryptedIdentity
+	if err := json.Unmarshal(data, &ei); err != nil {
+		return false
+	}
+	return ei.KDF != ""
+}
+
+// MarshalEncrypted returns the identity encrypted with passphrase.
+func (fi *FullIdentity) MarshalEncrypted(passphrase []byte) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("empty passphrase")
+	}
+
+	ei := encryptedIdentity{
+		Version: encryptedVersion,
+		KDF:     kdfScrypt,
+		Salt:    make([]byte, saltSize),
+		N:       scryptN,
+		R:       scryptR,
+		P:       scryptP,
+	}
+	if _, err := io.ReadFull(prng, ei.Salt); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(prng, ei.Nonce[:]); err != nil {
+		return nil, err
+	}
+	key, err := ei.deriveKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer zero(key[:])
+
+	b, err := fi.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	defer zero(b)
+	ei.Box = secretbox.Seal(nil, b, &ei.Nonce, key)
+
+	return json.Marshal(ei)
+}
+
+// UnmarshalEncryptedFullIdentity decrypts an identity that was encrypted
+// with MarshalEncrypted.
+func UnmarshalEncryptedFullIdentity(data, passphrase []byte) (*FullIdentity, error) {
+	var ei encryptedIdentity
+	err := json.Unmarshal(data, &ei)
+	if err != nil {
+		return nil, err
+	}
+	if ei.Version != encryptedVersion {
+		return nil, fmt.Errorf("unsupported encrypted identity version: %v",
+			ei.Version)
+	}
+	key, err := ei.deriveKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer zero(key[:])
+
+	b, ok := secretbox.Open(nil, ei.Box, &ei.Nonce, key)
+	if !ok {
+		return nil, ErrDecrypt
+	}
+	defer zero(b)
+
+	return UnmarshalFullIdentity(b)
+}
+
+// SaveEncrypted writes the identity encrypted with passphrase to filename.
+func (fi *FullIdentity) SaveEncrypted(filename string, passphrase []byte) error {
+	id, err := fi.MarshalEncrypted(passphrase)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, id, 0600)
+}
+
 func (fi *FullIdentity) SignMessage(message []byte) [SignatureSize]byte {
 	signature := ed25519.Sign(&fi.PrivateKey, message)
 	return *signature
@@ -136,6 +290,43 @@ func LoadPublicIdentity(filename string) (*PublicIdentity, error) {
 	return id, nil
 }
 
+// PublicIdentityFromString returns a PublicIdentity for the provided hex
+// encoded ed25519 public key.  The name and nick are left empty.
+func PublicIdentityFromString(key string) (*PublicIdentity, error) {
+	k, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(k) != pubKeySize {
+		return nil, fmt.Errorf("invalid public key size")
+	}
+
+	pi := PublicIdentity{}
+	copy(pi.Key[:], k)
+	if !extra25519.PublicKeyToCurve25519(&pi.Identity, &pi.Key) {
+		return nil, fmt.Errorf("invalid public key")
+	}
+
+	return &pi, nil
+}
+
+// SignatureFromString returns the signature for the provided hex encoded
+// string.
+func SignatureFromString(signature string) (*[SignatureSize]byte, error) {
+	s, err := hex.DecodeString(signature)
+	if err != nil {
+		return nil, err
+	}
+	if len(s) != SignatureSize {
+		return nil, fmt.Errorf("invalid signature size")
+	}
+
+	var sig [SignatureSize]byte
+	copy(sig[:], s)
+
+	return &sig, nil
+}
+
 func (p PublicIdentity) VerifyMessage(msg []byte, sig [SignatureSize]byte) bool {
 	return ed25519.Verify(&p.Key, msg, &sig)
 }
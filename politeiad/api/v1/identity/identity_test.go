@@ -5,8 +5,13 @@
 package identity
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/hex"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
@@ -91,3 +96,91 @@ func TestSign(t *testing.T) {
 		t.Fatalf("corrupt signature")
 	}
 }
+
+func TestFromString(t *testing.T) {
+	pi, err := PublicIdentityFromString(hex.EncodeToString(alice.Public.Key[:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pi.Key != alice.Public.Key || pi.Identity != alice.Public.Identity {
+		t.Fatalf("public identity not equal")
+	}
+
+	message := []byte("this is a message")
+	signature := alice.SignMessage(message)
+	sig, err := SignatureFromString(hex.EncodeToString(signature[:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pi.VerifyMessage(message, *sig) {
+		t.Fatalf("corrupt signature")
+	}
+
+	_, err = PublicIdentityFromString("00")
+	if err == nil {
+		t.Fatalf("expected invalid public key size")
+	}
+	_, err = SignatureFromString("zz")
+	if err == nil {
+		t.Fatalf("expected invalid hex")
+	}
+}
+
+func TestEncrypted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "politeia.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "identity.json")
+	passphrase := func() ([]byte, error) {
+		return []byte("moo"), nil
+	}
+
+	// Plaintext identities do not need a passphrase.
+	err = alice.Save(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := LoadFullIdentity(filename, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(a, alice) {
+		t.Fatalf("plaintext load failed")
+	}
+
+	err = alice.SaveEncrypted(filename, []byte("moo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(b) {
+		t.Fatalf("identity not encrypted")
+	}
+	if bytes.Contains(b, []byte(base64.StdEncoding.EncodeToString(
+		alice.PrivateKey[:]))) {
+		t.Fatalf("private key stored in plaintext")
+	}
+
+	_, err = LoadFullIdentity(filename, nil)
+	if err != ErrPassphraseRequired {
+		t.Fatalf("expected ErrPassphraseRequired got %v", err)
+	}
+	_, err = LoadFullIdentity(filename, func() ([]byte, error) {
+		return []byte("oink"), nil
+	})
+	if err != ErrDecrypt {
+		t.Fatalf("expected ErrDecrypt got %v", err)
+	}
+	a, err = LoadFullIdentity(filename, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(a, alice) {
+		t.Fatalf("encrypted load failed")
+	}
+}
@@ -7,6 +7,7 @@ package v1
 import (
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"net/http"
@@ -19,6 +20,7 @@ import (
 
 type ErrorStatusT int
 type PropStatusT int
+type AnchorStateT int
 
 const (
 	// Routes
@@ -27,9 +29,20 @@ const (
 	GetUnvettedRoute = "/v1/getunvetted/" // Retrieve unvetted proposal
 	GetVettedRoute   = "/v1/getvetted/"   // Retrieve vetted proposal
 
+	GetUnvettedVersionsRoute = "/v1/getunvettedversions/" // Unvetted history
+	GetVettedVersionsRoute   = "/v1/getvettedversions/"   // Vetted history
+	GetTimestampsRoute       = "/v1/gettimestamps/"       // Timestamp proofs
+	GetStatusRecordRoute     = "/v1/getstatusrecord/"     // Censorship proof
+	GetCommentsRoute         = "/v1/getcomments/"         // Proposal comments
+
 	// Auth required
 	InventoryRoute         = "/v1/inventory/"         // Inventory proposals
 	SetUnvettedStatusRoute = "/v1/setunvettedstatus/" // Set unvetted status
+	SetVettedStatusRoute   = "/v1/setvettedstatus/"   // Set vetted status
+	UpdateUnvettedRoute    = "/v1/updateunvetted/"    // Update unvetted proposal
+	UpdateVettedRoute      = "/v1/updatevetted/"      // Update vetted proposal
+	AppendCommentsRoute    = "/v1/appendcomments/"    // Append comments
+	CensorCommentRoute     = "/v1/censorcomment/"     // Censor comment
 
 	ChallengeSize = 32 // Size of challenge token in bytes
 
@@ -43,6 +56,11 @@ const (
 	ErrorStatusInvalidMIMEType             ErrorStatusT = 6
 	ErrorStatusUnsupportedMIMEType         ErrorStatusT = 7
 	ErrorStatusInvalidPropStatusTransition ErrorStatusT = 8
+	ErrorStatusProposalNotFound            ErrorStatusT = 9
+	ErrorStatusNoChanges                   ErrorStatusT = 10
+	ErrorStatusInvalidUserSignature        ErrorStatusT = 11
+	ErrorStatusInvalidComment              ErrorStatusT = 12
+	ErrorStatusCommentNotFound             ErrorStatusT = 13
 
 	// Proposal status codes (set and get)
 	PropStatusInvalid     PropStatusT = 0 // Invalid status
@@ -50,6 +68,15 @@ const (
 	PropStatusNotReviewed PropStatusT = 2 // Proposal has not been reviewed
 	PropStatusCensored    PropStatusT = 3 // Proposal has been censored
 	PropStatusPublic      PropStatusT = 4 // Proposal is publicly visible
+	PropStatusAbandoned   PropStatusT = 5 // Public, abandoned by its author
+	PropStatusArchived    PropStatusT = 6 // Public, no longer relevant
+	PropStatusWithdrawn   PropStatusT = 7 // Withdrawn by its author
+
+	// Anchor states
+	AnchorStateInvalid     AnchorStateT = 0 // Invalid state
+	AnchorStateNotAnchored AnchorStateT = 1 // Not submitted to dcrtime yet
+	AnchorStateUnconfirmed AnchorStateT = 2 // Waiting for confirmations
+	AnchorStateConfirmed   AnchorStateT = 3 // Confirmed on the blockchain
 
 	// Default network bits
 	DefaultMainnetHost = "politeia.decred.org"
@@ -72,6 +99,11 @@ var (
 		ErrorStatusInvalidMIMEType:             "invalid MIME type detected",
 		ErrorStatusUnsupportedMIMEType:         "unsupported MIME type",
 		ErrorStatusInvalidPropStatusTransition: "invalid proposal status transition",
+		ErrorStatusProposalNotFound:            "proposal not found",
+		ErrorStatusNoChanges:                   "no changes in proposal",
+		ErrorStatusInvalidUserSignature:        "invalid user signature",
+		ErrorStatusInvalidComment:              "invalid comment",
+		ErrorStatusCommentNotFound:             "comment not found",
 	}
 
 	// PropStatus converts proposal status codes to human readable text.
@@ -81,6 +113,17 @@ var (
 		PropStatusNotReviewed: "not reviewed",
 		PropStatusCensored:    "censored",
 		PropStatusPublic:      "public",
+		PropStatusAbandoned:   "abandoned",
+		PropStatusArchived:    "archived",
+		PropStatusWithdrawn:   "withdrawn",
+	}
+
+	// AnchorState converts anchor states to human readable text.
+	AnchorState = map[AnchorStateT]string{
+		AnchorStateInvalid:     "invalid",
+		AnchorStateNotAnchored: "not anchored",
+		AnchorStateUnconfirmed: "unconfirmed",
+		AnchorStateConfirmed:   "confirmed",
 	}
 
 	// Input validation
@@ -91,22 +134,23 @@ var (
 	ErrInvalidBase64 = errors.New("corrupt base64")
 	ErrInvalidMerkle = errors.New("merkle roots do not match")
 	ErrCorrupt       = errors.New("signature verification failed")
+	ErrInvalidChain  = errors.New("key chain does not link up")
 )
 
-// Verify ensures that a CensorshipRecord properly describes the array of
-// files.
-func Verify(pid identity.PublicIdentity, csr CensorshipRecord, files []File) error {
+// MerkleRoot returns the merkle root of the digests of the payloads of the
+// provided files.
+func MerkleRoot(files []File) (*[sha256.Size]byte, error) {
 	digests := make([]*[sha256.Size]byte, 0, len(files))
 	for _, file := range files {
 		payload, err := base64.StdEncoding.DecodeString(file.Payload)
 		if err != nil {
-			return ErrInvalidBase64
+			return nil, ErrInvalidBase64
 		}
 
 		// MIME
 		mimeType := http.DetectContentType(payload)
 		if !mime.MimeValid(mimeType) {
-			return mime.ErrUnsupportedMimeType
+			return nil, mime.ErrUnsupportedMimeType
 		}
 
 		// Digest
@@ -119,8 +163,17 @@ func Verify(pid identity.PublicIdentity, csr CensorshipRecord, files []File) err
 		digests = append(digests, &digest)
 	}
 
+	return merkle.Root(digests), nil
+}
+
+// Verify ensures that a CensorshipRecord properly describes the array of
+// files.
+func Verify(pid identity.PublicIdentity, csr CensorshipRecord, files []File) error {
 	// Verify merkle root
-	root := merkle.Root(digests)
+	root, err := MerkleRoot(files)
+	if err != nil {
+		return err
+	}
 	if hex.EncodeToString(root[:]) != csr.Merkle {
 		return ErrInvalidMerkle
 	}
@@ -148,6 +201,83 @@ func Verify(pid identity.PublicIdentity, csr CensorshipRecord, files []File) err
 	return nil
 }
 
+// VerifyUserSignature ensures that the Signature of a UserSignature is a
+// valid signature of its Merkle by its PublicKey.  When files are provided
+// Merkle must also be their merkle root.
+func VerifyUserSignature(us UserSignature, files []File) error {
+	if files != nil {
+		root, err := MerkleRoot(files)
+		if err != nil {
+			return err
+		}
+		if hex.EncodeToString(root[:]) != us.Merkle {
+			return ErrInvalidMerkle
+		}
+	}
+
+	pid, err := identity.PublicIdentityFromString(us.PublicKey)
+	if err != nil {
+		return ErrInvalidHex
+	}
+	merkle, err := hex.DecodeString(us.Merkle)
+	if err != nil {
+		return ErrInvalidHex
+	}
+	signature, err := identity.SignatureFromString(us.Signature)
+	if err != nil {
+		return ErrInvalidHex
+	}
+	if !pid.VerifyMessage(merkle, *signature) {
+		return ErrCorrupt
+	}
+
+	return nil
+}
+
+// StatusRecordMessage returns the message that is signed in a StatusRecord.
+// It is the concatenation of merkle, token, status and timestamp, the latter
+// two as little endian 64 bit integers, followed by the reason.
+func StatusRecordMessage(sr StatusRecord) ([]byte, error) {
+	merkle, err := hex.DecodeString(sr.Merkle)
+	if err != nil {
+		return nil, ErrInvalidHex
+	}
+	token, err := hex.DecodeString(sr.Token)
+	if err != nil {
+		return nil, ErrInvalidHex
+	}
+
+	message := make([]byte, 0, len(merkle)+len(token)+16+len(sr.Reason))
+	message = append(message, merkle...)
+	message = append(message, token...)
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(sr.Status))
+	message = append(message, b[:]...)
+	binary.LittleEndian.PutUint64(b[:], uint64(sr.Timestamp))
+	message = append(message, b[:]...)
+	message = append(message, sr.Reason...)
+
+	return message, nil
+}
+
+// VerifyStatusRecord ensures that a StatusRecord was signed by pid.
+func VerifyStatusRecord(pid identity.PublicIdentity, sr StatusRecord) error {
+	message, err := StatusRecordMessage(sr)
+	if err != nil {
+		return err
+	}
+
+	signature, err := identity.SignatureFromString(sr.Signature)
+	if err != nil {
+		return ErrInvalidHex
+	}
+	if !pid.VerifyMessage(message, *signature) {
+		return ErrCorrupt
+	}
+
+	return nil
+}
+
 // CensorshipRecord contains the proof that a proposal was accepted for review.
 // The proof is verifiable on the client side.
 //
@@ -161,6 +291,101 @@ type CensorshipRecord struct {
 	Signature string `json:"signature"` // Signature of merkle+token
 }
 
+// UserSignature contains the proof that a user submitted a proposal.
+//
+// The Merkle field contains the merkle root of the files as they were
+// submitted; later versions of the proposal may have a different merkle
+// root.  The Signature field contains the signature of the merkle root by the
+// ed25519 public key of the user.
+type UserSignature struct {
+	PublicKey string `json:"publickey"` // User public key
+	Merkle    string `json:"merkle"`    // Merkle root of submitted files
+	Signature string `json:"signature"` // Signature of merkle
+}
+
+// StatusRecord contains the proof that the server changed the status of a
+// proposal.  It allows the author of a censored proposal to prove that the
+// proposal, as identified by its merkle root, was censored.
+//
+// The Timestamp field contains the time of the status change.  The Signature
+// field contains the signature of StatusRecordMessage.
+type StatusRecord struct {
+	Token     string      `json:"token"`            // Censorship token
+	Merkle    string      `json:"merkle"`           // Merkle root of proposal
+	Status    PropStatusT `json:"status"`           // New status of proposal
+	Timestamp int64       `json:"timestamp"`        // Time of status change
+	Reason    string      `json:"reason,omitempty"` // Reason for status change
+	Signature string      `json:"signature"`        // Signature of record
+}
+
+// KeyRotationMessage returns the message that is signed in a KeyRotation.  It
+// is the concatenation of the old key, the new key and the timestamp as a
+// little endian 64 bit integer.
+func KeyRotationMessage(kr KeyRotation) ([]byte, error) {
+	oldKey, err := hex.DecodeString(kr.OldKey)
+	if err != nil {
+		return nil, ErrInvalidHex
+	}
+	newKey, err := hex.DecodeString(kr.NewKey)
+	if err != nil {
+		return nil, ErrInvalidHex
+	}
+
+	message := make([]byte, 0, len(oldKey)+len(newKey)+8)
+	message = append(message, oldKey...)
+	message = append(message, newKey...)
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(kr.Timestamp))
+	message = append(message, b[:]...)
+
+	return message, nil
+}
+
+// VerifyKeyChain ensures that every rotation in chain was signed by the key it
+// replaced, that every rotation starts at the key the previous one ended at
+// and that the last rotation ends at key.  An empty chain is valid.
+func VerifyKeyChain(chain []KeyRotation, key string) error {
+	for i, kr := range chain {
+		if i > 0 && (kr.OldKey != chain[i-1].NewKey ||
+			kr.Timestamp < chain[i-1].Timestamp) {
+			return ErrInvalidChain
+		}
+
+		pid, err := identity.PublicIdentityFromString(kr.OldKey)
+		if err != nil {
+			return ErrInvalidHex
+		}
+		message, err := KeyRotationMessage(kr)
+		if err != nil {
+			return err
+		}
+		signature, err := identity.SignatureFromString(kr.Signature)
+		if err != nil {
+			return ErrInvalidHex
+		}
+		if !pid.VerifyMessage(message, *signature) {
+			return ErrCorrupt
+		}
+	}
+	if len(chain) > 0 && chain[len(chain)-1].NewKey != key {
+		return ErrInvalidChain
+	}
+
+	return nil
+}
+
+// KeyRotation records that the server replaced its signing key.  Clients that
+// trust OldKey can use it to establish trust in NewKey.  Records signed by
+// OldKey before Timestamp remain valid.
+//
+// The Signature field contains the signature of KeyRotationMessage by OldKey.
+type KeyRotation struct {
+	OldKey    string `json:"oldkey"`    // Replaced public key
+	NewKey    string `json:"newkey"`    // Replacement public key
+	Timestamp int64  `json:"timestamp"` // Time of rotation
+	Signature string `json:"signature"` // Signature of rotation by OldKey
+}
+
 // Identity requests the proposal server identity.
 type Identity struct {
 	Challenge string `json:"challenge"` // Random challenge
@@ -173,6 +398,10 @@ type IdentityReply struct {
 	Nick     string `json:"nick"`     // Server nick name
 	Identity string `json:"identity"` // Public identity
 	Key      string `json:"key"`      // Public key
+
+	// KeyChain contains every key rotation of the server, oldest first.
+	// It is omitted if the server never rotated its key.
+	KeyChain []KeyRotation `json:"keychain,omitempty"`
 }
 
 // File describes an individual file that is part of the proposal.  The
@@ -189,18 +418,29 @@ type File struct {
 type ProposalRecord struct {
 	Name      string      `json:"name"`      // Suggested short proposal name
 	Status    PropStatusT `json:"status"`    // Current status of proposal
+	Version   uint        `json:"version"`   // Iteration count of proposal
 	Timestamp int64       `json:"timestamp"` // Last update of proposal
 	Files     []File      `json:"files"`     // Files that make up the proposal
 
+	// Reason is the optional reason of the last status change.
+	Reason string `json:"reason,omitempty"`
+
+	// UserSignature is set if the proposal was submitted by a user that
+	// signed it.
+	UserSignature *UserSignature `json:"usersignature,omitempty"`
+
 	CensorshipRecord CensorshipRecord `json:"censorshiprecord"`
 }
 
 // New initiates a new proposal.  It must include all files that are part of
 // the proposal.  The only acceptable file types are text, markdown and PNG.
+// The optional UserSignature is verified against the files and stored with
+// the proposal.
 type New struct {
-	Challenge string `json:"challenge"` // Random challenge
-	Name      string `json:"name"`      // Suggested short proposal name
-	Files     []File `json:"files"`     // Files that make up the proposal
+	Challenge     string         `json:"challenge"`               // Random challenge
+	Name          string         `json:"name"`                    // Suggested short proposal name
+	Files         []File         `json:"files"`                   // Files that make up the proposal
+	UserSignature *UserSignature `json:"usersignature,omitempty"` // Optional user signature
 }
 
 // NewReply returns the CensorshipRecord that is associated with a valid
@@ -211,10 +451,13 @@ type NewReply struct {
 	CensorshipRecord CensorshipRecord `json:"censorshiprecord"`
 }
 
-// GetUnvetted requests an unvetted proposal from the server.
+// GetUnvetted requests an unvetted proposal from the server.  If Version is
+// set the proposal is returned as it was at that version instead of the latest
+// version.
 type GetUnvetted struct {
-	Challenge string `json:"challenge"` // Random challenge
-	Token     string `json:"token"`     // Censorship token
+	Challenge string `json:"challenge"`         // Random challenge
+	Token     string `json:"token"`             // Censorship token
+	Version   uint   `json:"version,omitempty"` // Optional version
 }
 
 // GetUnvettedReply returns an unvetted proposal.  It retrieves the censorship
@@ -224,10 +467,13 @@ type GetUnvettedReply struct {
 	Proposal ProposalRecord `json:"proposalrecord"`
 }
 
-// GetVetted requests a vetted proposal from the server.
+// GetVetted requests a vetted proposal from the server.  If Version is
+// set the proposal is returned as it was at that version instead of the latest
+// version.
 type GetVetted struct {
-	Challenge string `json:"challenge"` // Random challenge
-	Token     string `json:"token"`     // Censorship token
+	Challenge string `json:"challenge"`         // Random challenge
+	Token     string `json:"token"`             // Censorship token
+	Version   uint   `json:"version,omitempty"` // Optional version
 }
 
 // GetVettedReply returns a vetted proposal.  It retrieves the censorship
@@ -237,33 +483,226 @@ type GetVettedReply struct {
 	Proposal ProposalRecord `json:"proposalrecord"`
 }
 
+// ProposalVersion describes a single entry in the history of a proposal.
+// Every commit that modified the proposal metadata results in an entry, so
+// status changes show up as entries that share the same Version.
+type ProposalVersion struct {
+	Commit    string      `json:"commit"`    // Digest of backend commit
+	Version   uint        `json:"version"`   // Iteration count of proposal
+	Status    PropStatusT `json:"status"`    // Status at this commit
+	Timestamp int64       `json:"timestamp"` // Last update of proposal
+	Merkle    string      `json:"merkle"`    // Merkle root of proposal
+}
+
+// GetUnvettedVersions requests the history of an unvetted proposal.
+type GetUnvettedVersions struct {
+	Challenge string `json:"challenge"` // Random challenge
+	Token     string `json:"token"`     // Censorship token
+}
+
+// GetUnvettedVersionsReply returns the history of an unvetted proposal,
+// newest first.
+type GetUnvettedVersionsReply struct {
+	Response string            `json:"response"` // Challenge response
+	Versions []ProposalVersion `json:"versions"` // Proposal history
+}
+
+// GetVettedVersions requests the history of a vetted proposal.
+type GetVettedVersions struct {
+	Challenge string `json:"challenge"` // Random challenge
+	Token     string `json:"token"`     // Censorship token
+}
+
+// GetVettedVersionsReply returns the history of a vetted proposal, newest
+// first.  This includes the history from before the proposal was published.
+type GetVettedVersionsReply struct {
+	Response string            `json:"response"` // Challenge response
+	Versions []ProposalVersion `json:"versions"` // Proposal history
+}
+
+// GetTimestamps requests the timestamp proofs of a proposal.  Vetted
+// proposals return the proofs of their vetted history and all other
+// proposals the proofs of their unvetted history.
+type GetTimestamps struct {
+	Challenge string `json:"challenge"` // Random challenge
+	Token     string `json:"token"`     // Censorship token
+}
+
+// Timestamp proves when a single commit of a proposal was anchored.  Digest
+// is proven to be part of MerkleRoot by MerklePath.  Once the anchor is
+// confirmed MerkleRoot is proven to be part of ChainMerkleRoot, which is
+// stored in Transaction, by ChainMerklePath.
+type Timestamp struct {
+	Digest     string         `json:"digest"`               // Anchored commit digest
+	Version    uint           `json:"version"`              // Proposal version at commit
+	State      AnchorStateT   `json:"state"`                // Anchor state of commit
+	MerkleRoot string         `json:"merkleroot,omitempty"` // Anchor merkle root
+	MerklePath *merkle.Branch `json:"merklepath,omitempty"` // Digest inclusion proof
+
+	// dcrtime portion, only set when State is AnchorStateConfirmed
+	ChainTimestamp  int64          `json:"chaintimestamp,omitempty"`  // Block timestamp
+	Transaction     string         `json:"transaction,omitempty"`     // Anchor transaction
+	ChainMerkleRoot string         `json:"chainmerkleroot,omitempty"` // Merkle root in Transaction
+	ChainMerklePath *merkle.Branch `json:"chainmerklepath,omitempty"` // MerkleRoot inclusion proof
+}
+
+// GetTimestampsReply returns the timestamp proofs of every commit of a
+// proposal, newest first.
+type GetTimestampsReply struct {
+	Response   string      `json:"response"`   // Challenge response
+	Timestamps []Timestamp `json:"timestamps"` // Commit timestamps
+}
+
 // SetUnvettedStatus updates the status of an unvetted proposal.  This is used
-// to either promote a proposal to the public viewable repository or to censor
-// it.
+// to either promote a proposal to the public viewable repository, to censor
+// it or to withdraw it on behalf of its author.  The optional reason is stored
+// with the proposal.
 type SetUnvettedStatus struct {
-	Challenge string      `json:"challenge"` // Random challenge
-	Token     string      `json:"token"`     // Censorship token
-	Status    PropStatusT `json:"status"`    // Update unvetted status of proposal
+	Challenge string      `json:"challenge"`        // Random challenge
+	Token     string      `json:"token"`            // Censorship token
+	Status    PropStatusT `json:"status"`           // Update unvetted status of proposal
+	Reason    string      `json:"reason,omitempty"` // Reason for status change
 }
 
 // SetUnvettedStatus is a response to a SetUnvettedStatus.  The status field
 // may be different than the status that was requested.  This should only
-// happen when the command fails.
+// happen when the command fails.  Every status change creates a new version of
+// the proposal; the timestamp of the change is in the status record.
 type SetUnvettedStatusReply struct {
-	Response string      `json:"response"` // Challenge response
-	Status   PropStatusT `json:"status"`   // Actual status, may differ from request
+	Response     string       `json:"response"`     // Challenge response
+	Status       PropStatusT  `json:"status"`       // Actual status, may differ from request
+	Version      uint         `json:"version"`      // Proposal version after the change
+	StatusRecord StatusRecord `json:"statusrecord"` // Signed status change
 }
 
-//type UpdateUnvetted struct {
-//	Challenge string `json:"challenge"` // Random challenge
-//	Token     string `json:"token"`     // Censorship token
-//	Files     []File `json:"files"`     // Files that make up the proposal
-//}
-//
-//type UpdateUnvetted struct {
-//	Challenge string `json:"challenge"` // Random challenge
-//	Token     string `json:"token"`     // Censorship token
-//}
+// SetVettedStatus moves a vetted proposal to a terminal status, either
+// abandoned or archived.  The proposal remains publicly viewable.  The
+// optional reason is stored with the proposal.
+type SetVettedStatus struct {
+	Challenge string      `json:"challenge"`        // Random challenge
+	Token     string      `json:"token"`            // Censorship token
+	Status    PropStatusT `json:"status"`           // Update vetted status of proposal
+	Reason    string      `json:"reason,omitempty"` // Reason for status change
+}
+
+// SetVettedStatusReply is a response to a SetVettedStatus.  The status field
+// may be different than the status that was requested.  This should only
+// happen when the command fails.  Every status change creates a new version of
+// the proposal; the timestamp of the change is in the status record.
+type SetVettedStatusReply struct {
+	Response     string       `json:"response"`     // Challenge response
+	Status       PropStatusT  `json:"status"`       // Actual status, may differ from request
+	Version      uint         `json:"version"`      // Proposal version after the change
+	StatusRecord StatusRecord `json:"statusrecord"` // Signed status change
+}
+
+// GetStatusRecord requests the signed status record of a censored proposal.
+// This call is not authenticated so that authors can obtain proof of
+// censorship at any time.
+type GetStatusRecord struct {
+	Challenge string `json:"challenge"` // Random challenge
+	Token     string `json:"token"`     // Censorship token
+}
+
+// GetStatusRecordReply returns the signed status record of a censored
+// proposal.
+type GetStatusRecordReply struct {
+	Response     string       `json:"response"`     // Challenge response
+	StatusRecord StatusRecord `json:"statusrecord"` // Signed status record
+}
+
+// UpdateUnvetted replaces the files of an unvetted proposal.  It must include
+// all files that are part of the proposal; files that are omitted are removed
+// from the proposal.
+type UpdateUnvetted struct {
+	Challenge string `json:"challenge"` // Random challenge
+	Token     string `json:"token"`     // Censorship token
+	Files     []File `json:"files"`     // Files that make up the proposal
+}
+
+// UpdateUnvettedReply returns the new CensorshipRecord of the updated
+// proposal.  The token remains the same but the merkle root and signature
+// reflect the new files.
+type UpdateUnvettedReply struct {
+	Response         string           `json:"response"` // Challenge response
+	Timestamp        int64            `json:"timestamp"`
+	CensorshipRecord CensorshipRecord `json:"censorshiprecord"`
+}
+
+// UpdateVetted replaces the files of a vetted proposal.  It must include all
+// files that are part of the proposal; files that are omitted are removed
+// from the proposal.
+type UpdateVetted struct {
+	Challenge string `json:"challenge"` // Random challenge
+	Token     string `json:"token"`     // Censorship token
+	Files     []File `json:"files"`     // Files that make up the proposal
+}
+
+// UpdateVettedReply returns the new CensorshipRecord of the updated proposal.
+// The token remains the same but the merkle root and signature reflect the
+// new files.
+type UpdateVettedReply struct {
+	Response         string           `json:"response"` // Challenge response
+	Timestamp        int64            `json:"timestamp"`
+	CensorshipRecord CensorshipRecord `json:"censorshiprecord"`
+}
+
+// Comment is a comment on a vetted proposal.  The CommentID is assigned by the
+// frontend and identifies the comment; a comment that is appended twice is
+// only stored once.  A ParentID of 0 indicates a comment on the proposal.
+// Censored comments are returned without text.
+type Comment struct {
+	CommentID uint64 `json:"commentid"`          // Unique comment identifier
+	UserID    uint64 `json:"userid"`             // Author of the comment
+	ParentID  uint64 `json:"parentid"`           // Comment that is replied to
+	Timestamp int64  `json:"timestamp"`          // Time the comment was made
+	Comment   string `json:"comment"`            // Comment text
+	Censored  bool   `json:"censored,omitempty"` // Comment text was removed
+	Reason    string `json:"reason,omitempty"`   // Censorship reason
+}
+
+// AppendComments stores comments with a vetted proposal.  The comments are
+// committed to the proposal record and anchored like the proposal itself.
+type AppendComments struct {
+	Challenge string    `json:"challenge"` // Random challenge
+	Token     string    `json:"token"`     // Censorship token
+	Comments  []Comment `json:"comments"`  // Comments to append
+}
+
+// AppendCommentsReply returns the number of comments that were stored.
+// Comments that were appended before are not counted.
+type AppendCommentsReply struct {
+	Response string `json:"response"` // Challenge response
+	Appended uint   `json:"appended"` // Number of new comments
+}
+
+// CensorComment replaces a comment of a vetted proposal with a tombstone that
+// records the reason.  The comment is no longer returned with its text.  The
+// text remains in the history of the proposal record since that history is
+// anchored.
+type CensorComment struct {
+	Challenge string `json:"challenge"` // Random challenge
+	Token     string `json:"token"`     // Censorship token
+	CommentID uint64 `json:"commentid"` // Comment to censor
+	Reason    string `json:"reason"`    // Censorship reason
+}
+
+// CensorCommentReply is the reply to CensorComment.
+type CensorCommentReply struct {
+	Response string `json:"response"` // Challenge response
+}
+
+// GetComments requests the comments of a vetted proposal.
+type GetComments struct {
+	Challenge string `json:"challenge"` // Random challenge
+	Token     string `json:"token"`     // Censorship token
+}
+
+// GetCommentsReply returns the comments of a vetted proposal, oldest first.
+type GetCommentsReply struct {
+	Response string    `json:"response"` // Challenge response
+	Comments []Comment `json:"comments"` // Proposal comments
+}
 
 // Inventory sends an (expensive and therefore authenticated) inventory request
 // for vetted proposals (master branch) and branches (censored, unpublished etc)
@@ -272,11 +711,26 @@ type SetUnvettedStatusReply struct {
 // The IncludeFiles flag indicates if the records contain the proposal payload
 // as well.  This can quickly become very large and should only be used when
 // recovering the client side.
+//
+// Records are returned newest first by timestamp, ties are broken by token.
+// The count fields limit the number of records returned; zero returns all
+// records.  Since limits the reply to records that were updated after the
+// provided UNIX timestamp.  The cursor fields are copied from a prior
+// InventoryReply in order to fetch the next page of records.  A record that is
+// updated while the pages are fetched moves to the front of the listing, where
+// a later request with Since finds it.  The status fields limit the reply to
+// records with one of the provided statuses; empty returns records of any
+// status.
 type Inventory struct {
-	Challenge     string `json:"challenge"`     // Random challenge
-	IncludeFiles  bool   `json:"includefiles"`  // Include files in records
-	VettedCount   uint   `json:"vettedcount"`   // Last N vetted proposals
-	BranchesCount uint   `json:"branchescount"` // Last N branches (censored, new etc)
+	Challenge      string        `json:"challenge"`                // Random challenge
+	IncludeFiles   bool          `json:"includefiles"`             // Include files in records
+	VettedCount    uint          `json:"vettedcount"`              // Newest N vetted proposals
+	BranchesCount  uint          `json:"branchescount"`            // Newest N branches (censored, new etc)
+	Since          int64         `json:"since,omitempty"`          // Only records updated after this time
+	VettedCursor   string        `json:"vettedcursor,omitempty"`   // Resume vetted after cursor
+	BranchesCursor string        `json:"branchescursor,omitempty"` // Resume branches after cursor
+	VettedStatus   []PropStatusT `json:"vettedstatus,omitempty"`   // Only vetted records in status
+	BranchesStatus []PropStatusT `json:"branchesstatus,omitempty"` // Only branch records in status
 }
 
 // InventoryReply returns vetted and branch proposal censorship records.  If
@@ -284,10 +738,15 @@ type Inventory struct {
 // ProposalRecords will also include the proposal files.  This obviously
 // enlarges the payload size and should therefore be used only in disaster
 // recovery scenarios.
+//
+// The cursor fields are set when a page was filled up and more records may be
+// available.  The cursors are opaque and should be passed back verbatim.
 type InventoryReply struct {
-	Response string           `json:"response"` // Challenge response
-	Vetted   []ProposalRecord `json:"vetted"`   // Last N vetted proposals
-	Branches []ProposalRecord `json:"branches"` // Last N branches (censored, new etc)
+	Response       string           `json:"response"`                 // Challenge response
+	Vetted         []ProposalRecord `json:"vetted"`                   // Newest N vetted proposals
+	Branches       []ProposalRecord `json:"branches"`                 // Newest N branches (censored, new etc)
+	VettedCursor   string           `json:"vettedcursor,omitempty"`   // Next vetted page
+	BranchesCursor string           `json:"branchescursor,omitempty"` // Next branches page
 }
 
 // UserErrorReply returns details about an error that occurred while trying to
@@ -0,0 +1,89 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package v1
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/decred/politeia/politeiad/api/v1/identity"
+)
+
+// signStatusRecord signs sr with id the way politeiad does.
+func signStatusRecord(t *testing.T, id *identity.FullIdentity, sr StatusRecord) StatusRecord {
+	message, err := StatusRecordMessage(sr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := id.SignMessage(message)
+	sr.Signature = hex.EncodeToString(signature[:])
+	return sr
+}
+
+func TestStatusRecord(t *testing.T) {
+	id, err := identity.New("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sr := signStatusRecord(t, id, StatusRecord{
+		Token:     hex.EncodeToString(make([]byte, 32)),
+		Merkle:    hex.EncodeToString(make([]byte, 32)),
+		Status:    PropStatusCensored,
+		Timestamp: 1500000000,
+		Reason:    "spam",
+	})
+	err = VerifyStatusRecord(id.Public, sr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every field is covered by the signature.
+	other, err := identity.New("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, tamper := range map[string]func(*StatusRecord){
+		"token": func(sr *StatusRecord) {
+			sr.Token = hex.EncodeToString(make([]byte, 31))
+		},
+		"merkle": func(sr *StatusRecord) {
+			sr.Merkle = hex.EncodeToString(make([]byte, 31))
+		},
+		"status": func(sr *StatusRecord) {
+			sr.Status = PropStatusPublic
+		},
+		"timestamp": func(sr *StatusRecord) {
+			sr.Timestamp++
+		},
+		"reason": func(sr *StatusRecord) {
+			sr.Reason = "not spam"
+		},
+		"signature": func(sr *StatusRecord) {
+			sr.Signature = signStatusRecord(t, other, *sr).Signature
+		},
+	} {
+		tampered := sr
+		tamper(&tampered)
+		err = VerifyStatusRecord(id.Public, tampered)
+		if err != ErrCorrupt {
+			t.Fatalf("%v: expected ErrCorrupt got %v", name, err)
+		}
+	}
+
+	// Signatures of the wrong size are rejected rather than padded.
+	for _, s := range []string{
+		"",
+		sr.Signature[:len(sr.Signature)-2],
+		sr.Signature + "00",
+		"zz",
+	} {
+		tampered := sr
+		tampered.Signature = s
+		err = VerifyStatusRecord(id.Public, tampered)
+		if err != ErrInvalidHex {
+			t.Fatalf("%q: expected ErrInvalidHex got %v", s, err)
+		}
+	}
+}
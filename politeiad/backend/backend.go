@@ -5,11 +5,18 @@
 package backend
 
 import (
+	"bytes"
 	"crypto/sha256"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"net/http"
+	"sort"
 
+	"github.com/decred/dcrtime/merkle"
 	"github.com/decred/politeia/politeiad/api/v1"
+	"github.com/decred/politeia/politeiad/api/v1/mime"
+	"github.com/decred/politeia/util"
 )
 
 var (
@@ -21,8 +28,16 @@ var (
 
 	// ErrInvalidTransition is emitted when an invalid status transition
 	// occurs.  The only valid transitions are from unvetted -> vetted and
-	// unvetted to censored.
+	// unvetted to censored.  It is also emitted when an update is attempted
+	// on a proposal that is not in an updatable state.
 	ErrInvalidTransition = errors.New("invalid proposal status transition")
+
+	// ErrNoChanges is emitted when a proposal update does not change any
+	// of the proposal files.
+	ErrNoChanges = errors.New("no changes to proposal")
+
+	// ErrCommentNotFound is emitted when a comment could not be found.
+	ErrCommentNotFound = errors.New("comment not found")
 )
 
 // ContentVerificationError is returned when a submitted proposal contains
@@ -47,22 +62,65 @@ type PSRStatusT int
 
 const (
 	// All possible PSR status codes
-	PSRStatusInvalid  PSRStatusT = 0
-	PSRStatusUnvetted PSRStatusT = 1
-	PSRStatusVetted   PSRStatusT = 2
-	PSRStatusCensored PSRStatusT = 3
+	PSRStatusInvalid   PSRStatusT = 0
+	PSRStatusUnvetted  PSRStatusT = 1
+	PSRStatusVetted    PSRStatusT = 2
+	PSRStatusCensored  PSRStatusT = 3
+	PSRStatusAbandoned PSRStatusT = 4 // Vetted, abandoned by its author
+	PSRStatusArchived  PSRStatusT = 5 // Vetted, no longer relevant
+	PSRStatusWithdrawn PSRStatusT = 6 // Unvetted, withdrawn by its author
 )
 
 var (
 	// PSRStatus converts a status code to a human readable error.
 	PSRStatus = map[PSRStatusT]string{
-		PSRStatusInvalid:  "invalid",
-		PSRStatusUnvetted: "unvetted",
-		PSRStatusVetted:   "vetted",
-		PSRStatusCensored: "censored",
+		PSRStatusInvalid:   "invalid",
+		PSRStatusUnvetted:  "unvetted",
+		PSRStatusVetted:    "vetted",
+		PSRStatusCensored:  "censored",
+		PSRStatusAbandoned: "abandoned",
+		PSRStatusArchived:  "archived",
+		PSRStatusWithdrawn: "withdrawn",
 	}
 )
 
+// StatusVetted returns true if proposals with the provided status are vetted.
+// Vetted proposals stay vetted when they move to a terminal state.
+func StatusVetted(status PSRStatusT) bool {
+	switch status {
+	case PSRStatusVetted, PSRStatusAbandoned, PSRStatusArchived:
+		return true
+	}
+	return false
+}
+
+// UnvettedTransition returns true if an unvetted proposal may move from
+// status from to status to.  Unvetted proposals are published or censored by
+// an admin or withdrawn by their author.
+func UnvettedTransition(from, to PSRStatusT) bool {
+	if from != PSRStatusUnvetted {
+		return false
+	}
+	switch to {
+	case PSRStatusVetted, PSRStatusCensored, PSRStatusWithdrawn:
+		return true
+	}
+	return false
+}
+
+// VettedTransition returns true if a vetted proposal may move from status
+// from to status to.  Vetted proposals are abandoned or archived by an admin.
+func VettedTransition(from, to PSRStatusT) bool {
+	if from != PSRStatusVetted {
+		return false
+	}
+	switch to {
+	case PSRStatusAbandoned, PSRStatusArchived:
+		return true
+	}
+	return false
+}
+
 // ProposalStorageRecord is the metadata of a proposal.
 type ProposalStorageRecord struct {
 	Version   uint              // Iteration count of proposal
@@ -71,17 +129,314 @@ type ProposalStorageRecord struct {
 	Name      string            // Short name of proposal
 	Timestamp int64             // Last updated
 	Token     []byte            // Proposal authentication token
+	Reason    string            // Reason for the last status change
+}
+
+// UserSignature is the proof that a user submitted a proposal.  It is
+// stored verbatim when the proposal is created and never changes.
+type UserSignature struct {
+	PublicKey []byte            // User ed25519 public key
+	Merkle    [sha256.Size]byte // Merkle root of the submitted files
+	Signature []byte            // Signature of Merkle
 }
 
 // ProposalRecord is a ProposalStorageRecord that includes the files.
 type ProposalRecord struct {
 	ProposalStorageRecord ProposalStorageRecord
 	Files                 []File
+	UserSignature         *UserSignature // Optional
+}
+
+// ProposalVersion is a single entry in the history of a proposal.  Every
+// commit that modified the ProposalStorageRecord results in a version.
+type ProposalVersion struct {
+	Digest                []byte                // Commit digest
+	ProposalStorageRecord ProposalStorageRecord // Metadata at Digest
+}
+
+// Comment is a comment on a vetted proposal.  Comments are created by the
+// frontend, which assigns the CommentID.  A ParentID of 0 indicates a comment
+// on the proposal itself.  A censored comment is a tombstone without text.
+type Comment struct {
+	CommentID uint64 // Unique comment identifier
+	UserID    uint64 // Author of the comment
+	ParentID  uint64 // Comment that is replied to, 0 if none
+	Timestamp int64  // Time the comment was made
+	Comment   string // Comment text, blank when censored
+	Censored  bool   // Set to true when the text was removed
+	Reason    string // Censorship reason
+}
+
+// NewComments returns the comments that are not in existing, in the order
+// they were provided.  Comments are identified by their CommentID so that
+// the frontend can resend comments without creating duplicates.  Backends
+// use this to implement AppendComments consistently.
+func NewComments(existing, comments []Comment) ([]Comment, error) {
+	seen := make(map[uint64]struct{}, len(existing)+len(comments))
+	for _, v := range existing {
+		seen[v.CommentID] = struct{}{}
+	}
+
+	fresh := make([]Comment, 0, len(comments))
+	for _, v := range comments {
+		if v.CommentID == 0 || v.Comment == "" {
+			return nil, ContentVerificationError{
+				ErrorCode: v1.ErrorStatusInvalidComment,
+				ErrorContext: []string{
+					fmt.Sprintf("%v", v.CommentID),
+				},
+			}
+		}
+		if _, ok := seen[v.CommentID]; ok {
+			continue
+		}
+		seen[v.CommentID] = struct{}{}
+		fresh = append(fresh, v)
+	}
+
+	return fresh, nil
+}
+
+// CensorComment returns a copy of comments in which comment commentID is
+// replaced by a tombstone that records reason.  It returns false if the
+// comment was censored already.  Backends use this to implement CensorComment
+// consistently.
+func CensorComment(comments []Comment, commentID uint64, reason string) ([]Comment, bool, error) {
+	for i, v := range comments {
+		if v.CommentID != commentID {
+			continue
+		}
+		if v.Censored {
+			return comments, false, nil
+		}
+		censored := make([]Comment, len(comments))
+		copy(censored, comments)
+		censored[i].Comment = ""
+		censored[i].Censored = true
+		censored[i].Reason = reason
+		return censored, true, nil
+	}
+	return nil, false, ErrCommentNotFound
+}
+
+// AnchorStateT describes how far a commit made it into the blockchain.
+type AnchorStateT int
+
+const (
+	// All possible anchor states
+	AnchorStateInvalid     AnchorStateT = 0 // Invalid state
+	AnchorStateNotAnchored AnchorStateT = 1 // Not submitted to dcrtime yet
+	AnchorStateUnconfirmed AnchorStateT = 2 // Waiting for confirmations
+	AnchorStateConfirmed   AnchorStateT = 3 // Confirmed on the blockchain
+)
+
+var (
+	// AnchorState converts an anchor state to human readable text.
+	AnchorState = map[AnchorStateT]string{
+		AnchorStateInvalid:     "invalid",
+		AnchorStateNotAnchored: "not anchored",
+		AnchorStateUnconfirmed: "unconfirmed",
+		AnchorStateConfirmed:   "confirmed",
+	}
+)
+
+// Timestamp is the timestamp proof of a single commit of a proposal.  Digest
+// is proven to be part of the anchor MerkleRoot by MerklePath.  Once the
+// anchor is confirmed MerkleRoot is proven to be part of ChainMerkleRoot,
+// which is stored in Transaction, by ChainMerklePath.
+type Timestamp struct {
+	Digest     []byte        // Anchored commit digest
+	Version    uint          // Proposal version at this commit
+	State      AnchorStateT  // Anchor state of the commit
+	MerkleRoot []byte        // Anchor merkle root, if anchored
+	MerklePath merkle.Branch // Inclusion proof of Digest in MerkleRoot
+
+	// dcrtime portion, only valid when State is AnchorStateConfirmed
+	ChainTimestamp  int64         // Block timestamp
+	Transaction     string        // Anchor transaction
+	ChainMerkleRoot []byte        // Merkle root stored in Transaction
+	ChainMerklePath merkle.Branch // Inclusion proof of MerkleRoot
+}
+
+// InventoryCursor identifies a position in an inventory listing.  Inventory
+// records are sorted newest first by timestamp and ties are broken by token.
+// A record that is updated while a listing is walked moves to the front, ahead
+// of the cursor.
+type InventoryCursor struct {
+	Timestamp int64  // Timestamp of the last record returned
+	Token     []byte // Token of the last record returned
+}
+
+// InventoryRequest selects a page of inventory records.
+type InventoryRequest struct {
+	Count  uint             // Maximum number of records, 0 means all
+	Since  int64            // Only return records updated after Since
+	Cursor *InventoryCursor // Only return records that sort after Cursor
+	Status []PSRStatusT     // Only return records in Status, empty means all
+}
+
+// inventoryBefore returns true if the record described by timestamp a and
+// token ta sorts before the record described by timestamp b and token tb.
+func inventoryBefore(a int64, ta []byte, b int64, tb []byte) bool {
+	if a != b {
+		return a > b
+	}
+	return bytes.Compare(ta, tb) < 0
+}
+
+// inventoryStatus returns true if status is one of the requested statuses.
+// An empty list matches every status.
+func inventoryStatus(status PSRStatusT, statuses []PSRStatusT) bool {
+	if len(statuses) == 0 {
+		return true
+	}
+	for _, v := range statuses {
+		if v == status {
+			return true
+		}
+	}
+	return false
+}
+
+// InventoryPage sorts the provided records newest first and returns the page
+// that is selected by the InventoryRequest.  The provided slice is sorted in
+// place.  Backends use this to implement Inventory consistently.
+func InventoryPage(psrs []ProposalStorageRecord, ir InventoryRequest) []ProposalStorageRecord {
+	sort.Slice(psrs, func(i, j int) bool {
+		return inventoryBefore(psrs[i].Timestamp, psrs[i].Token,
+			psrs[j].Timestamp, psrs[j].Token)
+	})
+
+	page := make([]ProposalStorageRecord, 0, len(psrs))
+	for _, v := range psrs {
+		if v.Timestamp <= ir.Since {
+			// Sorted so everything after this is older.
+			break
+		}
+		if ir.Cursor != nil && !inventoryBefore(ir.Cursor.Timestamp,
+			ir.Cursor.Token, v.Timestamp, v.Token) {
+			continue
+		}
+		if !inventoryStatus(v.Status, ir.Status) {
+			continue
+		}
+		page = append(page, v)
+		if ir.Count != 0 && uint(len(page)) == ir.Count {
+			break
+		}
+	}
+
+	return page
+}
+
+// VerifyContent verifies that all provided files are sane and returns the
+// decoded payloads in the same order as the files.
+func VerifyContent(files []File) ([][]byte, error) {
+	payloads := make([][]byte, 0, len(files))
+	for i := range files {
+		// Validate digest
+		d, ok := util.ConvertDigest(files[i].Digest)
+		if !ok {
+			return nil, ContentVerificationError{
+				ErrorCode: v1.ErrorStatusInvalidFileDigest,
+				ErrorContext: []string{
+					files[i].Name,
+				},
+			}
+		}
+
+		// Decode base64 payload
+		payload, err := base64.StdEncoding.DecodeString(files[i].Payload)
+		if err != nil {
+			return nil, ContentVerificationError{
+				ErrorCode: v1.ErrorStatusInvalidBase64,
+				ErrorContext: []string{
+					files[i].Name,
+				},
+			}
+		}
+
+		// Calculate payload digest
+		dp := util.Digest(payload)
+		if !bytes.Equal(d[:], dp) {
+			return nil, ContentVerificationError{
+				ErrorCode: v1.ErrorStatusInvalidFileDigest,
+				ErrorContext: []string{
+					files[i].Name,
+				},
+			}
+		}
+
+		// Verify MIME
+		detectedMIMEType := http.DetectContentType(payload)
+		if detectedMIMEType != files[i].MIME {
+			return nil, ContentVerificationError{
+				ErrorCode: v1.ErrorStatusInvalidMIMEType,
+				ErrorContext: []string{
+					files[i].Name,
+					detectedMIMEType,
+				},
+			}
+		}
+		if !mime.MimeValid(files[i].MIME) {
+			return nil, ContentVerificationError{
+				ErrorCode: v1.ErrorStatusUnsupportedMIMEType,
+				ErrorContext: []string{
+					files[i].Name,
+					files[i].MIME,
+				},
+			}
+		}
+
+		payloads = append(payloads, payload)
+	}
+
+	return payloads, nil
+}
+
+// VerifyProposal verifies the content of all provided files and ensures that
+// the proposal is not empty and does not contain duplicate filenames.  It
+// returns the decoded payloads in the same order as the files.
+func VerifyProposal(files []File) ([][]byte, error) {
+	payloads, err := VerifyContent(files)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(payloads) == 0 {
+		return nil, fmt.Errorf("empty proposal")
+	}
+
+	// Prevent duplicate filenames
+	for i := range files {
+		for j := range files {
+			if i == j {
+				continue
+			}
+			if files[i].Name == files[j].Name {
+				return nil, fmt.Errorf("duplicate filename %v",
+					files[i].Name)
+			}
+		}
+	}
+
+	return payloads, nil
+}
+
+// MerkleRoot returns the merkle root of the provided decoded payloads.  This
+// is the root that is recorded in the ProposalStorageRecord.
+func MerkleRoot(payloads [][]byte) [sha256.Size]byte {
+	hashes := make([]*[sha256.Size]byte, 0, len(payloads))
+	for i := range payloads {
+		d := sha256.Sum256(payloads[i])
+		hashes = append(hashes, &d)
+	}
+	return *merkle.Root(hashes)
 }
 
 type Backend interface {
-	// Create new proposal
-	New(string, []File) (*ProposalStorageRecord, error)
+	// Create new proposal with an optional user signature
+	New(string, []File, *UserSignature) (*ProposalStorageRecord, error)
 
 	// Get unvetted proposal
 	GetUnvetted([]byte) (*ProposalRecord, error)
@@ -89,11 +444,47 @@ type Backend interface {
 	// Get vetted proposal
 	GetVetted([]byte) (*ProposalRecord, error)
 
-	// Set unvetted proposal status
-	SetUnvettedStatus([]byte, PSRStatusT) (PSRStatusT, error)
+	// Get unvetted proposal at a specific version (token, version)
+	GetUnvettedVersion([]byte, uint) (*ProposalRecord, error)
+
+	// Get vetted proposal at a specific version (token, version)
+	GetVettedVersion([]byte, uint) (*ProposalRecord, error)
+
+	// Get history of unvetted proposal, newest first
+	UnvettedVersions([]byte) ([]ProposalVersion, error)
+
+	// Get history of vetted proposal, newest first
+	VettedVersions([]byte) ([]ProposalVersion, error)
+
+	// Update unvetted proposal files (token, files)
+	UpdateUnvettedRecord([]byte, []File) (*ProposalStorageRecord, error)
+
+	// Update vetted proposal files (token, files)
+	UpdateVettedRecord([]byte, []File) (*ProposalStorageRecord, error)
+
+	// Set unvetted proposal status (token, status, reason)
+	SetUnvettedStatus([]byte, PSRStatusT, string) (PSRStatusT, error)
+
+	// Set vetted proposal status (token, status, reason)
+	SetVettedStatus([]byte, PSRStatusT, string) (PSRStatusT, error)
+
+	// Get timestamp proofs of all commits of a proposal, newest first
+	Timestamps([]byte) ([]Timestamp, error)
+
+	// Append comments to a vetted proposal and return the number of
+	// comments that were not stored before (token, comments)
+	AppendComments([]byte, []Comment) (uint, error)
+
+	// Replace a comment of a vetted proposal with a tombstone
+	// (token, comment id, reason)
+	CensorComment([]byte, uint64, string) error
+
+	// Get comments of a vetted proposal, oldest first
+	GetComments([]byte) ([]Comment, error)
 
-	// Inventory retrieves various proposal records.
-	Inventory(uint, uint, bool) ([]ProposalRecord, []ProposalRecord, error)
+	// Inventory retrieves a page of vetted and a page of unvetted proposal
+	// records (vetted, branches, includeFiles).
+	Inventory(InventoryRequest, InventoryRequest, bool) ([]ProposalRecord, []ProposalRecord, error)
 
 	// Close performs cleanup of the backend.
 	Close()
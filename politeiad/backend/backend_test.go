@@ -0,0 +1,58 @@
+package backend
+
+import (
+	"testing"
+)
+
+func TestInventoryPage(t *testing.T) {
+	psrs := []ProposalStorageRecord{
+		{Token: []byte{0x01}, Timestamp: 10},
+		{Token: []byte{0x02}, Timestamp: 30},
+		{Token: []byte{0x03}, Timestamp: 20},
+		{Token: []byte{0x04}, Timestamp: 30},
+		{Token: []byte{0x05}, Timestamp: 40, Status: PSRStatusArchived},
+	}
+
+	tokens := func(page []ProposalStorageRecord) []byte {
+		t := make([]byte, 0, len(page))
+		for _, v := range page {
+			t = append(t, v.Token[0])
+		}
+		return t
+	}
+
+	tests := []struct {
+		name string
+		ir   InventoryRequest
+		want []byte
+	}{
+		{"all", InventoryRequest{}, []byte{5, 2, 4, 3, 1}},
+		{"count", InventoryRequest{Count: 2}, []byte{5, 2}},
+		{"since", InventoryRequest{Since: 20}, []byte{5, 2, 4}},
+		{"cursor", InventoryRequest{
+			Count:  2,
+			Cursor: &InventoryCursor{Timestamp: 30, Token: []byte{0x02}},
+		}, []byte{4, 3}},
+		{"cursor since", InventoryRequest{
+			Since:  10,
+			Cursor: &InventoryCursor{Timestamp: 30, Token: []byte{0x04}},
+		}, []byte{3}},
+		{"status", InventoryRequest{
+			Status: []PSRStatusT{PSRStatusArchived},
+		}, []byte{5}},
+		{"status count", InventoryRequest{
+			Count:  2,
+			Status: []PSRStatusT{PSRStatusInvalid},
+		}, []byte{2, 4}},
+		{"exhausted", InventoryRequest{
+			Cursor: &InventoryCursor{Timestamp: 10, Token: []byte{0x01}},
+		}, []byte{}},
+	}
+
+	for _, test := range tests {
+		got := tokens(InventoryPage(psrs, test.ir))
+		if string(got) != string(test.want) {
+			t.Fatalf("%v: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
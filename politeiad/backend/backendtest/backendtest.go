@@ -0,0 +1,1039 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package backendtest provides a conformance test suite that every
+// backend.Backend implementation is expected to pass.  Implementations run it
+// from their own tests:
+//
+//	func TestConformance(t *testing.T) {
+//		backendtest.Run(t, func(t *testing.T) (backend.Backend, func()) {
+//			...
+//		})
+//	}
+package backendtest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/decred/politeia/politeiad/api/v1"
+	"github.com/decred/politeia/politeiad/backend"
+	"github.com/decred/politeia/util"
+)
+
+// NewFunc returns a new and empty backend and a function that releases all
+// resources once the backend has been closed.  The suite always closes the
+// backend itself.
+type NewFunc func(t *testing.T) (backend.Backend, func())
+
+// Run runs the entire conformance suite.  Every test uses its own backend.
+func Run(t *testing.T, newBackend NewFunc) {
+	tests := []struct {
+		name string
+		test func(*testing.T, backend.Backend)
+	}{
+		{"New", testNew},
+		{"InvalidContent", testInvalidContent},
+		{"Update", testUpdate},
+		{"Transitions", testTransitions},
+		{"VettedTransitions", testVettedTransitions},
+		{"Reason", testReason},
+		{"UserSignature", testUserSignature},
+		{"NotFound", testNotFound},
+		{"Inventory", testInventory},
+		{"Comments", testComments},
+		{"CensorComment", testCensorComment},
+		{"Shutdown", testShutdown},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			b, cleanup := newBackend(t)
+			defer cleanup()
+			defer func() {
+				// testShutdown closes the backend itself.
+				if test.name != "Shutdown" {
+					b.Close()
+				}
+			}()
+			test.test(t, b)
+		})
+	}
+}
+
+// TextFiles returns count text files with random content whose names are
+// prefixed with name.  Backend tests use it to create proposals.
+func TextFiles(t *testing.T, name string, count int) []backend.File {
+	files := make([]backend.File, 0, count)
+	for j := 0; j < count; j++ {
+		r, err := util.Random(64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Create text file
+		payload := hex.EncodeToString(r)
+		digest := hex.EncodeToString(util.Digest([]byte(payload)))
+		// We expect base64 encoded content
+		b64 := base64.StdEncoding.EncodeToString([]byte(payload))
+
+		files = append(files, backend.File{
+			Name:    name + "_" + strconv.Itoa(j),
+			MIME:    http.DetectContentType([]byte(payload)),
+			Digest:  digest,
+			Payload: b64,
+		})
+	}
+	return files
+}
+
+// newProposal creates a proposal and optionally moves it to status.
+func newProposal(t *testing.T, b backend.Backend, status backend.PSRStatusT) (*backend.ProposalStorageRecord, []backend.File) {
+	files := TextFiles(t, "file", 2)
+	psr, err := b.New("proposal", files, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status == backend.PSRStatusUnvetted {
+		return psr, files
+	}
+
+	s, err := b.SetUnvettedStatus(psr.Token, status, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != status {
+		t.Fatalf("invalid status got %v wanted %v", s, status)
+	}
+	psr.Status = status
+	psr.Version++
+	return psr, files
+}
+
+// equalFiles returns true if both sets contain the same files regardless of
+// their order.
+func equalFiles(a, b []backend.File) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, fa := range a {
+		found := false
+		for _, fb := range b {
+			if fa == fb {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// expectError fails the test if err is not the expected error.
+func expectError(t *testing.T, what string, err, expected error) {
+	if err != expected {
+		t.Fatalf("%v: got error %v wanted %v", what, err, expected)
+	}
+}
+
+// expectContentError fails the test if err is not a ContentVerificationError
+// with the expected error code.
+func expectContentError(t *testing.T, what string, err error, expected v1.ErrorStatusT) {
+	e, ok := err.(backend.ContentVerificationError)
+	if !ok {
+		t.Fatalf("%v: got error %v wanted content verification error",
+			what, err)
+	}
+	if e.ErrorCode != expected {
+		t.Fatalf("%v: got error code %v wanted %v", what,
+			v1.ErrorStatus[e.ErrorCode], v1.ErrorStatus[expected])
+	}
+}
+
+func testNew(t *testing.T, b backend.Backend) {
+	files := TextFiles(t, "file", 3)
+	psr, err := b.New("new", files, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if psr.Name != "new" || psr.Version != 1 ||
+		psr.Status != backend.PSRStatusUnvetted || len(psr.Token) == 0 {
+		t.Fatalf("invalid record %+v", psr)
+	}
+
+	pr, err := b.GetUnvetted(psr.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.ProposalStorageRecord.Merkle != psr.Merkle ||
+		!bytes.Equal(pr.ProposalStorageRecord.Token, psr.Token) {
+		t.Fatalf("invalid record %+v", pr.ProposalStorageRecord)
+	}
+	if !equalFiles(pr.Files, files) {
+		t.Fatalf("unexpected files")
+	}
+
+	// Tokens are unique
+	psr2, err := b.New("new", files, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(psr.Token, psr2.Token) {
+		t.Fatalf("duplicate token %x", psr.Token)
+	}
+}
+
+func testInvalidContent(t *testing.T, b backend.Backend) {
+	// Invalid digest
+	files := TextFiles(t, "file", 2)
+	files[1].Digest = files[0].Digest
+	_, err := b.New("digest", files, nil)
+	expectContentError(t, "digest", err, v1.ErrorStatusInvalidFileDigest)
+
+	// Digest that is not a digest
+	files = TextFiles(t, "file", 2)
+	files[0].Digest = "nope"
+	_, err = b.New("not a digest", files, nil)
+	expectContentError(t, "not a digest", err,
+		v1.ErrorStatusInvalidFileDigest)
+
+	// Invalid base64
+	files = TextFiles(t, "file", 2)
+	files[0].Payload = "!"
+	_, err = b.New("base64", files, nil)
+	expectContentError(t, "base64", err, v1.ErrorStatusInvalidBase64)
+
+	// MIME type that does not match the content
+	files = TextFiles(t, "file", 2)
+	files[0].MIME = "image/png"
+	_, err = b.New("mime", files, nil)
+	expectContentError(t, "mime", err, v1.ErrorStatusInvalidMIMEType)
+
+	// Duplicate names
+	files = TextFiles(t, "file", 2)
+	files[1].Name = files[0].Name
+	_, err = b.New("duplicate", files, nil)
+	if err == nil {
+		t.Fatalf("duplicate: expected error")
+	}
+
+	// Empty proposal
+	_, err = b.New("empty", nil, nil)
+	if err == nil {
+		t.Fatalf("empty: expected error")
+	}
+
+	// Updates are verified as well
+	psr, _ := newProposal(t, b, backend.PSRStatusUnvetted)
+	files = TextFiles(t, "file", 2)
+	files[0].MIME = "image/png"
+	_, err = b.UpdateUnvettedRecord(psr.Token, files)
+	expectContentError(t, "update", err, v1.ErrorStatusInvalidMIMEType)
+
+	// Nothing was created
+	_, branches, err := b.Inventory(backend.InventoryRequest{},
+		backend.InventoryRequest{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(branches) != 1 {
+		t.Fatalf("invalid inventory got %v wanted 1", len(branches))
+	}
+}
+
+func testUpdate(t *testing.T, b backend.Backend) {
+	psr, files := newProposal(t, b, backend.PSRStatusUnvetted)
+
+	// Identical content is not an update
+	_, err := b.UpdateUnvettedRecord(psr.Token, files)
+	expectError(t, "no changes", err, backend.ErrNoChanges)
+
+	updated := TextFiles(t, "update", 1)
+	upsr, err := b.UpdateUnvettedRecord(psr.Token, updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upsr.Version != psr.Version+1 || upsr.Merkle == psr.Merkle {
+		t.Fatalf("invalid record %+v", upsr)
+	}
+	pr, err := b.GetUnvetted(psr.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalFiles(pr.Files, updated) {
+		t.Fatalf("unexpected files")
+	}
+
+	// The previous version is still available
+	pr, err = b.GetUnvettedVersion(psr.Token, psr.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalFiles(pr.Files, files) {
+		t.Fatalf("unexpected files at version %v", psr.Version)
+	}
+	pv, err := b.UnvettedVersions(psr.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pv) != 2 || pv[0].ProposalStorageRecord.Version != 2 ||
+		pv[1].ProposalStorageRecord.Version != 1 {
+		t.Fatalf("invalid history %+v", pv)
+	}
+
+	// Publish and update the vetted proposal
+	_, err = b.SetUnvettedStatus(psr.Token, backend.PSRStatusVetted, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = b.UpdateVettedRecord(psr.Token, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err = b.GetVetted(psr.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalFiles(pr.Files, files) ||
+		pr.ProposalStorageRecord.Status != backend.PSRStatusVetted ||
+		pr.ProposalStorageRecord.Version != 4 {
+		t.Fatalf("invalid vetted proposal %+v",
+			pr.ProposalStorageRecord)
+	}
+
+	// The vetted history includes the unvetted versions
+	pv, err = b.VettedVersions(psr.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pv) != 4 {
+		t.Fatalf("invalid history length got %v wanted 4", len(pv))
+	}
+	for i, v := range pv {
+		if v.ProposalStorageRecord.Version != uint(len(pv)-i) {
+			t.Fatalf("invalid history %+v", pv)
+		}
+	}
+
+	// There is a timestamp for every version
+	ts, err := b.Timestamps(psr.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ts) != len(pv) {
+		t.Fatalf("invalid timestamps length got %v wanted %v", len(ts),
+			len(pv))
+	}
+	for i, v := range ts {
+		if v.Version != pv[i].ProposalStorageRecord.Version ||
+			v.State == backend.AnchorStateInvalid {
+			t.Fatalf("invalid timestamp %+v", v)
+		}
+	}
+}
+
+func testTransitions(t *testing.T, b backend.Backend) {
+	tests := []struct {
+		from   backend.PSRStatusT
+		to     backend.PSRStatusT
+		status backend.PSRStatusT // Status returned by SetUnvettedStatus
+		err    error
+	}{
+		// Allowed
+		{backend.PSRStatusUnvetted, backend.PSRStatusVetted,
+			backend.PSRStatusVetted, nil},
+		{backend.PSRStatusUnvetted, backend.PSRStatusCensored,
+			backend.PSRStatusCensored, nil},
+		{backend.PSRStatusUnvetted, backend.PSRStatusWithdrawn,
+			backend.PSRStatusWithdrawn, nil},
+
+		// Forbidden
+		{backend.PSRStatusUnvetted, backend.PSRStatusUnvetted,
+			backend.PSRStatusUnvetted, backend.ErrInvalidTransition},
+		{backend.PSRStatusUnvetted, backend.PSRStatusInvalid,
+			backend.PSRStatusUnvetted, backend.ErrInvalidTransition},
+		{backend.PSRStatusCensored, backend.PSRStatusVetted,
+			backend.PSRStatusCensored, backend.ErrInvalidTransition},
+		{backend.PSRStatusCensored, backend.PSRStatusCensored,
+			backend.PSRStatusCensored, backend.ErrInvalidTransition},
+		{backend.PSRStatusCensored, backend.PSRStatusUnvetted,
+			backend.PSRStatusCensored, backend.ErrInvalidTransition},
+		{backend.PSRStatusUnvetted, backend.PSRStatusArchived,
+			backend.PSRStatusUnvetted, backend.ErrInvalidTransition},
+		{backend.PSRStatusWithdrawn, backend.PSRStatusVetted,
+			backend.PSRStatusWithdrawn, backend.ErrInvalidTransition},
+
+		// Vetted proposals are no longer unvetted
+		{backend.PSRStatusVetted, backend.PSRStatusCensored,
+			backend.PSRStatusInvalid, backend.ErrProposalNotFound},
+		{backend.PSRStatusVetted, backend.PSRStatusUnvetted,
+			backend.PSRStatusInvalid, backend.ErrProposalNotFound},
+	}
+
+	for _, test := range tests {
+		psr, _ := newProposal(t, b, test.from)
+		status, err := b.SetUnvettedStatus(psr.Token, test.to, "")
+		what := backend.PSRStatus[test.from] + " -> " +
+			backend.PSRStatus[test.to]
+		expectError(t, what, err, test.err)
+		if status != test.status {
+			t.Fatalf("%v: invalid status got %v wanted %v", what,
+				status, test.status)
+		}
+
+		// Verify the proposal ended up where it should be
+		want := test.from
+		if test.err == nil {
+			want = test.to
+		}
+		var pr *backend.ProposalRecord
+		if want == backend.PSRStatusVetted {
+			pr, err = b.GetVetted(psr.Token)
+		} else {
+			pr, err = b.GetUnvetted(psr.Token)
+		}
+		if err != nil {
+			t.Fatalf("%v: %v", what, err)
+		}
+		if pr.ProposalStorageRecord.Status != want {
+			t.Fatalf("%v: invalid status got %v wanted %v", what,
+				pr.ProposalStorageRecord.Status, want)
+		}
+	}
+
+	// Censored proposals can not be updated
+	psr, _ := newProposal(t, b, backend.PSRStatusCensored)
+	_, err := b.UpdateUnvettedRecord(psr.Token,
+		TextFiles(t, "file", 1))
+	expectError(t, "update censored", err, backend.ErrInvalidTransition)
+
+	// Neither can withdrawn proposals
+	psr, _ = newProposal(t, b, backend.PSRStatusWithdrawn)
+	_, err = b.UpdateUnvettedRecord(psr.Token,
+		TextFiles(t, "file", 1))
+	expectError(t, "update withdrawn", err, backend.ErrInvalidTransition)
+}
+
+func testVettedTransitions(t *testing.T, b backend.Backend) {
+	tests := []struct {
+		from   backend.PSRStatusT
+		to     backend.PSRStatusT
+		status backend.PSRStatusT // Status returned by SetVettedStatus
+		err    error
+	}{
+		// Allowed
+		{backend.PSRStatusVetted, backend.PSRStatusAbandoned,
+			backend.PSRStatusAbandoned, nil},
+		{backend.PSRStatusVetted, backend.PSRStatusArchived,
+			backend.PSRStatusArchived, nil},
+
+		// Forbidden
+		{backend.PSRStatusVetted, backend.PSRStatusVetted,
+			backend.PSRStatusVetted, backend.ErrInvalidTransition},
+		{backend.PSRStatusVetted, backend.PSRStatusCensored,
+			backend.PSRStatusVetted, backend.ErrInvalidTransition},
+		{backend.PSRStatusVetted, backend.PSRStatusWithdrawn,
+			backend.PSRStatusVetted, backend.ErrInvalidTransition},
+		{backend.PSRStatusAbandoned, backend.PSRStatusArchived,
+			backend.PSRStatusAbandoned, backend.ErrInvalidTransition},
+		{backend.PSRStatusArchived, backend.PSRStatusVetted,
+			backend.PSRStatusArchived, backend.ErrInvalidTransition},
+
+		// Unvetted proposals are not vetted
+		{backend.PSRStatusUnvetted, backend.PSRStatusArchived,
+			backend.PSRStatusInvalid, backend.ErrProposalNotFound},
+		{backend.PSRStatusCensored, backend.PSRStatusAbandoned,
+			backend.PSRStatusInvalid, backend.ErrProposalNotFound},
+	}
+
+	for _, test := range tests {
+		// Terminal vetted states are reached through a vetted proposal
+		from := test.from
+		if from == backend.PSRStatusAbandoned ||
+			from == backend.PSRStatusArchived {
+			from = backend.PSRStatusVetted
+		}
+		psr, _ := newProposal(t, b, from)
+		if from != test.from {
+			_, err := b.SetVettedStatus(psr.Token, test.from, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		status, err := b.SetVettedStatus(psr.Token, test.to, "reason")
+		what := backend.PSRStatus[test.from] + " -> " +
+			backend.PSRStatus[test.to]
+		expectError(t, what, err, test.err)
+		if status != test.status {
+			t.Fatalf("%v: invalid status got %v wanted %v", what,
+				status, test.status)
+		}
+
+		// Verify the proposal ended up where it should be
+		want := test.from
+		if test.err == nil {
+			want = test.to
+		}
+		var pr *backend.ProposalRecord
+		if backend.StatusVetted(want) {
+			pr, err = b.GetVetted(psr.Token)
+		} else {
+			pr, err = b.GetUnvetted(psr.Token)
+		}
+		if err != nil {
+			t.Fatalf("%v: %v", what, err)
+		}
+		if pr.ProposalStorageRecord.Status != want {
+			t.Fatalf("%v: invalid status got %v wanted %v", what,
+				pr.ProposalStorageRecord.Status, want)
+		}
+		if test.err == nil && pr.ProposalStorageRecord.Reason != "reason" {
+			t.Fatalf("%v: invalid reason got %q", what,
+				pr.ProposalStorageRecord.Reason)
+		}
+	}
+
+	// Abandoned and archived proposals can not be updated
+	psr, _ := newProposal(t, b, backend.PSRStatusVetted)
+	_, err := b.SetVettedStatus(psr.Token, backend.PSRStatusArchived, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = b.UpdateVettedRecord(psr.Token, TextFiles(t, "file", 1))
+	expectError(t, "update archived", err, backend.ErrInvalidTransition)
+}
+
+func testReason(t *testing.T, b backend.Backend) {
+	// The reason is returned with the proposal and survives updates.
+	files := TextFiles(t, "file", 1)
+	psr, err := b.New("published", files, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = b.SetUnvettedStatus(psr.Token, backend.PSRStatusVetted,
+		"looks good")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = b.UpdateVettedRecord(psr.Token, TextFiles(t, "file", 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err := b.GetVetted(psr.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.ProposalStorageRecord.Reason != "looks good" {
+		t.Fatalf("invalid reason got %q", pr.ProposalStorageRecord.Reason)
+	}
+
+	psr, err = b.New("censored", files, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if psr.Reason != "" {
+		t.Fatalf("unexpected reason %q", psr.Reason)
+	}
+	_, err = b.SetUnvettedStatus(psr.Token, backend.PSRStatusCensored,
+		"spam\nmultiple lines")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err = b.GetUnvetted(psr.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.ProposalStorageRecord.Reason != "spam\nmultiple lines" {
+		t.Fatalf("invalid reason got %q", pr.ProposalStorageRecord.Reason)
+	}
+	pv, err := b.UnvettedVersions(psr.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pv[0].ProposalStorageRecord.Reason != "spam\nmultiple lines" ||
+		pv[1].ProposalStorageRecord.Reason != "" {
+		t.Fatalf("invalid reason history %+v", pv)
+	}
+}
+
+func testUserSignature(t *testing.T, b backend.Backend) {
+	files := TextFiles(t, "file", 2)
+	us := backend.UserSignature{
+		PublicKey: []byte{0x01, 0x02},
+		Merkle:    backend.MerkleRoot([][]byte{[]byte("payload")}),
+		Signature: []byte{0x03, 0x04},
+	}
+	psr, err := b.New("signed", files, &us)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The signature survives updates and publishing.
+	_, err = b.UpdateUnvettedRecord(psr.Token, TextFiles(t, "file", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(what string, got *backend.UserSignature) {
+		if got == nil || !bytes.Equal(got.PublicKey, us.PublicKey) ||
+			got.Merkle != us.Merkle ||
+			!bytes.Equal(got.Signature, us.Signature) {
+			t.Fatalf("%v: invalid user signature %+v", what, got)
+		}
+	}
+	pr, err := b.GetUnvetted(psr.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("GetUnvetted", pr.UserSignature)
+	_, err = b.SetUnvettedStatus(psr.Token, backend.PSRStatusVetted, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err = b.GetVetted(psr.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("GetVetted", pr.UserSignature)
+	pr, err = b.GetVettedVersion(psr.Token, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("GetVettedVersion", pr.UserSignature)
+
+	// Unsigned proposals have no signature.
+	unsigned, _ := newProposal(t, b, backend.PSRStatusUnvetted)
+	pr, err = b.GetUnvetted(unsigned.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.UserSignature != nil {
+		t.Fatalf("unexpected user signature %+v", pr.UserSignature)
+	}
+
+	vetted, branches, err := b.Inventory(backend.InventoryRequest{},
+		backend.InventoryRequest{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vetted) != 1 || len(branches) != 1 {
+		t.Fatalf("invalid inventory vetted %v branches %v",
+			len(vetted), len(branches))
+	}
+	check("Inventory", vetted[0].UserSignature)
+	if branches[0].UserSignature != nil {
+		t.Fatalf("unexpected user signature %+v",
+			branches[0].UserSignature)
+	}
+}
+
+func testNotFound(t *testing.T, b backend.Backend) {
+	token, err := util.Random(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := TextFiles(t, "file", 1)
+
+	_, err = b.GetUnvetted(token)
+	expectError(t, "GetUnvetted", err, backend.ErrProposalNotFound)
+	_, err = b.GetVetted(token)
+	expectError(t, "GetVetted", err, backend.ErrProposalNotFound)
+	_, err = b.GetUnvettedVersion(token, 1)
+	expectError(t, "GetUnvettedVersion", err, backend.ErrProposalNotFound)
+	_, err = b.GetVettedVersion(token, 1)
+	expectError(t, "GetVettedVersion", err, backend.ErrProposalNotFound)
+	_, err = b.UnvettedVersions(token)
+	expectError(t, "UnvettedVersions", err, backend.ErrProposalNotFound)
+	_, err = b.VettedVersions(token)
+	expectError(t, "VettedVersions", err, backend.ErrProposalNotFound)
+	_, err = b.UpdateUnvettedRecord(token, files)
+	expectError(t, "UpdateUnvettedRecord", err,
+		backend.ErrProposalNotFound)
+	_, err = b.UpdateVettedRecord(token, files)
+	expectError(t, "UpdateVettedRecord", err, backend.ErrProposalNotFound)
+	_, err = b.SetUnvettedStatus(token, backend.PSRStatusVetted, "")
+	expectError(t, "SetUnvettedStatus", err, backend.ErrProposalNotFound)
+	_, err = b.SetVettedStatus(token, backend.PSRStatusArchived, "")
+	expectError(t, "SetVettedStatus", err, backend.ErrProposalNotFound)
+	_, err = b.Timestamps(token)
+	expectError(t, "Timestamps", err, backend.ErrProposalNotFound)
+
+	// Proposals are only visible in their own state
+	unvetted, _ := newProposal(t, b, backend.PSRStatusUnvetted)
+	_, err = b.GetVetted(unvetted.Token)
+	expectError(t, "GetVetted unvetted", err, backend.ErrProposalNotFound)
+	_, err = b.UpdateVettedRecord(unvetted.Token, files)
+	expectError(t, "UpdateVettedRecord unvetted", err,
+		backend.ErrProposalNotFound)
+	vetted, _ := newProposal(t, b, backend.PSRStatusVetted)
+	_, err = b.GetUnvetted(vetted.Token)
+	expectError(t, "GetUnvetted vetted", err, backend.ErrProposalNotFound)
+	_, err = b.UpdateUnvettedRecord(vetted.Token, files)
+	expectError(t, "UpdateUnvettedRecord vetted", err,
+		backend.ErrProposalNotFound)
+
+	// Versions that do not exist
+	_, err = b.GetUnvettedVersion(unvetted.Token, 2)
+	expectError(t, "GetUnvettedVersion 2", err,
+		backend.ErrProposalNotFound)
+	_, err = b.GetVettedVersion(vetted.Token, 3)
+	expectError(t, "GetVettedVersion 3", err, backend.ErrProposalNotFound)
+}
+
+func testInventory(t *testing.T, b backend.Backend) {
+	vetted, branches, err := b.Inventory(backend.InventoryRequest{},
+		backend.InventoryRequest{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vetted) != 0 || len(branches) != 0 {
+		t.Fatalf("inventory not empty")
+	}
+
+	// Censored proposals remain in the unvetted inventory
+	want := make(map[string]backend.PSRStatusT)
+	files := make(map[string][]backend.File)
+	tokens := make(map[backend.PSRStatusT][][]byte)
+	for _, status := range []backend.PSRStatusT{
+		backend.PSRStatusUnvetted,
+		backend.PSRStatusUnvetted,
+		backend.PSRStatusCensored,
+		backend.PSRStatusVetted,
+		backend.PSRStatusVetted,
+		backend.PSRStatusVetted,
+	} {
+		psr, f := newProposal(t, b, status)
+		want[hex.EncodeToString(psr.Token)] = status
+		files[hex.EncodeToString(psr.Token)] = f
+		tokens[status] = append(tokens[status], psr.Token)
+	}
+
+	for _, includeFiles := range []bool{false, true} {
+		vetted, branches, err := b.Inventory(backend.InventoryRequest{},
+			backend.InventoryRequest{}, includeFiles)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(vetted) != 3 || len(branches) != 3 {
+			t.Fatalf("invalid inventory vetted %v branches %v",
+				len(vetted), len(branches))
+		}
+		for _, pr := range append(vetted, branches...) {
+			id := hex.EncodeToString(pr.ProposalStorageRecord.Token)
+			if pr.ProposalStorageRecord.Status != want[id] {
+				t.Fatalf("invalid status %v: got %v wanted %v",
+					id, pr.ProposalStorageRecord.Status,
+					want[id])
+			}
+			if !includeFiles && len(pr.Files) != 0 {
+				t.Fatalf("unexpected files %v", id)
+			}
+			if includeFiles && !equalFiles(pr.Files, files[id]) {
+				t.Fatalf("unexpected files %v", id)
+			}
+		}
+		for _, pr := range vetted {
+			if pr.ProposalStorageRecord.Status !=
+				backend.PSRStatusVetted {
+				t.Fatalf("unvetted proposal in vetted inventory")
+			}
+		}
+	}
+
+	// Pages
+	vetted, branches, err = b.Inventory(backend.InventoryRequest{
+		Count: 2,
+	}, backend.InventoryRequest{
+		Count: 1,
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vetted) != 2 || len(branches) != 1 {
+		t.Fatalf("invalid page vetted %v branches %v", len(vetted),
+			len(branches))
+	}
+
+	// Counts return the most recently updated records, newest first.
+	// Timestamps have a resolution of a second so the updates are a
+	// second apart.
+	for _, i := range []int{1, 0} {
+		time.Sleep(time.Second)
+		_, err = b.UpdateVettedRecord(tokens[backend.PSRStatusVetted][i],
+			TextFiles(t, "newest", 1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = b.UpdateUnvettedRecord(
+			tokens[backend.PSRStatusUnvetted][i],
+			TextFiles(t, "newest", 1))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	vetted, branches, err = b.Inventory(backend.InventoryRequest{
+		Count: 2,
+	}, backend.InventoryRequest{
+		Count: 2,
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, page := range []struct {
+		records []backend.ProposalRecord
+		tokens  [][]byte
+	}{
+		{vetted, tokens[backend.PSRStatusVetted]},
+		{branches, tokens[backend.PSRStatusUnvetted]},
+	} {
+		if len(page.records) != 2 ||
+			!bytes.Equal(page.records[0].ProposalStorageRecord.Token,
+				page.tokens[0]) ||
+			!bytes.Equal(page.records[1].ProposalStorageRecord.Token,
+				page.tokens[1]) {
+			t.Fatalf("invalid newest records %v", page.records)
+		}
+	}
+
+	// Status filter
+	vetted, branches, err = b.Inventory(backend.InventoryRequest{
+		Status: []backend.PSRStatusT{backend.PSRStatusArchived},
+	}, backend.InventoryRequest{
+		Status: []backend.PSRStatusT{backend.PSRStatusCensored},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vetted) != 0 || len(branches) != 1 ||
+		branches[0].ProposalStorageRecord.Status !=
+			backend.PSRStatusCensored {
+		t.Fatalf("invalid filter vetted %v branches %v", len(vetted),
+			len(branches))
+	}
+}
+
+// expectComments fails the test if the comments of token are not expected.
+func expectComments(t *testing.T, b backend.Backend, token []byte, expected []backend.Comment) {
+	comments, err := b.GetComments(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != len(expected) {
+		t.Fatalf("invalid comments got %v wanted %v", comments,
+			expected)
+	}
+	for i := range comments {
+		if comments[i] != expected[i] {
+			t.Fatalf("invalid comment %v got %v wanted %v", i,
+				comments[i], expected[i])
+		}
+	}
+}
+
+func testComments(t *testing.T, b backend.Backend) {
+	comments := make([]backend.Comment, 0, 3)
+	for i := uint64(1); i <= 3; i++ {
+		comments = append(comments, backend.Comment{
+			CommentID: i,
+			UserID:    10 + i,
+			ParentID:  i - 1,
+			Timestamp: int64(100 + i),
+			Comment:   "comment " + strconv.Itoa(int(i)),
+		})
+	}
+
+	// Only vetted proposals have comments
+	for _, status := range []backend.PSRStatusT{
+		backend.PSRStatusUnvetted,
+		backend.PSRStatusCensored,
+	} {
+		psr, _ := newProposal(t, b, status)
+		_, err := b.AppendComments(psr.Token, comments)
+		expectError(t, "append "+backend.PSRStatus[status], err,
+			backend.ErrProposalNotFound)
+		_, err = b.GetComments(psr.Token)
+		expectError(t, "get "+backend.PSRStatus[status], err,
+			backend.ErrProposalNotFound)
+	}
+
+	psr, _ := newProposal(t, b, backend.PSRStatusVetted)
+	expectComments(t, b, psr.Token, nil)
+	pv, err := b.VettedVersions(psr.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Comments that were appended before are skipped
+	appended, err := b.AppendComments(psr.Token, comments[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if appended != 2 {
+		t.Fatalf("invalid appended got %v wanted 2", appended)
+	}
+	appended, err = b.AppendComments(psr.Token, comments[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if appended != 1 {
+		t.Fatalf("invalid appended got %v wanted 1", appended)
+	}
+	appended, err = b.AppendComments(psr.Token, comments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if appended != 0 {
+		t.Fatalf("invalid appended got %v wanted 0", appended)
+	}
+	expectComments(t, b, psr.Token, comments)
+
+	// Invalid comments are rejected as a whole
+	_, err = b.AppendComments(psr.Token, []backend.Comment{{
+		CommentID: 4,
+		Comment:   "valid",
+	}, {
+		CommentID: 0,
+		Comment:   "no id",
+	}})
+	expectContentError(t, "comment id", err, v1.ErrorStatusInvalidComment)
+	_, err = b.AppendComments(psr.Token, []backend.Comment{{
+		CommentID: 4,
+	}})
+	expectContentError(t, "empty comment", err,
+		v1.ErrorStatusInvalidComment)
+	expectComments(t, b, psr.Token, comments)
+
+	// Comments do not create proposal versions
+	pv2, err := b.VettedVersions(psr.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pv2) != len(pv) {
+		t.Fatalf("invalid versions got %v wanted %v", len(pv2), len(pv))
+	}
+
+	// Comments survive updates and terminal states
+	_, err = b.UpdateVettedRecord(psr.Token, TextFiles(t, "file", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = b.SetVettedStatus(psr.Token, backend.PSRStatusArchived, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	extra := backend.Comment{
+		CommentID: 4,
+		Comment:   "archived",
+	}
+	_, err = b.AppendComments(psr.Token, []backend.Comment{extra})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectComments(t, b, psr.Token, append(comments, extra))
+}
+
+func testCensorComment(t *testing.T, b backend.Backend) {
+	psr, _ := newProposal(t, b, backend.PSRStatusUnvetted)
+	err := b.CensorComment(psr.Token, 1, "")
+	expectError(t, "unvetted", err, backend.ErrProposalNotFound)
+
+	psr, _ = newProposal(t, b, backend.PSRStatusVetted)
+	comments := []backend.Comment{{
+		CommentID: 1,
+		UserID:    1,
+		Comment:   "abusive",
+	}, {
+		CommentID: 2,
+		ParentID:  1,
+		Comment:   "reply",
+	}}
+	_, err = b.AppendComments(psr.Token, comments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = b.CensorComment(psr.Token, 3, "")
+	expectError(t, "missing comment", err, backend.ErrCommentNotFound)
+
+	// Only the text of the censored comment is gone
+	err = b.CensorComment(psr.Token, 1, "abuse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tombstone := backend.Comment{
+		CommentID: 1,
+		UserID:    1,
+		Censored:  true,
+		Reason:    "abuse",
+	}
+	expectComments(t, b, psr.Token, []backend.Comment{tombstone,
+		comments[1]})
+
+	// Censoring again or appending the comment again changes nothing
+	err = b.CensorComment(psr.Token, 1, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	appended, err := b.AppendComments(psr.Token, comments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if appended != 0 {
+		t.Fatalf("invalid appended got %v wanted 0", appended)
+	}
+	expectComments(t, b, psr.Token, []backend.Comment{tombstone,
+		comments[1]})
+}
+
+func testShutdown(t *testing.T, b backend.Backend) {
+	psr, files := newProposal(t, b, backend.PSRStatusUnvetted)
+	b.Close()
+
+	_, err := b.New("shutdown", files, nil)
+	expectError(t, "New", err, backend.ErrShutdown)
+	_, err = b.GetUnvetted(psr.Token)
+	expectError(t, "GetUnvetted", err, backend.ErrShutdown)
+	_, err = b.GetVetted(psr.Token)
+	expectError(t, "GetVetted", err, backend.ErrShutdown)
+	_, err = b.GetUnvettedVersion(psr.Token, 1)
+	expectError(t, "GetUnvettedVersion", err, backend.ErrShutdown)
+	_, err = b.GetVettedVersion(psr.Token, 1)
+	expectError(t, "GetVettedVersion", err, backend.ErrShutdown)
+	_, err = b.UnvettedVersions(psr.Token)
+	expectError(t, "UnvettedVersions", err, backend.ErrShutdown)
+	_, err = b.VettedVersions(psr.Token)
+	expectError(t, "VettedVersions", err, backend.ErrShutdown)
+	_, err = b.UpdateUnvettedRecord(psr.Token,
+		TextFiles(t, "file", 1))
+	expectError(t, "UpdateUnvettedRecord", err, backend.ErrShutdown)
+	_, err = b.UpdateVettedRecord(psr.Token,
+		TextFiles(t, "file", 1))
+	expectError(t, "UpdateVettedRecord", err, backend.ErrShutdown)
+	_, err = b.SetUnvettedStatus(psr.Token, backend.PSRStatusCensored, "")
+	expectError(t, "SetUnvettedStatus censored", err, backend.ErrShutdown)
+	_, err = b.SetUnvettedStatus(psr.Token, backend.PSRStatusVetted, "")
+	expectError(t, "SetUnvettedStatus vetted", err, backend.ErrShutdown)
+	_, err = b.SetVettedStatus(psr.Token, backend.PSRStatusArchived, "")
+	expectError(t, "SetVettedStatus", err, backend.ErrShutdown)
+	_, err = b.Timestamps(psr.Token)
+	expectError(t, "Timestamps", err, backend.ErrShutdown)
+	_, _, err = b.Inventory(backend.InventoryRequest{},
+		backend.InventoryRequest{}, false)
+	expectError(t, "Inventory", err, backend.ErrShutdown)
+	_, err = b.AppendComments(psr.Token, []backend.Comment{{
+		CommentID: 1,
+		Comment:   "shutdown",
+	}})
+	expectError(t, "AppendComments", err, backend.ErrShutdown)
+	err = b.CensorComment(psr.Token, 1, "")
+	expectError(t, "CensorComment", err, backend.ErrShutdown)
+	_, err = b.GetComments(psr.Token)
+	expectError(t, "GetComments", err, backend.ErrShutdown)
+}
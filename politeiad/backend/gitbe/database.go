@@ -6,18 +6,25 @@ package gitbe
 
 import (
 	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"time"
 
 	"github.com/decred/dcrtime/merkle"
+	"github.com/decred/politeia/politeiad/backend"
 	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
-// The database contains 3 types of records:
+// The database contains 7 types of records:
 //	[lastanchor][LastAnchor]
 //	[Merkle Root][Anchor]
 //	[unconfirmed][UnconfirmedAnchor]
+//	[inflight<token>][InFlight]
+//	[unvetted<Merkle Root>][Anchor]
+//	[lastunvetted][LastUnvettedAnchor]
+//	[unconfirmedunvetted][UnconfirmedAnchor]
 //
 // The LastAnchor record is used to persist the last committed anchor.  The
 // information that is contained in the record allows us to create a git log
@@ -34,6 +41,18 @@ import (
 // identify what anchors have not been confirmed by dcrtime and to resume
 // waiting for their confirmation.  Once an anchor is confirmed it should be
 // removed from this list; this operation SHALL be atomic.
+//
+// The in flight records describe proposal status transitions that have
+// started but not yet completed.  A record is written before the git repos
+// are touched and removed once the transition either completed or was rolled
+// back.  Any record that exists at startup therefore belongs to a transition
+// that was interrupted and must be recovered before serving requests.
+//
+// The unvetted records are the unvetted repo counterparts of the anchor, last
+// anchor and unconfirmed anchor records.  They anchor the commits on the
+// unvetted proposal branches, including censored proposals, and are kept
+// separate from the vetted records.  Instead of a single digest the
+// LastUnvettedAnchor record holds the head of every branch that was anchored.
 
 const (
 	DbVersion  uint32 = 1
@@ -88,8 +107,10 @@ type Anchor struct {
 	Time     int64      // OS time when record was created
 
 	// dcrtime portion, only valid when Type == AnchorVerified
-	ChainTimestamp int64  // Time anchor was confirmed on blockchain
-	Transaction    string // Anchor transaction
+	ChainTimestamp  int64         // Time anchor was confirmed on blockchain
+	Transaction     string        // Anchor transaction
+	ChainMerkleRoot string        // Merkle root stored in Transaction
+	ChainMerklePath merkle.Branch // Inclusion proof of key in ChainMerkleRoot
 }
 
 // newAnchorRecord creates an Anchor Record and the Merkle Root from the
@@ -182,6 +203,30 @@ func (g *gitBackEnd) readAnchorRecord(key [sha256.Size]byte) (*Anchor, error) {
 	return DecodeAnchor(payload)
 }
 
+// readAnchorRecords retrieves all anchor records indexed by merkle root.
+// Anchor records are the only records with a key of the size of a digest.
+//
+// This function must be called with the lock or the read lock held.
+func (g *gitBackEnd) readAnchorRecords() (map[[sha256.Size]byte]*Anchor, error) {
+	anchors := make(map[[sha256.Size]byte]*Anchor)
+	i := g.db.NewIterator(nil, nil)
+	defer i.Release()
+	for i.Next() {
+		if len(i.Key()) != sha256.Size {
+			continue
+		}
+		anchor, err := DecodeAnchor(i.Value())
+		if err != nil {
+			return nil, err
+		}
+		var key [sha256.Size]byte
+		copy(key[:], i.Key())
+		anchors[key] = anchor
+	}
+
+	return anchors, i.Error()
+}
+
 const (
 	LastAnchorKey = "lastanchor" // Key to identify LastAnchor
 )
@@ -342,3 +387,251 @@ func (g *gitBackEnd) readUnconfirmedAnchorRecord() (*UnconfirmedAnchor, error) {
 	// Decode
 	return DecodeUnconfirmedAnchor(payload)
 }
+
+const (
+	InFlightKeyPrefix = "inflight"
+)
+
+// InFlight records the state of the git repos right before a proposal status
+// transition started.  It contains everything needed to either finish or roll
+// back the transition after a crash.
+type InFlight struct {
+	Token          []byte             // Proposal token
+	Status         backend.PSRStatusT // Requested status
+	Branch         string             // Head of branch token in unvetted
+	UnvettedMaster string             // Head of master in unvetted
+	VettedMaster   string             // Head of master in vetted
+	Time           int64              // OS time when record was created
+}
+
+// inFlightKey returns the database key of the InFlight record for token.
+func inFlightKey(token []byte) []byte {
+	return []byte(InFlightKeyPrefix + hex.EncodeToString(token))
+}
+
+// encodeInFlight encodes an InFlight record into a JSON byte slice.
+func encodeInFlight(inFlight InFlight) ([]byte, error) {
+	b, err := json.Marshal(inFlight)
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// DecodeInFlight decodes a JSON byte slice into an InFlight record.
+func DecodeInFlight(payload []byte) (*InFlight, error) {
+	var inFlight InFlight
+
+	err := json.Unmarshal(payload, &inFlight)
+	if err != nil {
+		return nil, err
+	}
+
+	return &inFlight, nil
+}
+
+// writeInFlightRecord encodes and writes the supplied record to the database.
+//
+// This function must be called with the lock held.
+func (g *gitBackEnd) writeInFlightRecord(inFlight InFlight) error {
+	// Encode
+	ifr, err := encodeInFlight(inFlight)
+	if err != nil {
+		return err
+	}
+
+	// Use a batch for now
+	batch := new(leveldb.Batch)
+	batch.Put(inFlightKey(inFlight.Token), ifr)
+
+	return g.db.Write(batch, nil)
+}
+
+// deleteInFlightRecord removes the InFlight record for token.
+//
+// This function must be called with the lock held.
+func (g *gitBackEnd) deleteInFlightRecord(token []byte) error {
+	return g.db.Delete(inFlightKey(token), nil)
+}
+
+// readInFlightRecord retrieves the InFlight record for token.  It returns nil
+// if there is none.
+//
+// This function must be called with the lock held.
+func (g *gitBackEnd) readInFlightRecord(token []byte) (*InFlight, error) {
+	payload, err := g.db.Get(inFlightKey(token), nil)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return DecodeInFlight(payload)
+}
+
+// readInFlightRecords retrieves all InFlight records.
+//
+// This function must be called with the lock held.
+func (g *gitBackEnd) readInFlightRecords() ([]InFlight, error) {
+	iter := g.db.NewIterator(util.BytesPrefix([]byte(InFlightKeyPrefix)),
+		nil)
+	defer iter.Release()
+
+	records := make([]InFlight, 0)
+	for iter.Next() {
+		inFlight, err := DecodeInFlight(iter.Value())
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, *inFlight)
+	}
+
+	return records, iter.Error()
+}
+
+const (
+	UnvettedAnchorKeyPrefix = "unvetted"            // Unvetted Anchor prefix
+	LastUnvettedAnchorKey   = "lastunvetted"        // LastUnvettedAnchor key
+	UnconfirmedUnvettedKey  = "unconfirmedunvetted" // Unconfirmed unvetted
+)
+
+// LastUnvettedAnchor record.
+type LastUnvettedAnchor struct {
+	Heads  map[string][]byte // [id]Branch head that was anchored
+	Time   int64             // OS time when record was created
+	Merkle []byte            // Merkle root that points to Anchor record
+}
+
+// unvettedAnchorKey returns the database key of the unvetted anchor record
+// with the provided merkle root.
+func unvettedAnchorKey(key [sha256.Size]byte) []byte {
+	return append([]byte(UnvettedAnchorKeyPrefix), key[:]...)
+}
+
+// writeUnvettedAnchorRecord encodes and writes the supplied unvetted anchor
+// record to the database.
+//
+// This function must be called with the lock held.
+func (g *gitBackEnd) writeUnvettedAnchorRecord(key [sha256.Size]byte, anchor Anchor) error {
+	a, err := encodeAnchor(anchor)
+	if err != nil {
+		return err
+	}
+
+	return g.db.Put(unvettedAnchorKey(key), a, nil)
+}
+
+// readUnvettedAnchorRecord retrieves the unvetted anchor record based on the
+// provided merkle root.
+//
+// This function must be called with the lock held.
+func (g *gitBackEnd) readUnvettedAnchorRecord(key [sha256.Size]byte) (*Anchor, error) {
+	payload, err := g.db.Get(unvettedAnchorKey(key), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodeAnchor(payload)
+}
+
+// readUnvettedAnchorRecords retrieves all unvetted anchor records indexed by
+// merkle root.
+//
+// This function must be called with the lock or the read lock held.
+func (g *gitBackEnd) readUnvettedAnchorRecords() (map[[sha256.Size]byte]*Anchor, error) {
+	anchors := make(map[[sha256.Size]byte]*Anchor)
+	i := g.db.NewIterator(util.BytesPrefix([]byte(UnvettedAnchorKeyPrefix)),
+		nil)
+	defer i.Release()
+	for i.Next() {
+		anchor, err := DecodeAnchor(i.Value())
+		if err != nil {
+			return nil, err
+		}
+		var key [sha256.Size]byte
+		copy(key[:], i.Key()[len(UnvettedAnchorKeyPrefix):])
+		anchors[key] = anchor
+	}
+
+	return anchors, i.Error()
+}
+
+// encodeLastUnvettedAnchor encodes LastUnvettedAnchor into a byte slice.
+func encodeLastUnvettedAnchor(lastAnchor LastUnvettedAnchor) ([]byte, error) {
+	b, err := json.Marshal(lastAnchor)
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// DecodeLastUnvettedAnchor decodes a payload into a LastUnvettedAnchor.
+func DecodeLastUnvettedAnchor(payload []byte) (*LastUnvettedAnchor, error) {
+	var lastAnchor LastUnvettedAnchor
+
+	err := json.Unmarshal(payload, &lastAnchor)
+	if err != nil {
+		return nil, err
+	}
+
+	return &lastAnchor, nil
+}
+
+// writeLastUnvettedAnchorRecord encodes and writes the supplied record to the
+// database.
+//
+// This function must be called with the lock held.
+func (g *gitBackEnd) writeLastUnvettedAnchorRecord(lastAnchor LastUnvettedAnchor) error {
+	la, err := encodeLastUnvettedAnchor(lastAnchor)
+	if err != nil {
+		return err
+	}
+
+	return g.db.Put([]byte(LastUnvettedAnchorKey), la, nil)
+}
+
+// readLastUnvettedAnchorRecord retrieves the last unvetted anchor record.
+//
+// This function must be called with the lock held.
+func (g *gitBackEnd) readLastUnvettedAnchorRecord() (*LastUnvettedAnchor, error) {
+	payload, err := g.db.Get([]byte(LastUnvettedAnchorKey), nil)
+	if err != nil {
+		if err == leveldb.ErrNotFound {
+			return &LastUnvettedAnchor{}, nil
+		}
+		return nil, err
+	}
+
+	return DecodeLastUnvettedAnchor(payload)
+}
+
+// writeUnconfirmedUnvettedAnchorRecord encodes and writes the supplied
+// unconfirmed unvetted anchor record to the database.
+//
+// This function must be called with the lock held.
+func (g *gitBackEnd) writeUnconfirmedUnvettedAnchorRecord(unconfirmed UnconfirmedAnchor) error {
+	ua, err := encodeUnconfirmedAnchor(unconfirmed)
+	if err != nil {
+		return err
+	}
+
+	return g.db.Put([]byte(UnconfirmedUnvettedKey), ua, nil)
+}
+
+// readUnconfirmedUnvettedAnchorRecord retrieves the unconfirmed unvetted
+// anchor record.
+//
+// This function must be called with the lock held.
+func (g *gitBackEnd) readUnconfirmedUnvettedAnchorRecord() (*UnconfirmedAnchor, error) {
+	payload, err := g.db.Get([]byte(UnconfirmedUnvettedKey), nil)
+	if err != nil {
+		if err == leveldb.ErrNotFound {
+			return &UnconfirmedAnchor{}, nil
+		}
+		return nil, err
+	}
+
+	return DecodeUnconfirmedAnchor(payload)
+}
@@ -2,6 +2,7 @@ package gitbe
 
 import (
 	"bufio"
+	"bytes"
 	"crypto/sha1"
 	"encoding/hex"
 	"fmt"
@@ -9,6 +10,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 )
@@ -50,6 +52,13 @@ func (e gitError) log() {
 // git excutes the git command using the provided arguments.  If the path
 // argument is set it'll be copied to the GIT_DIR environment variable.
 func (g *gitBackEnd) git(path string, args ...string) ([]string, error) {
+	return g.gitEnv(path, nil, args...)
+}
+
+// gitEnv executes the git command using the provided arguments and
+// additional environment variables.  The environment variables are of the
+// form "key=value".
+func (g *gitBackEnd) gitEnv(path string, env []string, args ...string) ([]string, error) {
 	if len(args) == 0 {
 		return nil, fmt.Errorf("git requires arguments")
 	}
@@ -57,6 +66,7 @@ func (g *gitBackEnd) git(path string, args ...string) ([]string, error) {
 	// Setup gitError
 	ge := gitError{
 		cmd:    make([]string, 0, len(args)+1),
+		env:    env,
 		stdout: make([]string, 0, 128),
 		stderr: make([]string, 0, 128),
 	}
@@ -66,6 +76,12 @@ func (g *gitBackEnd) git(path string, args ...string) ([]string, error) {
 	if g.gitTrace {
 		defer func() { ge.log() }()
 	}
+	if g.testGitHook != nil {
+		ge.err = g.testGitHook(args)
+		if ge.err != nil {
+			return nil, ge
+		}
+	}
 
 	cmd := exec.Command(g.gitPath, args...)
 
@@ -73,6 +89,9 @@ func (g *gitBackEnd) git(path string, args ...string) ([]string, error) {
 	if path != "" {
 		cmd.Dir = path
 	}
+	if len(env) != 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
 
 	// Make sure pipes are handled before we exit
 	var wg sync.WaitGroup
@@ -150,6 +169,48 @@ func (g *gitBackEnd) git(path string, args ...string) ([]string, error) {
 	return ge.stdout, nil
 }
 
+// gitRaw executes the git command using the provided arguments and returns
+// stdout unmodified.  This is used to retrieve file blobs that may not be
+// line oriented.  If the path argument is set it'll be used as the working
+// directory.
+func (g *gitBackEnd) gitRaw(path string, args ...string) ([]byte, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("git requires arguments")
+	}
+
+	// Setup gitError
+	ge := gitError{
+		cmd: make([]string, 0, len(args)+1),
+	}
+	ge.cmd = append(ge.cmd, g.gitPath)
+	ge.cmd = append(ge.cmd, args...)
+	if g.gitTrace {
+		defer func() { ge.log() }()
+	}
+	if g.testGitHook != nil {
+		ge.err = g.testGitHook(args)
+		if ge.err != nil {
+			return nil, ge
+		}
+	}
+
+	cmd := exec.Command(g.gitPath, args...)
+	if path != "" {
+		cmd.Dir = path
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		ge.err = fmt.Errorf("cmd.Output: %v", err)
+		ge.stderr = strings.Split(strings.TrimSpace(stderr.String()), "\n")
+		return nil, ge
+	}
+
+	return out, nil
+}
+
 // gitVersion returns the version of git.
 func (g *gitBackEnd) gitVersion() (string, error) {
 	out, err := g.git("", "version")
@@ -169,6 +230,16 @@ func (g *gitBackEnd) gitAdd(path, filename string) error {
 	return err
 }
 
+func (g *gitBackEnd) gitRm(path, filename string, recursive bool) error {
+	var err error
+	if recursive {
+		_, err = g.git(path, "rm", "-r", filename)
+	} else {
+		_, err = g.git(path, "rm", filename)
+	}
+	return err
+}
+
 func (g *gitBackEnd) gitCommit(path, message string) error {
 	_, err := g.git(path, "commit", "-m", message)
 	return err
@@ -179,23 +250,168 @@ func (g *gitBackEnd) gitCheckout(path, branch string) error {
 	return err
 }
 
+// gitCheckoutForce checks out branch and discards all local modifications to
+// tracked files.
+func (g *gitBackEnd) gitCheckoutForce(path, branch string) error {
+	_, err := g.git(path, "checkout", "-f", branch)
+	return err
+}
+
+// gitCheckoutReset checks out branch and forcibly points it at commit.  The
+// branch is created if it does not exist and all local modifications to
+// tracked files are discarded.
+func (g *gitBackEnd) gitCheckoutReset(path, branch, commit string) error {
+	_, err := g.git(path, "checkout", "-f", "-B", branch, commit)
+	return err
+}
+
+// gitClean removes all untracked files and directories that are not ignored.
+func (g *gitBackEnd) gitClean(path string) error {
+	_, err := g.git(path, "clean", "-f", "-d")
+	return err
+}
+
+// gitRebaseAbort aborts a rebase that is in progress.  It errors out if there
+// is no rebase in progress.
+func (g *gitBackEnd) gitRebaseAbort(path string) error {
+	_, err := g.git(path, "rebase", "--abort")
+	return err
+}
+
 func (g *gitBackEnd) gitBranchDelete(path, branch string) error {
 	_, err := g.git(path, "branch", "-D", branch)
 	return err
 }
 
-func (g *gitBackEnd) gitBranches(path string) ([]string, error) {
-	branches, err := g.git(path, "branch")
+// gitBranchHeads returns the commit digest each local branch points to,
+// indexed by branch name.  All heads are read at once so that callers get a
+// consistent view even when branches are created or deleted concurrently.
+func (g *gitBackEnd) gitBranchHeads(path string) (map[string]string, error) {
+	out, err := g.git(path, "for-each-ref",
+		"--format=%(refname:short) %(objectname)", "refs/heads/")
 	if err != nil {
 		return nil, err
 	}
 
-	b := make([]string, 0, len(branches))
-	for _, v := range branches {
-		b = append(b, strings.Trim(v, " *\t\n"))
+	heads := make(map[string]string, len(out))
+	for _, v := range out {
+		s := strings.Fields(v)
+		if len(s) != 2 {
+			return nil, fmt.Errorf("invalid for-each-ref output: %v", v)
+		}
+		heads[s[0]] = s[1]
+	}
+
+	return heads, nil
+}
+
+// gitRevParse returns the digest of the commit ref points to.
+func (g *gitBackEnd) gitRevParse(path, ref string) (string, error) {
+	out, err := g.git(path, "rev-parse", "--verify", ref+"^{commit}")
+	if err != nil {
+		return "", err
+	}
+	if len(out) != 1 {
+		return "", fmt.Errorf("invalid rev-parse output")
 	}
 
-	return b, nil
+	return out[0], nil
+}
+
+// gitHashObject writes the content of filename to the object database and
+// returns the digest of the resulting blob.
+func (g *gitBackEnd) gitHashObject(path, filename string) (string, error) {
+	out, err := g.git(path, "hash-object", "-w", filename)
+	if err != nil {
+		return "", err
+	}
+	if len(out) != 1 {
+		return "", fmt.Errorf("invalid hash-object output")
+	}
+
+	return out[0], nil
+}
+
+// gitIndexEnv returns the environment that directs git to use index file
+// instead of the repository index.  This allows commits to be created
+// without touching the index or worktree of the repository.
+func gitIndexEnv(index string) []string {
+	return []string{"GIT_INDEX_FILE=" + index}
+}
+
+// gitReadTree populates index with the tree of commit.
+func (g *gitBackEnd) gitReadTree(path, index, commit string) error {
+	_, err := g.gitEnv(path, gitIndexEnv(index), "read-tree", commit)
+	return err
+}
+
+// gitRmCached recursively removes filename from index.  It does not error out
+// if filename does not exist.
+func (g *gitBackEnd) gitRmCached(path, index, filename string) error {
+	_, err := g.gitEnv(path, gitIndexEnv(index), "rm", "--cached", "-r",
+		"-q", "--ignore-unmatch", filename)
+	return err
+}
+
+// gitUpdateIndex adds blob to index as filename.
+func (g *gitBackEnd) gitUpdateIndex(path, index, blob, filename string) error {
+	_, err := g.gitEnv(path, gitIndexEnv(index), "update-index", "--add",
+		"--cacheinfo", "100644,"+blob+","+filename)
+	return err
+}
+
+// gitWriteTree writes index to the object database and returns the digest of
+// the resulting tree.
+func (g *gitBackEnd) gitWriteTree(path, index string) (string, error) {
+	out, err := g.gitEnv(path, gitIndexEnv(index), "write-tree")
+	if err != nil {
+		return "", err
+	}
+	if len(out) != 1 {
+		return "", fmt.Errorf("invalid write-tree output")
+	}
+
+	return out[0], nil
+}
+
+// gitCommitTree creates a commit of tree with the provided parent and returns
+// its digest.  No reference is updated.
+func (g *gitBackEnd) gitCommitTree(path, tree, parent, message string) (string, error) {
+	out, err := g.git(path, "commit-tree", tree, "-p", parent, "-m",
+		message)
+	if err != nil {
+		return "", err
+	}
+	if len(out) != 1 {
+		return "", fmt.Errorf("invalid commit-tree output")
+	}
+
+	return out[0], nil
+}
+
+// gitUpdateRef atomically points ref at commit provided that ref currently
+// points at old.  An empty old requires that ref does not exist yet.
+func (g *gitBackEnd) gitUpdateRef(path, ref, commit, old string) error {
+	_, err := g.git(path, "update-ref", ref, commit, old)
+	return err
+}
+
+// gitSetRef points ref at commit regardless of where it points to.
+func (g *gitBackEnd) gitSetRef(path, ref, commit string) error {
+	_, err := g.git(path, "update-ref", ref, commit)
+	return err
+}
+
+// gitDeleteRef deletes ref.  It succeeds if ref does not exist.
+func (g *gitBackEnd) gitDeleteRef(path, ref string) error {
+	_, err := g.git(path, "update-ref", "-d", ref)
+	return err
+}
+
+// gitExists returns true if filename was recorded in commit.
+func (g *gitBackEnd) gitExists(path, commit, filename string) bool {
+	_, err := g.gitRaw(path, "cat-file", "-e", commit+":"+filename)
+	return err == nil
 }
 
 func (g *gitBackEnd) gitPull(path string, fastForward bool) error {
@@ -272,6 +488,115 @@ func (g *gitBackEnd) gitLog(path string) ([]string, error) {
 	return out, nil
 }
 
+// gitFileLog returns the digests of all commits reachable from ref that
+// modified filename.  The digests are returned newest first.
+func (g *gitBackEnd) gitFileLog(path, ref, filename string) ([]string, error) {
+	out, err := g.git(path, "log", "--pretty=format:%H", ref, "--",
+		filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// gitShow returns the content of filename as it was recorded in commit.
+func (g *gitBackEnd) gitShow(path, commit, filename string) ([]byte, error) {
+	return g.gitRaw(path, "show", commit+":"+filename)
+}
+
+// gitCatFileBatch returns the content of each of the provided objects, which
+// are of the form "commit:filename", in the same order.  All objects are read
+// by a single git process.  It is an error if an object does not exist.
+func (g *gitBackEnd) gitCatFileBatch(path string, objects []string) ([][]byte, error) {
+	args := []string{"cat-file", "--batch"}
+
+	// Setup gitError
+	ge := gitError{
+		cmd: make([]string, 0, len(args)+1),
+	}
+	ge.cmd = append(ge.cmd, g.gitPath)
+	ge.cmd = append(ge.cmd, args...)
+	if g.gitTrace {
+		defer func() { ge.log() }()
+	}
+	if g.testGitHook != nil {
+		ge.err = g.testGitHook(args)
+		if ge.err != nil {
+			return nil, ge
+		}
+	}
+
+	for _, v := range objects {
+		if strings.ContainsAny(v, "\n") {
+			ge.err = fmt.Errorf("invalid object name: %q", v)
+			return nil, ge
+		}
+	}
+	cmd := exec.Command(g.gitPath, args...)
+	if path != "" {
+		cmd.Dir = path
+	}
+	cmd.Stdin = strings.NewReader(strings.Join(objects, "\n") + "\n")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		ge.err = fmt.Errorf("cmd.Output: %v", err)
+		ge.stderr = strings.Split(strings.TrimSpace(stderr.String()), "\n")
+		return nil, ge
+	}
+
+	// Every object is reported as "<digest> <type> <size>" followed by
+	// the content and a newline, or as "<object> missing".
+	blobs := make([][]byte, 0, len(objects))
+	for _, object := range objects {
+		i := bytes.IndexByte(out, '\n')
+		if i < 0 {
+			ge.err = fmt.Errorf("short cat-file output for %v", object)
+			return nil, ge
+		}
+		header := strings.Fields(string(out[:i]))
+		out = out[i+1:]
+		if len(header) != 3 {
+			ge.err = fmt.Errorf("cat-file %v: %v", object,
+				strings.Join(header, " "))
+			return nil, ge
+		}
+		size, err := strconv.Atoi(header[2])
+		if err != nil || size < 0 || len(out) < size+1 {
+			ge.err = fmt.Errorf("invalid cat-file output for %v",
+				object)
+			return nil, ge
+		}
+		blobs = append(blobs, out[:size])
+		out = out[size+1:]
+	}
+
+	return blobs, nil
+}
+
+// gitListTree returns the names of the entries of directory dir as it was
+// recorded in commit.
+func (g *gitBackEnd) gitListTree(path, commit, dir string) ([]string, error) {
+	out, err := g.gitRaw(path, "ls-tree", "-z", "--name-only",
+		commit+":"+dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, 16)
+	for _, v := range strings.Split(string(out), "\x00") {
+		if v == "" {
+			continue
+		}
+		names = append(names, v)
+	}
+
+	return names, nil
+}
+
 func (g *gitBackEnd) gitFsck(path string) ([]string, error) {
 	out, err := g.git(path, "fsck", "--full", "--strict")
 	if err != nil {
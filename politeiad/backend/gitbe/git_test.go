@@ -15,13 +15,12 @@ import (
 	"github.com/btcsuite/btclog"
 )
 
-type testWriter struct {
-	t *testing.T
-}
-
-func (w *testWriter) Write(p []byte) (int, error) {
-	w.t.Logf("%s", p)
-	return len(p), nil
+// TestMain sets the package logger once.  Backends log from goroutines that
+// may outlive the test that created them, so the logger can neither be
+// replaced by later tests nor write to the testing.T of a single test.
+func TestMain(m *testing.M) {
+	UseLogger(btclog.NewBackend(os.Stdout).Logger("TEST"))
+	os.Exit(m.Run())
 }
 
 func newGitBackEnd() *gitBackEnd {
@@ -47,8 +46,6 @@ func TestVersion(t *testing.T) {
 }
 
 func TestInit(t *testing.T) {
-	log := btclog.NewBackend(&testWriter{t}).Logger("TEST")
-	UseLogger(log)
 	g := newGitBackEnd()
 	defer os.RemoveAll(g.root)
 
@@ -59,8 +56,6 @@ func TestInit(t *testing.T) {
 }
 
 func TestLog(t *testing.T) {
-	log := btclog.NewBackend(&testWriter{t}).Logger("TEST")
-	UseLogger(log)
 	g := newGitBackEnd()
 	defer os.RemoveAll(g.root)
 
@@ -77,8 +72,6 @@ func TestLog(t *testing.T) {
 
 func TestFsck(t *testing.T) {
 	// Test git fsck, we build on top of that with a dcrtime fsck
-	log := btclog.NewBackend(&testWriter{t}).Logger("TEST")
-	UseLogger(log)
 	g := newGitBackEnd()
 	defer os.RemoveAll(g.root)
 
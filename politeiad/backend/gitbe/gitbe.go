@@ -4,24 +4,21 @@ import (
 	"bytes"
 	"crypto/sha1"
 	"crypto/sha256"
-	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"net/http"
 	"os"
 	"path/filepath"
-	"strconv"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/decred/dcrtime/api/v1"
 	"github.com/decred/dcrtime/merkle"
-	pd "github.com/decred/politeia/politeiad/api/v1"
-	"github.com/decred/politeia/politeiad/api/v1/mime"
 	"github.com/decred/politeia/politeiad/backend"
 	"github.com/decred/politeia/util"
 	"github.com/marcopeereboom/lockfile"
@@ -50,10 +47,25 @@ const (
 	// metadata record.
 	defaultProposalStorageRecordFilename = "psr.json"
 
+	// defaultUserSignatureFilename is the filename of the signature of
+	// the user that submitted the proposal.  It is stored next to the
+	// proposal metadata record and never changes.
+	defaultUserSignatureFilename = "usersig.json"
+
+	// defaultCommentsFilename is the filename of the comments of a vetted
+	// proposal.  It contains one JSON encoded comment per line, oldest
+	// first.
+	defaultCommentsFilename = "comments.json"
+
 	// defaultAuditTrailFile is the filename where a human readable audit
 	// trail is kept.
 	defaultAuditTrailFile = "anchor_audit_trail.txt"
 
+	// defaultUnvettedAuditTrailFile is the filename where a human readable
+	// audit trail of the unvetted anchors is kept.  It lives outside of the
+	// repos since unvetted content must not leak into the vetted repo.
+	defaultUnvettedAuditTrailFile = "unvetted_anchor_audit_trail.txt"
+
 	// defaultAnchorsDirectory is the directory where anchors are stored.
 	// They are indexed by TX.
 	defaultAnchorsDirectory = "anchors"
@@ -99,8 +111,52 @@ type gitBackEnd struct {
 	exit        chan struct{}      // Close channel
 	checkAnchor chan struct{}      // Work notification
 
+	// The global lock is only taken by operations that touch the
+	// unvetted worktree or both repos.  All other operations hold the
+	// shutdown lock shared and use the finer grained locks.  Locks are
+	// always acquired in this order: lock, vettedLock, proposal lock.
+	shutdownLock sync.RWMutex             // Held exclusively by Close
+	vettedLock   sync.Mutex               // Vetted repo writes
+	tokenLock    sync.Mutex               // Protects tokenLocks
+	tokenLocks   map[string]*proposalLock // [id]lock
+
 	// The following items are used for testing only
-	testAnchors map[string]bool // [digest]anchored
+	testAnchors map[string]bool           // [digest]anchored
+	testGitHook func(args []string) error // Inject git failures
+}
+
+// proposalLock serializes all writes to a single proposal.  It is reference
+// counted so that it can be discarded once nobody uses it.
+type proposalLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lockProposal acquires the lock of proposal id.
+func (g *gitBackEnd) lockProposal(id string) {
+	g.tokenLock.Lock()
+	l, ok := g.tokenLocks[id]
+	if !ok {
+		l = &proposalLock{}
+		g.tokenLocks[id] = l
+	}
+	l.refs++
+	g.tokenLock.Unlock()
+
+	l.Lock()
+}
+
+// unlockProposal releases the lock of proposal id.
+func (g *gitBackEnd) unlockProposal(id string) {
+	g.tokenLock.Lock()
+	l := g.tokenLocks[id]
+	l.refs--
+	if l.refs == 0 {
+		delete(g.tokenLocks, id)
+	}
+	g.tokenLock.Unlock()
+
+	l.Unlock()
 }
 
 // extendSHA1 appends 0 to make a SHA1 the size of a SHA256 digest.
@@ -141,153 +197,27 @@ func extendSHA1FromString(s string) (string, error) {
 	return hex.EncodeToString(d), nil
 }
 
-// newUniqueID returns a new unique proposal ID.  The function will hold the
-// unvettedLock if successful.  The callee is responsible for releasing the
-// lock.
-//
-// This function must be called without holding the unvetted lock.
-func (g *gitBackEnd) newUniqueID() (uint64, error) {
-	err := g.lock.Lock(LockDuration)
-	if err != nil {
-		return 0, err
-	}
-
-	// Get Dirs.
-	files, err := ioutil.ReadDir(g.unvetted)
-	if err != nil {
-		return 0, err
-	}
-
-	// Find biggest proposal ID
-	var last uint64
-	for _, file := range files {
-		// This check ignores lockFilename as well
-		if !file.IsDir() {
-			continue
-		}
-		p, err := strconv.ParseUint(file.Name(), 10, 64)
-		if err != nil {
-			continue
-		}
-		if p > last {
-			last = p
-		}
-	}
-	id := last + 1
-
-	// Create directory
-	err = os.MkdirAll(filepath.Join(g.unvetted, strconv.FormatUint(id, 10)),
-		0764)
+// verifyProposal verifies the content of all provided files and ensures that
+// the proposal is not empty and does not contain duplicate filenames.  It
+// returns a cooked array of the files.
+func verifyProposal(files []backend.File) ([]file, error) {
+	payloads, err := backend.VerifyProposal(files)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	return id, nil
-}
-
-// verifyContent verifies that all provided backend.File are sane and returns a
-// cooked array of the files.
-func verifyContent(files []backend.File) ([]file, error) {
 	fa := make([]file, 0, len(files))
 	for i := range files {
-		// Validate digest
-		d, ok := util.ConvertDigest(files[i].Digest)
-		if !ok {
-			return nil, backend.ContentVerificationError{
-				ErrorCode: pd.ErrorStatusInvalidFileDigest,
-				ErrorContext: []string{
-					files[i].Name,
-				},
-			}
-		}
-
-		// Setup cooked file.
-		f := file{
-			name: files[i].Name,
-		}
-
-		// Decode base64 payload
-		var err error
-		f.payload, err = base64.StdEncoding.DecodeString(files[i].Payload)
-		if err != nil {
-			return nil, backend.ContentVerificationError{
-				ErrorCode: pd.ErrorStatusInvalidBase64,
-				ErrorContext: []string{
-					files[i].Name,
-				},
-			}
-		}
-
-		// Calculate payload digest
-		dp := util.Digest(f.payload)
-		if !bytes.Equal(d[:], dp) {
-			return nil, backend.ContentVerificationError{
-				ErrorCode: pd.ErrorStatusInvalidFileDigest,
-				ErrorContext: []string{
-					files[i].Name,
-				},
-			}
-		}
-		f.digest = dp
-
-		// Verify MIME
-		detectedMIMEType := http.DetectContentType(f.payload)
-		if detectedMIMEType != files[i].MIME {
-			return nil, backend.ContentVerificationError{
-				ErrorCode: pd.ErrorStatusInvalidMIMEType,
-				ErrorContext: []string{
-					files[i].Name,
-					detectedMIMEType,
-				},
-			}
-		}
-		if !mime.MimeValid(files[i].MIME) {
-			return nil, backend.ContentVerificationError{
-				ErrorCode: pd.ErrorStatusUnsupportedMIMEType,
-				ErrorContext: []string{
-					files[i].Name,
-					files[i].MIME,
-				},
-			}
-		}
-
-		fa = append(fa, f)
+		fa = append(fa, file{
+			name:    files[i].Name,
+			digest:  util.Digest(payloads[i]),
+			payload: payloads[i],
+		})
 	}
 
 	return fa, nil
 }
 
-// loadProposal loads an entire proposal of disk.  It returns an array of
-// backend.File that is completely filled out.
-//
-// This function must be called with the lock held.
-func loadProposal(path, id string) ([]backend.File, error) {
-	// Get dir.
-	propDir := filepath.Join(path, id, defaultPayloadDir)
-	files, err := ioutil.ReadDir(propDir)
-	if err != nil {
-		return nil, err
-	}
-
-	bf := make([]backend.File, 0, len(files))
-	// Load all files
-	for _, file := range files {
-		fn := filepath.Join(propDir, file.Name())
-		if file.IsDir() {
-			return nil, fmt.Errorf("proposal corrupt: %v", path)
-		}
-
-		f := backend.File{Name: file.Name()}
-		f.MIME, f.Digest, f.Payload, err = util.LoadFile(fn)
-		if err != nil {
-			return nil, err
-		}
-		bf = append(bf, f)
-	}
-
-	return bf, nil
-}
-
 // loadPSR loads a ProposalStorageRecord from the provided path/id.  This may
 // be unvetted/id or vetted/id.
 //
@@ -312,27 +242,16 @@ func loadPSR(path, id string) (*backend.ProposalStorageRecord, error) {
 	return &psr, nil
 }
 
-// createPSR stores a ProposalStorageRecord to the provided path/id.  This may be
-// unvetted/id or vetted/id.
-//
-// This function should be called with the lock held.
-func createPSR(path, id, name string, status backend.PSRStatusT, version uint, hashes []*[sha256.Size]byte, token []byte) (*backend.ProposalStorageRecord, error) {
-	// Create proposal storage record
-	psr := backend.ProposalStorageRecord{
-		Name:      name,
-		Version:   version,
-		Status:    status,
-		Merkle:    *merkle.Root(hashes),
-		Timestamp: time.Now().Unix(),
-		Token:     token,
-	}
-
-	err := updatePSR(path, id, &psr)
+// encodePSR encodes a ProposalStorageRecord exactly like updatePSR stores it
+// on disk.
+func encodePSR(psr backend.ProposalStorageRecord) ([]byte, error) {
+	var b bytes.Buffer
+	err := json.NewEncoder(&b).Encode(psr)
 	if err != nil {
 		return nil, err
 	}
 
-	return &psr, nil
+	return b.Bytes(), nil
 }
 
 // updatePSR updates the ProposalStorageRecord status to the provided path/id.
@@ -405,23 +324,33 @@ func (g *gitBackEnd) deltaCommits(path string, lastAnchor []byte) ([]*[sha256.Si
 		return nil, nil, nil, fmt.Errorf("invalid git output")
 	}
 
-	// Generate return data
+	digests, commitMessages, err := parseOneline(out)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return digests, commitMessages, out, nil
+}
+
+// parseOneline parses the output of git log --pretty=oneline and returns the
+// extended commit digests and the commit messages.
+func parseOneline(out []string) ([]*[sha256.Size]byte, []string, error) {
 	digests := make([]*[sha256.Size]byte, 0, len(out))
 	commitMessages := make([]string, 0, len(out))
 	for _, line := range out {
 		// Returned data is "<digest> <commit message>"
 		ds := strings.SplitN(line, " ", 2)
 		if len(ds) == 0 {
-			return nil, nil, nil, fmt.Errorf("invalid log")
+			return nil, nil, fmt.Errorf("invalid log")
 		}
 
 		// Validate returned digest
 		sha1Digest, err := hex.DecodeString(ds[0])
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, nil, err
 		}
 		if len(sha1Digest) != sha1.Size {
-			return nil, nil, nil, fmt.Errorf("invalid sha1 size")
+			return nil, nil, fmt.Errorf("invalid sha1 size")
 		}
 		sha256DigestB := extendSHA1(sha1Digest)
 		var sha256Digest [sha256.Size]byte
@@ -432,7 +361,7 @@ func (g *gitBackEnd) deltaCommits(path string, lastAnchor []byte) ([]*[sha256.Si
 		commitMessages = append(commitMessages, ds[1])
 	}
 
-	return digests, commitMessages, out, nil
+	return digests, commitMessages, nil
 }
 
 // anchor takes a slice of commit digests and commit messages that are stored
@@ -461,10 +390,10 @@ func (g *gitBackEnd) anchor(digests []*[sha256.Size]byte) error {
 	return util.Timestamp(g.dcrtimeHost, digests)
 }
 
-// appendAuditTrail adds a record to the audit trail.
-func (g *gitBackEnd) appendAuditTrail(path string, ts int64, merkle [sha256.Size]byte, lines []string) error {
-	f, err := os.OpenFile(filepath.Join(path, defaultAuditTrailFile),
-		os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+// appendAuditTrail adds a record to the audit trail in filename.
+func (g *gitBackEnd) appendAuditTrail(filename string, ts int64, merkle [sha256.Size]byte, lines []string) error {
+	f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_APPEND,
+		0644)
 	if err != nil {
 		return err
 	}
@@ -557,8 +486,8 @@ func (g *gitBackEnd) anchorRepo(path string) (*[sha256.Size]byte, error) {
 
 	// Commit merkle root as an anchor and append included commits to audit
 	// trail
-	err = g.appendAuditTrail(path, anchorRecord.Time, *anchorKey,
-		auditLines)
+	err = g.appendAuditTrail(filepath.Join(path, defaultAuditTrailFile),
+		anchorRecord.Time, *anchorKey, auditLines)
 	if err != nil {
 		return nil, fmt.Errorf("could not append to audit trail: %v",
 			err)
@@ -611,6 +540,126 @@ func (g *gitBackEnd) anchorRepo(path string) (*[sha256.Size]byte, error) {
 	return anchorKey, nil
 }
 
+// anchorUnvetted drops an anchor for all commits on the unvetted proposal
+// branches, including censored proposals, that have not been anchored yet.
+// Unlike anchorRepo it does not commit anything to the repo; the anchor is
+// only recorded in the database and in the unvetted audit trail.
+//
+// This function should be called with the lock held.
+func (g *gitBackEnd) anchorUnvetted() (*[sha256.Size]byte, error) {
+	last, err := g.readLastUnvettedAnchorRecord()
+	if err != nil {
+		return nil, fmt.Errorf("could not find last unvetted heads: %v",
+			err)
+	}
+
+	// Snapshot the proposal branches.  Anything that is committed after
+	// this point is picked up by the next anchor.
+	refs, err := g.gitBranchHeads(g.unvetted)
+	if err != nil {
+		return nil, err
+	}
+	heads := make(map[string][]byte, len(refs))
+	args := []string{"log", "--pretty=oneline"}
+	exclude := []string{"--not", "master"}
+	for id, ref := range refs {
+		if !util.IsDigest(id) {
+			continue
+		}
+		d, err := hex.DecodeString(ref)
+		if err != nil {
+			return nil, err
+		}
+		heads[id] = extendSHA1(d)
+
+		// Only walk branches that moved since the last anchor and stop
+		// at the commits that were anchored already.
+		prev, ok := last.Heads[id]
+		if !ok {
+			args = append(args, ref)
+			continue
+		}
+		if bytes.Equal(prev, heads[id]) {
+			continue
+		}
+		args = append(args, ref)
+		exclude = append(exclude, hex.EncodeToString(unextendSHA256(prev)))
+	}
+	if len(args) == 2 {
+		return nil, errNothingToDo
+	}
+
+	// git log <moved heads> --not master <anchored heads> --pretty=oneline
+	out, err := g.git(g.unvetted, append(args, exclude...)...)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return nil, errNothingToDo
+	}
+	digests, messages, err := parseOneline(out)
+	if err != nil {
+		return nil, fmt.Errorf("could not determine unvetted delta: %v",
+			err)
+	}
+
+	auditLines := make([]string, 0, len(digests))
+	for k, digest := range digests {
+		auditLines = append(auditLines, fmt.Sprintf("%x %v", *digest,
+			messages[k]))
+	}
+	anchorRecord, anchorKey, err := newAnchorRecord(AnchorUnverified,
+		digests, messages)
+	if err != nil {
+		return nil, fmt.Errorf("newAnchorRecord: %v", err)
+	}
+
+	// Anchor commits, see anchorRepo for why the key is appended.
+	log.Infof("Anchoring %v proposal branches", filepath.Base(g.unvetted))
+	err = g.anchor(append(digests, anchorKey))
+	if err != nil {
+		return nil, fmt.Errorf("anchor: %v", err)
+	}
+
+	err = g.appendAuditTrail(filepath.Join(g.root,
+		defaultUnvettedAuditTrailFile), anchorRecord.Time, *anchorKey,
+		auditLines)
+	if err != nil {
+		return nil, fmt.Errorf("could not append to audit trail: %v",
+			err)
+	}
+
+	// Commit anchor and LastUnvettedAnchor to database
+	err = g.writeUnvettedAnchorRecord(*anchorKey, *anchorRecord)
+	if err != nil {
+		return nil, fmt.Errorf("writeUnvettedAnchorRecord: %v", err)
+	}
+	mr := make([]byte, sha256.Size)
+	copy(mr, anchorKey[:])
+	err = g.writeLastUnvettedAnchorRecord(LastUnvettedAnchor{
+		Heads:  heads,
+		Time:   anchorRecord.Time,
+		Merkle: mr,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("writeLastUnvettedAnchorRecord: %v", err)
+	}
+
+	// Append merkle to unconfirmed unvetted anchor record
+	ua, err := g.readUnconfirmedUnvettedAnchorRecord()
+	if err != nil {
+		return nil, err
+	}
+	ua.Merkles = append(ua.Merkles, mr)
+	err = g.writeUnconfirmedUnvettedAnchorRecord(*ua)
+	if err != nil {
+		return nil, fmt.Errorf("writeUnconfirmedUnvettedAnchorRecord: "+
+			"%v", err)
+	}
+
+	return anchorKey, nil
+}
+
 // anchor verifies if there are new commits in all repos and if that is the
 // case it drops and anchor in dcrtime for each of them.
 func (g *gitBackEnd) anchorAllRepos() error {
@@ -626,30 +675,47 @@ func (g *gitBackEnd) anchorAllRepos() error {
 			log.Errorf("anchorAllRepos unlock error: %v", err)
 		}
 	}()
+
 	if g.shutdown {
 		return fmt.Errorf("anchorAllRepos: %v", backend.ErrShutdown)
 	}
 
+	g.vettedLock.Lock()
+	defer g.vettedLock.Unlock()
+
 	//  Anchor vetted
 	log.Infof("Anchoring %v", g.vetted)
 	mr, err := g.anchorRepo(g.vetted)
-	if err != nil {
-		if err == errNothingToDo {
-			log.Infof("Anchoring %v: nothing to do", g.vetted)
-			return nil
-		}
+	switch {
+	case err == errNothingToDo:
+		log.Infof("Anchoring %v: nothing to do", g.vetted)
+	case err != nil:
 		return fmt.Errorf("anchor repo %v: %v", g.vetted, err)
-	}
+	default:
+		// Sync vetted to unvetted
+
+		// git pull --ff-only --rebase
+		err = g.gitPull(g.unvetted, true)
+		if err != nil {
+			return err
+		}
 
-	// Sync vetted to unvetted
+		log.Infof("Dropping anchor complete: %x", *mr)
+	}
 
-	// git pull --ff-only --rebase
-	err = g.gitPull(g.unvetted, true)
+	// Anchor unvetted branches
+	log.Infof("Anchoring %v branches", g.unvetted)
+	mr, err = g.anchorUnvetted()
 	if err != nil {
-		return err
+		if err == errNothingToDo {
+			log.Infof("Anchoring %v branches: nothing to do",
+				g.unvetted)
+			return nil
+		}
+		return fmt.Errorf("anchor branches %v: %v", g.unvetted, err)
 	}
 
-	log.Infof("Dropping anchor complete: %x", *mr)
+	log.Infof("Dropping unvetted anchor complete: %x", *mr)
 
 	return nil
 }
@@ -714,9 +780,104 @@ func (g *gitBackEnd) anchorChecker() error {
 		return fmt.Errorf("afterAnchorVerify: %v", err)
 	}
 
+	// Repeat for the unvetted anchors
+	ua, err = g.safeReadUnconfirmedUnvettedAnchorRecord()
+	if err != nil {
+		return fmt.Errorf("anchorChecker read unvetted: %v", err)
+	}
+	if len(ua.Merkles) == 0 {
+		return nil
+	}
+	vrs = make([]v1.VerifyDigest, 0, len(ua.Merkles))
+	precious = make([][]byte, 0, len(ua.Merkles))
+	for _, u := range ua.Merkles {
+		digest := hex.EncodeToString(u)
+		vr, err := g.verifyAnchor(digest)
+		if err != nil {
+			precious = append(precious, u)
+			log.Errorf("anchorChecker verify unvetted: %v", err)
+			continue
+		}
+		vrs = append(vrs, *vr)
+	}
+
+	err = g.afterUnvettedAnchorVerify(vrs, precious)
+	if err != nil {
+		return fmt.Errorf("afterUnvettedAnchorVerify: %v", err)
+	}
+
 	return nil
 }
 
+// setAnchorVerified marks anchor as verified and records the dcrtime chain
+// information.
+func setAnchorVerified(anchor *Anchor, ci v1.ChainInformation) {
+	anchor.Type = AnchorVerified
+	anchor.ChainTimestamp = ci.ChainTimestamp
+	anchor.Transaction = ci.Transaction
+	anchor.ChainMerkleRoot = ci.MerkleRoot
+	anchor.ChainMerklePath = ci.MerklePath
+}
+
+// afterUnvettedAnchorVerify completes the unvetted anchor verification
+// process.  Unvetted anchors are not committed to any repo so only the
+// database and the unvetted audit trail are updated.
+func (g *gitBackEnd) afterUnvettedAnchorVerify(vrs []v1.VerifyDigest, precious [][]byte) error {
+	// Lock filesystem
+	err := g.lock.Lock(LockDuration)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := g.lock.Unlock()
+		if err != nil {
+			log.Errorf("afterUnvettedAnchorVerify unlock error: %v",
+				err)
+		}
+	}()
+
+	for _, vr := range vrs {
+		if vr.ChainInformation.ChainTimestamp == 0 {
+			// dcrtime returns 0 when there are not enough
+			// confirmations yet.
+			return fmt.Errorf("not enough confirmations: %v",
+				vr.Digest)
+		}
+
+		mr, ok := util.ConvertDigest(vr.Digest)
+		if !ok {
+			return fmt.Errorf("invalid digest: %v", vr.Digest)
+		}
+		anchor, err := g.readUnvettedAnchorRecord(mr)
+		if err != nil {
+			return err
+		}
+		setAnchorVerified(anchor, vr.ChainInformation)
+		err = g.writeUnvettedAnchorRecord(mr, *anchor)
+		if err != nil {
+			return err
+		}
+
+		line := fmt.Sprintf("%v anchored in TX %v", vr.Digest,
+			vr.ChainInformation.Transaction)
+		err = g.appendAuditTrail(filepath.Join(g.root,
+			defaultUnvettedAuditTrailFile),
+			vr.ChainInformation.ChainTimestamp, mr, []string{line})
+		if err != nil {
+			return err
+		}
+
+		// Mark test anchors as confirmed by dcrtime
+		if g.test {
+			g.testAnchors[vr.Digest] = true
+		}
+	}
+
+	// Update database record
+	ua := UnconfirmedAnchor{Merkles: precious}
+	return g.writeUnconfirmedUnvettedAnchorRecord(ua)
+}
+
 // afterAnchorVerify completes the anchor verification process.  It is a
 // separate function in order not having to futz with locks.
 func (g *gitBackEnd) afterAnchorVerify(vrs []v1.VerifyDigest, precious [][]byte) error {
@@ -732,6 +893,9 @@ func (g *gitBackEnd) afterAnchorVerify(vrs []v1.VerifyDigest, precious [][]byte)
 		}
 	}()
 
+	g.vettedLock.Lock()
+	defer g.vettedLock.Unlock()
+
 	if len(vrs) != 0 {
 		// git checkout master
 		err = g.gitCheckout(g.vetted, "master")
@@ -757,8 +921,9 @@ func (g *gitBackEnd) afterAnchorVerify(vrs []v1.VerifyDigest, precious [][]byte)
 		line := fmt.Sprintf("%v anchored in TX %v", vr.Digest,
 			vr.ChainInformation.Transaction)
 		commitMsg += line + "\n"
-		err = g.appendAuditTrail(g.vetted,
-			vr.ChainInformation.ChainTimestamp, mr, []string{line})
+		err = g.appendAuditTrail(filepath.Join(g.vetted,
+			defaultAuditTrailFile), vr.ChainInformation.ChainTimestamp,
+			mr, []string{line})
 		if err != nil {
 			return err
 		}
@@ -799,9 +964,7 @@ func (g *gitBackEnd) afterAnchorVerify(vrs []v1.VerifyDigest, precious [][]byte)
 		if err != nil {
 			return err
 		}
-		anchor.Type = AnchorVerified
-		anchor.ChainTimestamp = vr.ChainInformation.ChainTimestamp
-		anchor.Transaction = vr.ChainInformation.Transaction
+		setAnchorVerified(anchor, vr.ChainInformation)
 		err = g.writeAnchorRecord(d, *anchor)
 		if err != nil {
 			return err
@@ -877,8 +1040,27 @@ func (g *gitBackEnd) safeReadUnconfirmedAnchorRecord() (*UnconfirmedAnchor, erro
 	return g.readUnconfirmedAnchorRecord()
 }
 
-// anchorAllReposCronJob is the cron job that anchors all repos at a preset time.
-func (g *gitBackEnd) anchorAllReposCronJob() {
+// safeReadUnconfirmedUnvettedAnchorRecord is a wrapper around
+// readUnconfirmedUnvettedAnchorRecord that handles locking.
+func (g *gitBackEnd) safeReadUnconfirmedUnvettedAnchorRecord() (*UnconfirmedAnchor, error) {
+	// Lock filesystem
+	err := g.lock.Lock(LockDuration)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err := g.lock.Unlock()
+		if err != nil {
+			log.Errorf("safeReadUnconfirmedUnvettedAnchorRecord "+
+				"unlock error: %v", err)
+		}
+	}()
+
+	return g.readUnconfirmedUnvettedAnchorRecord()
+}
+
+// anchorAllReposCronJob is the cron job that anchors all repos at a preset time.
+func (g *gitBackEnd) anchorAllReposCronJob() {
 	err := g.anchorAllRepos()
 	if err != nil {
 		log.Errorf("%v", err)
@@ -932,34 +1114,113 @@ func (g *gitBackEnd) verifyAnchor(digest string) (*v1.VerifyDigest, error) {
 	return &vr.Digests[0], nil
 }
 
-// New takes a proposal verifies it and drops it on disk in the unvetted
-// directory.  Proposals and metadata are stored in unvetted/token/.  the
-// function returns a ProposaltorageRecord.
+// commitBranch commits files on top of parent and points branch at the
+// result.  Files are indexed by their path relative to the root of the repo
+// and the paths in remove are recursively deleted before the files are
+// added.  The commit is built in a private index so the index and worktree of
+// the repo are never touched.  Branch is only moved if it still points at
+// parent, or if it does not exist yet when create is set, which makes the
+// commit atomic.  It returns the digest of the new commit.
 //
-// New satisfies the backend interface.
-func (g *gitBackEnd) New(name string, files []backend.File) (*backend.ProposalStorageRecord, error) {
-	fa, err := verifyContent(files)
+// This function must be called WITH the proposal lock held.
+func (g *gitBackEnd) commitBranch(repo, branch, parent string, create bool, message string, remove []string, files map[string][]byte) (string, error) {
+	dir, err := ioutil.TempDir("", "gitbe")
 	if err != nil {
-		return nil, err
+		return "", err
 	}
+	defer os.RemoveAll(dir)
+	index := filepath.Join(dir, "index")
 
-	if len(fa) == 0 {
-		return nil, fmt.Errorf("empty proposal")
+	// git read-tree parent
+	err = g.gitReadTree(repo, index, parent)
+	if err != nil {
+		return "", err
 	}
 
-	// Prevent duplicate filenames
-	for i := range files {
-		for j := range files {
-			if i == j {
-				continue
-			}
-			if files[i].Name == files[j].Name {
-				return nil, fmt.Errorf("duplicate filename %v",
-					files[i].Name)
-			}
+	// git rm --cached -r remove
+	for _, v := range remove {
+		err = g.gitRmCached(repo, index, v)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// Add files in a predictable order.
+	names := make([]string, 0, len(files))
+	for k := range files {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	blob := filepath.Join(dir, "blob")
+	for _, name := range names {
+		err = ioutil.WriteFile(blob, files[name], 0600)
+		if err != nil {
+			return "", err
+		}
+		digest, err := g.gitHashObject(repo, blob)
+		if err != nil {
+			return "", err
+		}
+		err = g.gitUpdateIndex(repo, index, digest, name)
+		if err != nil {
+			return "", err
 		}
 	}
 
+	tree, err := g.gitWriteTree(repo, index)
+	if err != nil {
+		return "", err
+	}
+	commit, err := g.gitCommitTree(repo, tree, parent, message)
+	if err != nil {
+		return "", err
+	}
+
+	old := parent
+	if create {
+		old = ""
+	}
+	err = g.gitUpdateRef(repo, "refs/heads/"+branch, commit, old)
+	if err != nil {
+		return "", err
+	}
+
+	return commit, nil
+}
+
+// proposalContent returns the payload files and the ProposalStorageRecord of
+// proposal id indexed by their path relative to the root of the repo.
+func proposalContent(id string, psr backend.ProposalStorageRecord, fa []file) (map[string][]byte, error) {
+	content := make(map[string][]byte, len(fa)+2)
+	for i := range fa {
+		content[id+"/"+defaultPayloadDir+"/"+fa[i].name] = fa[i].payload
+	}
+
+	b, err := encodePSR(psr)
+	if err != nil {
+		return nil, err
+	}
+	content[id+"/"+defaultProposalStorageRecordFilename] = b
+
+	return content, nil
+}
+
+// New takes a proposal verifies it and drops it on disk in the unvetted
+// directory.  Proposals and metadata are stored in unvetted/token/.  the
+// function returns a ProposaltorageRecord.  The optional user signature is
+// stored in unvetted/token/usersig.json.
+//
+// The proposal branch is created in a single atomic step without touching
+// the unvetted worktree.  A failure therefore leaves nothing behind and New
+// does not have to wait for operations on other proposals.
+//
+// New satisfies the backend interface.
+func (g *gitBackEnd) New(name string, files []backend.File, us *backend.UserSignature) (*backend.ProposalStorageRecord, error) {
+	fa, err := verifyProposal(files)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create a censorship token.
 	token, err := util.Random(32)
 	if err != nil {
@@ -967,90 +1228,228 @@ func (g *gitBackEnd) New(name string, files []backend.File) (*backend.ProposalSt
 	}
 	id := hex.EncodeToString(token)
 
-	// Lock filesystem
-	err = g.lock.Lock(LockDuration)
+	g.shutdownLock.RLock()
+	defer g.shutdownLock.RUnlock()
+	if g.shutdown {
+		return nil, backend.ErrShutdown
+	}
+
+	g.lockProposal(id)
+	defer g.unlockProposal(id)
+
+	// Branch off of unvetted master.  The branch is rebased onto vetted
+	// master when the proposal is published.
+	master, err := g.gitRevParse(g.unvetted, "master")
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		// XXX add git unwind in here too
-		err := g.lock.Unlock()
+
+	// Create Proposal Storage Record
+	hashes := make([]*[sha256.Size]byte, 0, len(fa))
+	for i := range fa {
+		var d [sha256.Size]byte
+		copy(d[:], fa[i].digest)
+		hashes = append(hashes, &d)
+	}
+	psr := backend.ProposalStorageRecord{
+		Name:      name,
+		Version:   1,
+		Status:    backend.PSRStatusUnvetted,
+		Merkle:    *merkle.Root(hashes),
+		Timestamp: time.Now().Unix(),
+		Token:     token,
+	}
+	content, err := proposalContent(id, psr, fa)
+	if err != nil {
+		return nil, err
+	}
+	if us != nil {
+		b, err := json.Marshal(*us)
 		if err != nil {
-			log.Errorf("Unlock error: %v", err)
+			return nil, err
 		}
-	}()
-	if g.shutdown {
-		return nil, backend.ErrShutdown
+		content[id+"/"+defaultUserSignatureFilename] = b
 	}
 
-	// git checkout master
-	err = g.gitCheckout(g.unvetted, "master")
+	// git checkout -b id; git add id; git commit -m "message"
+	_, err = g.commitBranch(g.unvetted, id, master, true,
+		"Add proposal "+id, nil, content)
 	if err != nil {
 		return nil, err
 	}
 
-	// git pull --ff-only --rebase
-	err = g.gitPull(g.unvetted, true)
+	return &psr, nil
+}
+
+// bumpPSR recalculates the merkle root of psr for the provided files and
+// bumps its version and timestamp.  It returns backend.ErrNoChanges if the
+// files are identical to the ones psr describes.
+func bumpPSR(psr *backend.ProposalStorageRecord, fa []file) error {
+	hashes := make([]*[sha256.Size]byte, 0, len(fa))
+	for i := range fa {
+		var d [sha256.Size]byte
+		copy(d[:], fa[i].digest)
+		hashes = append(hashes, &d)
+	}
+	root := merkle.Root(hashes)
+	if bytes.Equal(root[:], psr.Merkle[:]) {
+		return backend.ErrNoChanges
+	}
+
+	psr.Merkle = *root
+	psr.Version++
+	psr.Timestamp = time.Now().Unix()
+
+	return nil
+}
+
+// resetMaster forces master of repo back to head and removes everything a
+// failed commit left behind in the index and the worktree.
+//
+// This function must be called WITH the lock or the vetted lock held.
+func (g *gitBackEnd) resetMaster(repo, head string) error {
+	// git checkout -f -B master head
+	err := g.gitCheckoutReset(repo, "master", head)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	// git checkout -b id
-	err = g.gitNewBranch(g.unvetted, id)
+	// git clean -f -d
+	return g.gitClean(repo)
+}
+
+// updateProposal replaces the payload of proposal id in the provided repo
+// with the provided files.  The ProposalStorageRecord version is bumped, the
+// merkle root is recalculated and the result is committed.  The caller is
+// responsible for having master checked out.  If any step fails, master, the
+// index and the worktree are reset so that the partial update is not picked
+// up by the next commit, and psr is left untouched.
+//
+// This function must be called WITH the lock or the vetted lock held.
+func (g *gitBackEnd) updateProposal(repo, id string, psr *backend.ProposalStorageRecord, fa []file) error {
+	// Update Proposal Storage Record and bail if nothing changed.
+	updated := *psr
+	err := bumpPSR(&updated, fa)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	head, err := g.gitRevParse(repo, "master")
+	if err != nil {
+		return err
+	}
+	err = g.commitProposal(repo, id, &updated, fa)
+	if err != nil {
+		if rerr := g.resetMaster(repo, head); rerr != nil {
+			log.Errorf("updateProposal rollback %v: %v", id, rerr)
+		}
+		return err
+	}
+	*psr = updated
+
+	return nil
+}
+
+// commitProposal writes the payload and the ProposalStorageRecord of proposal
+// id to the worktree of repo and commits them.
+//
+// This function must be called WITH the lock or the vetted lock held.
+func (g *gitBackEnd) commitProposal(repo, id string, psr *backend.ProposalStorageRecord, fa []file) error {
+	// git rm -r id/payload
+	path := filepath.Join(repo, id, defaultPayloadDir)
+	err := g.gitRm(repo, path, true)
+	if err != nil {
+		return err
 	}
 
 	// Process files.
-	path := filepath.Join(g.unvetted, id, defaultPayloadDir)
 	err = os.MkdirAll(path, 0764)
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	hashes := make([]*[sha256.Size]byte, 0, len(fa))
 	for i := range fa {
 		// Copy files into directory id/payload/filename.
 		filename := filepath.Join(path, fa[i].name)
 		err = ioutil.WriteFile(filename, fa[i].payload, 0444)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		var d [sha256.Size]byte
-		copy(d[:], fa[i].digest)
-		hashes = append(hashes, &d)
 
 		// git add id/payload/filename
-		err = g.gitAdd(g.unvetted, filename)
+		err = g.gitAdd(repo, filename)
 		if err != nil {
-			return nil, err
+			return err
 		}
-
 	}
 
-	// Save Proposal Storage Record
-	psr, err := createPSR(g.unvetted, id, name, backend.PSRStatusUnvetted, 1,
-		hashes, token)
+	// Store Proposal Storage Record
+	err = updatePSR(repo, id, psr)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// git add id/psr.json
-	filename := filepath.Join(g.unvetted, id,
+	filename := filepath.Join(repo, id,
 		defaultProposalStorageRecordFilename)
-	err = g.gitAdd(g.unvetted, filename)
+	err = g.gitAdd(repo, filename)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// git commit -m "message"
-	err = g.gitCommit(path, "Add proposal "+id)
+	return g.gitCommit(repo, "Update proposal "+id)
+}
+
+// UpdateUnvettedRecord replaces the files of an unvetted proposal.  The files
+// are committed on top of the proposal branch and the updated
+// ProposalStorageRecord is returned.  Censored proposals can not be updated.
+//
+// UpdateUnvettedRecord satisfies the backend interface.
+func (g *gitBackEnd) UpdateUnvettedRecord(token []byte, files []backend.File) (*backend.ProposalStorageRecord, error) {
+	fa, err := verifyProposal(files)
 	if err != nil {
 		return nil, err
 	}
 
-	// git checkout master
-	err = g.gitCheckout(g.unvetted, "master")
+	g.shutdownLock.RLock()
+	defer g.shutdownLock.RUnlock()
+	if g.shutdown {
+		return nil, backend.ErrShutdown
+	}
+
+	id := hex.EncodeToString(token)
+	g.lockProposal(id)
+	defer g.unlockProposal(id)
+
+	// Load PSR
+	commit, err := g.proposalRef(g.unvetted, id)
+	if err != nil {
+		return nil, err
+	}
+	psr, err := g.loadPSRCommit(g.unvetted, commit, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only unvetted proposals can be updated
+	if psr.Status != backend.PSRStatusUnvetted {
+		return nil, backend.ErrInvalidTransition
+	}
+
+	// Update Proposal Storage Record and bail if nothing changed.
+	err = bumpPSR(psr, fa)
+	if err != nil {
+		return nil, err
+	}
+	content, err := proposalContent(id, *psr, fa)
+	if err != nil {
+		return nil, err
+	}
+
+	// git rm -r id/payload; git add id; git commit -m "message"
+	_, err = g.commitBranch(g.unvetted, id, commit, false,
+		"Update proposal "+id, []string{id + "/" + defaultPayloadDir},
+		content)
 	if err != nil {
 		return nil, err
 	}
@@ -1058,22 +1457,58 @@ func (g *gitBackEnd) New(name string, files []backend.File) (*backend.ProposalSt
 	return psr, nil
 }
 
-// getProposalLock is the generic implementation of GetUnvetted/GetVetted.  It
-// returns a proposal record from the provided repo.
+// UpdateVettedRecord replaces the files of a vetted proposal.  The files are
+// committed directly to the vetted repository and the updated
+// ProposalStorageRecord is returned.
 //
-// This function must be called WITHOUT the lock held.
-func (g *gitBackEnd) getProposalLock(token []byte, repo string, includeFiles bool) (*backend.ProposalRecord, error) {
-	// Lock filesystem
-	err := g.lock.Lock(LockDuration)
+// UpdateVettedRecord satisfies the backend interface.
+func (g *gitBackEnd) UpdateVettedRecord(token []byte, files []backend.File) (*backend.ProposalStorageRecord, error) {
+	fa, err := verifyProposal(files)
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		err := g.lock.Unlock()
-		if err != nil {
-			log.Errorf("Unlock error: %v", err)
-		}
-	}()
+
+	g.shutdownLock.RLock()
+	defer g.shutdownLock.RUnlock()
+	if g.shutdown {
+		return nil, backend.ErrShutdown
+	}
+
+	g.vettedLock.Lock()
+	defer g.vettedLock.Unlock()
+
+	id := hex.EncodeToString(token)
+	g.lockProposal(id)
+	defer g.unlockProposal(id)
+
+	// Load PSR
+	psr, err := loadPSR(g.vetted, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only vetted proposals live in the vetted repo but be paranoid
+	if psr.Status != backend.PSRStatusVetted {
+		return nil, backend.ErrInvalidTransition
+	}
+
+	err = g.updateProposal(g.vetted, id, psr, fa)
+	if err != nil {
+		return nil, err
+	}
+
+	return psr, nil
+}
+
+// getProposalLock is the generic implementation of GetUnvetted/GetVetted.  It
+// returns a proposal record from the provided repo.
+//
+// This function must be called WITHOUT the read lock held.
+func (g *gitBackEnd) getProposalLock(token []byte, repo string, includeFiles bool) (*backend.ProposalRecord, error) {
+	// Reads only touch git objects so they do not need the filesystem
+	// lock.
+	g.shutdownLock.RLock()
+	defer g.shutdownLock.RUnlock()
 	if g.shutdown {
 		return nil, backend.ErrShutdown
 	}
@@ -1082,28 +1517,23 @@ func (g *gitBackEnd) getProposalLock(token []byte, repo string, includeFiles boo
 }
 
 // getProposal is the generic implementation of GetUnvetted/GetVetted.  It
-// returns a proposal record from the provided repo.
+// returns a proposal record from the provided repo.  The record is read
+// straight out of git objects and therefore the worktree is never modified.
 //
-// This function must be called WITH the lock held.
+// This function must be called WITH the read lock held.
 func (g *gitBackEnd) getProposal(token []byte, repo string, includeFiles bool) (*backend.ProposalRecord, error) {
 	id := hex.EncodeToString(token)
-	if repo == g.unvetted {
-		// git checkout id
-		err := g.gitCheckout(repo, id)
-		if err != nil {
-			return nil, backend.ErrProposalNotFound
-		}
+	commit, err := g.proposalRef(repo, id)
+	if err != nil {
+		return nil, err
+	}
+	if !g.gitExists(repo, commit, id+"/"+
+		defaultProposalStorageRecordFilename) {
+		return nil, backend.ErrProposalNotFound
 	}
-	defer func() {
-		// git checkout master
-		err := g.gitCheckout(repo, "master")
-		if err != nil {
-			log.Errorf("could not switch to master: %v", err)
-		}
-	}()
 
 	// load PSR
-	psr, err := loadPSR(repo, id)
+	psr, err := g.loadPSRCommit(repo, commit, id)
 	if err != nil {
 		return nil, err
 	}
@@ -1111,15 +1541,20 @@ func (g *gitBackEnd) getProposal(token []byte, repo string, includeFiles bool) (
 	var files []backend.File
 	if includeFiles {
 		// load files
-		files, err = loadProposal(repo, id)
+		files, err = g.loadProposalCommit(repo, commit, id)
 		if err != nil {
 			return nil, err
 		}
 	}
+	us, err := g.loadUserSignatureCommit(repo, commit, id)
+	if err != nil {
+		return nil, err
+	}
 
 	return &backend.ProposalRecord{
 		ProposalStorageRecord: *psr,
 		Files: files,
+		UserSignature:         us,
 	}, nil
 }
 
@@ -1208,6 +1643,14 @@ func (g *gitBackEnd) fsck(path string) error {
 			if err != nil {
 				return err
 			}
+		} else if bytes.HasPrefix(key, []byte(InFlightKeyPrefix)) {
+			// Recovered before fsck runs.
+			continue
+		} else if string(key) == LastUnvettedAnchorKey ||
+			string(key) == UnconfirmedUnvettedKey ||
+			bytes.HasPrefix(key, []byte(UnvettedAnchorKeyPrefix)) {
+			// Unvetted anchors do not live in the vetted repo.
+			continue
 		} else {
 			anchor, err := DecodeAnchor(value)
 			if err != nil {
@@ -1294,255 +1737,1290 @@ func (g *gitBackEnd) fsck(path string) error {
 	return nil
 }
 
-// GetUnvetted checks out branch token and returns the content of
-// unvetted/token directory.
+// GetUnvetted returns the content of unvetted/token directory as it is
+// recorded in branch token.
 //
 // GetUnvetted satisfies the backend interface.
 func (g *gitBackEnd) GetUnvetted(token []byte) (*backend.ProposalRecord, error) {
 	return g.getProposalLock(token, g.unvetted, true)
 }
 
-// GetVetted returns the content of vetted/token directory.
+// GetVetted returns the content of vetted/token directory as it is recorded
+// in master.
 //
 // GetVetted satisfies the backend interface.
 func (g *gitBackEnd) GetVetted(token []byte) (*backend.ProposalRecord, error) {
 	return g.getProposalLock(token, g.vetted, true)
 }
 
-// SetUnvettedStatus tries to update the status for an unvetted proposal.  If
-// the proposal is found the prior status is returned if the function errors
-// out.  This is a bit unusual so keep it in mind.
+// proposalRef returns the digest of the commit that holds the latest state of
+// proposal id in the provided repo.  Unvetted proposals live in a branch
+// named after the token while vetted proposals live in master.  Resolving the
+// reference once lets callers read a consistent snapshot even if the
+// reference moves while they are reading.
 //
-// SetUnvettedStatus satisfies the backend interface.
-func (g *gitBackEnd) SetUnvettedStatus(token []byte, status backend.PSRStatusT) (backend.PSRStatusT, error) {
-	// Lock filesystem
-	err := g.lock.Lock(LockDuration)
-	if err != nil {
-		return backend.PSRStatusInvalid, err
-	}
-	defer func() {
-		err := g.lock.Unlock()
-		if err != nil {
-			log.Errorf("Unlock error: %v", err)
-		}
-	}()
-	if g.shutdown {
-		return backend.PSRStatusInvalid, backend.ErrShutdown
+// This function must be called WITH either the lock or the read lock held.
+func (g *gitBackEnd) proposalRef(repo, id string) (string, error) {
+	if repo != g.unvetted {
+		return g.gitRevParse(repo, "master")
 	}
 
-	// git checkout id
-	id := hex.EncodeToString(token)
-	err = g.gitCheckout(g.unvetted, id)
+	commit, err := g.gitRevParse(repo, "refs/heads/"+id)
 	if err != nil {
-		return backend.PSRStatusInvalid, backend.ErrProposalNotFound
+		return "", backend.ErrProposalNotFound
 	}
-	defer func() {
-		// git checkout master
-		err = g.gitCheckout(g.unvetted, "master")
-		if err != nil {
-			log.Errorf("could not switch to master: %v", err)
+
+	return commit, nil
+}
+
+// loadPSRCommit loads the ProposalStorageRecord of proposal id as it was
+// recorded in the provided commit.  This does not require a checkout.
+//
+// This function must be called WITH either the lock or the read lock held.
+func (g *gitBackEnd) loadPSRCommit(repo, commit, id string) (*backend.ProposalStorageRecord, error) {
+	b, err := g.gitShow(repo, commit,
+		id+"/"+defaultProposalStorageRecordFilename)
+	if err != nil {
+		return nil, err
+	}
+
+	var psr backend.ProposalStorageRecord
+	err = json.Unmarshal(b, &psr)
+	if err != nil {
+		return nil, err
+	}
+	return &psr, nil
+}
+
+// loadPSRsCommit loads the ProposalStorageRecords of the proposals in
+// commits, which maps the proposal id to the commit to read the record from.
+// Entries that are not proposals are skipped.  All records are read by a
+// single git process.  This does not require a checkout.
+//
+// This function must be called WITH either the lock or the read lock held.
+func (g *gitBackEnd) loadPSRsCommit(repo string, commits map[string]string) ([]backend.ProposalStorageRecord, error) {
+	objects := make([]string, 0, len(commits))
+	for id, commit := range commits {
+		if !util.IsDigest(id) {
+			continue
 		}
-	}()
+		objects = append(objects, commit+":"+id+"/"+
+			defaultProposalStorageRecordFilename)
+	}
+	if len(objects) == 0 {
+		return []backend.ProposalStorageRecord{}, nil
+	}
+	blobs, err := g.gitCatFileBatch(repo, objects)
+	if err != nil {
+		return nil, err
+	}
+
+	psrs := make([]backend.ProposalStorageRecord, 0, len(blobs))
+	for i, b := range blobs {
+		var psr backend.ProposalStorageRecord
+		err = json.Unmarshal(b, &psr)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", objects[i], err)
+		}
+		psrs = append(psrs, psr)
+	}
+	return psrs, nil
+}
+
+// loadUserSignatureCommit loads the UserSignature of proposal id as it was
+// recorded in the provided commit.  It returns nil if the proposal was not
+// signed by a user.  This does not require a checkout.
+//
+// This function must be called WITH either the lock or the read lock held.
+func (g *gitBackEnd) loadUserSignatureCommit(repo, commit, id string) (*backend.UserSignature, error) {
+	filename := id + "/" + defaultUserSignatureFilename
+	if !g.gitExists(repo, commit, filename) {
+		return nil, nil
+	}
+	b, err := g.gitShow(repo, commit, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var us backend.UserSignature
+	err = json.Unmarshal(b, &us)
+	if err != nil {
+		return nil, err
+	}
+	return &us, nil
+}
+
+// loadProposalCommit loads the payload of proposal id as it was recorded in
+// the provided commit.  It returns an array of backend.File that is
+// completely filled out.  This does not require a checkout.
+//
+// This function must be called WITH either the lock or the read lock held.
+func (g *gitBackEnd) loadProposalCommit(repo, commit, id string) ([]backend.File, error) {
+	dir := id + "/" + defaultPayloadDir
+	names, err := g.gitListTree(repo, commit, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	bf := make([]backend.File, 0, len(names))
+	for _, name := range names {
+		b, err := g.gitShow(repo, commit, dir+"/"+name)
+		if err != nil {
+			return nil, err
+		}
+
+		f := backend.File{Name: name}
+		f.MIME, f.Digest, f.Payload, err = util.LoadBytes(b)
+		if err != nil {
+			return nil, err
+		}
+		bf = append(bf, f)
+	}
+
+	return bf, nil
+}
+
+// proposalVersions returns every commit that modified the
+// ProposalStorageRecord of proposal id in the provided repo.  The versions
+// are returned newest first.
+//
+// This function must be called WITH either the lock or the read lock held.
+func (g *gitBackEnd) proposalVersions(repo, id string) ([]backend.ProposalVersion, error) {
+	ref, err := g.proposalRef(repo, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// git log --pretty=format:%H ref -- id/psr.json
+	commits, err := g.gitFileLog(repo, ref,
+		id+"/"+defaultProposalStorageRecordFilename)
+	if err != nil {
+		return nil, err
+	}
+	if len(commits) == 0 {
+		return nil, backend.ErrProposalNotFound
+	}
+
+	pv := make([]backend.ProposalVersion, 0, len(commits))
+	for _, commit := range commits {
+		digest, err := hex.DecodeString(commit)
+		if err != nil {
+			return nil, err
+		}
+		psr, err := g.loadPSRCommit(repo, commit, id)
+		if err != nil {
+			return nil, err
+		}
+		pv = append(pv, backend.ProposalVersion{
+			Digest:                digest,
+			ProposalStorageRecord: *psr,
+		})
+	}
+
+	return pv, nil
+}
+
+// proposalVersionsLock is the generic implementation of
+// UnvettedVersions/VettedVersions.
+//
+// This function must be called WITHOUT the read lock held.
+func (g *gitBackEnd) proposalVersionsLock(token []byte, repo string) ([]backend.ProposalVersion, error) {
+	// Reads only touch git objects so they do not need the filesystem
+	// lock.
+	g.shutdownLock.RLock()
+	defer g.shutdownLock.RUnlock()
+	if g.shutdown {
+		return nil, backend.ErrShutdown
+	}
+
+	return g.proposalVersions(repo, hex.EncodeToString(token))
+}
+
+// getProposalVersionLock is the generic implementation of
+// GetUnvettedVersion/GetVettedVersion.  It returns the proposal record as it
+// was at the last commit of the requested version.
+//
+// This function must be called WITHOUT the read lock held.
+func (g *gitBackEnd) getProposalVersionLock(token []byte, repo string, version uint) (*backend.ProposalRecord, error) {
+	// Reads only touch git objects so they do not need the filesystem
+	// lock.
+	g.shutdownLock.RLock()
+	defer g.shutdownLock.RUnlock()
+	if g.shutdown {
+		return nil, backend.ErrShutdown
+	}
+
+	id := hex.EncodeToString(token)
+	pv, err := g.proposalVersions(repo, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Versions are sorted newest first so the first hit is the final
+	// state of the requested version.
+	for _, v := range pv {
+		if v.ProposalStorageRecord.Version != version {
+			continue
+		}
+		commit := hex.EncodeToString(v.Digest)
+		files, err := g.loadProposalCommit(repo, commit, id)
+		if err != nil {
+			return nil, err
+		}
+		us, err := g.loadUserSignatureCommit(repo, commit, id)
+		if err != nil {
+			return nil, err
+		}
+		return &backend.ProposalRecord{
+			ProposalStorageRecord: v.ProposalStorageRecord,
+			Files:                 files,
+			UserSignature:         us,
+		}, nil
+	}
+
+	return nil, backend.ErrProposalNotFound
+}
+
+// GetUnvettedVersion returns the content of unvetted/token directory as it
+// was at the provided version.
+//
+// GetUnvettedVersion satisfies the backend interface.
+func (g *gitBackEnd) GetUnvettedVersion(token []byte, version uint) (*backend.ProposalRecord, error) {
+	return g.getProposalVersionLock(token, g.unvetted, version)
+}
+
+// GetVettedVersion returns the content of vetted/token directory as it was at
+// the provided version.
+//
+// GetVettedVersion satisfies the backend interface.
+func (g *gitBackEnd) GetVettedVersion(token []byte, version uint) (*backend.ProposalRecord, error) {
+	return g.getProposalVersionLock(token, g.vetted, version)
+}
+
+// UnvettedVersions returns the history of an unvetted proposal.  Every commit
+// in the proposal branch that modified the proposal storage record is
+// returned, newest first.
+//
+// UnvettedVersions satisfies the backend interface.
+func (g *gitBackEnd) UnvettedVersions(token []byte) ([]backend.ProposalVersion, error) {
+	return g.proposalVersionsLock(token, g.unvetted)
+}
+
+// VettedVersions returns the history of a vetted proposal.  Every commit in
+// master that modified the proposal storage record is returned, newest
+// first.  This includes the commits that were made while the proposal was
+// unvetted.
+//
+// VettedVersions satisfies the backend interface.
+func (g *gitBackEnd) VettedVersions(token []byte) ([]backend.ProposalVersion, error) {
+	return g.proposalVersionsLock(token, g.vetted)
+}
+
+// Timestamps returns the timestamp proofs of every commit that modified the
+// ProposalStorageRecord of a proposal, newest first.  Vetted proposals are
+// looked up in the vetted repo and all other proposals in the unvetted repo.
+// Commits in the vetted repo and on the unvetted proposal branches are
+// anchored separately.
+//
+// The anchored digest of a commit is its SHA1 digest extended to the size of
+// a SHA256 digest with zeros.
+//
+// Timestamps satisfies the backend interface.
+func (g *gitBackEnd) Timestamps(token []byte) ([]backend.Timestamp, error) {
+	// Reads only touch git objects and the database so they do not need
+	// the filesystem lock.
+	g.shutdownLock.RLock()
+	defer g.shutdownLock.RUnlock()
+	if g.shutdown {
+		return nil, backend.ErrShutdown
+	}
+
+	id := hex.EncodeToString(token)
+	pv, err := g.proposalVersions(g.vetted, id)
+	if err == backend.ErrProposalNotFound {
+		pv, err = g.proposalVersions(g.unvetted, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Index the anchors by the commits they contain.  The vetted and
+	// unvetted anchors are kept apart since their merkle roots live in
+	// different database records.
+	type anchorIndex struct {
+		key    [sha256.Size]byte
+		anchor *Anchor
+		vetted bool
+	}
+	vetted, err := g.readAnchorRecords()
+	if err != nil {
+		return nil, err
+	}
+	unvetted, err := g.readUnvettedAnchorRecords()
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]anchorIndex)
+	for key, anchor := range unvetted {
+		for _, d := range anchor.Digests {
+			index[hex.EncodeToString(d)] = anchorIndex{
+				key:    key,
+				anchor: anchor,
+			}
+		}
+	}
+	for key, anchor := range vetted {
+		for _, d := range anchor.Digests {
+			index[hex.EncodeToString(d)] = anchorIndex{
+				key:    key,
+				anchor: anchor,
+				vetted: true,
+			}
+		}
+	}
+	master, err := g.gitRevParse(g.vetted, "master")
+	if err != nil {
+		return nil, err
+	}
+
+	ts := make([]backend.Timestamp, 0, len(pv))
+	for _, v := range pv {
+		digest := extendSHA1(v.Digest)
+		t := backend.Timestamp{
+			Digest:  digest,
+			Version: v.ProposalStorageRecord.Version,
+			State:   backend.AnchorStateNotAnchored,
+		}
+		ai, ok := index[hex.EncodeToString(digest)]
+		if !ok {
+			ts = append(ts, t)
+			continue
+		}
+
+		// Prove that the commit is part of the anchor.
+		anchor := ai.anchor
+		leafs := make([]*[sha256.Size]byte, 0, len(anchor.Digests))
+		for _, d := range anchor.Digests {
+			var leaf [sha256.Size]byte
+			copy(leaf[:], d)
+			leafs = append(leafs, &leaf)
+		}
+		var leaf [sha256.Size]byte
+		copy(leaf[:], digest)
+		t.MerkleRoot = append([]byte{}, ai.key[:]...)
+		t.MerklePath = *merkle.AuthPath(leafs, &leaf)
+		t.State = backend.AnchorStateUnconfirmed
+		if anchor.Type != AnchorVerified {
+			ts = append(ts, t)
+			continue
+		}
+
+		// Prove that the anchor is part of the blockchain.  Older vetted
+		// anchors only have the chain information that was committed
+		// to the vetted repo when the anchor was confirmed.
+		t.State = backend.AnchorStateConfirmed
+		t.ChainTimestamp = anchor.ChainTimestamp
+		t.Transaction = anchor.Transaction
+		t.ChainMerklePath = anchor.ChainMerklePath
+		chainMerkleRoot := anchor.ChainMerkleRoot
+		if chainMerkleRoot == "" && ai.vetted {
+			b, err := g.gitShow(g.vetted, master,
+				defaultAnchorsDirectory+"/"+
+					hex.EncodeToString(ai.key[:]))
+			if err != nil {
+				return nil, err
+			}
+			var ci v1.ChainInformation
+			err = json.Unmarshal(b, &ci)
+			if err != nil {
+				return nil, err
+			}
+			t.ChainMerklePath = ci.MerklePath
+			chainMerkleRoot = ci.MerkleRoot
+		}
+		if chainMerkleRoot != "" {
+			t.ChainMerkleRoot, err = hex.DecodeString(chainMerkleRoot)
+			if err != nil {
+				return nil, err
+			}
+		}
+		ts = append(ts, t)
+	}
+
+	return ts, nil
+}
+
+// SetUnvettedStatus tries to update the status for an unvetted proposal.  If
+// the proposal is found the prior status is returned if the function errors
+// out.  This is a bit unusual so keep it in mind.  The optional reason is
+// stored in the ProposalStorageRecord and appended to the commit message.
+//
+// SetUnvettedStatus satisfies the backend interface.
+func (g *gitBackEnd) SetUnvettedStatus(token []byte, status backend.PSRStatusT, reason string) (backend.PSRStatusT, error) {
+	// Publishing moves the proposal across repos and therefore requires
+	// the global lock.  Everything else only touches the proposal branch.
+	if status == backend.PSRStatusVetted {
+		// Lock filesystem
+		err := g.lock.Lock(LockDuration)
+		if err != nil {
+			return backend.PSRStatusInvalid, err
+		}
+		defer func() {
+			err := g.lock.Unlock()
+			if err != nil {
+				log.Errorf("Unlock error: %v", err)
+			}
+		}()
+		if g.shutdown {
+			return backend.PSRStatusInvalid, backend.ErrShutdown
+		}
+
+		g.vettedLock.Lock()
+		defer g.vettedLock.Unlock()
+	} else {
+		g.shutdownLock.RLock()
+		defer g.shutdownLock.RUnlock()
+		if g.shutdown {
+			return backend.PSRStatusInvalid, backend.ErrShutdown
+		}
+	}
+
+	id := hex.EncodeToString(token)
+	g.lockProposal(id)
+	defer g.unlockProposal(id)
+
+	// Load PSR
+	commit, err := g.proposalRef(g.unvetted, id)
+	if err != nil {
+		return backend.PSRStatusInvalid, err
+	}
+	psr, err := g.loadPSRCommit(g.unvetted, commit, id)
+	if err != nil {
+		return backend.PSRStatusInvalid, err
+	}
+	oldStatus := psr.Status
+	psr.Reason = reason
+	psr.Timestamp = time.Now().Unix()
+
+	// We only allow a transition from unvetted to vetted, censored or
+	// withdrawn
+	switch {
+	case !backend.UnvettedTransition(psr.Status, status):
+		return oldStatus, backend.ErrInvalidTransition
+
+	case status == backend.PSRStatusVetted:
+		// unvetted -> vetted
+		err = g.publish(token, psr)
+
+	default:
+		// unvetted -> censored or withdrawn
+		err = g.setBranchStatus(id, commit, psr, status)
+	}
+	if err != nil {
+		return oldStatus, err
+	}
+
+	return psr.Status, nil
+}
+
+// SetVettedStatus moves a vetted proposal to a terminal status.  The proposal
+// remains in the vetted repo and the status change is committed to it so that
+// it ends up in the audit trail.  If the proposal is found the prior status is
+// returned if the function errors out.
+//
+// SetVettedStatus satisfies the backend interface.
+func (g *gitBackEnd) SetVettedStatus(token []byte, status backend.PSRStatusT, reason string) (backend.PSRStatusT, error) {
+	g.shutdownLock.RLock()
+	defer g.shutdownLock.RUnlock()
+	if g.shutdown {
+		return backend.PSRStatusInvalid, backend.ErrShutdown
+	}
+
+	g.vettedLock.Lock()
+	defer g.vettedLock.Unlock()
+
+	id := hex.EncodeToString(token)
+	g.lockProposal(id)
+	defer g.unlockProposal(id)
+
+	// Load PSR
+	psr, err := loadPSR(g.vetted, id)
+	if err != nil {
+		return backend.PSRStatusInvalid, err
+	}
+	oldStatus := psr.Status
+
+	// We only allow a transition from vetted to abandoned or archived
+	if !backend.VettedTransition(psr.Status, status) {
+		return oldStatus, backend.ErrInvalidTransition
+	}
+	psr.Status = status
+	psr.Version += 1
+	psr.Reason = reason
+	psr.Timestamp = time.Now().Unix()
+
+	// git add id/psr.json; git commit -m "message"
+	head, err := g.gitRevParse(g.vetted, "master")
+	if err != nil {
+		return oldStatus, err
+	}
+	err = updatePSR(g.vetted, id, psr)
+	if err == nil {
+		err = g.commitPSR(g.vetted, id,
+			statusMessage(backend.PSRStatus[status], reason))
+	}
+	if err != nil {
+		if rerr := g.resetMaster(g.vetted, head); rerr != nil {
+			log.Errorf("SetVettedStatus rollback %v: %v", id, rerr)
+		}
+		return oldStatus, err
+	}
+
+	return psr.Status, nil
+}
+
+// loadCommentsCommit loads the comments of proposal id as they were recorded in
+// the provided commit.  This does not require a checkout.
+//
+// This function must be called WITH either the lock or the read lock held.
+func (g *gitBackEnd) loadCommentsCommit(repo, commit, id string) ([]backend.Comment, error) {
+	filename := id + "/" + defaultCommentsFilename
+	if !g.gitExists(repo, commit, filename) {
+		return []backend.Comment{}, nil
+	}
+	b, err := g.gitShow(repo, commit, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	comments := make([]backend.Comment, 0, 64)
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	for decoder.More() {
+		var c backend.Comment
+		err = decoder.Decode(&c)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	return comments, nil
+}
+
+// AppendComments commits the comments of a vetted proposal that were not
+// stored before to the vetted repo and returns how many there were.  The
+// comments are anchored with the rest of the vetted repo.
+//
+// AppendComments satisfies the backend interface.
+func (g *gitBackEnd) AppendComments(token []byte, comments []backend.Comment) (uint, error) {
+	g.shutdownLock.RLock()
+	defer g.shutdownLock.RUnlock()
+	if g.shutdown {
+		return 0, backend.ErrShutdown
+	}
+
+	g.vettedLock.Lock()
+	defer g.vettedLock.Unlock()
+
+	id := hex.EncodeToString(token)
+	g.lockProposal(id)
+	defer g.unlockProposal(id)
+
+	// Only vetted proposals live in the vetted repo.
+	psr, err := loadPSR(g.vetted, id)
+	if err != nil {
+		return 0, err
+	}
+	if !backend.StatusVetted(psr.Status) {
+		return 0, backend.ErrProposalNotFound
+	}
+
+	// The committed comments are authoritative.
+	master, err := g.gitRevParse(g.vetted, "master")
+	if err != nil {
+		return 0, err
+	}
+	existing, err := g.loadCommentsCommit(g.vetted, master, id)
+	if err != nil {
+		return 0, err
+	}
+	fresh, err := backend.NewComments(existing, comments)
+	if err != nil {
+		return 0, err
+	}
+	if len(fresh) == 0 {
+		return 0, nil
+	}
+
+	err = g.commitComments(id, append(existing, fresh...),
+		fmt.Sprintf("Add %v comments to %v", len(fresh), id))
+	if err != nil {
+		if rerr := g.resetMaster(g.vetted, master); rerr != nil {
+			log.Errorf("AppendComments rollback %v: %v", id, rerr)
+		}
+		return 0, err
+	}
+
+	return uint(len(fresh)), nil
+}
+
+// CensorComment replaces a comment of a vetted proposal with a tombstone and
+// commits it to the vetted repo.  The comments that are served no longer
+// contain the text but the history of the vetted repo does; earlier commits
+// are anchored and can not be rewritten.
+//
+// CensorComment satisfies the backend interface.
+func (g *gitBackEnd) CensorComment(token []byte, commentID uint64, reason string) error {
+	g.shutdownLock.RLock()
+	defer g.shutdownLock.RUnlock()
+	if g.shutdown {
+		return backend.ErrShutdown
+	}
+
+	g.vettedLock.Lock()
+	defer g.vettedLock.Unlock()
+
+	id := hex.EncodeToString(token)
+	g.lockProposal(id)
+	defer g.unlockProposal(id)
+
+	// Only vetted proposals live in the vetted repo.
+	psr, err := loadPSR(g.vetted, id)
+	if err != nil {
+		return err
+	}
+	if !backend.StatusVetted(psr.Status) {
+		return backend.ErrProposalNotFound
+	}
+
+	master, err := g.gitRevParse(g.vetted, "master")
+	if err != nil {
+		return err
+	}
+	existing, err := g.loadCommentsCommit(g.vetted, master, id)
+	if err != nil {
+		return err
+	}
+	censored, changed, err := backend.CensorComment(existing, commentID,
+		reason)
+	if err != nil || !changed {
+		return err
+	}
+
+	err = g.commitComments(id, censored,
+		fmt.Sprintf("Censor comment %v of %v", commentID, id))
+	if err != nil {
+		if rerr := g.resetMaster(g.vetted, master); rerr != nil {
+			log.Errorf("CensorComment rollback %v: %v", id, rerr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// commitComments writes the comments of proposal id to the worktree of the
+// vetted repo and commits them.
+//
+// This function must be called WITH the vetted lock held.
+func (g *gitBackEnd) commitComments(id string, comments []backend.Comment, message string) error {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	for _, v := range comments {
+		err := encoder.Encode(v)
+		if err != nil {
+			return err
+		}
+	}
+	filename := filepath.Join(g.vetted, id, defaultCommentsFilename)
+	err := ioutil.WriteFile(filename, b.Bytes(), 0664)
+	if err != nil {
+		return err
+	}
+
+	// git add id/comments.json; git commit -m "message"
+	err = g.gitAdd(g.vetted, filename)
+	if err != nil {
+		return err
+	}
+	return g.gitCommit(g.vetted, message)
+}
+
+// GetComments returns the comments of a vetted proposal, oldest first.  The
+// comments are read straight out of git objects.
+//
+// GetComments satisfies the backend interface.
+func (g *gitBackEnd) GetComments(token []byte) ([]backend.Comment, error) {
+	g.shutdownLock.RLock()
+	defer g.shutdownLock.RUnlock()
+	if g.shutdown {
+		return nil, backend.ErrShutdown
+	}
+
+	id := hex.EncodeToString(token)
+	master, err := g.gitRevParse(g.vetted, "master")
+	if err != nil {
+		return nil, err
+	}
+	if !g.gitExists(g.vetted, master, id+"/"+
+		defaultProposalStorageRecordFilename) {
+		return nil, backend.ErrProposalNotFound
+	}
+
+	return g.loadCommentsCommit(g.vetted, master, id)
+}
+
+// publish moves proposal token from the unvetted repo into the vetted repo.
+// The transition is recorded in the database before the repos are touched so
+// that it can be finished or rolled back after a crash.  If the transition
+// fails it is rolled back right away.
+//
+// This function must be called WITH the lock, the vetted lock and the
+// proposal lock held.
+func (g *gitBackEnd) publish(token []byte, psr *backend.ProposalStorageRecord) error {
+	// Record the state of both repos before touching them so that an
+	// interrupted transition can be finished or rolled back.
+	id := hex.EncodeToString(token)
+	inFlight, err := g.beginTransition(token, backend.PSRStatusVetted)
+	if err != nil {
+		return err
+	}
+
+	err = g.publishRepos(id, psr)
+	if err != nil {
+		rerr := g.restoreRepoState(inFlight)
+		if rerr != nil {
+			// Keep the in flight record around so that the
+			// rollback is retried by the next attempt or at
+			// startup.
+			log.Errorf("publish rollback %v: %v", id, rerr)
+			return err
+		}
+		derr := g.deleteInFlightRecord(token)
+		if derr != nil {
+			log.Errorf("publish delete in flight %v: %v", id, derr)
+		}
+		return err
+	}
+
+	// The transition is complete.  Should the delete fail the recovery
+	// code will find the transition completed and simply clean up.
+	err = g.deleteInFlightRecord(token)
+	if err != nil {
+		log.Errorf("publish delete in flight %v: %v", id, err)
+	}
+
+	return nil
+}
+
+// beginTransition records the current heads of branch token and of both
+// masters in the database.  The record must be deleted once the transition
+// completed or was rolled back.  A record that was left behind by a prior
+// transition whose rollback failed is recovered first.
+//
+// This function must be called WITH the lock held.
+func (g *gitBackEnd) beginTransition(token []byte, status backend.PSRStatusT) (*InFlight, error) {
+	prior, err := g.readInFlightRecord(token)
+	if err != nil {
+		return nil, err
+	}
+	if prior != nil {
+		committed, err := g.recoverTransition(prior)
+		if err != nil {
+			return nil, fmt.Errorf("recover %x: %v", token, err)
+		}
+		if committed {
+			// The status changed after all.
+			return nil, backend.ErrInvalidTransition
+		}
+	}
+
+	id := hex.EncodeToString(token)
+	inFlight := InFlight{
+		Token:  token,
+		Status: status,
+		Time:   time.Now().Unix(),
+	}
+	inFlight.Branch, err = g.gitRevParse(g.unvetted, "refs/heads/"+id)
+	if err != nil {
+		return nil, err
+	}
+	inFlight.UnvettedMaster, err = g.gitRevParse(g.unvetted, "master")
+	if err != nil {
+		return nil, err
+	}
+	inFlight.VettedMaster, err = g.gitRevParse(g.vetted, "master")
+	if err != nil {
+		return nil, err
+	}
 
-	// Load PSR
-	psr, err := loadPSR(g.unvetted, id)
+	err = g.writeInFlightRecord(inFlight)
 	if err != nil {
-		return backend.PSRStatusInvalid, err
+		return nil, err
 	}
-	oldStatus := psr.Status
 
-	// We only allow a transition from unvetted to vetted or censored
-	switch {
-	case psr.Status == backend.PSRStatusUnvetted &&
-		status == backend.PSRStatusVetted:
+	return &inFlight, nil
+}
 
-		// unvetted -> vetted
+// restoreRepoState undoes a partially completed status transition.  Both
+// masters and the proposal branch are forced back to the recorded heads, the
+// worktrees are cleaned, the proposal branch is removed from the vetted repo
+// along with its remote tracking ref in the unvetted repo and unvetted is left
+// on master.
+//
+// This function must be called WITH the lock held.
+func (g *gitBackEnd) restoreRepoState(inFlight *InFlight) error {
+	id := hex.EncodeToString(inFlight.Token)
 
-		// Update PSR first
-		psr.Status = backend.PSRStatusVetted
-		psr.Version += 1
-		err = updatePSR(g.unvetted, id, psr)
-		if err != nil {
-			return oldStatus, err
-		}
+	// A failed rebase leaves the repo mid-rebase.  Aborting errors out
+	// when there is no rebase in progress, which is the common case.
+	if g.gitRebaseAbort(g.vetted) == nil {
+		log.Infof("Aborted rebase in vetted repo")
+	}
+	if g.gitRebaseAbort(g.unvetted) == nil {
+		log.Infof("Aborted rebase in unvetted repo")
+	}
 
-		// Commit psr
-		err = g.commitPSR(g.unvetted, id, "published")
-		if err != nil {
-			return oldStatus, err
-		}
-
-		// on unvetted repo:
-		//     git checkout master
-		//     git pull --ff--only --rebase
-		//     git checkout id
-		//     git rebase master
-		//     git push --set-upstream origin id
-		// on vetted repo:
-		//     git rebase id
-		//     git branch -D id
-		// on unvetted repo:
-		//     git checkout master
-		//     git branch -D id
-		//     git pull --ff-only
-
-		//
-		// UNVETTED REPO CREATE PR
-		//
-		// git checkout master
-		err = g.gitCheckout(g.unvetted, "master")
-		if err != nil {
-			return oldStatus, err
-		}
+	//
+	// VETTED REPO
+	//
 
-		// git pull --ff-only --rebase
-		err = g.gitPull(g.unvetted, true)
-		if err != nil {
-			return oldStatus, err
-		}
+	// git checkout -f -B master vettedMaster
+	err := g.gitCheckoutReset(g.vetted, "master", inFlight.VettedMaster)
+	if err != nil {
+		return err
+	}
+	err = g.gitClean(g.vetted)
+	if err != nil {
+		return err
+	}
 
-		// git checkout id
-		err = g.gitCheckout(g.unvetted, id)
+	// git branch -D id
+	heads, err := g.gitBranchHeads(g.vetted)
+	if err != nil {
+		return err
+	}
+	if _, ok := heads[id]; ok {
+		err = g.gitBranchDelete(g.vetted, id)
 		if err != nil {
-			return oldStatus, backend.ErrProposalNotFound
+			return err
 		}
+	}
 
-		// git rebase master
-		err = g.gitRebase(g.unvetted, "master")
-		if err != nil {
-			return oldStatus, err
-		}
+	//
+	// UNVETTED REPO
+	//
 
-		// git push --set-upstream origin id
-		err = g.gitPush(g.unvetted, "origin", id, true)
-		if err != nil {
-			return oldStatus, err
-		}
+	// git checkout -f -B id branch
+	err = g.gitCheckoutReset(g.unvetted, id, inFlight.Branch)
+	if err != nil {
+		return err
+	}
 
-		//
-		// VETTED REPO REPLAY BRANCH
-		//
+	// Pushing the branch to vetted created a remote tracking ref and
+	// pulling moved the one of master.  Vetted master was restored above.
+	// git update-ref -d refs/remotes/origin/id
+	err = g.gitDeleteRef(g.unvetted, "refs/remotes/origin/"+id)
+	if err != nil {
+		return err
+	}
+	// git update-ref refs/remotes/origin/master vettedMaster
+	err = g.gitSetRef(g.unvetted, "refs/remotes/origin/master",
+		inFlight.VettedMaster)
+	if err != nil {
+		return err
+	}
 
-		// git rebase id
-		err = g.gitRebase(g.vetted, id)
-		if err != nil {
-			return oldStatus, err
-		}
+	// git checkout -f -B master unvettedMaster
+	err = g.gitCheckoutReset(g.unvetted, "master", inFlight.UnvettedMaster)
+	if err != nil {
+		return err
+	}
 
-		// git branch -D id
-		err = g.gitBranchDelete(g.vetted, id)
-		if err != nil {
-			return oldStatus, err
-		}
+	return g.gitClean(g.unvetted)
+}
 
-		//
-		// UNVETTED REPO SYNC
-		//
+// transitionCommitted returns true if the interrupted transition described by
+// inFlight made it to the point where the new status is recorded in the
+// repo that serves it.
+//
+// This function must be called WITH the lock held.
+func (g *gitBackEnd) transitionCommitted(inFlight *InFlight) (bool, error) {
+	id := hex.EncodeToString(inFlight.Token)
 
-		// git checkout master
-		err = g.gitCheckout(g.unvetted, "master")
-		if err != nil {
-			return oldStatus, err
-		}
+	repo, before := g.unvetted, inFlight.Branch
+	ref := "refs/heads/" + id
+	if inFlight.Status == backend.PSRStatusVetted {
+		repo, before = g.vetted, inFlight.VettedMaster
+		ref = "master"
+	}
 
-		// git pull --ff-only --rebase
-		err = g.gitPull(g.unvetted, true)
+	head, err := g.gitRevParse(repo, ref)
+	if err != nil || head == before {
+		return false, nil
+	}
+	if !g.gitExists(repo, head, id+"/"+
+		defaultProposalStorageRecordFilename) {
+		return false, nil
+	}
+	psr, err := g.loadPSRCommit(repo, head, id)
+	if err != nil {
+		return false, err
+	}
+
+	return psr.Status == inFlight.Status, nil
+}
+
+// finishTransition completes the cleanup portion of a transition that was
+// interrupted after it committed.  It leaves both repos clean and on master,
+// syncs unvetted with vetted and removes the proposal branches of published
+// proposals.
+//
+// This function must be called WITH the lock held.
+func (g *gitBackEnd) finishTransition(inFlight *InFlight) error {
+	id := hex.EncodeToString(inFlight.Token)
+
+	// Aborting errors out when there is no rebase in progress.
+	if g.gitRebaseAbort(g.vetted) == nil {
+		log.Infof("Aborted rebase in vetted repo")
+	}
+	if g.gitRebaseAbort(g.unvetted) == nil {
+		log.Infof("Aborted rebase in unvetted repo")
+	}
+
+	for _, repo := range []string{g.vetted, g.unvetted} {
+		err := g.gitCheckoutForce(repo, "master")
 		if err != nil {
-			return oldStatus, err
+			return err
 		}
-
-		// git branch -D id
-		err = g.gitBranchDelete(g.unvetted, id)
+		err = g.gitClean(repo)
 		if err != nil {
-			return oldStatus, err
+			return err
 		}
+	}
+
+	if inFlight.Status != backend.PSRStatusVetted {
+		return nil
+	}
+
+	// git pull --ff-only --rebase
+	err := g.gitPull(g.unvetted, true)
+	if err != nil {
+		return err
+	}
 
-	case psr.Status == backend.PSRStatusUnvetted &&
-		status == backend.PSRStatusCensored:
-		// unvetted -> censored
-		psr.Status = backend.PSRStatusCensored
-		psr.Version += 1
-		err = updatePSR(g.unvetted, id, psr)
+	// git branch -D id
+	for _, repo := range []string{g.vetted, g.unvetted} {
+		heads, err := g.gitBranchHeads(repo)
 		if err != nil {
-			return oldStatus, err
+			return err
 		}
-
-		// Commit psr
-		err = g.commitPSR(g.unvetted, id, "censored")
+		if _, ok := heads[id]; !ok {
+			continue
+		}
+		err = g.gitBranchDelete(repo, id)
 		if err != nil {
-			return oldStatus, err
+			return err
 		}
-	default:
-		return oldStatus, backend.ErrInvalidTransition
 	}
 
-	return psr.Status, nil
+	return nil
 }
 
-// Inventory returns an inventory of vetted and unvetted proposals.  If
-// includeFiles is set the content is also returned.
-func (g *gitBackEnd) Inventory(vettedCount, branchCount uint, includeFiles bool) ([]backend.ProposalRecord, []backend.ProposalRecord, error) {
-	// Lock filesystem
-	err := g.lock.Lock(LockDuration)
+// recoverTransitions finishes or rolls back every status transition that was
+// interrupted by a crash.  Transitions that committed are finished, all
+// others are rolled back.
+//
+// This function must be called WITH the lock held.
+func (g *gitBackEnd) recoverTransitions() error {
+	records, err := g.readInFlightRecords()
 	if err != nil {
-		return nil, nil, err
+		return err
 	}
-	defer func() {
-		err := g.lock.Unlock()
+
+	for i := range records {
+		_, err = g.recoverTransition(&records[i])
 		if err != nil {
-			log.Errorf("Unlock error: %v", err)
+			return fmt.Errorf("recover %x: %v", records[i].Token,
+				err)
 		}
-	}()
+	}
+
+	return nil
+}
+
+// recoverTransition finishes the transition described by inFlight if it
+// committed and rolls it back otherwise.  The record is deleted once the
+// repos are consistent again.  It returns true if the transition committed.
+//
+// This function must be called WITH the lock held.
+func (g *gitBackEnd) recoverTransition(inFlight *InFlight) (bool, error) {
+	id := hex.EncodeToString(inFlight.Token)
+	committed, err := g.transitionCommitted(inFlight)
+	if err != nil {
+		return false, err
+	}
+	if committed {
+		log.Infof("Finishing interrupted transition of %v to %v",
+			id, backend.PSRStatus[inFlight.Status])
+		err = g.finishTransition(inFlight)
+	} else {
+		log.Infof("Rolling back interrupted transition of %v to %v",
+			id, backend.PSRStatus[inFlight.Status])
+		err = g.restoreRepoState(inFlight)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return committed, g.deleteInFlightRecord(inFlight.Token)
+}
+
+// publishRepos moves proposal id from the unvetted repo into the vetted repo.
+// On failure the repos may be left in an intermediate state that must be
+// undone with restoreRepoState.
+//
+// This function must be called WITH the lock, the vetted lock and the
+// proposal lock held.
+func (g *gitBackEnd) publishRepos(id string, psr *backend.ProposalStorageRecord) error {
+	// git checkout -f id
+	err := g.gitCheckoutForce(g.unvetted, id)
+	if err != nil {
+		return err
+	}
+
+	// Update PSR first
+	psr.Status = backend.PSRStatusVetted
+	psr.Version += 1
+	err = updatePSR(g.unvetted, id, psr)
+	if err != nil {
+		return err
+	}
+
+	// Commit psr
+	err = g.commitPSR(g.unvetted, id, statusMessage("published",
+		psr.Reason))
+	if err != nil {
+		return err
+	}
+
+	// on unvetted repo:
+	//     git checkout master
+	//     git pull --ff--only --rebase
+	//     git checkout id
+	//     git rebase master
+	//     git push --set-upstream origin id
+	// on vetted repo:
+	//     git rebase id
+	//     git branch -D id
+	// on unvetted repo:
+	//     git checkout master
+	//     git branch -D id
+	//     git pull --ff-only
+
+	//
+	// UNVETTED REPO CREATE PR
+	//
+	// git checkout master
+	err = g.gitCheckout(g.unvetted, "master")
+	if err != nil {
+		return err
+	}
+
+	// git pull --ff-only --rebase
+	err = g.gitPull(g.unvetted, true)
+	if err != nil {
+		return err
+	}
+
+	// git checkout id
+	err = g.gitCheckout(g.unvetted, id)
+	if err != nil {
+		return backend.ErrProposalNotFound
+	}
+
+	// git rebase master
+	err = g.gitRebase(g.unvetted, "master")
+	if err != nil {
+		return err
+	}
+
+	// git push --set-upstream origin id
+	err = g.gitPush(g.unvetted, "origin", id, true)
+	if err != nil {
+		return err
+	}
+
+	//
+	// VETTED REPO REPLAY BRANCH
+	//
+
+	// git rebase id
+	err = g.gitRebase(g.vetted, id)
+	if err != nil {
+		return err
+	}
+
+	// git branch -D id
+	err = g.gitBranchDelete(g.vetted, id)
+	if err != nil {
+		return err
+	}
+
+	//
+	// UNVETTED REPO SYNC
+	//
+
+	// git checkout master
+	err = g.gitCheckout(g.unvetted, "master")
+	if err != nil {
+		return err
+	}
+
+	// git pull --ff-only --rebase
+	err = g.gitPull(g.unvetted, true)
+	if err != nil {
+		return err
+	}
+
+	// git branch -D id
+	err = g.gitBranchDelete(g.unvetted, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// statusMessage returns the commit message of a status change.  The reason,
+// if any, becomes the body of the message.
+func statusMessage(subject, reason string) string {
+	if reason == "" {
+		return subject
+	}
+	return subject + "\n\n" + reason
+}
+
+// setBranchStatus moves unvetted proposal id to a status that keeps it on its
+// branch, i.e. censored or withdrawn.  Commit is the current head of the
+// proposal branch.  The updated ProposalStorageRecord is committed in a single
+// atomic step so there is nothing to roll back on failure.
+//
+// This function must be called WITH the proposal lock held.
+func (g *gitBackEnd) setBranchStatus(id, commit string, psr *backend.ProposalStorageRecord, status backend.PSRStatusT) error {
+	psr.Status = status
+	psr.Version += 1
+	b, err := encodePSR(*psr)
+	if err != nil {
+		return err
+	}
+
+	// git add id/psr.json; git commit -m "message"
+	_, err = g.commitBranch(g.unvetted, id, commit, false,
+		statusMessage("Update proposal status "+id+" "+
+			backend.PSRStatus[status], psr.Reason), nil,
+		map[string][]byte{
+			id + "/" + defaultProposalStorageRecordFilename: b,
+		})
+	return err
+}
+
+// Inventory returns a page of vetted and a page of unvetted proposals.  The
+// proposal storage records are read straight out of git objects, without
+// taking the filesystem lock, and the files are only loaded for the records
+// that are returned.  If includeFiles is set the content and the user
+// signature are also returned.
+//
+// Inventory satisfies the backend interface.
+func (g *gitBackEnd) Inventory(vetted, branches backend.InventoryRequest, includeFiles bool) ([]backend.ProposalRecord, []backend.ProposalRecord, error) {
+	// Reads only touch git objects so they do not need the filesystem
+	// lock.
+	g.shutdownLock.RLock()
+	defer g.shutdownLock.RUnlock()
 	if g.shutdown {
 		return nil, nil, backend.ErrShutdown
 	}
 
-	// Walk vetted, we can simply take the vetted directory and sort the
-	// entries by time.
-	files, err := ioutil.ReadDir(g.vetted)
+	// Resolve the unvetted branches before master.  A proposal that is
+	// published while we are reading may therefore show up in both lists
+	// but it is never missing from both.
+	heads, err := g.gitBranchHeads(g.unvetted)
+	if err != nil {
+		return nil, nil, err
+	}
+	master, err := g.gitRevParse(g.vetted, "master")
 	if err != nil {
 		return nil, nil, err
 	}
 
-	// Strip non proposal directories
-	pr := make([]backend.ProposalRecord, 0, len(files))
-	for _, v := range files {
-		id := v.Name()
-		if !util.IsDigest(id) {
-			continue
-		}
-
-		ids, err := hex.DecodeString(id)
-		if err != nil {
-			return nil, nil, err
-		}
-		prv, err := g.getProposal(ids, g.vetted, includeFiles)
-		if err != nil {
-			return nil, nil, err
-		}
-		pr = append(pr, *prv)
+	// Walk vetted and read the proposal storage records out of master.
+	ids, err := g.gitListTree(g.vetted, master, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	commits := make(map[string]string, len(ids))
+	for _, id := range ids {
+		commits[id] = master
+	}
+	vpsrs, err := g.loadPSRsCommit(g.vetted, commits)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// Walk Branches on unvetted
-	branches, err := g.gitBranches(g.unvetted)
+	// Walk branches on unvetted and read the proposal storage record
+	// straight out of the branch head.
+	bpsrs, err := g.loadPSRsCommit(g.unvetted, heads)
 	if err != nil {
 		return nil, nil, err
 	}
-	br := make([]backend.ProposalRecord, 0, len(branches))
-	for _, id := range branches {
-		if !util.IsDigest(id) {
-			continue
-		}
 
-		ids, err := hex.DecodeString(id)
-		if err != nil {
-			return nil, nil, err
+	// Select pages and load files if requested.
+	pr := make([]backend.ProposalRecord, 0, len(vpsrs))
+	for _, psr := range backend.InventoryPage(vpsrs, vetted) {
+		var (
+			files []backend.File
+			us    *backend.UserSignature
+		)
+		if includeFiles {
+			id := hex.EncodeToString(psr.Token)
+			files, err = g.loadProposalCommit(g.vetted, master, id)
+			if err != nil {
+				return nil, nil, err
+			}
+			us, err = g.loadUserSignatureCommit(g.vetted, master, id)
+			if err != nil {
+				return nil, nil, err
+			}
 		}
-		pru, err := g.getProposal(ids, g.unvetted, includeFiles)
-		if err != nil {
-			return nil, nil, err
+		pr = append(pr, backend.ProposalRecord{
+			ProposalStorageRecord: psr,
+			Files:                 files,
+			UserSignature:         us,
+		})
+	}
+	br := make([]backend.ProposalRecord, 0, len(bpsrs))
+	for _, psr := range backend.InventoryPage(bpsrs, branches) {
+		var (
+			files []backend.File
+			us    *backend.UserSignature
+		)
+		if includeFiles {
+			id := hex.EncodeToString(psr.Token)
+			files, err = g.loadProposalCommit(g.unvetted,
+				heads[id], id)
+			if err != nil {
+				return nil, nil, err
+			}
+			us, err = g.loadUserSignatureCommit(g.unvetted,
+				heads[id], id)
+			if err != nil {
+				return nil, nil, err
+			}
 		}
-		br = append(br, *pru)
+		br = append(br, backend.ProposalRecord{
+			ProposalStorageRecord: psr,
+			Files:                 files,
+			UserSignature:         us,
+		})
 	}
 
 	return pr, br, nil
@@ -1566,7 +3044,16 @@ func (g *gitBackEnd) Close() {
 		}
 	}()
 
+	if g.shutdown {
+		// Already closed.
+		return
+	}
+
+	// Wait for readers to drain.
+	g.shutdownLock.Lock()
 	g.shutdown = true
+	g.shutdownLock.Unlock()
+
 	close(g.exit)
 	g.db.Close()
 }
@@ -1617,6 +3104,12 @@ func (g *gitBackEnd) newLocked() error {
 		return err
 	}
 
+	// Recover interrupted status transitions
+	err = g.recoverTransitions()
+	if err != nil {
+		return err
+	}
+
 	// Fsck _o/
 	log.Infof("Running git fsck on vetted repository")
 	_, err = g.gitFsck(g.vetted)
@@ -1651,6 +3144,7 @@ func New(root, dcrtimeHost, gitPath string, gitTrace bool) (*gitBackEnd, error)
 		exit:        make(chan struct{}),
 		checkAnchor: make(chan struct{}),
 		testAnchors: make(map[string]bool),
+		tokenLocks:  make(map[string]*proposalLock),
 	}
 
 	err := g.newLocked()
@@ -14,14 +14,17 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
-	"github.com/btcsuite/btclog"
 	"github.com/davecgh/go-spew/spew"
+	"github.com/decred/dcrtime/merkle"
 	"github.com/decred/politeia/politeiad/backend"
+	"github.com/decred/politeia/politeiad/backend/backendtest"
 	"github.com/decred/politeia/util"
 )
 
@@ -54,9 +57,6 @@ func TestExtendUnextend(t *testing.T) {
 }
 
 func TestAnchorWithCommits(t *testing.T) {
-	log := btclog.NewBackend(&testWriter{t}).Logger("TEST")
-	UseLogger(log)
-
 	dir, err := ioutil.TempDir("", "politeia.test")
 	if err != nil {
 		t.Fatal(err)
@@ -69,6 +69,7 @@ func TestAnchorWithCommits(t *testing.T) {
 		t.Fatal(err)
 	}
 	g.test = true
+	defer g.Close()
 
 	// Create 5 unvetted proposals
 	propCount := 5
@@ -100,7 +101,7 @@ func TestAnchorWithCommits(t *testing.T) {
 		}
 		allFiles[i] = files
 
-		psr[i], err = g.New(name, files)
+		psr[i], err = g.New(name, files, nil)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -161,7 +162,8 @@ func TestAnchorWithCommits(t *testing.T) {
 
 	// Vet 1 of the proposals
 	t.Logf("===== VET PROP 1 =====")
-	status, err := g.SetUnvettedStatus(psr[1].Token, backend.PSRStatusVetted)
+	status, err := g.SetUnvettedStatus(psr[1].Token,
+		backend.PSRStatusVetted, "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -338,7 +340,7 @@ func TestAnchorWithCommits(t *testing.T) {
 
 	// Vet + anchor
 	t.Logf("===== INTERLEAVE ANCHORS =====")
-	_, err = g.SetUnvettedStatus(psr[2].Token, backend.PSRStatusVetted)
+	_, err = g.SetUnvettedStatus(psr[2].Token, backend.PSRStatusVetted, "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -348,7 +350,7 @@ func TestAnchorWithCommits(t *testing.T) {
 	}
 
 	// Vet + anchor
-	_, err = g.SetUnvettedStatus(psr[0].Token, backend.PSRStatusVetted)
+	_, err = g.SetUnvettedStatus(psr[0].Token, backend.PSRStatusVetted, "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -374,3 +376,1419 @@ func TestAnchorWithCommits(t *testing.T) {
 
 func TestDcrtimeFsck(t *testing.T) {
 }
+
+func TestUpdateRecords(t *testing.T) {
+	dir, err := ioutil.TempDir("", "politeia.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	g, err := New(dir, "", "", testing.Verbose())
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.test = true
+	defer g.Close()
+
+	// Create two unvetted proposals
+	psr, err := g.New("update", backendtest.TextFiles(t, "update", 2), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	psrCensor, err := g.New("censor", backendtest.TextFiles(t, "censor", 2), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Update unvetted
+	t.Logf("===== UPDATE UNVETTED =====")
+	files := backendtest.TextFiles(t, "updated", 3)
+	upsr, err := g.UpdateUnvettedRecord(psr.Token, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upsr.Version != psr.Version+1 {
+		t.Fatalf("unexpected version got %v wanted %v", upsr.Version,
+			psr.Version+1)
+	}
+	if upsr.Merkle == psr.Merkle {
+		t.Fatalf("merkle root was not updated")
+	}
+	pru, err := g.GetUnvetted(psr.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&pru.ProposalStorageRecord, upsr) {
+		t.Fatalf("unexpected psr got %v, wanted %v",
+			spew.Sdump(pru.ProposalStorageRecord), spew.Sdump(upsr))
+	}
+	if !reflect.DeepEqual(pru.Files, files) {
+		t.Fatalf("unexpected payload got %v, wanted %v",
+			spew.Sdump(pru.Files), spew.Sdump(files))
+	}
+
+	// Same files again
+	_, err = g.UpdateUnvettedRecord(psr.Token, files)
+	if err != backend.ErrNoChanges {
+		t.Fatalf("expected ErrNoChanges, got %v", err)
+	}
+
+	// Censored proposals can't be updated
+	_, err = g.SetUnvettedStatus(psrCensor.Token,
+		backend.PSRStatusCensored, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = g.UpdateUnvettedRecord(psrCensor.Token, files)
+	if err != backend.ErrInvalidTransition {
+		t.Fatalf("expected ErrInvalidTransition, got %v", err)
+	}
+
+	// Vetted proposals aren't unvetted anymore
+	t.Logf("===== UPDATE VETTED =====")
+	_, err = g.UpdateVettedRecord(psr.Token, files)
+	if err != backend.ErrProposalNotFound {
+		t.Fatalf("expected ErrProposalNotFound, got %v", err)
+	}
+	_, err = g.SetUnvettedStatus(psr.Token, backend.PSRStatusVetted, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = g.UpdateUnvettedRecord(psr.Token, files)
+	if err != backend.ErrProposalNotFound {
+		t.Fatalf("expected ErrProposalNotFound, got %v", err)
+	}
+	files = backendtest.TextFiles(t, "vetted", 1)
+	vpsr, err := g.UpdateVettedRecord(psr.Token, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vpsr.Version != upsr.Version+2 {
+		t.Fatalf("unexpected version got %v wanted %v", vpsr.Version,
+			upsr.Version+2)
+	}
+	prv, err := g.GetVetted(psr.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&prv.ProposalStorageRecord, vpsr) {
+		t.Fatalf("unexpected psr got %v, wanted %v",
+			spew.Sdump(prv.ProposalStorageRecord), spew.Sdump(vpsr))
+	}
+	if !reflect.DeepEqual(prv.Files, files) {
+		t.Fatalf("unexpected payload got %v, wanted %v",
+			spew.Sdump(prv.Files), spew.Sdump(files))
+	}
+
+	// Make sure the next publish still works on top of the vetted update
+	psr2, err := g.New("after", backendtest.TextFiles(t, "after", 1), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = g.SetUnvettedStatus(psr2.Token, backend.PSRStatusVetted, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Tests walking the inventory page by page while records that were already
+// returned are updated, and that a count returns the most recently updated
+// records.
+func TestInventoryPages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "politeia.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	g, err := New(dir, "", "", testing.Verbose())
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.test = true
+	defer g.Close()
+
+	// inventory returns the vetted or the branches listing.
+	inventory := func(vetted bool, ir backend.InventoryRequest) []backend.ProposalRecord {
+		var records []backend.ProposalRecord
+		if vetted {
+			records, _, err = g.Inventory(ir,
+				backend.InventoryRequest{}, false)
+		} else {
+			_, records, err = g.Inventory(
+				backend.InventoryRequest{}, ir, false)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return records
+	}
+
+	// update updates a record, which makes it the most recently updated
+	// one.
+	update := func(vetted bool, token []byte) {
+		files := backendtest.TextFiles(t, "updated", 1)
+		if vetted {
+			_, err = g.UpdateVettedRecord(token, files)
+		} else {
+			_, err = g.UpdateUnvettedRecord(token, files)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Create proposals and publish every other one.
+	expected := map[bool]map[string]int{
+		false: make(map[string]int),
+		true:  make(map[string]int),
+	}
+	tokens := make(map[bool][][]byte)
+	for i := 0; i < 8; i++ {
+		name := fmt.Sprintf("page%v", i)
+		psr, err := g.New(name, backendtest.TextFiles(t, name, 1), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vetted := i%2 == 0
+		if vetted {
+			_, err = g.SetUnvettedStatus(psr.Token,
+				backend.PSRStatusVetted, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		expected[vetted][hex.EncodeToString(psr.Token)] = 1
+		tokens[vetted] = append(tokens[vetted], psr.Token)
+	}
+
+	// Timestamps have a resolution of a second.  Updates have to be
+	// newer than every record that is paged through.
+	time.Sleep(time.Second)
+
+	for _, vetted := range []bool{false, true} {
+		seen := make(map[string]int)
+		ir := backend.InventoryRequest{Count: 3}
+		for {
+			records := inventory(vetted, ir)
+			for _, v := range records {
+				token := v.ProposalStorageRecord.Token
+				seen[hex.EncodeToString(token)]++
+			}
+			if uint(len(records)) < ir.Count {
+				break
+			}
+
+			// The updated record moves in front of the cursor.
+			update(vetted, records[0].ProposalStorageRecord.Token)
+
+			last := records[len(records)-1].ProposalStorageRecord
+			ir.Cursor = &backend.InventoryCursor{
+				Timestamp: last.Timestamp,
+				Token:     last.Token,
+			}
+		}
+		if !reflect.DeepEqual(seen, expected[vetted]) {
+			t.Fatalf("vetted %v: got %v, want %v", vetted, seen,
+				expected[vetted])
+		}
+	}
+
+	// Update two records a second apart, they are the two newest records
+	// with the most recent one first.
+	for _, i := range []int{2, 1} {
+		time.Sleep(time.Second)
+		for _, vetted := range []bool{false, true} {
+			update(vetted, tokens[vetted][i])
+		}
+	}
+	for _, vetted := range []bool{false, true} {
+		records := inventory(vetted, backend.InventoryRequest{Count: 2})
+		if len(records) != 2 ||
+			!bytes.Equal(records[0].ProposalStorageRecord.Token,
+				tokens[vetted][1]) ||
+			!bytes.Equal(records[1].ProposalStorageRecord.Token,
+				tokens[vetted][2]) {
+			t.Fatalf("vetted %v: unexpected newest records %v",
+				vetted, records)
+		}
+	}
+}
+
+func TestProposalVersions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "politeia.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	g, err := New(dir, "", "", testing.Verbose())
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.test = true
+	defer g.Close()
+
+	// Create proposal and update it once while unvetted
+	filesV1 := backendtest.TextFiles(t, "v1", 2)
+	psr, err := g.New("versions", filesV1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filesV2 := backendtest.TextFiles(t, "v2", 1)
+	_, err = g.UpdateUnvettedRecord(psr.Token, filesV2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Logf("===== UNVETTED VERSIONS =====")
+	pv, err := g.UnvettedVersions(psr.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pv) != 2 {
+		t.Fatalf("unexpected number of versions got %v wanted %v",
+			len(pv), 2)
+	}
+	if pv[0].ProposalStorageRecord.Version != 2 ||
+		pv[1].ProposalStorageRecord.Version != 1 {
+		t.Fatalf("unexpected version order: %v", spew.Sdump(pv))
+	}
+	pr, err := g.GetUnvettedVersion(psr.Token, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&pr.ProposalStorageRecord, psr) {
+		t.Fatalf("unexpected psr got %v, wanted %v",
+			spew.Sdump(pr.ProposalStorageRecord), spew.Sdump(psr))
+	}
+	if !reflect.DeepEqual(pr.Files, filesV1) {
+		t.Fatalf("unexpected payload got %v, wanted %v",
+			spew.Sdump(pr.Files), spew.Sdump(filesV1))
+	}
+	_, err = g.GetUnvettedVersion(psr.Token, 3)
+	if err != backend.ErrProposalNotFound {
+		t.Fatalf("expected ErrProposalNotFound, got %v", err)
+	}
+
+	// Publish and update the vetted proposal
+	t.Logf("===== VETTED VERSIONS =====")
+	_, err = g.VettedVersions(psr.Token)
+	if err != backend.ErrProposalNotFound {
+		t.Fatalf("expected ErrProposalNotFound, got %v", err)
+	}
+	_, err = g.SetUnvettedStatus(psr.Token, backend.PSRStatusVetted, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filesV3 := backendtest.TextFiles(t, "v3", 1)
+	_, err = g.UpdateVettedRecord(psr.Token, filesV3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = g.UnvettedVersions(psr.Token)
+	if err != backend.ErrProposalNotFound {
+		t.Fatalf("expected ErrProposalNotFound, got %v", err)
+	}
+
+	// New, update, publish and update.  Publishing bumps the version.
+	pv, err = g.VettedVersions(psr.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pv) != 4 {
+		t.Fatalf("unexpected number of versions got %v wanted %v",
+			len(pv), 4)
+	}
+	if pv[1].ProposalStorageRecord.Version != 3 ||
+		pv[1].ProposalStorageRecord.Status != backend.PSRStatusVetted {
+		t.Fatalf("unexpected publish record: %v", spew.Sdump(pv[1]))
+	}
+	for k, files := range [][]backend.File{filesV1, filesV2, filesV2,
+		filesV3} {
+		pr, err := g.GetVettedVersion(psr.Token, uint(k+1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(pr.Files, files) {
+			t.Fatalf("unexpected payload version %v got %v, "+
+				"wanted %v", k+1, spew.Sdump(pr.Files),
+				spew.Sdump(files))
+		}
+	}
+}
+
+func TestConcurrentReads(t *testing.T) {
+	dir, err := ioutil.TempDir("", "politeia.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	g, err := New(dir, "", "", testing.Verbose())
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.test = true
+	defer g.Close()
+
+	// Create two unvetted proposals and publish one of them
+	unvetted, err := g.New("unvetted", backendtest.TextFiles(t, "unvetted", 2),
+		nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vettedFiles := backendtest.TextFiles(t, "vetted", 3)
+	vetted, err := g.New("vetted", vettedFiles, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = g.SetUnvettedStatus(vetted.Token, backend.PSRStatusVetted, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Hold the filesystem lock, reads must not block on it.
+	err = g.lock.Lock(LockDuration)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const readers = 8
+	errC := make(chan error, readers)
+	for i := 0; i < readers; i++ {
+		go func() {
+			pr, err := g.GetUnvetted(unvetted.Token)
+			if err != nil {
+				errC <- err
+				return
+			}
+			if pr.ProposalStorageRecord.Name != "unvetted" ||
+				len(pr.Files) != 2 {
+				errC <- fmt.Errorf("unexpected unvetted: %v",
+					spew.Sdump(pr))
+				return
+			}
+			pr, err = g.GetVetted(vetted.Token)
+			if err != nil {
+				errC <- err
+				return
+			}
+			if !reflect.DeepEqual(pr.Files, vettedFiles) {
+				errC <- fmt.Errorf("unexpected vetted: %v",
+					spew.Sdump(pr))
+				return
+			}
+			_, err = g.GetVetted(unvetted.Token)
+			if err != backend.ErrProposalNotFound {
+				errC <- fmt.Errorf("expected "+
+					"ErrProposalNotFound, got %v", err)
+				return
+			}
+			v, b, err := g.Inventory(backend.InventoryRequest{},
+				backend.InventoryRequest{}, true)
+			if err != nil {
+				errC <- err
+				return
+			}
+			if len(v) != 1 || len(b) != 1 {
+				errC <- fmt.Errorf("unexpected inventory "+
+					"vetted %v branches %v", len(v), len(b))
+				return
+			}
+			errC <- nil
+		}()
+	}
+	for i := 0; i < readers; i++ {
+		select {
+		case err := <-errC:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(30 * time.Second):
+			t.Fatalf("reads blocked on the filesystem lock")
+		}
+	}
+
+	err = g.lock.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reads must not have moved the unvetted worktree off master.
+	out, err := g.git(g.unvetted, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0] != "master" {
+		t.Fatalf("unexpected unvetted HEAD: %v", out)
+	}
+
+	// Reads must fail once the backend is shut down.
+	g.Close()
+	_, err = g.GetUnvetted(unvetted.Token)
+	if err != backend.ErrShutdown {
+		t.Fatalf("expected ErrShutdown, got %v", err)
+	}
+}
+
+// failNthGit returns a git hook that fails the nth git command that is
+// executed and lets all others through.
+func failNthGit(n int) func([]string) error {
+	count := 0
+	return func(args []string) error {
+		count++
+		if count == n {
+			return fmt.Errorf("injected failure: git %v",
+				strings.Join(args, " "))
+		}
+		return nil
+	}
+}
+
+// repoSnapshot returns a description of the local and remote tracking
+// branches and the worktree status of both repos.
+func repoSnapshot(t *testing.T, g *gitBackEnd) string {
+	var s string
+	for _, repo := range []string{g.unvetted, g.vetted} {
+		refs, err := g.git(repo, "for-each-ref",
+			"--format=%(refname) %(objectname)", "refs/heads/",
+			"refs/remotes/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		status, err := g.git(repo, "status", "--porcelain")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s += fmt.Sprintf("%v heads %v status %v\n", repo, refs,
+			status)
+	}
+	return s
+}
+
+// testRollback injects a failure in every git command op executes, one at a
+// time, and verifies that the repos are left untouched and that unvetted is
+// back on master.  It returns once op runs to completion.
+func testRollback(t *testing.T, g *gitBackEnd, op func() error) {
+	for n := 1; ; n++ {
+		// A failure injected into a best effort step of a previous
+		// attempt, such as the final checkout, may leave unvetted on
+		// a branch.  Start every attempt from master.
+		err := g.gitCheckout(g.unvetted, "master")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		before := repoSnapshot(t, g)
+		g.testGitHook = failNthGit(n)
+		err = op()
+		g.testGitHook = nil
+		if err == nil {
+			t.Logf("completed after %v injected failures", n-1)
+			return
+		}
+		after := repoSnapshot(t, g)
+		if before != after {
+			t.Fatalf("failure %v (%v) not rolled back: before %v "+
+				"after %v", n, err, before, after)
+		}
+		head, err := g.git(g.unvetted, "rev-parse", "--abbrev-ref",
+			"HEAD")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(head) != 1 || head[0] != "master" {
+			t.Fatalf("failure %v: unvetted not on master: %v", n,
+				head)
+		}
+	}
+}
+
+// testCommitRollback injects a failure in the git add and git commit commands
+// op executes, one at a time, and verifies that the repos are left untouched.
+// Failures of the commands before are swallowed by gitExists, which would
+// complete testRollback early.  op must succeed without injected failures.
+func testCommitRollback(t *testing.T, g *gitBackEnd, op func() error) {
+	for _, cmd := range []string{"add", "commit"} {
+		before := repoSnapshot(t, g)
+		g.testGitHook = func(args []string) error {
+			if args[0] == cmd {
+				return fmt.Errorf("injected failure: git %v",
+					strings.Join(args, " "))
+			}
+			return nil
+		}
+		err := op()
+		g.testGitHook = nil
+		if err == nil {
+			t.Fatalf("%v: expected error", cmd)
+		}
+		after := repoSnapshot(t, g)
+		if before != after {
+			t.Fatalf("%v: not rolled back: before %v after %v", cmd,
+				before, after)
+		}
+	}
+	err := op()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "politeia.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	g, err := New(dir, "", "", testing.Verbose())
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.test = true
+	defer g.Close()
+
+	t.Logf("===== NEW =====")
+	files := backendtest.TextFiles(t, "rollback", 2)
+	var psr *backend.ProposalStorageRecord
+	testRollback(t, g, func() error {
+		var err error
+		psr, err = g.New("rollback", files, nil)
+		return err
+	})
+	_, branches, err := g.Inventory(backend.InventoryRequest{},
+		backend.InventoryRequest{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(branches) != 1 {
+		t.Fatalf("unexpected number of branches got %v wanted 1",
+			len(branches))
+	}
+
+	t.Logf("===== CENSOR =====")
+	censor, err := g.New("censor", backendtest.TextFiles(t, "censor", 1), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testRollback(t, g, func() error {
+		_, err := g.SetUnvettedStatus(censor.Token,
+			backend.PSRStatusCensored, "")
+		return err
+	})
+
+	t.Logf("===== PUBLISH =====")
+	testRollback(t, g, func() error {
+		_, err := g.SetUnvettedStatus(psr.Token,
+			backend.PSRStatusVetted, "")
+		if err != nil {
+			// Proposal must still be unvetted and unchanged.
+			pr, gerr := g.GetUnvetted(psr.Token)
+			if gerr != nil {
+				t.Fatal(gerr)
+			}
+			if !reflect.DeepEqual(&pr.ProposalStorageRecord, psr) {
+				t.Fatalf("unexpected psr got %v, wanted %v",
+					spew.Sdump(pr.ProposalStorageRecord),
+					spew.Sdump(psr))
+			}
+			_, gerr = g.GetVetted(psr.Token)
+			if gerr != backend.ErrProposalNotFound {
+				t.Fatalf("expected ErrProposalNotFound, got %v",
+					gerr)
+			}
+		}
+		return err
+	})
+	pr, err := g.GetVetted(psr.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.ProposalStorageRecord.Status != backend.PSRStatusVetted {
+		t.Fatalf("unexpected status: got %v wanted %v",
+			pr.ProposalStorageRecord.Status, backend.PSRStatusVetted)
+	}
+	if !reflect.DeepEqual(pr.Files, files) {
+		t.Fatalf("unexpected payload got %v, wanted %v",
+			spew.Sdump(pr.Files), spew.Sdump(files))
+	}
+
+	t.Logf("===== UPDATE VETTED =====")
+	updated := backendtest.TextFiles(t, "updated", 1)
+	version := pr.ProposalStorageRecord.Version
+	testRollback(t, g, func() error {
+		_, err := g.UpdateVettedRecord(psr.Token, updated)
+		if err != nil {
+			// Proposal must be unchanged.
+			pr, gerr := g.GetVetted(psr.Token)
+			if gerr != nil {
+				t.Fatal(gerr)
+			}
+			if pr.ProposalStorageRecord.Version != version ||
+				!reflect.DeepEqual(pr.Files, files) {
+				t.Fatalf("unexpected proposal %v",
+					spew.Sdump(pr))
+			}
+		}
+		return err
+	})
+	pr, err = g.GetVetted(psr.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.ProposalStorageRecord.Version != version+1 {
+		t.Fatalf("unexpected version: got %v wanted %v",
+			pr.ProposalStorageRecord.Version, version+1)
+	}
+	if !reflect.DeepEqual(pr.Files, updated) {
+		t.Fatalf("unexpected payload got %v, wanted %v",
+			spew.Sdump(pr.Files), spew.Sdump(updated))
+	}
+
+	t.Logf("===== COMMENTS =====")
+	comments := []backend.Comment{{
+		CommentID: 1,
+		UserID:    1,
+		Timestamp: time.Now().Unix(),
+		Comment:   "rollback",
+	}}
+
+	testCommitRollback(t, g, func() error {
+		_, err := g.AppendComments(psr.Token, comments)
+		return err
+	})
+	c, err := g.GetComments(psr.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c, comments) {
+		t.Fatalf("unexpected comments got %v, wanted %v",
+			spew.Sdump(c), spew.Sdump(comments))
+	}
+
+	t.Logf("===== CENSOR COMMENT =====")
+	testCommitRollback(t, g, func() error {
+		return g.CensorComment(psr.Token, 1, "rollback")
+	})
+	c, err = g.GetComments(psr.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c) != 1 || !c[0].Censored || c[0].Comment != "" {
+		t.Fatalf("unexpected comments %v", spew.Sdump(c))
+	}
+
+	t.Logf("===== ARCHIVE =====")
+	version = pr.ProposalStorageRecord.Version
+	testRollback(t, g, func() error {
+		_, err := g.SetVettedStatus(psr.Token,
+			backend.PSRStatusArchived, "")
+		if err != nil {
+			// Proposal must still be vetted and unchanged.
+			pr, gerr := g.GetVetted(psr.Token)
+			if gerr != nil {
+				t.Fatal(gerr)
+			}
+			if pr.ProposalStorageRecord.Status !=
+				backend.PSRStatusVetted ||
+				pr.ProposalStorageRecord.Version != version {
+				t.Fatalf("unexpected proposal %v",
+					spew.Sdump(pr))
+			}
+		}
+		return err
+	})
+	pr, err = g.GetVetted(psr.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.ProposalStorageRecord.Status != backend.PSRStatusArchived {
+		t.Fatalf("unexpected status: got %v wanted %v",
+			pr.ProposalStorageRecord.Status, backend.PSRStatusArchived)
+	}
+}
+
+// errCrash is used to simulate politeiad dying in the middle of an operation.
+var errCrash = fmt.Errorf("crash")
+
+// crash runs op and simulates a crash at the nth git command by panicking on
+// it and on every git command after it.  It returns true if op ran to
+// completion.
+func crash(g *gitBackEnd, n int, op func() error) (completed bool, err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		if r != errCrash {
+			panic(r)
+		}
+		completed = false
+	}()
+
+	count := 0
+	g.testGitHook = func(args []string) error {
+		count++
+		if count >= n {
+			panic(errCrash)
+		}
+		return nil
+	}
+	err = op()
+	g.testGitHook = nil
+
+	return true, err
+}
+
+func TestRecoverTransitions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "politeia.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	g, err := New(dir, "", "", testing.Verbose())
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.test = true
+	defer func() {
+		g.Close()
+	}()
+
+	for _, status := range []backend.PSRStatusT{backend.PSRStatusCensored,
+		backend.PSRStatusVetted} {
+		t.Logf("===== CRASH %v =====", backend.PSRStatus[status])
+		for n := 1; ; n++ {
+			psr, err := g.New("crash", backendtest.TextFiles(t, "crash", 2),
+				nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			before := repoSnapshot(t, g)
+
+			completed, err := crash(g, n, func() error {
+				_, err := g.SetUnvettedStatus(psr.Token,
+					status, "")
+				return err
+			})
+			if completed {
+				if err != nil {
+					t.Fatal(err)
+				}
+				t.Logf("completed after %v crashes", n-1)
+				break
+			}
+
+			// Restart
+			g.Close()
+			g, err = New(dir, "", "", testing.Verbose())
+			if err != nil {
+				t.Fatalf("crash %v: %v", n, err)
+			}
+			g.test = true
+
+			records, err := g.readInFlightRecords()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != 0 {
+				t.Fatalf("crash %v: in flight records left: %v",
+					n, spew.Sdump(records))
+			}
+
+			// The transition was either rolled back or finished.
+			pr, err := g.GetUnvetted(psr.Token)
+			switch {
+			case err == nil && pr.ProposalStorageRecord.Status ==
+				backend.PSRStatusUnvetted:
+				after := repoSnapshot(t, g)
+				if before != after {
+					t.Fatalf("crash %v not rolled back: "+
+						"before %v after %v", n, before,
+						after)
+				}
+
+			case err == nil && pr.ProposalStorageRecord.Status ==
+				backend.PSRStatusCensored &&
+				status == backend.PSRStatusCensored:
+
+			case err == backend.ErrProposalNotFound &&
+				status == backend.PSRStatusVetted:
+				pr, err = g.GetVetted(psr.Token)
+				if err != nil {
+					t.Fatalf("crash %v: %v", n, err)
+				}
+				if pr.ProposalStorageRecord.Status != status {
+					t.Fatalf("crash %v: unexpected status %v",
+						n, pr.ProposalStorageRecord.Status)
+				}
+				id := hex.EncodeToString(psr.Token)
+				for _, repo := range []string{g.vetted,
+					g.unvetted} {
+					heads, err := g.gitBranchHeads(repo)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if _, ok := heads[id]; ok {
+						t.Fatalf("crash %v: branch left "+
+							"in %v", n, repo)
+					}
+				}
+
+			default:
+				t.Fatalf("crash %v: unexpected state %v %v", n,
+					err, spew.Sdump(pr))
+			}
+
+			// Worktrees must be clean.
+			for _, repo := range []string{g.vetted, g.unvetted} {
+				out, err := g.git(repo, "status", "--porcelain")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(out) != 0 {
+					t.Fatalf("crash %v: dirty %v: %v", n,
+						repo, out)
+				}
+			}
+		}
+	}
+}
+
+// Tests that a transition whose rollback failed is recovered by the next
+// attempt instead of blocking it until a restart.
+func TestRetryTransition(t *testing.T) {
+	dir, err := ioutil.TempDir("", "politeia.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	g, err := New(dir, "", "", testing.Verbose())
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.test = true
+	defer g.Close()
+
+	psr, err := g.New("retry", backendtest.TextFiles(t, "retry", 1), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fail every git command from the nth one on, which fails the
+	// rollback as well, until an in flight record is left behind.
+	for n := 1; ; n++ {
+		count := 0
+		g.testGitHook = func(args []string) error {
+			count++
+			if count >= n {
+				return fmt.Errorf("injected failure: git %v",
+					strings.Join(args, " "))
+			}
+			return nil
+		}
+		_, err = g.SetUnvettedStatus(psr.Token, backend.PSRStatusVetted,
+			"")
+		g.testGitHook = nil
+		if err == nil {
+			t.Fatalf("publish did not fail")
+		}
+		records, err := g.readInFlightRecords()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 0 {
+			break
+		}
+	}
+
+	_, err = g.SetUnvettedStatus(psr.Token, backend.PSRStatusVetted, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := g.readInFlightRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 0 {
+		t.Fatalf("in flight records left: %v", spew.Sdump(records))
+	}
+	pr, err := g.GetVetted(psr.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.ProposalStorageRecord.Status != backend.PSRStatusVetted {
+		t.Fatalf("unexpected status: got %v wanted %v",
+			pr.ProposalStorageRecord.Status, backend.PSRStatusVetted)
+	}
+}
+
+func TestConcurrentLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "politeia.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	g, err := New(dir, "", "", testing.Verbose())
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.test = true
+	defer g.Close()
+
+	// Every worker creates a proposal, updates it and then either leaves
+	// it alone, censors it or publishes and updates it again.
+	const workers = 9
+	type job struct {
+		files  [][]backend.File
+		status backend.PSRStatusT
+		token  []byte
+	}
+	jobs := make([]job, workers)
+	for i := range jobs {
+		jobs[i].files = [][]backend.File{
+			backendtest.TextFiles(t, "load", 2),
+			backendtest.TextFiles(t, "load", 3),
+		}
+		jobs[i].status = []backend.PSRStatusT{
+			backend.PSRStatusUnvetted,
+			backend.PSRStatusCensored,
+			backend.PSRStatusVetted,
+		}[i%3]
+	}
+
+	t.Logf("===== LOAD =====")
+	errC := make(chan error, workers)
+	for i := range jobs {
+		go func(j *job) {
+			errC <- func() error {
+				psr, err := g.New("load", j.files[0], nil)
+				if err != nil {
+					return err
+				}
+				j.token = psr.Token
+				_, err = g.UpdateUnvettedRecord(j.token, j.files[1])
+				if err != nil {
+					return err
+				}
+				pr, err := g.GetUnvetted(j.token)
+				if err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(pr.Files, j.files[1]) {
+					return fmt.Errorf("unexpected payload %x",
+						j.token)
+				}
+				if j.status == backend.PSRStatusUnvetted {
+					return nil
+				}
+				_, err = g.SetUnvettedStatus(j.token,
+					j.status, "")
+				if err != nil {
+					return err
+				}
+				if j.status != backend.PSRStatusVetted {
+					return nil
+				}
+				_, err = g.UpdateVettedRecord(j.token, j.files[0])
+				return err
+			}()
+		}(&jobs[i])
+	}
+
+	// Keep reading and anchoring while the workers run.
+	done := make(chan struct{})
+	bgC := make(chan error, 2)
+	go func() {
+		for {
+			select {
+			case <-done:
+				bgC <- nil
+				return
+			default:
+			}
+			_, _, err := g.Inventory(backend.InventoryRequest{},
+				backend.InventoryRequest{}, true)
+			if err != nil {
+				bgC <- err
+				return
+			}
+		}
+	}()
+	go func() {
+		for {
+			select {
+			case <-done:
+				bgC <- nil
+				return
+			default:
+			}
+			err := g.anchorAllRepos()
+			if err != nil {
+				bgC <- err
+				return
+			}
+
+			// Give the lock file a chance to be taken by publish.
+			time.Sleep(50 * time.Millisecond)
+		}
+	}()
+
+	for range jobs {
+		err := <-errC
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	for i := 0; i < 2; i++ {
+		err := <-bgC
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Logf("===== VERIFY =====")
+	for _, j := range jobs {
+		var (
+			pr  *backend.ProposalRecord
+			err error
+		)
+		want := j.files[1]
+		if j.status == backend.PSRStatusVetted {
+			pr, err = g.GetVetted(j.token)
+			want = j.files[0]
+		} else {
+			pr, err = g.GetUnvetted(j.token)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pr.ProposalStorageRecord.Status != j.status {
+			t.Fatalf("unexpected status got %v wanted %v",
+				pr.ProposalStorageRecord.Status, j.status)
+		}
+		if !reflect.DeepEqual(pr.Files, want) {
+			t.Fatalf("unexpected payload got %v, wanted %v",
+				spew.Sdump(pr.Files), spew.Sdump(want))
+		}
+	}
+	vetted, branches, err := g.Inventory(backend.InventoryRequest{},
+		backend.InventoryRequest{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vetted) != workers/3 || len(branches) != workers-workers/3 {
+		t.Fatalf("unexpected inventory vetted %v branches %v",
+			len(vetted), len(branches))
+	}
+	for _, repo := range []string{g.vetted, g.unvetted} {
+		out, err := g.git(repo, "status", "--porcelain")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) != 0 {
+			t.Fatalf("dirty %v: %v", repo, out)
+		}
+		_, err = g.gitFsck(repo)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Operations on unvetted branches must not wait for the global lock.
+	t.Logf("===== GLOBAL LOCK HELD =====")
+	err = g.lock.Lock(LockDuration)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		errC <- func() error {
+			psr, err := g.New("locked", jobs[0].files[0], nil)
+			if err != nil {
+				return err
+			}
+			_, err = g.UpdateUnvettedRecord(psr.Token,
+				jobs[0].files[1])
+			if err != nil {
+				return err
+			}
+			_, err = g.SetUnvettedStatus(psr.Token,
+				backend.PSRStatusCensored, "")
+			return err
+		}()
+	}()
+	select {
+	case err := <-errC:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(LockDuration / 2):
+		t.Fatalf("unvetted operations blocked on the global lock")
+	}
+	err = g.lock.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	backendtest.Run(t, func(t *testing.T) (backend.Backend, func()) {
+		dir, err := ioutil.TempDir("", "politeia.test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		g, err := New(dir, "", "", testing.Verbose())
+		if err != nil {
+			os.RemoveAll(dir)
+			t.Fatal(err)
+		}
+		g.test = true
+		return g, func() { os.RemoveAll(dir) }
+	})
+}
+
+func TestTimestamps(t *testing.T) {
+	dir, err := ioutil.TempDir("", "politeia.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	g, err := New(dir, "", "", testing.Verbose())
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.test = true
+	defer g.Close()
+
+	// verify checks the state of all timestamps and the proofs they
+	// carry.
+	verify := func(token []byte, count int, state backend.AnchorStateT) {
+		ts, err := g.Timestamps(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ts) != count {
+			t.Fatalf("invalid timestamps got %v wanted %v",
+				len(ts), count)
+		}
+		for _, v := range ts {
+			if v.State != state {
+				t.Fatalf("invalid state got %v wanted %v",
+					backend.AnchorState[v.State],
+					backend.AnchorState[state])
+			}
+			if state == backend.AnchorStateNotAnchored {
+				continue
+			}
+
+			// Digest must be a leaf of the anchor merkle root.
+			found := false
+			for _, h := range v.MerklePath.Hashes {
+				if bytes.Equal(h[:], v.Digest) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				t.Fatalf("digest %x not in merkle path", v.Digest)
+			}
+			root, err := merkle.VerifyAuthPath(&v.MerklePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(root[:], v.MerkleRoot) {
+				t.Fatalf("invalid merkle root got %x wanted %x",
+					root, v.MerkleRoot)
+			}
+			if state == backend.AnchorStateConfirmed &&
+				(v.Transaction != expectedTestTX ||
+					v.ChainTimestamp == 0) {
+				t.Fatalf("invalid chain information %v %v",
+					v.Transaction, v.ChainTimestamp)
+			}
+		}
+	}
+
+	unvetted, err := g.New("unvetted", backendtest.TextFiles(t, "file", 1), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vetted, err := g.New("vetted", backendtest.TextFiles(t, "file", 2), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = g.UpdateUnvettedRecord(vetted.Token,
+		backendtest.TextFiles(t, "file", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = g.SetUnvettedStatus(vetted.Token, backend.PSRStatusVetted, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify(unvetted.Token, 1, backend.AnchorStateNotAnchored)
+	verify(vetted.Token, 3, backend.AnchorStateNotAnchored)
+
+	t.Logf("===== ANCHOR =====")
+	err = g.anchorAllRepos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify(unvetted.Token, 1, backend.AnchorStateUnconfirmed)
+	verify(vetted.Token, 3, backend.AnchorStateUnconfirmed)
+
+	t.Logf("===== CONFIRM =====")
+	err = g.anchorChecker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify(unvetted.Token, 1, backend.AnchorStateConfirmed)
+	verify(vetted.Token, 3, backend.AnchorStateConfirmed)
+
+	// Commits after the anchor are not anchored.
+	_, err = g.UpdateVettedRecord(vetted.Token,
+		backendtest.TextFiles(t, "file", 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts, err := g.Timestamps(vetted.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts[0].State != backend.AnchorStateNotAnchored ||
+		ts[1].State != backend.AnchorStateConfirmed {
+		t.Fatalf("invalid states %v %v", ts[0].State, ts[1].State)
+	}
+}
+
+func TestAnchorUnvetted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "politeia.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	g, err := New(dir, "", "", testing.Verbose())
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.test = true
+	defer g.Close()
+
+	// unvettedDigests returns the number of commits that were anchored
+	// as unvetted and whether all unvetted anchors were confirmed.
+	unvettedDigests := func() (int, bool) {
+		anchors, err := g.readUnvettedAnchorRecords()
+		if err != nil {
+			t.Fatal(err)
+		}
+		count, verified := 0, true
+		for _, v := range anchors {
+			count += len(v.Digests)
+			verified = verified && v.Type == AnchorVerified
+		}
+		return count, verified
+	}
+
+	unvetted, err := g.New("unvetted", backendtest.TextFiles(t, "file", 1), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	censored, err := g.New("censored", backendtest.TextFiles(t, "file", 1), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = g.SetUnvettedStatus(censored.Token,
+		backend.PSRStatusCensored, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = g.anchorAllRepos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := unvettedDigests(); count != 3 {
+		t.Fatalf("invalid unvetted digests got %v wanted 3", count)
+	}
+
+	// Unvetted commits must not end up in the vetted records.
+	pv, err := g.UnvettedVersions(unvetted.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	anchors, err := g.readAnchorRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range anchors {
+		for _, d := range v.Digests {
+			if bytes.Equal(d, extendSHA1(pv[0].Digest)) {
+				t.Fatalf("unvetted commit in vetted anchor")
+			}
+		}
+	}
+
+	// Nothing new, nothing to do.
+	_, err = g.anchorUnvetted()
+	if err != errNothingToDo {
+		t.Fatalf("expected errNothingToDo got %v", err)
+	}
+
+	// Only new commits are anchored.
+	_, err = g.UpdateUnvettedRecord(unvetted.Token,
+		backendtest.TextFiles(t, "file", 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = g.anchorAllRepos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := unvettedDigests(); count != 4 {
+		t.Fatalf("invalid unvetted digests got %v wanted 4", count)
+	}
+
+	err = g.anchorChecker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, verified := unvettedDigests(); !verified {
+		t.Fatalf("unvetted anchors not verified")
+	}
+	ua, err := g.readUnconfirmedUnvettedAnchorRecord()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ua.Merkles) != 0 {
+		t.Fatalf("unexpected unconfirmed anchors %v", len(ua.Merkles))
+	}
+	ts, err := g.Timestamps(censored.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range ts {
+		if v.State != backend.AnchorStateConfirmed {
+			t.Fatalf("invalid state got %v", v.State)
+		}
+	}
+
+	// The unvetted audit trail is kept outside of the repos.
+	_, err = os.Stat(filepath.Join(dir, defaultUnvettedAuditTrailFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = os.Stat(filepath.Join(dir, defaultVettedPath,
+		defaultUnvettedAuditTrailFile))
+	if !os.IsNotExist(err) {
+		t.Fatalf("unvetted audit trail in vetted repo: %v", err)
+	}
+}
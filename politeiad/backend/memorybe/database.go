@@ -0,0 +1,212 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package memorybe
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"time"
+
+	"github.com/decred/politeia/politeiad/backend"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// The database contains 2 types of records:
+//	[version][Version]
+//	[proposal<token>][Proposal]
+//
+// The proposal records contain the entire history of a proposal.  Every
+// change to a proposal appends a ProposalVersion to its record and the record
+// is written in a single Put.  Status transitions are therefore atomic and
+// there is nothing to recover after a crash.
+//
+// A proposal is vetted when the status of its newest version is vetted.  All
+// other proposals are unvetted, which includes censored proposals.
+
+const (
+	DbVersion  uint32 = 1
+	VersionKey        = "version"
+
+	// ProposalKeyPrefix is prepended to the token of a proposal record.
+	ProposalKeyPrefix = "proposal"
+)
+
+type Version struct {
+	Version uint32 // Database version
+	Time    int64  // Time of record creation
+}
+
+// encodeVersion encodes Version into a JSON byte slice.
+func encodeVersion(version Version) ([]byte, error) {
+	b, err := json.Marshal(version)
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// DecodeVersion decodes a JSON byte slice into a Version.
+func DecodeVersion(payload []byte) (*Version, error) {
+	var version Version
+
+	err := json.Unmarshal(payload, &version)
+	if err != nil {
+		return nil, err
+	}
+
+	return &version, nil
+}
+
+// ProposalVersion is a snapshot of a proposal.  Digest uniquely identifies
+// the snapshot and plays the role of the commit digest in the git backend.
+type ProposalVersion struct {
+	Digest                []byte                        // SHA256 of snapshot
+	ProposalStorageRecord backend.ProposalStorageRecord // Metadata
+	Files                 []backend.File                // Payload
+}
+
+// Proposal is a database record that holds every snapshot of a proposal,
+// oldest first, the optional signature of the user that submitted it and the
+// comments on the proposal, oldest first.
+type Proposal struct {
+	Versions      []ProposalVersion
+	UserSignature *backend.UserSignature
+	Comments      []backend.Comment
+}
+
+// newest returns the latest snapshot of the proposal.
+func (p *Proposal) newest() *ProposalVersion {
+	return &p.Versions[len(p.Versions)-1]
+}
+
+// appendVersion appends a snapshot of psr and files to the proposal and
+// calculates its digest.
+func (p *Proposal) appendVersion(psr backend.ProposalStorageRecord, files []backend.File) error {
+	pv := ProposalVersion{
+		ProposalStorageRecord: psr,
+		Files:                 files,
+	}
+
+	// Chain the snapshots so that identical content at different points
+	// in the history yields different digests.
+	var parent []byte
+	if len(p.Versions) != 0 {
+		parent = p.newest().Digest
+	}
+	b, err := json.Marshal(pv)
+	if err != nil {
+		return err
+	}
+	d := sha256.Sum256(append(parent, b...))
+	pv.Digest = d[:]
+
+	p.Versions = append(p.Versions, pv)
+	return nil
+}
+
+// proposalKey returns the database key of the proposal record of token.
+func proposalKey(token []byte) []byte {
+	return append([]byte(ProposalKeyPrefix), token...)
+}
+
+// encodeProposal encodes Proposal into a JSON byte slice.
+func encodeProposal(proposal Proposal) ([]byte, error) {
+	b, err := json.Marshal(proposal)
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// DecodeProposal decodes a JSON byte slice into a Proposal.
+func DecodeProposal(payload []byte) (*Proposal, error) {
+	var proposal Proposal
+
+	err := json.Unmarshal(payload, &proposal)
+	if err != nil {
+		return nil, err
+	}
+
+	return &proposal, nil
+}
+
+// writeProposalRecord stores the proposal record of token.
+//
+// This function must be called with the lock held.
+func (m *memoryBackEnd) writeProposalRecord(token []byte, proposal Proposal) error {
+	payload, err := encodeProposal(proposal)
+	if err != nil {
+		return err
+	}
+
+	return m.db.Put(proposalKey(token), payload, nil)
+}
+
+// readProposalRecord retrieves the proposal record of token.  It returns
+// backend.ErrProposalNotFound if the record does not exist.
+//
+// This function must be called with the lock held.
+func (m *memoryBackEnd) readProposalRecord(token []byte) (*Proposal, error) {
+	payload, err := m.db.Get(proposalKey(token), nil)
+	if err == leveldb.ErrNotFound {
+		return nil, backend.ErrProposalNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return DecodeProposal(payload)
+}
+
+// readProposalRecords retrieves all proposal records.
+//
+// This function must be called with the lock held.
+func (m *memoryBackEnd) readProposalRecords() ([]Proposal, error) {
+	var proposals []Proposal
+	i := m.db.NewIterator(util.BytesPrefix([]byte(ProposalKeyPrefix)), nil)
+	defer i.Release()
+	for i.Next() {
+		proposal, err := DecodeProposal(i.Value())
+		if err != nil {
+			return nil, err
+		}
+		proposals = append(proposals, *proposal)
+	}
+
+	return proposals, i.Error()
+}
+
+// openDB opens the database at path or an in memory database if path is
+// empty.
+func (m *memoryBackEnd) openDB(path string) error {
+	var err error
+	if path == "" {
+		m.db, err = leveldb.Open(storage.NewMemStorage(), nil)
+	} else {
+		m.db, err = leveldb.OpenFile(path, nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	// See if we need to write a version record
+	exists, err := m.db.Has([]byte(VersionKey), nil)
+	if err != nil || exists {
+		return err
+	}
+
+	// Write version record
+	v, err := encodeVersion(Version{
+		Version: DbVersion,
+		Time:    time.Now().Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	return m.db.Put([]byte(VersionKey), v, nil)
+}
@@ -0,0 +1,501 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package memorybe
+
+import (
+	"sync"
+	"time"
+
+	"github.com/decred/politeia/politeiad/backend"
+	"github.com/decred/politeia/util"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+var (
+	_ backend.Backend = (*memoryBackEnd)(nil)
+)
+
+// memoryBackEnd is a leveldb based backend context that satisfies the backend
+// interface.  The database lives in memory unless a path is provided.  It does
+// not require git and is meant for development and integration tests.  There
+// is no anchoring.
+type memoryBackEnd struct {
+	sync.RWMutex             // Protects db and shutdown
+	db           *leveldb.DB // Database
+	shutdown     bool        // Backend is shutdown
+}
+
+// get returns the proposal record of token if it is in the requested state.
+// Vetted proposals are not visible as unvetted and vice versa.
+//
+// This function must be called WITH the lock held.
+func (m *memoryBackEnd) get(token []byte, vetted bool) (*Proposal, error) {
+	if m.shutdown {
+		return nil, backend.ErrShutdown
+	}
+
+	p, err := m.readProposalRecord(token)
+	if err != nil {
+		return nil, err
+	}
+	status := p.newest().ProposalStorageRecord.Status
+	if backend.StatusVetted(status) != vetted {
+		return nil, backend.ErrProposalNotFound
+	}
+
+	return p, nil
+}
+
+// New verifies a proposal and stores it as unvetted together with the
+// optional user signature.  The function returns a ProposalStorageRecord.
+//
+// New satisfies the backend interface.
+func (m *memoryBackEnd) New(name string, files []backend.File, us *backend.UserSignature) (*backend.ProposalStorageRecord, error) {
+	payloads, err := backend.VerifyProposal(files)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a censorship token.
+	token, err := util.Random(32)
+	if err != nil {
+		return nil, err
+	}
+
+	psr := backend.ProposalStorageRecord{
+		Name:      name,
+		Version:   1,
+		Status:    backend.PSRStatusUnvetted,
+		Merkle:    backend.MerkleRoot(payloads),
+		Timestamp: time.Now().Unix(),
+		Token:     token,
+	}
+	p := Proposal{
+		UserSignature: us,
+	}
+	err = p.appendVersion(psr, files)
+	if err != nil {
+		return nil, err
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	if m.shutdown {
+		return nil, backend.ErrShutdown
+	}
+
+	err = m.writeProposalRecord(token, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return &psr, nil
+}
+
+// update replaces the files of proposal token.  The proposal must be in the
+// requested state and must not have moved to a terminal status.
+func (m *memoryBackEnd) update(token []byte, files []backend.File, vetted bool) (*backend.ProposalStorageRecord, error) {
+	payloads, err := backend.VerifyProposal(files)
+	if err != nil {
+		return nil, err
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	p, err := m.get(token, vetted)
+	if err != nil {
+		return nil, err
+	}
+	psr := p.newest().ProposalStorageRecord
+	if psr.Status != backend.PSRStatusUnvetted &&
+		psr.Status != backend.PSRStatusVetted {
+		return nil, backend.ErrInvalidTransition
+	}
+
+	// Bail if nothing changed.
+	root := backend.MerkleRoot(payloads)
+	if root == psr.Merkle {
+		return nil, backend.ErrNoChanges
+	}
+	psr.Merkle = root
+	psr.Version++
+	psr.Timestamp = time.Now().Unix()
+
+	err = p.appendVersion(psr, files)
+	if err != nil {
+		return nil, err
+	}
+	err = m.writeProposalRecord(token, *p)
+	if err != nil {
+		return nil, err
+	}
+
+	return &psr, nil
+}
+
+// UpdateUnvettedRecord replaces the files of an unvetted proposal and returns
+// the updated ProposalStorageRecord.  Censored proposals can not be updated.
+//
+// UpdateUnvettedRecord satisfies the backend interface.
+func (m *memoryBackEnd) UpdateUnvettedRecord(token []byte, files []backend.File) (*backend.ProposalStorageRecord, error) {
+	return m.update(token, files, false)
+}
+
+// UpdateVettedRecord replaces the files of a vetted proposal and returns the
+// updated ProposalStorageRecord.
+//
+// UpdateVettedRecord satisfies the backend interface.
+func (m *memoryBackEnd) UpdateVettedRecord(token []byte, files []backend.File) (*backend.ProposalStorageRecord, error) {
+	return m.update(token, files, true)
+}
+
+// getProposal is the generic implementation of GetUnvetted/GetVetted.
+func (m *memoryBackEnd) getProposal(token []byte, vetted bool) (*backend.ProposalRecord, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	p, err := m.get(token, vetted)
+	if err != nil {
+		return nil, err
+	}
+	pv := p.newest()
+
+	return &backend.ProposalRecord{
+		ProposalStorageRecord: pv.ProposalStorageRecord,
+		Files:                 pv.Files,
+		UserSignature:         p.UserSignature,
+	}, nil
+}
+
+// GetUnvetted returns the latest state of an unvetted proposal.
+//
+// GetUnvetted satisfies the backend interface.
+func (m *memoryBackEnd) GetUnvetted(token []byte) (*backend.ProposalRecord, error) {
+	return m.getProposal(token, false)
+}
+
+// GetVetted returns the latest state of a vetted proposal.
+//
+// GetVetted satisfies the backend interface.
+func (m *memoryBackEnd) GetVetted(token []byte) (*backend.ProposalRecord, error) {
+	return m.getProposal(token, true)
+}
+
+// getProposalVersion is the generic implementation of
+// GetUnvettedVersion/GetVettedVersion.  It returns the last snapshot of the
+// requested version.
+func (m *memoryBackEnd) getProposalVersion(token []byte, vetted bool, version uint) (*backend.ProposalRecord, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	p, err := m.get(token, vetted)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(p.Versions) - 1; i >= 0; i-- {
+		pv := p.Versions[i]
+		if pv.ProposalStorageRecord.Version != version {
+			continue
+		}
+		return &backend.ProposalRecord{
+			ProposalStorageRecord: pv.ProposalStorageRecord,
+			Files:                 pv.Files,
+			UserSignature:         p.UserSignature,
+		}, nil
+	}
+
+	return nil, backend.ErrProposalNotFound
+}
+
+// GetUnvettedVersion returns an unvetted proposal as it was at the provided
+// version.
+//
+// GetUnvettedVersion satisfies the backend interface.
+func (m *memoryBackEnd) GetUnvettedVersion(token []byte, version uint) (*backend.ProposalRecord, error) {
+	return m.getProposalVersion(token, false, version)
+}
+
+// GetVettedVersion returns a vetted proposal as it was at the provided
+// version.
+//
+// GetVettedVersion satisfies the backend interface.
+func (m *memoryBackEnd) GetVettedVersion(token []byte, version uint) (*backend.ProposalRecord, error) {
+	return m.getProposalVersion(token, true, version)
+}
+
+// proposalVersions is the generic implementation of
+// UnvettedVersions/VettedVersions.  The versions are returned newest first.
+func (m *memoryBackEnd) proposalVersions(token []byte, vetted bool) ([]backend.ProposalVersion, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	p, err := m.get(token, vetted)
+	if err != nil {
+		return nil, err
+	}
+	pv := make([]backend.ProposalVersion, 0, len(p.Versions))
+	for i := len(p.Versions) - 1; i >= 0; i-- {
+		pv = append(pv, backend.ProposalVersion{
+			Digest:                p.Versions[i].Digest,
+			ProposalStorageRecord: p.Versions[i].ProposalStorageRecord,
+		})
+	}
+
+	return pv, nil
+}
+
+// UnvettedVersions returns the history of an unvetted proposal, newest first.
+//
+// UnvettedVersions satisfies the backend interface.
+func (m *memoryBackEnd) UnvettedVersions(token []byte) ([]backend.ProposalVersion, error) {
+	return m.proposalVersions(token, false)
+}
+
+// VettedVersions returns the history of a vetted proposal, newest first.  This
+// includes the versions that were recorded while the proposal was unvetted.
+//
+// VettedVersions satisfies the backend interface.
+func (m *memoryBackEnd) VettedVersions(token []byte) ([]backend.ProposalVersion, error) {
+	return m.proposalVersions(token, true)
+}
+
+// Timestamps returns a timestamp for every version of a proposal, newest
+// first.  The memory backend does not anchor anything so none of the versions
+// are anchored.
+//
+// Timestamps satisfies the backend interface.
+func (m *memoryBackEnd) Timestamps(token []byte) ([]backend.Timestamp, error) {
+	m.RLock()
+	defer m.RUnlock()
+	if m.shutdown {
+		return nil, backend.ErrShutdown
+	}
+
+	p, err := m.readProposalRecord(token)
+	if err != nil {
+		return nil, err
+	}
+	ts := make([]backend.Timestamp, 0, len(p.Versions))
+	for i := len(p.Versions) - 1; i >= 0; i-- {
+		ts = append(ts, backend.Timestamp{
+			Digest:  p.Versions[i].Digest,
+			Version: p.Versions[i].ProposalStorageRecord.Version,
+			State:   backend.AnchorStateNotAnchored,
+		})
+	}
+
+	return ts, nil
+}
+
+// AppendComments stores the comments of a vetted proposal that were not
+// stored before and returns how many there were.  The comments are written
+// together with the proposal record.
+//
+// AppendComments satisfies the backend interface.
+func (m *memoryBackEnd) AppendComments(token []byte, comments []backend.Comment) (uint, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	p, err := m.get(token, true)
+	if err != nil {
+		return 0, err
+	}
+	fresh, err := backend.NewComments(p.Comments, comments)
+	if err != nil {
+		return 0, err
+	}
+	if len(fresh) == 0 {
+		return 0, nil
+	}
+
+	p.Comments = append(p.Comments, fresh...)
+	err = m.writeProposalRecord(token, *p)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint(len(fresh)), nil
+}
+
+// CensorComment replaces a comment of a vetted proposal with a tombstone.
+// The proposal record is rewritten so the text is gone for good.
+//
+// CensorComment satisfies the backend interface.
+func (m *memoryBackEnd) CensorComment(token []byte, commentID uint64, reason string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	p, err := m.get(token, true)
+	if err != nil {
+		return err
+	}
+	comments, changed, err := backend.CensorComment(p.Comments, commentID,
+		reason)
+	if err != nil || !changed {
+		return err
+	}
+
+	p.Comments = comments
+	return m.writeProposalRecord(token, *p)
+}
+
+// GetComments returns the comments of a vetted proposal, oldest first.
+//
+// GetComments satisfies the backend interface.
+func (m *memoryBackEnd) GetComments(token []byte) ([]backend.Comment, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	p, err := m.get(token, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.Comments, nil
+}
+
+// setStatus is the generic implementation of SetUnvettedStatus and
+// SetVettedStatus.  The transition is written in a single database operation.
+func (m *memoryBackEnd) setStatus(token []byte, status backend.PSRStatusT, reason string, vetted bool) (backend.PSRStatusT, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	p, err := m.get(token, vetted)
+	if err != nil {
+		return backend.PSRStatusInvalid, err
+	}
+	pv := p.newest()
+	psr := pv.ProposalStorageRecord
+	oldStatus := psr.Status
+
+	transition := backend.UnvettedTransition
+	if vetted {
+		transition = backend.VettedTransition
+	}
+	if !transition(oldStatus, status) {
+		return oldStatus, backend.ErrInvalidTransition
+	}
+	psr.Status = status
+	psr.Version++
+	psr.Reason = reason
+	psr.Timestamp = time.Now().Unix()
+
+	err = p.appendVersion(psr, pv.Files)
+	if err != nil {
+		return oldStatus, err
+	}
+	err = m.writeProposalRecord(token, *p)
+	if err != nil {
+		return oldStatus, err
+	}
+
+	return psr.Status, nil
+}
+
+// SetUnvettedStatus tries to update the status for an unvetted proposal.  If
+// the proposal is found the prior status is returned if the function errors
+// out.  The optional reason is stored in the ProposalStorageRecord.
+//
+// SetUnvettedStatus satisfies the backend interface.
+func (m *memoryBackEnd) SetUnvettedStatus(token []byte, status backend.PSRStatusT, reason string) (backend.PSRStatusT, error) {
+	return m.setStatus(token, status, reason, false)
+}
+
+// SetVettedStatus moves a vetted proposal to a terminal status.  If the
+// proposal is found the prior status is returned if the function errors out.
+//
+// SetVettedStatus satisfies the backend interface.
+func (m *memoryBackEnd) SetVettedStatus(token []byte, status backend.PSRStatusT, reason string) (backend.PSRStatusT, error) {
+	return m.setStatus(token, status, reason, true)
+}
+
+// Inventory returns a page of vetted and a page of unvetted proposals.  If
+// includeFiles is set the content is also returned.
+//
+// Inventory satisfies the backend interface.
+func (m *memoryBackEnd) Inventory(vetted, branches backend.InventoryRequest, includeFiles bool) ([]backend.ProposalRecord, []backend.ProposalRecord, error) {
+	m.RLock()
+	defer m.RUnlock()
+	if m.shutdown {
+		return nil, nil, backend.ErrShutdown
+	}
+
+	proposals, err := m.readProposalRecords()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Split by state and remember the files and user signature of every
+	// proposal.
+	files := make(map[string][]backend.File, len(proposals))
+	sigs := make(map[string]*backend.UserSignature, len(proposals))
+	vpsrs := make([]backend.ProposalStorageRecord, 0, len(proposals))
+	bpsrs := make([]backend.ProposalStorageRecord, 0, len(proposals))
+	for i := range proposals {
+		pv := proposals[i].newest()
+		psr := pv.ProposalStorageRecord
+		files[string(psr.Token)] = pv.Files
+		sigs[string(psr.Token)] = proposals[i].UserSignature
+		if backend.StatusVetted(psr.Status) {
+			vpsrs = append(vpsrs, psr)
+		} else {
+			bpsrs = append(bpsrs, psr)
+		}
+	}
+
+	// Select pages and attach files if requested.
+	page := func(psrs []backend.ProposalStorageRecord, ir backend.InventoryRequest) []backend.ProposalRecord {
+		psrs = backend.InventoryPage(psrs, ir)
+		pr := make([]backend.ProposalRecord, 0, len(psrs))
+		for _, psr := range psrs {
+			r := backend.ProposalRecord{
+				ProposalStorageRecord: psr,
+			}
+			if includeFiles {
+				r.Files = files[string(psr.Token)]
+				r.UserSignature = sigs[string(psr.Token)]
+			}
+			pr = append(pr, r)
+		}
+		return pr
+	}
+
+	return page(vpsrs, vetted), page(bpsrs, branches), nil
+}
+
+// Close shuts down the backend.  All interface functions return
+// backend.ErrShutdown once the backend is shut down.
+//
+// Close satisfies the backend interface.
+func (m *memoryBackEnd) Close() {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.shutdown {
+		return
+	}
+	m.shutdown = true
+	m.db.Close()
+}
+
+// New returns a memoryBackEnd context.  The records are stored in a leveldb
+// database at path.  If path is empty the database only lives in memory and
+// everything is lost on Close.
+func New(path string) (*memoryBackEnd, error) {
+	m := &memoryBackEnd{}
+	err := m.openDB(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if path == "" {
+		log.Infof("Records are kept in memory only")
+	} else {
+		log.Infof("Database: %v", path)
+	}
+
+	return m, nil
+}
@@ -0,0 +1,191 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package memorybe
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/decred/politeia/politeiad/backend"
+	"github.com/decred/politeia/politeiad/backend/backendtest"
+)
+
+func TestLifecycle(t *testing.T) {
+	m, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	// Create and update
+	files := backendtest.TextFiles(t, "file", 2)
+	psr, err := m.New("lifecycle", files, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := psr.Token
+	_, err = m.UpdateUnvettedRecord(token, files)
+	if err != backend.ErrNoChanges {
+		t.Fatalf("expected ErrNoChanges got %v", err)
+	}
+	updated := backendtest.TextFiles(t, "file", 3)
+	psr, err = m.UpdateUnvettedRecord(token, updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if psr.Version != 2 {
+		t.Fatalf("invalid version got %v wanted 2", psr.Version)
+	}
+
+	// Unvetted proposals are not visible as vetted
+	_, err = m.GetVetted(token)
+	if err != backend.ErrProposalNotFound {
+		t.Fatalf("expected ErrProposalNotFound got %v", err)
+	}
+	_, err = m.UpdateVettedRecord(token, files)
+	if err != backend.ErrProposalNotFound {
+		t.Fatalf("expected ErrProposalNotFound got %v", err)
+	}
+
+	// Old versions remain available
+	pr, err := m.GetUnvettedVersion(token, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pr.Files, files) {
+		t.Fatalf("unexpected files got %v wanted %v",
+			spew.Sdump(pr.Files), spew.Sdump(files))
+	}
+
+	// Publish and update
+	status, err := m.SetUnvettedStatus(token, backend.PSRStatusVetted, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != backend.PSRStatusVetted {
+		t.Fatalf("invalid status got %v", status)
+	}
+	_, err = m.GetUnvetted(token)
+	if err != backend.ErrProposalNotFound {
+		t.Fatalf("expected ErrProposalNotFound got %v", err)
+	}
+	_, err = m.SetUnvettedStatus(token, backend.PSRStatusCensored, "")
+	if err != backend.ErrProposalNotFound {
+		t.Fatalf("expected ErrProposalNotFound got %v", err)
+	}
+	psr, err = m.UpdateVettedRecord(token, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if psr.Version != 4 || psr.Status != backend.PSRStatusVetted {
+		t.Fatalf("invalid psr %v", spew.Sdump(psr))
+	}
+
+	// The vetted history includes the unvetted versions
+	pv, err := m.VettedVersions(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range pv {
+		if v.ProposalStorageRecord.Version != uint(len(pv)-i) {
+			t.Fatalf("invalid history %v", spew.Sdump(pv))
+		}
+	}
+
+	// Censor
+	psr, err = m.New("censored", files, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err = m.SetUnvettedStatus(psr.Token,
+		backend.PSRStatusCensored, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != backend.PSRStatusCensored {
+		t.Fatalf("invalid status got %v", status)
+	}
+	status, err = m.SetUnvettedStatus(psr.Token,
+		backend.PSRStatusVetted, "")
+	if err != backend.ErrInvalidTransition {
+		t.Fatalf("expected ErrInvalidTransition got %v", err)
+	}
+	if status != backend.PSRStatusCensored {
+		t.Fatalf("invalid status got %v", status)
+	}
+	_, err = m.UpdateUnvettedRecord(psr.Token, updated)
+	if err != backend.ErrInvalidTransition {
+		t.Fatalf("expected ErrInvalidTransition got %v", err)
+	}
+
+	// Inventory
+	vetted, branches, err := m.Inventory(backend.InventoryRequest{},
+		backend.InventoryRequest{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vetted) != 1 || len(branches) != 1 {
+		t.Fatalf("invalid inventory vetted %v branches %v",
+			len(vetted), len(branches))
+	}
+	if !reflect.DeepEqual(vetted[0].Files, files) {
+		t.Fatalf("unexpected files got %v wanted %v",
+			spew.Sdump(vetted[0].Files), spew.Sdump(files))
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "politeia.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "db")
+
+	m, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := backendtest.TextFiles(t, "file", 2)
+	psr, err := m.New("persist", files, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Close()
+
+	// Everything fails after Close
+	_, err = m.GetUnvetted(psr.Token)
+	if err != backend.ErrShutdown {
+		t.Fatalf("expected ErrShutdown got %v", err)
+	}
+
+	m, err = New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	pr, err := m.GetUnvetted(psr.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pr.ProposalStorageRecord, *psr) ||
+		!reflect.DeepEqual(pr.Files, files) {
+		t.Fatalf("unexpected record %v", spew.Sdump(pr))
+	}
+}
+
+func TestConformance(t *testing.T) {
+	backendtest.Run(t, func(t *testing.T) (backend.Backend, func()) {
+		m, err := New("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m, func() {}
+	})
+}
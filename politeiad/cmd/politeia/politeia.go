@@ -16,6 +16,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -29,6 +30,7 @@ import (
 var (
 	defaultHomeDir          = dcrutil.AppDataDir("politeia", false)
 	defaultIdentityFilename = "identity.json"
+	defaultKeyChainFilename = "keychain.json"
 
 	identityFilename = flag.String("-id", filepath.Join(defaultHomeDir,
 		defaultIdentityFilename), "remote server identity file")
@@ -39,6 +41,8 @@ var (
 	rpcpass   = flag.String("rpcpass", "", "RPC password for privileged calls")
 	rpchost   = flag.String("rpchost", "", "RPC host")
 	rpccert   = flag.String("rpccert", "", "RPC certificate")
+	userid    = flag.String("userid", "", "User identity file used to "+
+		"sign new proposals")
 
 	verify = false // Validate server TLS certificate
 )
@@ -52,13 +56,28 @@ func usage() {
 		"identity\n")
 	fmt.Fprintf(os.Stderr, "  inventory         - Inventory proposals "+
 		"<vetted count> <branches count>\n")
+	fmt.Fprintf(os.Stderr, "  newidentity       - Create user identity "+
+		"<filename>\n")
 	fmt.Fprintf(os.Stderr, "  new               - Create new proposal "+
 		"<name> <filename>...\n")
 	fmt.Fprintf(os.Stderr, "  getunvetted       - Retrieve proposal "+
 		"<id>\n")
 	fmt.Fprintf(os.Stderr, "  setunvettedstatus - Set unvetted proposal "+
-		"status <publish|censor> <id>\n")
-	//fmt.Fprintf(os.Stderr, "  update      - Update proposal\n")
+		"status <publish|censor|withdraw> <id> [reason]\n")
+	fmt.Fprintf(os.Stderr, "  setvettedstatus   - Set vetted proposal "+
+		"status <abandon|archive> <id> [reason]\n")
+	fmt.Fprintf(os.Stderr, "  updateunvetted    - Update unvetted "+
+		"proposal <id> <filename>...\n")
+	fmt.Fprintf(os.Stderr, "  updatevetted      - Update vetted "+
+		"proposal <id> <filename>...\n")
+	fmt.Fprintf(os.Stderr, "  unvettedversions  - Retrieve unvetted "+
+		"proposal history <id>\n")
+	fmt.Fprintf(os.Stderr, "  vettedversions    - Retrieve vetted "+
+		"proposal history <id>\n")
+	fmt.Fprintf(os.Stderr, "  timestamps        - Retrieve proposal "+
+		"timestamp proofs <id>\n")
+	fmt.Fprintf(os.Stderr, "  statusrecord      - Retrieve censored "+
+		"proposal status record <id>\n")
 
 	fmt.Fprintf(os.Stderr, "\n")
 }
@@ -77,13 +96,63 @@ func cleanAndExpandPath(path string) string {
 	return filepath.Clean(os.ExpandEnv(path))
 }
 
+// keyChainFilename returns the name of the key chain file that belongs to the
+// identity file.
+func keyChainFilename(identityFilename string) string {
+	return filepath.Join(filepath.Dir(identityFilename),
+		defaultKeyChainFilename)
+}
+
+// verifyCensorshipRecord verifies a censorship record with the server
+// identity or, for records that were signed before the server rotated its
+// key, with one of the keys in the key chain.
+func verifyCensorshipRecord(id *identity.PublicIdentity, csr v1.CensorshipRecord, files []v1.File) error {
+	chain, err := util.LoadKeyChain(keyChainFilename(*identityFilename))
+	if err != nil {
+		return err
+	}
+	err = v1.VerifyKeyChain(chain, hex.EncodeToString(id.Key[:]))
+	if err != nil {
+		return err
+	}
+	ids, err := util.KeyChainIdentities(id, chain)
+	if err != nil {
+		return err
+	}
+
+	// Try the newest key first.
+	for i := len(ids) - 1; i >= 0; i-- {
+		err = v1.Verify(ids[i], csr, files)
+		if err != v1.ErrCorrupt {
+			return err
+		}
+	}
+
+	return err
+}
+
 func getIdentity() error {
 	// Fetch remote identity
-	id, err := util.RemoteIdentity(verify, *rpchost, *rpccert)
+	id, chain, err := util.RemoteIdentity(verify, *rpchost, *rpccert)
 	if err != nil {
 		return err
 	}
 
+	// A previously saved identity must lead to the new one.
+	if _, err := os.Stat(*identityFilename); err == nil {
+		pinned, err := identity.LoadPublicIdentity(*identityFilename)
+		if err != nil {
+			return err
+		}
+		err = util.VerifyIdentityRotation(pinned, id, chain)
+		if err != nil {
+			return err
+		}
+		if pinned.Key != id.Key {
+			fmt.Printf("Rotated from: %x\n", pinned.Key)
+		}
+	}
+
 	rf := filepath.Join(defaultHomeDir, defaultIdentityFilename)
 
 	// Pretty print identity.
@@ -92,6 +161,7 @@ func getIdentity() error {
 	fmt.Printf("Key        : %x\n", id.Key)
 	fmt.Printf("Identity   : %x\n", id.Identity)
 	fmt.Printf("Fingerprint: %v\n", id.Fingerprint())
+	fmt.Printf("Rotations  : %v\n", len(chain))
 
 	// Ask user if we like this identity
 	fmt.Printf("\nSave to %v or ctrl-c to abort ", rf)
@@ -116,6 +186,14 @@ func getIdentity() error {
 	}
 	fmt.Printf("Identity saved to: %v\n", rf)
 
+	// Save the key chain so that records signed by old keys verify.
+	kf := keyChainFilename(rf)
+	err = util.SaveKeyChain(kf, chain)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Key chain saved to: %v\n", kf)
+
 	return nil
 }
 
@@ -136,8 +214,18 @@ func printProposalRecord(header string, pr v1.ProposalRecord) {
 	fmt.Printf("%v:\n", header)
 	fmt.Printf("  Name       : %v\n", pr.Name)
 	fmt.Printf("  Status     : %v\n", status)
+	fmt.Printf("  Version    : %v\n", pr.Version)
 	fmt.Printf("  Timestamp  : %v\n", time.Unix(pr.Timestamp, 0).UTC())
+	if pr.Reason != "" {
+		fmt.Printf("  Reason     : %v\n", pr.Reason)
+	}
 	printCensorshipRecord(pr.CensorshipRecord)
+	if pr.UserSignature != nil {
+		fmt.Printf("  User signature:\n")
+		fmt.Printf("    Key      : %v\n", pr.UserSignature.PublicKey)
+		fmt.Printf("    Merkle   : %v\n", pr.UserSignature.Merkle)
+		fmt.Printf("    Signature: %v\n", pr.UserSignature.Signature)
+	}
 	for k, v := range pr.Files {
 		fmt.Printf("  File (%02v)  :\n", k)
 		fmt.Printf("    Name     : %v\n", v.Name)
@@ -146,13 +234,15 @@ func printProposalRecord(header string, pr v1.ProposalRecord) {
 	}
 }
 
-func remoteInventory() (*v1.InventoryReply, error) {
+func remoteInventory(vettedCount, branchesCount uint) (*v1.InventoryReply, error) {
 	challenge, err := util.Random(v1.ChallengeSize)
 	if err != nil {
 		return nil, err
 	}
 	b, err := json.Marshal(v1.Inventory{
-		Challenge: hex.EncodeToString(challenge),
+		Challenge:     hex.EncodeToString(challenge),
+		VettedCount:   vettedCount,
+		BranchesCount: branchesCount,
 	})
 	if err != nil {
 		return nil, err
@@ -213,8 +303,16 @@ func inventory() error {
 	if len(flags) < 2 {
 		return fmt.Errorf("vetted and branches counts expected")
 	}
+	vettedCount, err := strconv.ParseUint(flags[0], 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid vetted count: %v", err)
+	}
+	branchesCount, err := strconv.ParseUint(flags[1], 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid branches count: %v", err)
+	}
 
-	i, err := remoteInventory()
+	i, err := remoteInventory(uint(vettedCount), uint(branchesCount))
 	if err != nil {
 		return err
 	}
@@ -226,11 +324,44 @@ func inventory() error {
 		for _, v := range i.Branches {
 			printProposalRecord("Unvetted proposal", v)
 		}
+		if i.VettedCursor != "" {
+			fmt.Printf("Vetted cursor  : %v\n", i.VettedCursor)
+		}
+		if i.BranchesCursor != "" {
+			fmt.Printf("Branches cursor: %v\n", i.BranchesCursor)
+		}
 	}
 
 	return nil
 }
 
+func newIdentity() error {
+	flags := flag.Args()[1:] // Chop off action.
+
+	// Make sure we have the filename.
+	if len(flags) != 1 {
+		return fmt.Errorf("must provide one and only one filename")
+	}
+	if _, err := os.Stat(flags[0]); err == nil {
+		return fmt.Errorf("identity file already exists: %v", flags[0])
+	}
+
+	fi, err := identity.New("", "")
+	if err != nil {
+		return err
+	}
+	err = fi.Save(flags[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("User identity created:\n")
+	fmt.Printf("  Filename : %v\n", flags[0])
+	fmt.Printf("  Key      : %v\n", hex.EncodeToString(fi.Public.Key[:]))
+
+	return nil
+}
+
 func newProposal() error {
 	flags := flag.Args()[1:] // Chop off action.
 
@@ -284,6 +415,22 @@ func newProposal() error {
 	}
 	fmt.Printf("Submitted proposal name: %v\n", n.Name)
 
+	// Sign the merkle root with the user identity.
+	if *userid != "" {
+		fi, err := identity.LoadFullIdentity(*userid,
+			util.IdentityPassphrase("", ""))
+		if err != nil {
+			return err
+		}
+		root := merkle.Root(hashes)
+		signature := fi.SignMessage(root[:])
+		n.UserSignature = &v1.UserSignature{
+			PublicKey: hex.EncodeToString(fi.Public.Key[:]),
+			Merkle:    hex.EncodeToString(root[:]),
+			Signature: hex.EncodeToString(signature[:]),
+		}
+	}
+
 	// Convert Verify to JSON
 	b, err := json.Marshal(n)
 	if err != nil {
@@ -452,7 +599,7 @@ func getUnvetted() error {
 	}
 
 	// Verify content
-	err = v1.Verify(*id, reply.Proposal.CensorshipRecord,
+	err = verifyCensorshipRecord(id, reply.Proposal.CensorshipRecord,
 		reply.Proposal.Files)
 	if err != nil {
 		return err
@@ -553,7 +700,7 @@ func getVetted() error {
 	}
 
 	// Verify content
-	err = v1.Verify(*id, reply.Proposal.CensorshipRecord,
+	err = verifyCensorshipRecord(id, reply.Proposal.CensorshipRecord,
 		reply.Proposal.Files)
 	if err != nil {
 		return err
@@ -565,24 +712,362 @@ func getVetted() error {
 	return nil
 }
 
+func updateProposal(vetted bool) error {
+	flags := flag.Args()[1:] // Chop off action.
+
+	// Make sure we have the censorship token and at least one file.
+	if len(flags) < 2 {
+		return fmt.Errorf("must provide censorship token and at least " +
+			"one file")
+	}
+
+	// Validate censorship token
+	_, err := util.ConvertStringToken(flags[0])
+	if err != nil {
+		return err
+	}
+
+	// Fetch remote identity
+	id, err := identity.LoadPublicIdentity(*identityFilename)
+	if err != nil {
+		return err
+	}
+
+	// Create update command
+	challenge, err := util.Random(v1.ChallengeSize)
+	if err != nil {
+		return err
+	}
+	files := make([]v1.File, 0, len(flags[1:]))
+
+	// Open all files, validate MIME type and digest them.
+	for i, a := range flags[1:] {
+		file := v1.File{
+			Name: filepath.Base(a),
+		}
+		file.MIME, file.Digest, file.Payload, err = util.LoadFile(a)
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+
+		fmt.Printf("%02v: %v %v %v\n",
+			i, file.Digest, file.Name, file.MIME)
+	}
+
+	var (
+		n     interface{}
+		route string
+	)
+	if vetted {
+		n = v1.UpdateVetted{
+			Challenge: hex.EncodeToString(challenge),
+			Token:     flags[0],
+			Files:     files,
+		}
+		route = v1.UpdateVettedRoute
+	} else {
+		n = v1.UpdateUnvetted{
+			Challenge: hex.EncodeToString(challenge),
+			Token:     flags[0],
+			Files:     files,
+		}
+		route = v1.UpdateUnvettedRoute
+	}
+
+	// Convert to JSON
+	b, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	if *printJson {
+		fmt.Println(string(b))
+	}
+
+	c, err := util.NewClient(verify, *rpccert)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", *rpchost+route,
+		bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(*rpcuser, *rpcpass)
+	r, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusOK {
+		e, err := util.GetErrorFromJSON(r.Body)
+		if err != nil {
+			return fmt.Errorf("%v", r.Status)
+		}
+		return fmt.Errorf("%v: %v", r.Status, e)
+	}
+
+	bodyBytes := util.ConvertBodyToByteArray(r.Body, *printJson)
+
+	// Both replies are identical.
+	var reply v1.UpdateUnvettedReply
+	err = json.Unmarshal(bodyBytes, &reply)
+	if err != nil {
+		return fmt.Errorf("Could not unmarshal UpdateReply: %v", err)
+	}
+
+	// Verify challenge.
+	err = util.VerifyChallenge(id, challenge, reply.Response)
+	if err != nil {
+		return err
+	}
+
+	// Verify new censorship record against the files we sent.
+	err = v1.Verify(*id, reply.CensorshipRecord, files)
+	if err != nil {
+		return err
+	}
+
+	if !*printJson {
+		printCensorshipRecord(reply.CensorshipRecord)
+	}
+
+	return nil
+}
+
+func getVersions(vetted bool) error {
+	flags := flag.Args()[1:] // Chop off action.
+
+	// Make sure we have the censorship token
+	if len(flags) != 1 {
+		return fmt.Errorf("must provide one and only one censorship " +
+			"token")
+	}
+
+	// Validate censorship token
+	_, err := util.ConvertStringToken(flags[0])
+	if err != nil {
+		return err
+	}
+
+	// Fetch remote identity
+	id, err := identity.LoadPublicIdentity(*identityFilename)
+	if err != nil {
+		return err
+	}
+
+	// Create versions command
+	challenge, err := util.Random(v1.ChallengeSize)
+	if err != nil {
+		return err
+	}
+	var (
+		b     []byte
+		route string
+	)
+	if vetted {
+		route = v1.GetVettedVersionsRoute
+		b, err = json.Marshal(v1.GetVettedVersions{
+			Challenge: hex.EncodeToString(challenge),
+			Token:     flags[0],
+		})
+	} else {
+		route = v1.GetUnvettedVersionsRoute
+		b, err = json.Marshal(v1.GetUnvettedVersions{
+			Challenge: hex.EncodeToString(challenge),
+			Token:     flags[0],
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	if *printJson {
+		fmt.Println(string(b))
+	}
+
+	c, err := util.NewClient(verify, *rpccert)
+	if err != nil {
+		return err
+	}
+	r, err := c.Post(*rpchost+route, "application/json",
+		bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusOK {
+		e, err := util.GetErrorFromJSON(r.Body)
+		if err != nil {
+			return fmt.Errorf("%v", r.Status)
+		}
+		return fmt.Errorf("%v: %v", r.Status, e)
+	}
+
+	bodyBytes := util.ConvertBodyToByteArray(r.Body, *printJson)
+
+	// Both replies share the same layout.
+	var reply v1.GetVettedVersionsReply
+	err = json.Unmarshal(bodyBytes, &reply)
+	if err != nil {
+		return fmt.Errorf("Could not unmarshal versions reply: %v",
+			err)
+	}
+
+	// Verify challenge.
+	err = util.VerifyChallenge(id, challenge, reply.Response)
+	if err != nil {
+		return err
+	}
+
+	if !*printJson {
+		fmt.Printf("Proposal     : %v\n", flags[0])
+		for _, v := range reply.Versions {
+			status, ok := v1.PropStatus[v.Status]
+			if !ok {
+				status = v1.PropStatus[v1.PropStatusInvalid]
+			}
+			fmt.Printf("  Commit     : %v\n", v.Commit)
+			fmt.Printf("    Version  : %v\n", v.Version)
+			fmt.Printf("    Status   : %v\n", status)
+			fmt.Printf("    Timestamp: %v\n",
+				time.Unix(v.Timestamp, 0).UTC())
+			fmt.Printf("    Merkle   : %v\n", v.Merkle)
+		}
+	}
+	return nil
+}
+
+func getTimestamps() error {
+	flags := flag.Args()[1:] // Chop off action.
+
+	// Make sure we have the censorship token
+	if len(flags) != 1 {
+		return fmt.Errorf("must provide one and only one censorship " +
+			"token")
+	}
+
+	// Validate censorship token
+	_, err := util.ConvertStringToken(flags[0])
+	if err != nil {
+		return err
+	}
+
+	// Fetch remote identity
+	id, err := identity.LoadPublicIdentity(*identityFilename)
+	if err != nil {
+		return err
+	}
+
+	// Create timestamps command
+	challenge, err := util.Random(v1.ChallengeSize)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(v1.GetTimestamps{
+		Challenge: hex.EncodeToString(challenge),
+		Token:     flags[0],
+	})
+	if err != nil {
+		return err
+	}
+
+	if *printJson {
+		fmt.Println(string(b))
+	}
+
+	c, err := util.NewClient(verify, *rpccert)
+	if err != nil {
+		return err
+	}
+	r, err := c.Post(*rpchost+v1.GetTimestampsRoute, "application/json",
+		bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusOK {
+		e, err := util.GetErrorFromJSON(r.Body)
+		if err != nil {
+			return fmt.Errorf("%v", r.Status)
+		}
+		return fmt.Errorf("%v: %v", r.Status, e)
+	}
+
+	bodyBytes := util.ConvertBodyToByteArray(r.Body, *printJson)
+
+	var reply v1.GetTimestampsReply
+	err = json.Unmarshal(bodyBytes, &reply)
+	if err != nil {
+		return fmt.Errorf("Could not unmarshal timestamps reply: %v",
+			err)
+	}
+
+	// Verify challenge.
+	err = util.VerifyChallenge(id, challenge, reply.Response)
+	if err != nil {
+		return err
+	}
+
+	if !*printJson {
+		fmt.Printf("Proposal         : %v\n", flags[0])
+		for _, v := range reply.Timestamps {
+			state, ok := v1.AnchorState[v.State]
+			if !ok {
+				state = v1.AnchorState[v1.AnchorStateInvalid]
+			}
+			fmt.Printf("  Digest         : %v\n", v.Digest)
+			fmt.Printf("    Version      : %v\n", v.Version)
+			fmt.Printf("    State        : %v\n", state)
+			if v.State == v1.AnchorStateNotAnchored {
+				continue
+			}
+			fmt.Printf("    Merkle root  : %v\n", v.MerkleRoot)
+			if v.State != v1.AnchorStateConfirmed {
+				continue
+			}
+			fmt.Printf("    Transaction  : %v\n", v.Transaction)
+			fmt.Printf("    Timestamp    : %v\n",
+				time.Unix(v.ChainTimestamp, 0).UTC())
+			fmt.Printf("    Chain merkle : %v\n", v.ChainMerkleRoot)
+		}
+	}
+	return nil
+}
+
 func convertStatus(s string) (v1.PropStatusT, error) {
 	switch s {
 	case "censor":
 		return v1.PropStatusCensored, nil
 	case "publish":
 		return v1.PropStatusPublic, nil
+	case "withdraw":
+		return v1.PropStatusWithdrawn, nil
+	case "abandon":
+		return v1.PropStatusAbandoned, nil
+	case "archive":
+		return v1.PropStatusArchived, nil
 	}
 
 	return v1.PropStatusInvalid, fmt.Errorf("invalid status")
 }
 
-func setUnvettedStatus() error {
+func setStatus(vetted bool) error {
 	flags := flag.Args()[1:] // Chop off action.
 
-	// Make sure we have the status and the censorship token
-	if len(flags) != 2 {
+	// Make sure we have the status, the censorship token and optionally
+	// the reason
+	if len(flags) != 2 && len(flags) != 3 {
 		return fmt.Errorf("must provide status and censorship token")
 	}
+	var reason string
+	if len(flags) == 3 {
+		reason = flags[2]
+	}
 
 	// Verify we got a valid status
 	status, err := convertStatus(flags[0])
@@ -607,14 +1092,30 @@ func setUnvettedStatus() error {
 	if err != nil {
 		return err
 	}
-	n := v1.SetUnvettedStatus{
-		Challenge: hex.EncodeToString(challenge),
-		Status:    status,
-		Token:     flags[1],
+	var (
+		route         string
+		requestObject interface{}
+	)
+	if vetted {
+		route = v1.SetVettedStatusRoute
+		requestObject = v1.SetVettedStatus{
+			Challenge: hex.EncodeToString(challenge),
+			Status:    status,
+			Token:     flags[1],
+			Reason:    reason,
+		}
+	} else {
+		route = v1.SetUnvettedStatusRoute
+		requestObject = v1.SetUnvettedStatus{
+			Challenge: hex.EncodeToString(challenge),
+			Status:    status,
+			Token:     flags[1],
+			Reason:    reason,
+		}
 	}
 
 	// Convert to JSON
-	b, err := json.Marshal(n)
+	b, err := json.Marshal(requestObject)
 	if err != nil {
 		return err
 	}
@@ -627,8 +1128,7 @@ func setUnvettedStatus() error {
 	if err != nil {
 		return err
 	}
-	req, err := http.NewRequest("POST", *rpchost+v1.SetUnvettedStatusRoute,
-		bytes.NewReader(b))
+	req, err := http.NewRequest("POST", *rpchost+route, bytes.NewReader(b))
 	if err != nil {
 		return err
 	}
@@ -649,11 +1149,12 @@ func setUnvettedStatus() error {
 
 	bodyBytes := util.ConvertBodyToByteArray(r.Body, *printJson)
 
+	// The vetted and unvetted replies are identical.
 	var reply v1.SetUnvettedStatusReply
 	err = json.Unmarshal(bodyBytes, &reply)
 	if err != nil {
 		return fmt.Errorf("Could not unmarshal "+
-			"SetUnvettedStatusReply: %v", err)
+			"SetStatusReply: %v", err)
 	}
 
 	// Verify challenge.
@@ -662,6 +1163,12 @@ func setUnvettedStatus() error {
 		return err
 	}
 
+	// Verify status record.
+	err = v1.VerifyStatusRecord(*id, reply.StatusRecord)
+	if err != nil {
+		return err
+	}
+
 	if !*printJson {
 		// Pretty print proposal
 		status, ok := v1.PropStatus[reply.Status]
@@ -670,8 +1177,113 @@ func setUnvettedStatus() error {
 		}
 		fmt.Printf("Set proposal status:\n")
 		fmt.Printf("  Status   : %v\n", status)
+		printStatusRecord(reply.StatusRecord)
+	}
+
+	return nil
+}
+
+func printStatusRecord(sr v1.StatusRecord) {
+	status, ok := v1.PropStatus[sr.Status]
+	if !ok {
+		status = v1.PropStatus[v1.PropStatusInvalid]
+	}
+	fmt.Printf("  Status record:\n")
+	fmt.Printf("    Token    : %v\n", sr.Token)
+	fmt.Printf("    Merkle   : %v\n", sr.Merkle)
+	fmt.Printf("    Status   : %v\n", status)
+	fmt.Printf("    Timestamp: %v\n", time.Unix(sr.Timestamp, 0).UTC())
+	if sr.Reason != "" {
+		fmt.Printf("    Reason   : %v\n", sr.Reason)
+	}
+	fmt.Printf("    Signature: %v\n", sr.Signature)
+}
+
+func getStatusRecord() error {
+	flags := flag.Args()[1:] // Chop off action.
+
+	// Make sure we have the censorship token
+	if len(flags) != 1 {
+		return fmt.Errorf("must provide one and only one censorship " +
+			"token")
+	}
+
+	// Validate censorship token
+	_, err := util.ConvertStringToken(flags[0])
+	if err != nil {
+		return err
+	}
+
+	// Fetch remote identity
+	id, err := identity.LoadPublicIdentity(*identityFilename)
+	if err != nil {
+		return err
+	}
+
+	// Create status record command
+	challenge, err := util.Random(v1.ChallengeSize)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(v1.GetStatusRecord{
+		Challenge: hex.EncodeToString(challenge),
+		Token:     flags[0],
+	})
+	if err != nil {
+		return err
+	}
+
+	if *printJson {
+		fmt.Println(string(b))
+	}
+
+	c, err := util.NewClient(verify, *rpccert)
+	if err != nil {
+		return err
+	}
+	r, err := c.Post(*rpchost+v1.GetStatusRecordRoute, "application/json",
+		bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusOK {
+		e, err := util.GetErrorFromJSON(r.Body)
+		if err != nil {
+			return fmt.Errorf("%v", r.Status)
+		}
+		return fmt.Errorf("%v: %v", r.Status, e)
 	}
 
+	bodyBytes := util.ConvertBodyToByteArray(r.Body, *printJson)
+
+	var reply v1.GetStatusRecordReply
+	err = json.Unmarshal(bodyBytes, &reply)
+	if err != nil {
+		return fmt.Errorf("Could not unmarshal status record reply: %v",
+			err)
+	}
+
+	// Verify challenge.
+	err = util.VerifyChallenge(id, challenge, reply.Response)
+	if err != nil {
+		return err
+	}
+
+	// Verify status record.
+	if reply.StatusRecord.Token != flags[0] {
+		return fmt.Errorf("status record token mismatch")
+	}
+	err = v1.VerifyStatusRecord(*id, reply.StatusRecord)
+	if err != nil {
+		return err
+	}
+
+	if !*printJson {
+		fmt.Printf("Proposal     : %v\n", flags[0])
+		printStatusRecord(reply.StatusRecord)
+	}
 	return nil
 }
 
@@ -712,6 +1324,8 @@ func _main() error {
 		// Select action
 		if i == 0 {
 			switch a {
+			case "newidentity":
+				return newIdentity()
 			case "new":
 				return newProposal()
 			case "identity":
@@ -723,7 +1337,21 @@ func _main() error {
 			case "getvetted":
 				return getVetted()
 			case "setunvettedstatus":
-				return setUnvettedStatus()
+				return setStatus(false)
+			case "setvettedstatus":
+				return setStatus(true)
+			case "updateunvetted":
+				return updateProposal(false)
+			case "updatevetted":
+				return updateProposal(true)
+			case "unvettedversions":
+				return getVersions(false)
+			case "vettedversions":
+				return getVersions(true)
+			case "timestamps":
+				return getTimestamps()
+			case "statusrecord":
+				return getStatusRecord()
 			default:
 				return fmt.Errorf("invalid action: %v", a)
 			}
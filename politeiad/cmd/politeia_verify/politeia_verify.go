@@ -20,23 +20,29 @@ var (
 	publicKeyFlag = flag.String("k", "", "server public key")
 	tokenFlag     = flag.String("t", "", "proposal censorship token")
 	signatureFlag = flag.String("s", "", "proposal censorship signature")
+	userKeyFlag   = flag.String("u", "", "user public key")
+	userSigFlag   = flag.String("us", "", "user signature")
 	verboseFlag   = flag.Bool("v", false, "verbose output")
 )
 
 func usage() {
-	fmt.Fprintf(os.Stderr, "usage: politeia_verify [-v] -k <pubkey> -t <token> -s <signature> <filename>...\n")
+	fmt.Fprintf(os.Stderr, "usage: politeia_verify [-v] -k <pubkey> -t <token> -s <signature> [-u <userkey> -us <usersignature>] <filename>...\n")
 	fmt.Fprintf(os.Stderr, " parameters:\n")
 	fmt.Fprintf(os.Stderr, "  pubkey        - Politiea's public server key\n")
 	fmt.Fprintf(os.Stderr, "  token         - Proposal censorship token\n")
 	fmt.Fprintf(os.Stderr, "  signature     - Proposal censorship "+
 		"signature\n")
+	fmt.Fprintf(os.Stderr, "  userkey       - Optional public key of the "+
+		"user that submitted the proposal\n")
+	fmt.Fprintf(os.Stderr, "  usersignature - Optional user signature of "+
+		"the proposal\n")
 	fmt.Fprintf(os.Stderr, "  v             - Verbose output\n")
 	fmt.Fprintf(os.Stderr, "  filename      - One or more paths to the markdown "+
 		"and image files that make up the proposal\n")
 	fmt.Fprintf(os.Stderr, "\n")
 }
 
-func verifyProposal(key [ed25519.PublicKeySize]byte, token []byte, signature [ed25519.SignatureSize]byte) error {
+func verifyProposal(key [ed25519.PublicKeySize]byte, token []byte, signature [ed25519.SignatureSize]byte, userKey *[ed25519.PublicKeySize]byte, userSignature *[ed25519.SignatureSize]byte) error {
 	flags := flag.Args()
 	if len(flags) < 1 {
 		usage()
@@ -78,6 +84,24 @@ func verifyProposal(key [ed25519.PublicKeySize]byte, token []byte, signature [ed
 		return fmt.Errorf("Proposal failed verification")
 	}
 
+	// The user signs the merkle root of the files as they were
+	// submitted.
+	if userKey == nil {
+		return nil
+	}
+	if ed25519.Verify(userKey, merkle[:], userSignature) {
+		fmt.Println("User signature successfully verified")
+	} else {
+		if *verboseFlag {
+			return fmt.Errorf("User signature failed verification. "+
+				"Please ensure the user public key and the "+
+				"files are the ones that were submitted.\n"+
+				"  Merkle: %v", hex.EncodeToString(merkle[:]))
+		}
+
+		return fmt.Errorf("User signature failed verification")
+	}
+
 	return nil
 }
 
@@ -108,7 +132,37 @@ func _main() error {
 	var signature [ed25519.SignatureSize]byte
 	copy(signature[:], s)
 
-	return verifyProposal(publicKey, token, signature)
+	// Decode the optional user public key and signature.
+	var (
+		userKey       *[ed25519.PublicKeySize]byte
+		userSignature *[ed25519.SignatureSize]byte
+	)
+	if *userKeyFlag != "" || *userSigFlag != "" {
+		uk, err := hex.DecodeString(*userKeyFlag)
+		if err != nil {
+			return err
+		}
+		if len(uk) != ed25519.PublicKeySize {
+			return fmt.Errorf("invalid user public key size %v, "+
+				"expected %v", len(uk), ed25519.PublicKeySize)
+		}
+		userKey = new([ed25519.PublicKeySize]byte)
+		copy(userKey[:], uk)
+
+		us, err := hex.DecodeString(*userSigFlag)
+		if err != nil {
+			return err
+		}
+		if len(us) != ed25519.SignatureSize {
+			return fmt.Errorf("invalid user signature size %v, "+
+				"expected %v", len(us), ed25519.SignatureSize)
+		}
+		userSignature = new([ed25519.SignatureSize]byte)
+		copy(userSignature[:], us)
+	}
+
+	return verifyProposal(publicKey, token, signature, userKey,
+		userSignature)
 }
 
 func main() {
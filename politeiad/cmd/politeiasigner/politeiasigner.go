@@ -0,0 +1,96 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// politeiasigner holds the politeiad identity and signs messages for
+// politeiad over a unix socket, so that politeiad never loads the private key
+// itself.  Start politeiad with --signer pointing at the socket.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	"github.com/decred/dcrd/dcrutil"
+	"github.com/decred/politeia/politeiad/api/v1/identity"
+	"github.com/decred/politeia/politeiad/signer"
+	"github.com/decred/politeia/util"
+)
+
+var (
+	defaultHomeDir = dcrutil.AppDataDir("politeiad", false)
+	identityFile   = flag.String("identity", filepath.Join(defaultHomeDir,
+		"identity.json"), "politeiad identity file")
+	socketPath = flag.String("socket", filepath.Join(defaultHomeDir,
+		"signer.sock"), "unix socket to listen on")
+	passFile = flag.String("identitypassfile", "", "file containing the "+
+		"passphrase of an encrypted identity")
+	passEnv = flag.String("identitypassenv", "", "environment variable "+
+		"that contains the passphrase of an encrypted identity")
+)
+
+func _main() error {
+	flag.Parse()
+
+	id, err := identity.LoadFullIdentity(*identityFile,
+		util.IdentityPassphrase(*passEnv, *passFile))
+	if err != nil {
+		return err
+	}
+
+	// Remove a stale socket of a previous run.
+	if _, err := os.Stat(*socketPath); err == nil {
+		if c, err := net.Dial("unix", *socketPath); err == nil {
+			c.Close()
+			return fmt.Errorf("signer already running on %v",
+				*socketPath)
+		}
+		err = os.Remove(*socketPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Only the owner may connect.  The umask is set before the socket is
+	// created so that there is no window in which others can connect.
+	mask := umask(0077)
+	l, err := net.Listen("unix", *socketPath)
+	umask(mask)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	fmt.Printf("Public identity: %x\n", id.Public.Identity)
+	fmt.Printf("Listening on %v\n", *socketPath)
+
+	// Close the listener on interrupt so that the socket is removed.
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	shutdown := make(chan struct{})
+	go func() {
+		<-interrupt
+		close(shutdown)
+		l.Close()
+	}()
+
+	err = signer.Serve(l, signer.NewLocal(id))
+	select {
+	case <-shutdown:
+		return nil
+	default:
+		return err
+	}
+}
+
+func main() {
+	err := _main()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
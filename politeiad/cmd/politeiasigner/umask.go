@@ -0,0 +1,15 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+//go:build !windows
+// +build !windows
+
+package main
+
+import "syscall"
+
+// umask sets the file mode creation mask and returns the previous mask.
+func umask(mask int) int {
+	return syscall.Umask(mask)
+}
@@ -0,0 +1,11 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+// umask is a no-op on Windows, where access to the socket is governed by the
+// permissions of the directory that contains it.
+func umask(mask int) int {
+	return 0
+}
@@ -29,9 +29,15 @@ const (
 	defaultLogDirname       = "logs"
 	defaultLogFilename      = "politeiad.log"
 	defaultIdentityFilename = "identity.json"
+	defaultKeyChainFilename = "keychain.json"
+	defaultBackend          = backendGit
 
 	defaultMainnetPort = "49374"
 	defaultTestnetPort = "59374"
+
+	// Supported storage backends.
+	backendGit    = "git"
+	backendMemory = "memory"
 )
 
 var (
@@ -42,6 +48,7 @@ var (
 	defaultHTTPSCertFile = filepath.Join(defaultHomeDir, "https.cert")
 	defaultLogDir        = filepath.Join(defaultHomeDir, defaultLogDirname)
 	defaultIdentityFile  = filepath.Join(defaultHomeDir, defaultIdentityFilename)
+	defaultKeyChainFile  = filepath.Join(defaultHomeDir, defaultKeyChainFilename)
 )
 
 // runServiceCommand is only set to a real function on Windows.  It is used
@@ -52,27 +59,35 @@ var runServiceCommand func(string) error
 //
 // See loadConfig for details on the configuration load process.
 type config struct {
-	HomeDir     string   `short:"A" long:"appdata" description:"Path to application home directory"`
-	ShowVersion bool     `short:"V" long:"version" description:"Display version information and exit"`
-	ConfigFile  string   `short:"C" long:"configfile" description:"Path to configuration file"`
-	DataDir     string   `short:"b" long:"datadir" description:"Directory to store data"`
-	LogDir      string   `long:"logdir" description:"Directory to log output."`
-	TestNet     bool     `long:"testnet" description:"Use the test network"`
-	SimNet      bool     `long:"simnet" description:"Use the simulation test network"`
-	Profile     string   `long:"profile" description:"Enable HTTP profiling on given port -- NOTE port must be between 1024 and 65536"`
-	CPUProfile  string   `long:"cpuprofile" description:"Write CPU profile to the specified file"`
-	MemProfile  string   `long:"memprofile" description:"Write mem profile to the specified file"`
-	DebugLevel  string   `short:"d" long:"debuglevel" description:"Logging level for all subsystems {trace, debug, info, warn, error, critical} -- You may also specify <subsystem>=<level>,<subsystem2>=<level>,... to set the log level for individual subsystems -- Use show to list available subsystems"`
-	Listeners   []string `long:"listen" description:"Add an interface/port to listen for connections (default all interfaces port: 49152, testnet: 59152)"`
-	Version     string
-	HTTPSCert   string `long:"httpscert" description:"File containing the https certificate file"`
-	HTTPSKey    string `long:"httpskey" description:"File containing the https certificate key"`
-	RPCUser     string `long:"rpcuser" description:"RPC user name for privileged commands"`
-	RPCPass     string `long:"rpcpass" description:"RPC password for privileged commands"`
-	DcrtimeHost string `long:"dcrtimehost" description:"Dcrtime ip:port"`
-	DcrtimeCert string `long:"dcrtimecert" description:"File containing the https certificate file for dcrtimehost"`
-	Identity    string `long:"identity" description:"File containing the politeiad identity file"`
-	GitTrace    bool   `long:"gittrace" description:"Enable git tracing in logs"`
+	HomeDir          string   `short:"A" long:"appdata" description:"Path to application home directory"`
+	ShowVersion      bool     `short:"V" long:"version" description:"Display version information and exit"`
+	ConfigFile       string   `short:"C" long:"configfile" description:"Path to configuration file"`
+	DataDir          string   `short:"b" long:"datadir" description:"Directory to store data"`
+	LogDir           string   `long:"logdir" description:"Directory to log output."`
+	TestNet          bool     `long:"testnet" description:"Use the test network"`
+	SimNet           bool     `long:"simnet" description:"Use the simulation test network"`
+	Profile          string   `long:"profile" description:"Enable HTTP profiling on given port -- NOTE port must be between 1024 and 65536"`
+	CPUProfile       string   `long:"cpuprofile" description:"Write CPU profile to the specified file"`
+	MemProfile       string   `long:"memprofile" description:"Write mem profile to the specified file"`
+	DebugLevel       string   `short:"d" long:"debuglevel" description:"Logging level for all subsystems {trace, debug, info, warn, error, critical} -- You may also specify <subsystem>=<level>,<subsystem2>=<level>,... to set the log level for individual subsystems -- Use show to list available subsystems"`
+	Listeners        []string `long:"listen" description:"Add an interface/port to listen for connections (default all interfaces port: 49152, testnet: 59152)"`
+	Version          string
+	HTTPSCert        string `long:"httpscert" description:"File containing the https certificate file"`
+	HTTPSKey         string `long:"httpskey" description:"File containing the https certificate key"`
+	RPCUser          string `long:"rpcuser" description:"RPC user name for privileged commands"`
+	RPCPass          string `long:"rpcpass" description:"RPC password for privileged commands"`
+	DcrtimeHost      string `long:"dcrtimehost" description:"Dcrtime ip:port"`
+	DcrtimeCert      string `long:"dcrtimecert" description:"File containing the https certificate file for dcrtimehost"`
+	Identity         string `long:"identity" description:"File containing the politeiad identity file"`
+	KeyChain         string `long:"keychain" description:"File containing the key rotations of the politeiad identity"`
+	RotateKey        bool   `long:"rotatekey" description:"Replace the politeiad identity with a new one, record the rotation in the key chain and exit"`
+	IdentityPassEnv  string `long:"identitypassenv" description:"Environment variable that contains the passphrase of an encrypted identity"`
+	IdentityPassFile string `long:"identitypassfile" description:"File containing the passphrase of an encrypted identity"`
+	EncryptIdentity  bool   `long:"encryptidentity" description:"Encrypt the politeiad identity in place with a passphrase and exit"`
+	Signer           string `long:"signer" description:"Unix socket of an external signing daemon that holds the politeiad identity instead of the identity file"`
+	GitTrace         bool   `long:"gittrace" description:"Enable git tracing in logs"`
+	Backend          string `long:"backend" description:"Storage backend {git, memory}"`
+	MemoryDB         string `long:"memorydb" description:"Directory of the leveldb database of the memory backend; records are kept in memory only if not set"`
 }
 
 // serviceOptions defines the configuration options for the daemon as a service
@@ -245,6 +260,7 @@ func loadConfig() (*config, []string, error) {
 		HTTPSKey:   defaultHTTPSKeyFile,
 		HTTPSCert:  defaultHTTPSCertFile,
 		Version:    version(),
+		Backend:    defaultBackend,
 	}
 
 	// Service options which are only added on Windows.
@@ -435,6 +451,17 @@ func loadConfig() (*config, []string, error) {
 		}
 	}
 
+	// Validate storage backend
+	switch cfg.Backend {
+	case backendGit, backendMemory:
+	default:
+		str := "%s: Invalid backend [%v] -- choose one of git or memory"
+		err := fmt.Errorf(str, funcName, cfg.Backend)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Add the default listener if none were specified. The default
 	// listener is all addresses on the listen port for the network
 	// we are to connect to.
@@ -488,6 +515,23 @@ func loadConfig() (*config, []string, error) {
 	}
 	cfg.Identity = cleanAndExpandPath(cfg.Identity)
 
+	if cfg.KeyChain == "" {
+		cfg.KeyChain = defaultKeyChainFile
+	}
+	cfg.KeyChain = cleanAndExpandPath(cfg.KeyChain)
+
+	if cfg.IdentityPassFile != "" {
+		cfg.IdentityPassFile = cleanAndExpandPath(cfg.IdentityPassFile)
+	}
+
+	if cfg.Signer != "" {
+		cfg.Signer = cleanAndExpandPath(cfg.Signer)
+	}
+
+	if cfg.MemoryDB != "" {
+		cfg.MemoryDB = cleanAndExpandPath(cfg.MemoryDB)
+	}
+
 	// Set random username and password when not specified
 	if cfg.RPCUser == "" {
 		name, err := util.Random(32)
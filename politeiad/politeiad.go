@@ -5,6 +5,7 @@
 package main
 
 import (
+	"bytes"
 	"crypto/elliptic"
 	"crypto/x509"
 	"encoding/hex"
@@ -16,6 +17,8 @@ import (
 	"net/http/httputil"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -23,6 +26,8 @@ import (
 	"github.com/decred/politeia/politeiad/api/v1/identity"
 	"github.com/decred/politeia/politeiad/backend"
 	"github.com/decred/politeia/politeiad/backend/gitbe"
+	"github.com/decred/politeia/politeiad/backend/memorybe"
+	"github.com/decred/politeia/politeiad/signer"
 	"github.com/decred/politeia/util"
 	"github.com/gorilla/mux"
 )
@@ -32,7 +37,9 @@ type politeia struct {
 	backend  backend.Backend
 	cfg      *config
 	router   *mux.Router
-	identity *identity.FullIdentity
+	signer   signer.Signer
+	identity *identity.FullIdentity // Identity of signer, nil when remote
+	keyChain []v1.KeyRotation       // Rotations that lead to identity
 }
 
 func remoteAddr(r *http.Request) string {
@@ -56,6 +63,12 @@ func convertBackendStatus(status backend.PSRStatusT) v1.PropStatusT {
 		s = v1.PropStatusPublic
 	case backend.PSRStatusCensored:
 		s = v1.PropStatusCensored
+	case backend.PSRStatusAbandoned:
+		s = v1.PropStatusAbandoned
+	case backend.PSRStatusArchived:
+		s = v1.PropStatusArchived
+	case backend.PSRStatusWithdrawn:
+		s = v1.PropStatusWithdrawn
 	}
 	return s
 }
@@ -72,29 +85,133 @@ func convertFrontendStatus(status v1.PropStatusT) backend.PSRStatusT {
 		s = backend.PSRStatusVetted
 	case v1.PropStatusCensored:
 		s = backend.PSRStatusCensored
+	case v1.PropStatusAbandoned:
+		s = backend.PSRStatusAbandoned
+	case v1.PropStatusArchived:
+		s = backend.PSRStatusArchived
+	case v1.PropStatusWithdrawn:
+		s = backend.PSRStatusWithdrawn
 	}
 	return s
 }
 
-func (p *politeia) convertBackendProposal(bpr backend.ProposalRecord) v1.ProposalRecord {
-	psr := bpr.ProposalStorageRecord
+// convertFrontendStatuses converts an API status filter to backend statuses.
+func convertFrontendStatuses(statuses []v1.PropStatusT) []backend.PSRStatusT {
+	s := make([]backend.PSRStatusT, 0, len(statuses))
+	for _, v := range statuses {
+		s = append(s, convertFrontendStatus(v))
+	}
+	return s
+}
 
+// convertBackendCensorshipRecord creates a signed CensorshipRecord from a
+// backend ProposalStorageRecord.
+func (p *politeia) convertBackendCensorshipRecord(psr backend.ProposalStorageRecord) (v1.CensorshipRecord, error) {
 	// Calculate signature
 	merkleToken := make([]byte, len(psr.Merkle)+len(psr.Token))
 	copy(merkleToken, psr.Merkle[:])
 	copy(merkleToken[len(psr.Merkle[:]):], psr.Token)
-	signature := p.identity.SignMessage(merkleToken)
+	signature, err := p.signer.SignMessage(merkleToken)
+	if err != nil {
+		return v1.CensorshipRecord{}, err
+	}
 
-	// Convert record
-	pr := v1.ProposalRecord{
+	return v1.CensorshipRecord{
+		Merkle:    hex.EncodeToString(psr.Merkle[:]),
+		Token:     hex.EncodeToString(psr.Token),
+		Signature: hex.EncodeToString(signature[:]),
+	}, nil
+}
+
+// signStatusRecord creates a StatusRecord that attests the current status of
+// a proposal and signs it with the server identity.  The record carries the
+// time of the status change, so signing it again yields the same record.
+func (p *politeia) signStatusRecord(psr backend.ProposalStorageRecord) (*v1.StatusRecord, error) {
+	sr := v1.StatusRecord{
+		Token:     hex.EncodeToString(psr.Token),
+		Merkle:    hex.EncodeToString(psr.Merkle[:]),
 		Status:    convertBackendStatus(psr.Status),
-		Name:      psr.Name,
 		Timestamp: psr.Timestamp,
-		CensorshipRecord: v1.CensorshipRecord{
-			Merkle:    hex.EncodeToString(psr.Merkle[:]),
-			Token:     hex.EncodeToString(psr.Token),
-			Signature: hex.EncodeToString(signature[:]),
-		},
+		Reason:    psr.Reason,
+	}
+	message, err := v1.StatusRecordMessage(sr)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := p.signer.SignMessage(message)
+	if err != nil {
+		return nil, err
+	}
+	sr.Signature = hex.EncodeToString(signature[:])
+
+	return &sr, nil
+}
+
+// convertFrontendUserSignature converts an API UserSignature to a backend
+// UserSignature.
+func convertFrontendUserSignature(us v1.UserSignature) (*backend.UserSignature, error) {
+	publicKey, err := hex.DecodeString(us.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	merkle, ok := util.ConvertDigest(us.Merkle)
+	if !ok {
+		return nil, v1.ErrInvalidMerkle
+	}
+	signature, err := hex.DecodeString(us.Signature)
+	if err != nil {
+		return nil, err
+	}
+
+	return &backend.UserSignature{
+		PublicKey: publicKey,
+		Merkle:    merkle,
+		Signature: signature,
+	}, nil
+}
+
+// convertBackendUserSignature converts a backend UserSignature to an API
+// UserSignature.
+func convertBackendUserSignature(us backend.UserSignature) *v1.UserSignature {
+	return &v1.UserSignature{
+		PublicKey: hex.EncodeToString(us.PublicKey),
+		Merkle:    hex.EncodeToString(us.Merkle[:]),
+		Signature: hex.EncodeToString(us.Signature),
+	}
+}
+
+// convertFrontendFiles converts API files to backend files.
+func convertFrontendFiles(f []v1.File) []backend.File {
+	files := make([]backend.File, 0, len(f))
+	for _, v := range f {
+		files = append(files, backend.File{
+			Name:    v.Name,
+			MIME:    v.MIME,
+			Digest:  v.Digest,
+			Payload: v.Payload,
+		})
+	}
+	return files
+}
+
+func (p *politeia) convertBackendProposal(bpr backend.ProposalRecord) (v1.ProposalRecord, error) {
+	psr := bpr.ProposalStorageRecord
+	cr, err := p.convertBackendCensorshipRecord(psr)
+	if err != nil {
+		return v1.ProposalRecord{}, err
+	}
+
+	// Convert record
+	pr := v1.ProposalRecord{
+		Status:           convertBackendStatus(psr.Status),
+		Name:             psr.Name,
+		Version:          psr.Version,
+		Timestamp:        psr.Timestamp,
+		Reason:           psr.Reason,
+		CensorshipRecord: cr,
+	}
+	if bpr.UserSignature != nil {
+		pr.UserSignature = convertBackendUserSignature(*bpr.UserSignature)
 	}
 	pr.Files = make([]v1.File, 0, len(bpr.Files))
 	for _, v := range bpr.Files {
@@ -107,7 +224,40 @@ func (p *politeia) convertBackendProposal(bpr backend.ProposalRecord) v1.Proposa
 			})
 	}
 
-	return pr
+	return pr, nil
+}
+
+// convertBackendCursor returns the opaque inventory cursor that points at the
+// provided record.
+func convertBackendCursor(psr backend.ProposalStorageRecord) string {
+	return strconv.FormatInt(psr.Timestamp, 10) + ":" +
+		hex.EncodeToString(psr.Token)
+}
+
+// convertFrontendCursor decodes an opaque inventory cursor.  An empty cursor
+// yields a nil InventoryCursor.
+func convertFrontendCursor(cursor string) (*backend.InventoryCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	s := strings.SplitN(cursor, ":", 2)
+	if len(s) != 2 {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	timestamp, err := strconv.ParseInt(s[0], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	token, err := util.ConvertStringToken(s[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return &backend.InventoryCursor{
+		Timestamp: timestamp,
+		Token:     token,
+	}, nil
 }
 
 func (p *politeia) respondWithUserError(w http.ResponseWriter,
@@ -124,6 +274,25 @@ func (p *politeia) respondWithServerError(w http.ResponseWriter, errorCode int64
 	})
 }
 
+// respondWithSignerError logs a signer failure and writes a server error
+// reply.
+func (p *politeia) respondWithSignerError(w http.ResponseWriter, r *http.Request, err error) {
+	errorCode := time.Now().Unix()
+	log.Errorf("%v Signer error code %v: %v", remoteAddr(r), errorCode, err)
+	p.respondWithServerError(w, errorCode)
+}
+
+// signResponse returns the hex encoded signature of challenge.  It writes an
+// error reply and returns false when the signer fails.
+func (p *politeia) signResponse(w http.ResponseWriter, r *http.Request, challenge []byte) (string, bool) {
+	signature, err := p.signer.SignMessage(challenge)
+	if err != nil {
+		p.respondWithSignerError(w, r, err)
+		return "", false
+	}
+	return hex.EncodeToString(signature[:]), true
+}
+
 func (p *politeia) getIdentity(w http.ResponseWriter, r *http.Request) {
 	var t v1.Identity
 	decoder := json.NewDecoder(r.Body)
@@ -138,14 +307,19 @@ func (p *politeia) getIdentity(w http.ResponseWriter, r *http.Request) {
 		p.respondWithUserError(w, v1.ErrorStatusInvalidChallenge, nil)
 		return
 	}
-	response := p.identity.SignMessage(challenge)
+	response, ok := p.signResponse(w, r, challenge)
+	if !ok {
+		return
+	}
 
+	pi := p.signer.PublicIdentity()
 	reply := v1.IdentityReply{
-		Name:     p.identity.Public.Name,
-		Nick:     p.identity.Public.Nick,
-		Identity: hex.EncodeToString(p.identity.Public.Identity[:]),
-		Key:      hex.EncodeToString(p.identity.Public.Key[:]),
-		Response: hex.EncodeToString(response[:]),
+		Name:     pi.Name,
+		Nick:     pi.Nick,
+		Identity: hex.EncodeToString(pi.Identity[:]),
+		Key:      hex.EncodeToString(pi.Key[:]),
+		Response: response,
+		KeyChain: p.keyChain,
 	}
 
 	util.RespondWithJSON(w, http.StatusOK, reply)
@@ -172,19 +346,26 @@ func (p *politeia) newProposal(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Verify the optional user signature against the files.
+	var us *backend.UserSignature
+	if t.UserSignature != nil {
+		err = v1.VerifyUserSignature(*t.UserSignature, t.Files)
+		if err == nil {
+			us, err = convertFrontendUserSignature(*t.UserSignature)
+		}
+		if err != nil {
+			log.Errorf("%v New proposal: invalid user signature: %v",
+				remoteAddr(r), err)
+			p.respondWithUserError(w,
+				v1.ErrorStatusInvalidUserSignature, nil)
+			return
+		}
+	}
+
 	log.Infof("New proposal submitted %v: %v", remoteAddr(r), t.Name)
 
 	// Convert to backend call
-	files := make([]backend.File, 0, len(t.Files))
-	for _, v := range t.Files {
-		files = append(files, backend.File{
-			Name:    v.Name,
-			MIME:    v.MIME,
-			Digest:  v.Digest,
-			Payload: v.Payload,
-		})
-	}
-	psr, err := p.backend.New(t.Name, files)
+	psr, err := p.backend.New(t.Name, convertFrontendFiles(t.Files), us)
 	if err != nil {
 		// Check for content error.
 		if contentErr, ok := err.(backend.ContentVerificationError); ok {
@@ -203,20 +384,19 @@ func (p *politeia) newProposal(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Prepare reply.
-	merkleToken := make([]byte, len(psr.Merkle)+len(psr.Token))
-	copy(merkleToken, psr.Merkle[:])
-	copy(merkleToken[len(psr.Merkle[:]):], psr.Token)
-	signature := p.identity.SignMessage(merkleToken)
-
-	response := p.identity.SignMessage(challenge)
+	response, ok := p.signResponse(w, r, challenge)
+	if !ok {
+		return
+	}
+	cr, err := p.convertBackendCensorshipRecord(*psr)
+	if err != nil {
+		p.respondWithSignerError(w, r, err)
+		return
+	}
 	reply := v1.NewReply{
-		Response:  hex.EncodeToString(response[:]),
-		Timestamp: psr.Timestamp,
-		CensorshipRecord: v1.CensorshipRecord{
-			Merkle:    hex.EncodeToString(psr.Merkle[:]),
-			Token:     hex.EncodeToString(psr.Token),
-			Signature: hex.EncodeToString(signature[:]),
-		},
+		Response:         response,
+		Timestamp:        psr.Timestamp,
+		CensorshipRecord: cr,
 	}
 
 	log.Infof("New proposal accepted %v: token %v name \"%v\"", remoteAddr(r),
@@ -239,10 +419,13 @@ func (p *politeia) getUnvetted(w http.ResponseWriter, r *http.Request) {
 		p.respondWithUserError(w, v1.ErrorStatusInvalidChallenge, nil)
 		return
 	}
-	response := p.identity.SignMessage(challenge)
+	response, ok := p.signResponse(w, r, challenge)
+	if !ok {
+		return
+	}
 
 	reply := v1.GetUnvettedReply{
-		Response: hex.EncodeToString(response[:]),
+		Response: response,
 	}
 
 	// Validate token
@@ -253,7 +436,12 @@ func (p *politeia) getUnvetted(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Ask backend about the censorship token.
-	bpr, err := p.backend.GetUnvetted(token)
+	var bpr *backend.ProposalRecord
+	if t.Version == 0 {
+		bpr, err = p.backend.GetUnvetted(token)
+	} else {
+		bpr, err = p.backend.GetUnvettedVersion(token, t.Version)
+	}
 	if err == backend.ErrProposalNotFound {
 		reply.Proposal.Status = v1.PropStatusNotFound
 		log.Errorf("Get unvetted proposal %v: token %v not found",
@@ -267,10 +455,14 @@ func (p *politeia) getUnvetted(w http.ResponseWriter, r *http.Request) {
 		p.respondWithServerError(w, errorCode)
 		return
 	} else {
-		reply.Proposal = p.convertBackendProposal(*bpr)
+		reply.Proposal, err = p.convertBackendProposal(*bpr)
+		if err != nil {
+			p.respondWithSignerError(w, r, err)
+			return
+		}
 
 		// Double check proposal bits before sending them off
-		err := v1.Verify(p.identity.Public,
+		err := v1.Verify(p.signer.PublicIdentity(),
 			reply.Proposal.CensorshipRecord, reply.Proposal.Files)
 		if err != nil {
 			// Generic internal error.
@@ -305,10 +497,13 @@ func (p *politeia) getVetted(w http.ResponseWriter, r *http.Request) {
 		p.respondWithUserError(w, v1.ErrorStatusInvalidChallenge, nil)
 		return
 	}
-	response := p.identity.SignMessage(challenge)
+	response, ok := p.signResponse(w, r, challenge)
+	if !ok {
+		return
+	}
 
 	reply := v1.GetVettedReply{
-		Response: hex.EncodeToString(response[:]),
+		Response: response,
 	}
 
 	// Validate token
@@ -319,7 +514,12 @@ func (p *politeia) getVetted(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Ask backend about the censorship token.
-	bpr, err := p.backend.GetVetted(token)
+	var bpr *backend.ProposalRecord
+	if t.Version == 0 {
+		bpr, err = p.backend.GetVetted(token)
+	} else {
+		bpr, err = p.backend.GetVettedVersion(token, t.Version)
+	}
 	if err == backend.ErrProposalNotFound {
 		reply.Proposal.Status = v1.PropStatusNotFound
 		log.Errorf("Get vetted proposal %v: token %v not found",
@@ -333,10 +533,14 @@ func (p *politeia) getVetted(w http.ResponseWriter, r *http.Request) {
 		p.respondWithServerError(w, errorCode)
 		return
 	} else {
-		reply.Proposal = p.convertBackendProposal(*bpr)
+		reply.Proposal, err = p.convertBackendProposal(*bpr)
+		if err != nil {
+			p.respondWithSignerError(w, r, err)
+			return
+		}
 
 		// Double check proposal bits before sending them off
-		err := v1.Verify(p.identity.Public,
+		err := v1.Verify(p.signer.PublicIdentity(),
 			reply.Proposal.CensorshipRecord, reply.Proposal.Files)
 		if err != nil {
 			// Generic internal error.
@@ -355,83 +559,155 @@ func (p *politeia) getVetted(w http.ResponseWriter, r *http.Request) {
 	util.RespondWithJSON(w, http.StatusOK, reply)
 }
 
-func (p *politeia) inventory(w http.ResponseWriter, r *http.Request) {
-	var i v1.Inventory
-	decoder := json.NewDecoder(r.Body)
-	if err := decoder.Decode(&i); err != nil {
-		p.respondWithUserError(w, v1.ErrorStatusInvalidRequestPayload, nil)
-		return
+// convertBackendVersions converts the backend history of a proposal to API
+// versions.
+func convertBackendVersions(pv []backend.ProposalVersion) []v1.ProposalVersion {
+	versions := make([]v1.ProposalVersion, 0, len(pv))
+	for _, v := range pv {
+		psr := v.ProposalStorageRecord
+		versions = append(versions, v1.ProposalVersion{
+			Commit:    hex.EncodeToString(v.Digest),
+			Version:   psr.Version,
+			Status:    convertBackendStatus(psr.Status),
+			Timestamp: psr.Timestamp,
+			Merkle:    hex.EncodeToString(psr.Merkle[:]),
+		})
 	}
-	defer r.Body.Close()
+	return versions
+}
 
-	challenge, err := hex.DecodeString(i.Challenge)
+// proposalVersions is the generic implementation of
+// getUnvettedVersions/getVettedVersions.  It returns the signed challenge
+// response and the proposal history.  It writes an error reply and returns
+// false on failure.
+func (p *politeia) proposalVersions(w http.ResponseWriter, r *http.Request, vetted bool, c string, t string) (string, []v1.ProposalVersion, bool) {
+	challenge, err := hex.DecodeString(c)
 	if err != nil || len(challenge) != v1.ChallengeSize {
 		p.respondWithUserError(w, v1.ErrorStatusInvalidChallenge, nil)
-		return
+		return "", nil, false
 	}
-	response := p.identity.SignMessage(challenge)
-
-	reply := v1.InventoryReply{
-		Response: hex.EncodeToString(response[:]),
+	response, ok := p.signResponse(w, r, challenge)
+	if !ok {
+		return "", nil, false
 	}
 
-	// Ask backend for inventory
-	prs, brs, err := p.backend.Inventory(i.VettedCount, i.BranchesCount,
-		i.IncludeFiles)
+	// Validate token
+	token, err := util.ConvertStringToken(t)
 	if err != nil {
+		p.respondWithUserError(w, v1.ErrorStatusInvalidRequestPayload, nil)
+		return "", nil, false
+	}
+
+	// Ask backend for the proposal history.
+	var pv []backend.ProposalVersion
+	cmd := "unvetted"
+	if vetted {
+		cmd = "vetted"
+		pv, err = p.backend.VettedVersions(token)
+	} else {
+		pv, err = p.backend.UnvettedVersions(token)
+	}
+	if err == backend.ErrProposalNotFound {
+		log.Errorf("Get %v versions %v: token %v not found", cmd,
+			remoteAddr(r), t)
+		p.respondWithUserError(w, v1.ErrorStatusProposalNotFound, nil)
+		return "", nil, false
+	} else if err != nil {
 		// Generic internal error.
 		errorCode := time.Now().Unix()
-		log.Errorf("%v Inventory error code %v: %v", remoteAddr(r),
-			errorCode, err)
+		log.Errorf("%v Get %v versions error code %v: %v",
+			remoteAddr(r), cmd, errorCode, err)
 
 		p.respondWithServerError(w, errorCode)
-		return
+		return "", nil, false
 	}
 
-	// Convert backend proposals
-	vetted := make([]v1.ProposalRecord, 0, len(prs))
-	for _, v := range prs {
-		vetted = append(vetted, p.convertBackendProposal(v))
+	log.Infof("Get %v versions %v: token %v versions %v", cmd,
+		remoteAddr(r), t, len(pv))
+
+	return response, convertBackendVersions(pv), true
+}
+
+func (p *politeia) getUnvettedVersions(w http.ResponseWriter, r *http.Request) {
+	var t v1.GetUnvettedVersions
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&t); err != nil {
+		p.respondWithUserError(w, v1.ErrorStatusInvalidRequestPayload, nil)
+		return
 	}
-	reply.Vetted = vetted
+	defer r.Body.Close()
 
-	// Convert branches
-	unvetted := make([]v1.ProposalRecord, 0, len(brs))
-	for _, v := range brs {
-		unvetted = append(unvetted, p.convertBackendProposal(v))
+	response, versions, ok := p.proposalVersions(w, r, false, t.Challenge,
+		t.Token)
+	if !ok {
+		return
 	}
-	reply.Branches = unvetted
 
-	util.RespondWithJSON(w, http.StatusOK, reply)
+	util.RespondWithJSON(w, http.StatusOK, v1.GetUnvettedVersionsReply{
+		Response: response,
+		Versions: versions,
+	})
 }
 
-func (p *politeia) check(user, pass string) bool {
-	if user != p.cfg.RPCUser || pass != p.cfg.RPCPass {
-		return false
+func (p *politeia) getVettedVersions(w http.ResponseWriter, r *http.Request) {
+	var t v1.GetVettedVersions
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&t); err != nil {
+		p.respondWithUserError(w, v1.ErrorStatusInvalidRequestPayload, nil)
+		return
 	}
-	return true
+	defer r.Body.Close()
+
+	response, versions, ok := p.proposalVersions(w, r, true, t.Challenge,
+		t.Token)
+	if !ok {
+		return
+	}
+
+	util.RespondWithJSON(w, http.StatusOK, v1.GetVettedVersionsReply{
+		Response: response,
+		Versions: versions,
+	})
 }
 
-func (p *politeia) auth(fn http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		user, pass, ok := r.BasicAuth()
-		if !ok || !p.check(user, pass) {
-			log.Errorf("%v Unauthorized access for: %v",
-				remoteAddr(r), user)
-			w.Header().Set("WWW-Authenticate",
-				`Basic realm="Politeiad"`)
-			w.WriteHeader(401)
-			w.Write([]byte("401 Unauthorized\n"))
-			return
+// convertBackendTimestamps converts backend timestamp proofs to API
+// timestamps.
+func convertBackendTimestamps(bts []backend.Timestamp) []v1.Timestamp {
+	ts := make([]v1.Timestamp, 0, len(bts))
+	for _, v := range bts {
+		t := v1.Timestamp{
+			Digest:         hex.EncodeToString(v.Digest),
+			Version:        v.Version,
+			ChainTimestamp: v.ChainTimestamp,
+			Transaction:    v.Transaction,
 		}
-		log.Infof("%v Authorized access for: %v",
-			remoteAddr(r), user)
-		fn(w, r)
+		switch v.State {
+		case backend.AnchorStateNotAnchored:
+			t.State = v1.AnchorStateNotAnchored
+		case backend.AnchorStateUnconfirmed:
+			t.State = v1.AnchorStateUnconfirmed
+		case backend.AnchorStateConfirmed:
+			t.State = v1.AnchorStateConfirmed
+		default:
+			t.State = v1.AnchorStateInvalid
+		}
+		if len(v.MerkleRoot) != 0 {
+			t.MerkleRoot = hex.EncodeToString(v.MerkleRoot)
+			mp := v.MerklePath
+			t.MerklePath = &mp
+		}
+		if len(v.ChainMerkleRoot) != 0 {
+			t.ChainMerkleRoot = hex.EncodeToString(v.ChainMerkleRoot)
+			cmp := v.ChainMerklePath
+			t.ChainMerklePath = &cmp
+		}
+		ts = append(ts, t)
 	}
+	return ts
 }
 
-func (p *politeia) setUnvettedStatus(w http.ResponseWriter, r *http.Request) {
-	var t v1.SetUnvettedStatus
+func (p *politeia) getTimestamps(w http.ResponseWriter, r *http.Request) {
+	var t v1.GetTimestamps
 	decoder := json.NewDecoder(r.Body)
 	if err := decoder.Decode(&t); err != nil {
 		p.respondWithUserError(w, v1.ErrorStatusInvalidRequestPayload, nil)
@@ -444,7 +720,10 @@ func (p *politeia) setUnvettedStatus(w http.ResponseWriter, r *http.Request) {
 		p.respondWithUserError(w, v1.ErrorStatusInvalidChallenge, nil)
 		return
 	}
-	response := p.identity.SignMessage(challenge)
+	response, ok := p.signResponse(w, r, challenge)
+	if !ok {
+		return
+	}
 
 	// Validate token
 	token, err := util.ConvertStringToken(t.Token)
@@ -453,93 +732,842 @@ func (p *politeia) setUnvettedStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Ask backend to update unvetted status
-	status, err := p.backend.SetUnvettedStatus(token,
-		convertFrontendStatus(t.Status))
-	if err != nil {
-		oldStatus := v1.PropStatus[convertBackendStatus(status)]
-		newStatus := v1.PropStatus[t.Status]
-		// Check for specific errors
-		if err == backend.ErrInvalidTransition {
-			log.Errorf("%v Invalid status code transition: "+
-				"%v %v->%v", remoteAddr(r), t.Token, oldStatus,
-				newStatus)
-			p.respondWithUserError(w, v1.ErrorStatusInvalidPropStatusTransition, nil)
-			return
-		}
+	// Ask backend for the timestamp proofs.
+	bts, err := p.backend.Timestamps(token)
+	if err == backend.ErrProposalNotFound {
+		log.Errorf("Get timestamps %v: token %v not found",
+			remoteAddr(r), t.Token)
+		p.respondWithUserError(w, v1.ErrorStatusProposalNotFound, nil)
+		return
+	} else if err != nil {
 		// Generic internal error.
 		errorCode := time.Now().Unix()
-		log.Errorf("%v Set unvetted status error code %v: %v",
+		log.Errorf("%v Get timestamps error code %v: %v",
 			remoteAddr(r), errorCode, err)
 
 		p.respondWithServerError(w, errorCode)
 		return
 	}
-	reply := v1.SetUnvettedStatusReply{
-		Response: hex.EncodeToString(response[:]),
-		Status:   convertBackendStatus(status),
-	}
 
-	log.Infof("Set unvetted proposal status %v: token %v status %v",
-		remoteAddr(r), t.Token, v1.PropStatus[reply.Status])
+	log.Infof("Get timestamps %v: token %v commits %v", remoteAddr(r),
+		t.Token, len(bts))
 
-	util.RespondWithJSON(w, http.StatusOK, reply)
+	util.RespondWithJSON(w, http.StatusOK, v1.GetTimestampsReply{
+		Response:   response,
+		Timestamps: convertBackendTimestamps(bts),
+	})
 }
 
-// getError returns the error that is embedded in a JSON reply.
-func getError(r io.Reader) (string, error) {
-	var e interface{}
-	decoder := json.NewDecoder(r)
-	if err := decoder.Decode(&e); err != nil {
-		return "", err
-	}
-	m, ok := e.(map[string]interface{})
-	if !ok {
-		return "", fmt.Errorf("Could not decode response")
-	}
-	rError, ok := m["error"]
-	if !ok {
-		return "", fmt.Errorf("No error response")
+// updateProposal is the generic implementation of updateUnvetted and
+// updateVetted.  It replaces the files of a proposal and replies with the new
+// CensorshipRecord.
+func (p *politeia) updateProposal(w http.ResponseWriter, r *http.Request, vetted bool, c string, t string, f []v1.File) {
+	cmd := "unvetted"
+	if vetted {
+		cmd = "vetted"
 	}
-	return fmt.Sprintf("%v", rError), nil
-}
-
-func logging(f http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Trace incoming request
-		log.Tracef("%v", newLogClosure(func() string {
-			trace, err := httputil.DumpRequest(r, true)
-			if err != nil {
-				trace = []byte(fmt.Sprintf("logging: "+
-					"DumpRequest %v", err))
-			}
-			return string(trace)
-		}))
 
-		// Log incoming connection
-		log.Infof("%v %v %v %v", remoteAddr(r), r.Method, r.URL, r.Proto)
-		f(w, r)
+	challenge, err := hex.DecodeString(c)
+	if err != nil || len(challenge) != v1.ChallengeSize {
+		log.Errorf("%v Update %v proposal: invalid challenge",
+			remoteAddr(r), cmd)
+		p.respondWithUserError(w, v1.ErrorStatusInvalidChallenge, nil)
+		return
 	}
-}
 
-func _main() error {
-	// Load configuration and parse command line.  This function also
-	// initializes logging and configures it accordingly.
-	loadedCfg, _, err := loadConfig()
+	// Validate token
+	token, err := util.ConvertStringToken(t)
 	if err != nil {
-		return fmt.Errorf("Could not load configuration file: %v", err)
+		p.respondWithUserError(w, v1.ErrorStatusInvalidRequestPayload, nil)
+		return
 	}
-	defer func() {
-		if logRotator != nil {
-			logRotator.Close()
-		}
-	}()
 
-	log.Infof("Version : %v", version())
-	log.Infof("Network : %v", activeNetParams.Params.Name)
-	log.Infof("Home dir: %v", loadedCfg.HomeDir)
+	log.Infof("Update %v proposal submitted %v: %v", cmd, remoteAddr(r), t)
 
-	// Create the data directory in case it does not exist.
+	// Ask backend to update the proposal
+	var psr *backend.ProposalStorageRecord
+	if vetted {
+		psr, err = p.backend.UpdateVettedRecord(token,
+			convertFrontendFiles(f))
+	} else {
+		psr, err = p.backend.UpdateUnvettedRecord(token,
+			convertFrontendFiles(f))
+	}
+	if err != nil {
+		// Check for specific errors
+		switch err {
+		case backend.ErrProposalNotFound:
+			log.Errorf("%v Update %v proposal: token %v not found",
+				remoteAddr(r), cmd, t)
+			p.respondWithUserError(w, v1.ErrorStatusProposalNotFound,
+				nil)
+			return
+		case backend.ErrInvalidTransition:
+			log.Errorf("%v Update %v proposal: token %v can not be "+
+				"updated", remoteAddr(r), cmd, t)
+			p.respondWithUserError(w,
+				v1.ErrorStatusInvalidPropStatusTransition, nil)
+			return
+		case backend.ErrNoChanges:
+			log.Errorf("%v Update %v proposal: token %v no changes",
+				remoteAddr(r), cmd, t)
+			p.respondWithUserError(w, v1.ErrorStatusNoChanges, nil)
+			return
+		}
+
+		// Check for content error.
+		if contentErr, ok := err.(backend.ContentVerificationError); ok {
+			log.Errorf("%v Update %v proposal content error: %v %v",
+				remoteAddr(r), cmd, t, contentErr)
+			p.respondWithUserError(w, contentErr.ErrorCode,
+				contentErr.ErrorContext)
+			return
+		}
+
+		// Generic internal error.
+		errorCode := time.Now().Unix()
+		log.Errorf("%v Update %v proposal error code %v: %v",
+			remoteAddr(r), cmd, errorCode, err)
+		p.respondWithServerError(w, errorCode)
+		return
+	}
+
+	// Prepare reply.
+	response, ok := p.signResponse(w, r, challenge)
+	if !ok {
+		return
+	}
+	cr, err := p.convertBackendCensorshipRecord(*psr)
+	if err != nil {
+		p.respondWithSignerError(w, r, err)
+		return
+	}
+	var reply interface{}
+	if vetted {
+		reply = v1.UpdateVettedReply{
+			Response:         response,
+			Timestamp:        psr.Timestamp,
+			CensorshipRecord: cr,
+		}
+	} else {
+		reply = v1.UpdateUnvettedReply{
+			Response:         response,
+			Timestamp:        psr.Timestamp,
+			CensorshipRecord: cr,
+		}
+	}
+
+	log.Infof("Update %v proposal accepted %v: token %v version %v",
+		cmd, remoteAddr(r), t, psr.Version)
+
+	util.RespondWithJSON(w, http.StatusOK, reply)
+}
+
+func (p *politeia) updateUnvetted(w http.ResponseWriter, r *http.Request) {
+	var t v1.UpdateUnvetted
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&t); err != nil {
+		p.respondWithUserError(w, v1.ErrorStatusInvalidRequestPayload, nil)
+		return
+	}
+	defer r.Body.Close()
+
+	p.updateProposal(w, r, false, t.Challenge, t.Token, t.Files)
+}
+
+func (p *politeia) updateVetted(w http.ResponseWriter, r *http.Request) {
+	var t v1.UpdateVetted
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&t); err != nil {
+		p.respondWithUserError(w, v1.ErrorStatusInvalidRequestPayload, nil)
+		return
+	}
+	defer r.Body.Close()
+
+	p.updateProposal(w, r, true, t.Challenge, t.Token, t.Files)
+}
+
+func (p *politeia) inventory(w http.ResponseWriter, r *http.Request) {
+	var i v1.Inventory
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&i); err != nil {
+		p.respondWithUserError(w, v1.ErrorStatusInvalidRequestPayload, nil)
+		return
+	}
+	defer r.Body.Close()
+
+	challenge, err := hex.DecodeString(i.Challenge)
+	if err != nil || len(challenge) != v1.ChallengeSize {
+		p.respondWithUserError(w, v1.ErrorStatusInvalidChallenge, nil)
+		return
+	}
+	response, ok := p.signResponse(w, r, challenge)
+	if !ok {
+		return
+	}
+
+	reply := v1.InventoryReply{
+		Response: response,
+	}
+
+	// Validate cursors
+	vettedCursor, err := convertFrontendCursor(i.VettedCursor)
+	if err != nil {
+		p.respondWithUserError(w, v1.ErrorStatusInvalidRequestPayload, nil)
+		return
+	}
+	branchesCursor, err := convertFrontendCursor(i.BranchesCursor)
+	if err != nil {
+		p.respondWithUserError(w, v1.ErrorStatusInvalidRequestPayload, nil)
+		return
+	}
+
+	// Ask backend for inventory
+	prs, brs, err := p.backend.Inventory(backend.InventoryRequest{
+		Count:  i.VettedCount,
+		Since:  i.Since,
+		Cursor: vettedCursor,
+		Status: convertFrontendStatuses(i.VettedStatus),
+	}, backend.InventoryRequest{
+		Count:  i.BranchesCount,
+		Since:  i.Since,
+		Cursor: branchesCursor,
+		Status: convertFrontendStatuses(i.BranchesStatus),
+	}, i.IncludeFiles)
+	if err != nil {
+		// Generic internal error.
+		errorCode := time.Now().Unix()
+		log.Errorf("%v Inventory error code %v: %v", remoteAddr(r),
+			errorCode, err)
+
+		p.respondWithServerError(w, errorCode)
+		return
+	}
+
+	// Convert backend proposals
+	vetted := make([]v1.ProposalRecord, 0, len(prs))
+	for _, v := range prs {
+		pr, err := p.convertBackendProposal(v)
+		if err != nil {
+			p.respondWithSignerError(w, r, err)
+			return
+		}
+		vetted = append(vetted, pr)
+	}
+	reply.Vetted = vetted
+
+	// Convert branches
+	unvetted := make([]v1.ProposalRecord, 0, len(brs))
+	for _, v := range brs {
+		pr, err := p.convertBackendProposal(v)
+		if err != nil {
+			p.respondWithSignerError(w, r, err)
+			return
+		}
+		unvetted = append(unvetted, pr)
+	}
+	reply.Branches = unvetted
+
+	// Full pages may be followed by more records.
+	if i.VettedCount != 0 && uint(len(prs)) == i.VettedCount {
+		reply.VettedCursor = convertBackendCursor(
+			prs[len(prs)-1].ProposalStorageRecord)
+	}
+	if i.BranchesCount != 0 && uint(len(brs)) == i.BranchesCount {
+		reply.BranchesCursor = convertBackendCursor(
+			brs[len(brs)-1].ProposalStorageRecord)
+	}
+
+	util.RespondWithJSON(w, http.StatusOK, reply)
+}
+
+func (p *politeia) check(user, pass string) bool {
+	if user != p.cfg.RPCUser || pass != p.cfg.RPCPass {
+		return false
+	}
+	return true
+}
+
+func (p *politeia) auth(fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || !p.check(user, pass) {
+			log.Errorf("%v Unauthorized access for: %v",
+				remoteAddr(r), user)
+			w.Header().Set("WWW-Authenticate",
+				`Basic realm="Politeiad"`)
+			w.WriteHeader(401)
+			w.Write([]byte("401 Unauthorized\n"))
+			return
+		}
+		log.Infof("%v Authorized access for: %v",
+			remoteAddr(r), user)
+		fn(w, r)
+	}
+}
+
+// userError is returned by the helpers of the handlers when a request is
+// rejected.  The handler replies with its error code.
+type userError struct {
+	ErrorCode v1.ErrorStatusT
+}
+
+// Error satisfies the error interface.
+func (u userError) Error() string {
+	return v1.ErrorStatus[u.ErrorCode]
+}
+
+// respondWithError replies with the error code of a userError and with a
+// server error for any other error.
+func (p *politeia) respondWithError(w http.ResponseWriter, r *http.Request, cmd string, err error) {
+	if ue, ok := err.(userError); ok {
+		p.respondWithUserError(w, ue.ErrorCode, nil)
+		return
+	}
+
+	// Generic internal error.
+	errorCode := time.Now().Unix()
+	log.Errorf("%v %v error code %v: %v", remoteAddr(r), cmd, errorCode,
+		err)
+	p.respondWithServerError(w, errorCode)
+}
+
+// setStatus is the generic implementation of setUnvettedStatus and
+// setVettedStatus.  It changes the status of proposal token to status and
+// returns the signed reply.  Rejected requests return a userError.
+func (p *politeia) setStatus(r *http.Request, vetted bool, challenge, token string, status v1.PropStatusT, reason string) (*v1.SetUnvettedStatusReply, error) {
+	cmd := "unvetted"
+	if vetted {
+		cmd = "vetted"
+	}
+
+	c, err := hex.DecodeString(challenge)
+	if err != nil || len(c) != v1.ChallengeSize {
+		return nil, userError{v1.ErrorStatusInvalidChallenge}
+	}
+	response, err := p.signer.SignMessage(c)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate token
+	t, err := util.ConvertStringToken(token)
+	if err != nil {
+		return nil, userError{v1.ErrorStatusInvalidRequestPayload}
+	}
+
+	// Ask backend to update status
+	var actual backend.PSRStatusT
+	if vetted {
+		actual, err = p.backend.SetVettedStatus(t,
+			convertFrontendStatus(status), reason)
+	} else {
+		actual, err = p.backend.SetUnvettedStatus(t,
+			convertFrontendStatus(status), reason)
+	}
+	switch err {
+	case nil:
+	case backend.ErrInvalidTransition:
+		log.Errorf("%v Invalid status code transition: %v %v->%v",
+			remoteAddr(r), token,
+			v1.PropStatus[convertBackendStatus(actual)],
+			v1.PropStatus[status])
+		return nil, userError{v1.ErrorStatusInvalidPropStatusTransition}
+	case backend.ErrProposalNotFound:
+		log.Errorf("Set %v status %v: token %v not found", cmd,
+			remoteAddr(r), token)
+		return nil, userError{v1.ErrorStatusProposalNotFound}
+	default:
+		return nil, err
+	}
+
+	// Sign the new status.  Published proposals moved to the vetted
+	// repo.
+	var pr *backend.ProposalRecord
+	if backend.StatusVetted(actual) {
+		pr, err = p.backend.GetVetted(t)
+	} else {
+		pr, err = p.backend.GetUnvetted(t)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sr, err := p.signStatusRecord(pr.ProposalStorageRecord)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("Set %v proposal status %v: token %v status %v", cmd,
+		remoteAddr(r), token, v1.PropStatus[convertBackendStatus(actual)])
+
+	return &v1.SetUnvettedStatusReply{
+		Response:     hex.EncodeToString(response[:]),
+		Status:       convertBackendStatus(actual),
+		Version:      pr.ProposalStorageRecord.Version,
+		StatusRecord: *sr,
+	}, nil
+}
+
+func (p *politeia) setUnvettedStatus(w http.ResponseWriter, r *http.Request) {
+	var t v1.SetUnvettedStatus
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&t); err != nil {
+		p.respondWithUserError(w, v1.ErrorStatusInvalidRequestPayload, nil)
+		return
+	}
+	defer r.Body.Close()
+
+	reply, err := p.setStatus(r, false, t.Challenge, t.Token, t.Status,
+		t.Reason)
+	if err != nil {
+		p.respondWithError(w, r, "Set unvetted status", err)
+		return
+	}
+
+	util.RespondWithJSON(w, http.StatusOK, *reply)
+}
+
+func (p *politeia) setVettedStatus(w http.ResponseWriter, r *http.Request) {
+	var t v1.SetVettedStatus
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&t); err != nil {
+		p.respondWithUserError(w, v1.ErrorStatusInvalidRequestPayload, nil)
+		return
+	}
+	defer r.Body.Close()
+
+	reply, err := p.setStatus(r, true, t.Challenge, t.Token, t.Status,
+		t.Reason)
+	if err != nil {
+		p.respondWithError(w, r, "Set vetted status", err)
+		return
+	}
+
+	util.RespondWithJSON(w, http.StatusOK, v1.SetVettedStatusReply(*reply))
+}
+
+// getStatusRecord returns the signed status record of a censored proposal.
+// Proposals in any other state are reported as not found in order not to leak
+// unvetted content.
+func (p *politeia) getStatusRecord(w http.ResponseWriter, r *http.Request) {
+	var t v1.GetStatusRecord
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&t); err != nil {
+		p.respondWithUserError(w, v1.ErrorStatusInvalidRequestPayload, nil)
+		return
+	}
+	defer r.Body.Close()
+
+	challenge, err := hex.DecodeString(t.Challenge)
+	if err != nil || len(challenge) != v1.ChallengeSize {
+		p.respondWithUserError(w, v1.ErrorStatusInvalidChallenge, nil)
+		return
+	}
+	response, ok := p.signResponse(w, r, challenge)
+	if !ok {
+		return
+	}
+
+	// Validate token
+	token, err := util.ConvertStringToken(t.Token)
+	if err != nil {
+		p.respondWithUserError(w, v1.ErrorStatusInvalidRequestPayload, nil)
+		return
+	}
+
+	// Censored proposals remain in the unvetted repo.
+	pr, err := p.backend.GetUnvetted(token)
+	if err == backend.ErrProposalNotFound || (err == nil &&
+		pr.ProposalStorageRecord.Status != backend.PSRStatusCensored) {
+		log.Errorf("Get status record %v: token %v not found",
+			remoteAddr(r), t.Token)
+		p.respondWithUserError(w, v1.ErrorStatusProposalNotFound, nil)
+		return
+	}
+	var sr *v1.StatusRecord
+	if err == nil {
+		sr, err = p.signStatusRecord(pr.ProposalStorageRecord)
+	}
+	if err != nil {
+		// Generic internal error.
+		errorCode := time.Now().Unix()
+		log.Errorf("%v Get status record error code %v: %v",
+			remoteAddr(r), errorCode, err)
+
+		p.respondWithServerError(w, errorCode)
+		return
+	}
+
+	log.Infof("Get status record %v: token %v", remoteAddr(r), t.Token)
+
+	util.RespondWithJSON(w, http.StatusOK, v1.GetStatusRecordReply{
+		Response:     response,
+		StatusRecord: *sr,
+	})
+}
+
+// convertFrontendComments converts API comments to backend comments.
+func convertFrontendComments(c []v1.Comment) []backend.Comment {
+	comments := make([]backend.Comment, 0, len(c))
+	for _, v := range c {
+		comments = append(comments, backend.Comment{
+			CommentID: v.CommentID,
+			UserID:    v.UserID,
+			ParentID:  v.ParentID,
+			Timestamp: v.Timestamp,
+			Comment:   v.Comment,
+			Censored:  v.Censored,
+			Reason:    v.Reason,
+		})
+	}
+	return comments
+}
+
+// convertBackendComments converts backend comments to API comments.
+func convertBackendComments(c []backend.Comment) []v1.Comment {
+	comments := make([]v1.Comment, 0, len(c))
+	for _, v := range c {
+		comments = append(comments, v1.Comment{
+			CommentID: v.CommentID,
+			UserID:    v.UserID,
+			ParentID:  v.ParentID,
+			Timestamp: v.Timestamp,
+			Comment:   v.Comment,
+			Censored:  v.Censored,
+			Reason:    v.Reason,
+		})
+	}
+	return comments
+}
+
+func (p *politeia) appendComments(w http.ResponseWriter, r *http.Request) {
+	var t v1.AppendComments
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&t); err != nil {
+		p.respondWithUserError(w, v1.ErrorStatusInvalidRequestPayload, nil)
+		return
+	}
+	defer r.Body.Close()
+
+	challenge, err := hex.DecodeString(t.Challenge)
+	if err != nil || len(challenge) != v1.ChallengeSize {
+		p.respondWithUserError(w, v1.ErrorStatusInvalidChallenge, nil)
+		return
+	}
+
+	// Validate token
+	token, err := util.ConvertStringToken(t.Token)
+	if err != nil {
+		p.respondWithUserError(w, v1.ErrorStatusInvalidRequestPayload, nil)
+		return
+	}
+
+	appended, err := p.backend.AppendComments(token,
+		convertFrontendComments(t.Comments))
+	if err != nil {
+		if err == backend.ErrProposalNotFound {
+			log.Errorf("Append comments %v: token %v not found",
+				remoteAddr(r), t.Token)
+			p.respondWithUserError(w, v1.ErrorStatusProposalNotFound,
+				nil)
+			return
+		}
+		if contentErr, ok := err.(backend.ContentVerificationError); ok {
+			log.Errorf("%v Append comments content error: %v %v",
+				remoteAddr(r), t.Token, contentErr)
+			p.respondWithUserError(w, contentErr.ErrorCode,
+				contentErr.ErrorContext)
+			return
+		}
+
+		// Generic internal error.
+		errorCode := time.Now().Unix()
+		log.Errorf("%v Append comments error code %v: %v",
+			remoteAddr(r), errorCode, err)
+		p.respondWithServerError(w, errorCode)
+		return
+	}
+
+	// Prepare reply.
+	response, ok := p.signResponse(w, r, challenge)
+	if !ok {
+		return
+	}
+
+	log.Infof("Append comments %v: token %v comments %v appended %v",
+		remoteAddr(r), t.Token, len(t.Comments), appended)
+
+	util.RespondWithJSON(w, http.StatusOK, v1.AppendCommentsReply{
+		Response: response,
+		Appended: appended,
+	})
+}
+
+func (p *politeia) censorComment(w http.ResponseWriter, r *http.Request) {
+	var t v1.CensorComment
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&t); err != nil {
+		p.respondWithUserError(w, v1.ErrorStatusInvalidRequestPayload, nil)
+		return
+	}
+	defer r.Body.Close()
+
+	challenge, err := hex.DecodeString(t.Challenge)
+	if err != nil || len(challenge) != v1.ChallengeSize {
+		p.respondWithUserError(w, v1.ErrorStatusInvalidChallenge, nil)
+		return
+	}
+
+	// Validate token
+	token, err := util.ConvertStringToken(t.Token)
+	if err != nil {
+		p.respondWithUserError(w, v1.ErrorStatusInvalidRequestPayload, nil)
+		return
+	}
+
+	err = p.backend.CensorComment(token, t.CommentID, t.Reason)
+	if err == backend.ErrProposalNotFound {
+		log.Errorf("Censor comment %v: token %v not found",
+			remoteAddr(r), t.Token)
+		p.respondWithUserError(w, v1.ErrorStatusProposalNotFound, nil)
+		return
+	} else if err == backend.ErrCommentNotFound {
+		log.Errorf("Censor comment %v: comment %v of %v not found",
+			remoteAddr(r), t.CommentID, t.Token)
+		p.respondWithUserError(w, v1.ErrorStatusCommentNotFound, nil)
+		return
+	} else if err != nil {
+		// Generic internal error.
+		errorCode := time.Now().Unix()
+		log.Errorf("%v Censor comment error code %v: %v",
+			remoteAddr(r), errorCode, err)
+		p.respondWithServerError(w, errorCode)
+		return
+	}
+
+	// Prepare reply.
+	response, ok := p.signResponse(w, r, challenge)
+	if !ok {
+		return
+	}
+
+	log.Infof("Censor comment %v: token %v comment %v", remoteAddr(r),
+		t.Token, t.CommentID)
+
+	util.RespondWithJSON(w, http.StatusOK, v1.CensorCommentReply{
+		Response: response,
+	})
+}
+
+func (p *politeia) getComments(w http.ResponseWriter, r *http.Request) {
+	var t v1.GetComments
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&t); err != nil {
+		p.respondWithUserError(w, v1.ErrorStatusInvalidRequestPayload, nil)
+		return
+	}
+	defer r.Body.Close()
+
+	challenge, err := hex.DecodeString(t.Challenge)
+	if err != nil || len(challenge) != v1.ChallengeSize {
+		p.respondWithUserError(w, v1.ErrorStatusInvalidChallenge, nil)
+		return
+	}
+	response, ok := p.signResponse(w, r, challenge)
+	if !ok {
+		return
+	}
+
+	// Validate token
+	token, err := util.ConvertStringToken(t.Token)
+	if err != nil {
+		p.respondWithUserError(w, v1.ErrorStatusInvalidRequestPayload, nil)
+		return
+	}
+
+	comments, err := p.backend.GetComments(token)
+	if err == backend.ErrProposalNotFound {
+		log.Errorf("Get comments %v: token %v not found",
+			remoteAddr(r), t.Token)
+		p.respondWithUserError(w, v1.ErrorStatusProposalNotFound, nil)
+		return
+	} else if err != nil {
+		// Generic internal error.
+		errorCode := time.Now().Unix()
+		log.Errorf("%v Get comments error code %v: %v",
+			remoteAddr(r), errorCode, err)
+		p.respondWithServerError(w, errorCode)
+		return
+	}
+
+	log.Infof("Get comments %v: token %v comments %v", remoteAddr(r),
+		t.Token, len(comments))
+
+	util.RespondWithJSON(w, http.StatusOK, v1.GetCommentsReply{
+		Response: response,
+		Comments: convertBackendComments(comments),
+	})
+}
+
+// getError returns the error that is embedded in a JSON reply.
+func getError(r io.Reader) (string, error) {
+	var e interface{}
+	decoder := json.NewDecoder(r)
+	if err := decoder.Decode(&e); err != nil {
+		return "", err
+	}
+	m, ok := e.(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("Could not decode response")
+	}
+	rError, ok := m["error"]
+	if !ok {
+		return "", fmt.Errorf("No error response")
+	}
+	return fmt.Sprintf("%v", rError), nil
+}
+
+func logging(f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Trace incoming request
+		log.Tracef("%v", newLogClosure(func() string {
+			trace, err := httputil.DumpRequest(r, true)
+			if err != nil {
+				trace = []byte(fmt.Sprintf("logging: "+
+					"DumpRequest %v", err))
+			}
+			return string(trace)
+		}))
+
+		// Log incoming connection
+		log.Infof("%v %v %v %v", remoteAddr(r), r.Method, r.URL, r.Proto)
+		f(w, r)
+	}
+}
+
+// rotateIdentity replaces the identity of politeiad with a new one and
+// appends the rotation, signed by the old identity, to the key chain.  The old
+// public keys remain in the key chain so that the records they signed can
+// still be verified.  The new identity is encrypted with passphrase unless it
+// is nil.
+func (p *politeia) rotateIdentity(passphrase []byte) error {
+	id, err := identity.New(p.identity.Public.Name, p.identity.Public.Nick)
+	if err != nil {
+		return err
+	}
+
+	kr := v1.KeyRotation{
+		OldKey:    hex.EncodeToString(p.identity.Public.Key[:]),
+		NewKey:    hex.EncodeToString(id.Public.Key[:]),
+		Timestamp: time.Now().Unix(),
+	}
+	message, err := v1.KeyRotationMessage(kr)
+	if err != nil {
+		return err
+	}
+	signature := p.identity.SignMessage(message)
+	kr.Signature = hex.EncodeToString(signature[:])
+	chain := append(p.keyChain, kr)
+
+	// Write both files before replacing either of them.
+	newKeyChain := p.cfg.KeyChain + ".new"
+	newIdentity := p.cfg.Identity + ".new"
+	err = util.SaveKeyChain(newKeyChain, chain)
+	if err != nil {
+		return err
+	}
+	if passphrase != nil {
+		err = id.SaveEncrypted(newIdentity, passphrase)
+	} else {
+		err = id.Save(newIdentity)
+	}
+	if err != nil {
+		return err
+	}
+	err = os.Rename(newKeyChain, p.cfg.KeyChain)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(newIdentity, p.cfg.Identity)
+	if err != nil {
+		return fmt.Errorf("key chain updated but identity was not, "+
+			"move %v to %v: %v", newIdentity, p.cfg.Identity, err)
+	}
+
+	log.Infof("Identity rotated")
+	log.Infof("Old key: %v", kr.OldKey)
+	log.Infof("New key: %v", kr.NewKey)
+
+	return nil
+}
+
+// encryptIdentity encrypts a plaintext identity in place.  The passphrase is
+// read from the configured file or environment variable, or prompted for
+// twice when neither is set.
+func encryptIdentity(cfg *config) error {
+	id, err := identity.LoadFullIdentity(cfg.Identity, nil)
+	if err == identity.ErrPassphraseRequired {
+		return fmt.Errorf("identity %v is already encrypted", cfg.Identity)
+	} else if err != nil {
+		return err
+	}
+
+	var passphrase []byte
+	if cfg.IdentityPassFile != "" || cfg.IdentityPassEnv != "" {
+		passphrase, err = util.IdentityPassphrase(cfg.IdentityPassEnv,
+			cfg.IdentityPassFile)()
+		if err != nil {
+			return err
+		}
+	} else {
+		passphrase, err = util.PassphrasePrompt("New identity " +
+			"passphrase: ")()
+		if err != nil {
+			return err
+		}
+		confirm, err := util.PassphrasePrompt("Confirm passphrase: ")()
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(passphrase, confirm) {
+			return fmt.Errorf("passphrases do not match")
+		}
+	}
+
+	// Write the encrypted identity before replacing the plaintext one.
+	newIdentity := cfg.Identity + ".new"
+	err = id.SaveEncrypted(newIdentity, passphrase)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(newIdentity, cfg.Identity)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Identity encrypted: %v", cfg.Identity)
+
+	return nil
+}
+
+func _main() error {
+	// Load configuration and parse command line.  This function also
+	// initializes logging and configures it accordingly.
+	loadedCfg, _, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("Could not load configuration file: %v", err)
+	}
+	defer func() {
+		if logRotator != nil {
+			logRotator.Close()
+		}
+	}()
+
+	log.Infof("Version : %v", version())
+	log.Infof("Network : %v", activeNetParams.Params.Name)
+	log.Infof("Home dir: %v", loadedCfg.HomeDir)
+
+	// Create the data directory in case it does not exist.
 	err = os.MkdirAll(loadedCfg.DataDir, 0700)
 	if err != nil {
 		return err
@@ -561,8 +1589,9 @@ func _main() error {
 		log.Infof("HTTPS keypair created...")
 	}
 
-	// Generate ed25519 identity to save messages, tokens etc.
-	if !fileExists(loadedCfg.Identity) {
+	// Generate ed25519 identity to save messages, tokens etc.  An
+	// external signer keeps its own identity.
+	if loadedCfg.Signer == "" && !fileExists(loadedCfg.Identity) {
 		log.Infof("Generating signing identity...")
 		id, err := identity.New(util.FQDN(), "politeiad")
 		if err != nil {
@@ -580,12 +1609,61 @@ func _main() error {
 		cfg: loadedCfg,
 	}
 
-	// Load identity.
-	p.identity, err = identity.LoadFullIdentity(loadedCfg.Identity)
+	if loadedCfg.EncryptIdentity {
+		return encryptIdentity(loadedCfg)
+	}
+
+	// Connect to the external signer or load the identity.  The
+	// passphrase of an encrypted identity is kept around in case the
+	// identity is rotated.
+	var passphrase []byte
+	if loadedCfg.Signer != "" {
+		if loadedCfg.RotateKey {
+			return fmt.Errorf("rotatekey can not be used with an " +
+				"external signer")
+		}
+		p.signer, err = signer.Dial(loadedCfg.Signer)
+		if err != nil {
+			return fmt.Errorf("signer %v: %v", loadedCfg.Signer, err)
+		}
+		log.Infof("External signer: %v", loadedCfg.Signer)
+	} else {
+		p.identity, err = identity.LoadFullIdentity(loadedCfg.Identity,
+			func() ([]byte, error) {
+				pass, err := util.IdentityPassphrase(
+					loadedCfg.IdentityPassEnv,
+					loadedCfg.IdentityPassFile)()
+				if err != nil {
+					return nil, err
+				}
+				passphrase = append([]byte(nil), pass...)
+				return pass, nil
+			})
+		if err != nil {
+			return err
+		}
+		p.signer = signer.NewLocal(p.identity)
+	}
+	pi := p.signer.PublicIdentity()
+	log.Infof("Public identity: %x", pi.Identity)
+
+	// Load the key chain and make sure it ends at the identity.
+	p.keyChain, err = util.LoadKeyChain(loadedCfg.KeyChain)
 	if err != nil {
 		return err
 	}
-	log.Infof("Public identity: %x", p.identity.Public.Identity)
+	err = v1.VerifyKeyChain(p.keyChain, hex.EncodeToString(pi.Key[:]))
+	if err != nil {
+		return fmt.Errorf("invalid key chain %v: %v", loadedCfg.KeyChain,
+			err)
+	}
+	if len(p.keyChain) != 0 {
+		log.Infof("Key rotations: %v", len(p.keyChain))
+	}
+
+	if loadedCfg.RotateKey {
+		return p.rotateIdentity(passphrase)
+	}
 
 	// Load certs, if there.  If they aren't there assume OS is used to
 	// resolve cert validity.
@@ -607,13 +1685,20 @@ func _main() error {
 	}
 
 	// Setup backend.
-	gitbe.UseLogger(gitbeLog)
-	b, err := gitbe.New(loadedCfg.DataDir, loadedCfg.DcrtimeHost, "",
-		loadedCfg.GitTrace)
+	switch loadedCfg.Backend {
+	case backendGit:
+		gitbe.UseLogger(gitbeLog)
+		p.backend, err = gitbe.New(loadedCfg.DataDir,
+			loadedCfg.DcrtimeHost, "", loadedCfg.GitTrace)
+	case backendMemory:
+		memorybe.UseLogger(memorybeLog)
+		p.backend, err = memorybe.New(loadedCfg.MemoryDB)
+	default:
+		err = fmt.Errorf("invalid backend: %v", loadedCfg.Backend)
+	}
 	if err != nil {
 		return err
 	}
-	p.backend = b
 
 	// Setup mux
 	p.router = mux.NewRouter()
@@ -627,12 +1712,32 @@ func _main() error {
 		logging(p.getUnvetted)).Methods("POST")
 	p.router.HandleFunc(v1.GetVettedRoute,
 		logging(p.getVetted)).Methods("POST")
+	p.router.HandleFunc(v1.GetUnvettedVersionsRoute,
+		logging(p.getUnvettedVersions)).Methods("POST")
+	p.router.HandleFunc(v1.GetVettedVersionsRoute,
+		logging(p.getVettedVersions)).Methods("POST")
+	p.router.HandleFunc(v1.GetTimestampsRoute,
+		logging(p.getTimestamps)).Methods("POST")
+	p.router.HandleFunc(v1.GetStatusRecordRoute,
+		logging(p.getStatusRecord)).Methods("POST")
+	p.router.HandleFunc(v1.GetCommentsRoute,
+		logging(p.getComments)).Methods("POST")
 
 	// Routes that require auth
 	p.router.HandleFunc(v1.InventoryRoute,
 		logging(p.auth(p.inventory))).Methods("POST")
+	p.router.HandleFunc(v1.SetVettedStatusRoute,
+		logging(p.auth(p.setVettedStatus))).Methods("POST")
 	p.router.HandleFunc(v1.SetUnvettedStatusRoute,
 		logging(p.auth(p.setUnvettedStatus))).Methods("POST")
+	p.router.HandleFunc(v1.UpdateUnvettedRoute,
+		logging(p.auth(p.updateUnvetted))).Methods("POST")
+	p.router.HandleFunc(v1.UpdateVettedRoute,
+		logging(p.auth(p.updateVetted))).Methods("POST")
+	p.router.HandleFunc(v1.AppendCommentsRoute,
+		logging(p.auth(p.appendComments))).Methods("POST")
+	p.router.HandleFunc(v1.CensorCommentRoute,
+		logging(p.auth(p.censorComment))).Methods("POST")
 
 	// Bind to a port and pass our router in
 	listenC := make(chan error)
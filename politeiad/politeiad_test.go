@@ -0,0 +1,266 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/btcsuite/btclog"
+	"github.com/decred/politeia/politeiad/api/v1"
+	"github.com/decred/politeia/politeiad/api/v1/identity"
+	"github.com/decred/politeia/politeiad/backend"
+	"github.com/decred/politeia/politeiad/backend/memorybe"
+	"github.com/decred/politeia/politeiad/signer"
+	"github.com/decred/politeia/util"
+)
+
+// TestMain disables the logger.  The log rotator that it writes to is only
+// initialized by the daemon.
+func TestMain(m *testing.M) {
+	log = btclog.Disabled
+	os.Exit(m.Run())
+}
+
+// newTestPoliteia returns a politeia context with an in memory backend and a
+// local signer.
+func newTestPoliteia(t *testing.T) *politeia {
+	id, err := identity.New("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := memorybe.New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &politeia{
+		backend:  b,
+		signer:   signer.NewLocal(id),
+		identity: id,
+	}
+}
+
+// newTestProposal creates an unvetted proposal and returns its token.
+func newTestProposal(t *testing.T, p *politeia) []byte {
+	payload := []byte("proposal")
+	psr, err := p.backend.New("proposal", []backend.File{{
+		Name:    "index.md",
+		MIME:    "text/plain; charset=utf-8",
+		Digest:  hex.EncodeToString(util.Digest(payload)),
+		Payload: base64.StdEncoding.EncodeToString(payload),
+	}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return psr.Token
+}
+
+// callHandler posts request to handler and decodes the reply.  It returns
+// the HTTP status code.
+func callHandler(t *testing.T, handler http.HandlerFunc, request, reply interface{}) int {
+	b, err := json.Marshal(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("POST", "/", bytes.NewReader(b)))
+	err = json.Unmarshal(w.Body.Bytes(), reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w.Code
+}
+
+func TestGetStatusRecord(t *testing.T) {
+	p := newTestPoliteia(t)
+	defer p.backend.Close()
+
+	challenge, err := util.Random(v1.ChallengeSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := newTestProposal(t, p)
+	request := v1.GetStatusRecord{
+		Challenge: hex.EncodeToString(challenge),
+		Token:     hex.EncodeToString(token),
+	}
+
+	// Only censored proposals have a status record.
+	var ue v1.UserErrorReply
+	code := callHandler(t, p.getStatusRecord, request, &ue)
+	if code != http.StatusBadRequest ||
+		ue.ErrorCode != v1.ErrorStatusProposalNotFound {
+		t.Fatalf("expected ErrorStatusProposalNotFound got %v %v",
+			code, ue.ErrorCode)
+	}
+
+	_, err = p.backend.SetUnvettedStatus(token, backend.PSRStatusCensored,
+		"spam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err := p.backend.GetUnvetted(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The record attests the status change and asking for it again
+	// returns the same record.
+	var reply, again v1.GetStatusRecordReply
+	code = callHandler(t, p.getStatusRecord, request, &reply)
+	if code != http.StatusOK {
+		t.Fatalf("unexpected status code %v", code)
+	}
+	err = util.VerifyChallenge(&p.identity.Public, challenge, reply.Response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sr := reply.StatusRecord
+	if sr.Token != request.Token || sr.Status != v1.PropStatusCensored ||
+		sr.Reason != "spam" ||
+		sr.Timestamp != pr.ProposalStorageRecord.Timestamp {
+		t.Fatalf("unexpected status record %v", sr)
+	}
+	err = v1.VerifyStatusRecord(p.identity.Public, sr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	callHandler(t, p.getStatusRecord, request, &again)
+	if again.StatusRecord != sr {
+		t.Fatalf("status record changed got %v wanted %v",
+			again.StatusRecord, sr)
+	}
+
+	// A record that was altered after signing does not verify.
+	sr.Reason = "not spam"
+	err = v1.VerifyStatusRecord(p.identity.Public, sr)
+	if err != v1.ErrCorrupt {
+		t.Fatalf("expected ErrCorrupt got %v", err)
+	}
+}
+
+func TestSetStatus(t *testing.T) {
+	p := newTestPoliteia(t)
+	defer p.backend.Close()
+
+	challenge, err := util.Random(v1.ChallengeSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := newTestProposal(t, p)
+	request := v1.SetUnvettedStatus{
+		Challenge: hex.EncodeToString(challenge),
+		Token:     hex.EncodeToString(token),
+		Status:    v1.PropStatusPublic,
+		Reason:    "approved",
+	}
+	var reply v1.SetUnvettedStatusReply
+	code := callHandler(t, p.setUnvettedStatus, request, &reply)
+	if code != http.StatusOK {
+		t.Fatalf("unexpected status code %v", code)
+	}
+	err = util.VerifyChallenge(&p.identity.Public, challenge, reply.Response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Status != v1.PropStatusPublic || reply.Version != 2 ||
+		reply.StatusRecord.Status != v1.PropStatusPublic {
+		t.Fatalf("unexpected reply %v", reply)
+	}
+	err = v1.VerifyStatusRecord(p.identity.Public, reply.StatusRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rejected requests reply with a user error.
+	var ue v1.UserErrorReply
+	code = callHandler(t, p.setUnvettedStatus, request, &ue)
+	if code != http.StatusBadRequest ||
+		ue.ErrorCode != v1.ErrorStatusProposalNotFound {
+		t.Fatalf("expected ErrorStatusProposalNotFound got %v %v",
+			code, ue.ErrorCode)
+	}
+	code = callHandler(t, p.setVettedStatus, v1.SetVettedStatus{
+		Challenge: request.Challenge,
+		Token:     request.Token,
+		Status:    v1.PropStatusCensored,
+	}, &ue)
+	if code != http.StatusBadRequest ||
+		ue.ErrorCode != v1.ErrorStatusInvalidPropStatusTransition {
+		t.Fatalf("expected ErrorStatusInvalidPropStatusTransition "+
+			"got %v %v", code, ue.ErrorCode)
+	}
+	request.Challenge = ""
+	code = callHandler(t, p.setUnvettedStatus, request, &ue)
+	if code != http.StatusBadRequest ||
+		ue.ErrorCode != v1.ErrorStatusInvalidChallenge {
+		t.Fatalf("expected ErrorStatusInvalidChallenge got %v %v",
+			code, ue.ErrorCode)
+	}
+}
+
+func TestCensorComment(t *testing.T) {
+	p := newTestPoliteia(t)
+	defer p.backend.Close()
+
+	token := newTestProposal(t, p)
+	_, err := p.backend.SetUnvettedStatus(token, backend.PSRStatusVetted, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = p.backend.AppendComments(token, []backend.Comment{{
+		CommentID: 1,
+		Comment:   "abusive",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	challenge, err := util.Random(v1.ChallengeSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := v1.CensorComment{
+		Challenge: hex.EncodeToString(challenge),
+		Token:     hex.EncodeToString(token),
+		CommentID: 1,
+		Reason:    "abuse",
+	}
+	var reply v1.CensorCommentReply
+	code := callHandler(t, p.censorComment, request, &reply)
+	if code != http.StatusOK {
+		t.Fatalf("unexpected status code %v", code)
+	}
+	err = util.VerifyChallenge(&p.identity.Public, challenge, reply.Response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The public route no longer returns the text.
+	var gcr v1.GetCommentsReply
+	callHandler(t, p.getComments, v1.GetComments{
+		Challenge: request.Challenge,
+		Token:     request.Token,
+	}, &gcr)
+	if len(gcr.Comments) != 1 || gcr.Comments[0].Comment != "" ||
+		!gcr.Comments[0].Censored || gcr.Comments[0].Reason != "abuse" {
+		t.Fatalf("unexpected comments %v", gcr.Comments)
+	}
+
+	var ue v1.UserErrorReply
+	request.CommentID = 2
+	code = callHandler(t, p.censorComment, request, &ue)
+	if code != http.StatusBadRequest ||
+		ue.ErrorCode != v1.ErrorStatusCommentNotFound {
+		t.Fatalf("expected ErrorStatusCommentNotFound got %v %v",
+			code, ue.ErrorCode)
+	}
+}
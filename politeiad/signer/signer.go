@@ -0,0 +1,237 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package signer produces the signatures of politeiad.  Signatures are either
+// created in-process from a FullIdentity or by a separate signing daemon that
+// is reached over a unix socket, in which case the private key never lives in
+// the politeiad process.
+package signer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/decred/politeia/politeiad/api/v1/identity"
+)
+
+const (
+	// DefaultTimeout is the time a remote signer has to reply.
+	DefaultTimeout = 10 * time.Second
+
+	// MaxMessageSize is the largest message the signing daemon signs.
+	// politeiad only signs challenges, censorship records and status
+	// records, the latter include the censorship reason.
+	MaxMessageSize = 64 * 1024
+
+	commandIdentity = "identity"
+	commandSign     = "sign"
+)
+
+var (
+	// ErrMessageSize is returned when a message exceeds MaxMessageSize.
+	ErrMessageSize = errors.New("message too large")
+)
+
+// Signer signs messages on behalf of politeiad.
+type Signer interface {
+	// PublicIdentity returns the identity that verifies the signatures.
+	PublicIdentity() identity.PublicIdentity
+
+	// SignMessage returns the signature of message.
+	SignMessage(message []byte) ([identity.SignatureSize]byte, error)
+}
+
+// Local is a Signer that keeps the identity in memory.
+type Local struct {
+	id *identity.FullIdentity
+}
+
+// NewLocal returns a Signer that signs with id.
+func NewLocal(id *identity.FullIdentity) *Local {
+	return &Local{id: id}
+}
+
+// PublicIdentity satisfies the Signer interface.
+func (l *Local) PublicIdentity() identity.PublicIdentity {
+	return l.id.Public
+}
+
+// SignMessage satisfies the Signer interface.
+func (l *Local) SignMessage(message []byte) ([identity.SignatureSize]byte, error) {
+	return l.id.SignMessage(message), nil
+}
+
+// request is sent by Remote to the signing daemon.  Every request is
+// answered with a single reply.
+type request struct {
+	Command string `json:"command"`
+	Message []byte `json:"message,omitempty"`
+}
+
+// reply is the answer of the signing daemon.  Error is set when the request
+// failed.
+type reply struct {
+	Identity  *identity.PublicIdentity `json:"identity,omitempty"`
+	Signature []byte                   `json:"signature,omitempty"`
+	Error     string                   `json:"error,omitempty"`
+}
+
+// Remote is a Signer that asks a signing daemon for signatures.
+type Remote struct {
+	sync.Mutex
+	path    string
+	timeout time.Duration
+	public  identity.PublicIdentity
+	conn    net.Conn      // Connection to the daemon, nil when closed
+	decoder *json.Decoder // Reads replies from conn
+}
+
+// Dial connects to the signing daemon listening on the unix socket path and
+// retrieves its public identity.
+func Dial(path string) (*Remote, error) {
+	r := &Remote{
+		path:    path,
+		timeout: DefaultTimeout,
+	}
+	rep, err := r.call(request{Command: commandIdentity})
+	if err != nil {
+		return nil, err
+	}
+	if rep.Identity == nil {
+		return nil, fmt.Errorf("signer did not return an identity")
+	}
+	r.public = *rep.Identity
+
+	return r, nil
+}
+
+// call sends req to the daemon and returns its reply.  The connection is
+// reestablished if it was lost, for example because the daemon restarted.
+func (r *Remote) call(req request) (*reply, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.conn == nil {
+		conn, err := net.DialTimeout("unix", r.path, r.timeout)
+		if err != nil {
+			return nil, err
+		}
+		r.conn = conn
+		r.decoder = json.NewDecoder(conn)
+	}
+
+	var rep reply
+	err := r.conn.SetDeadline(time.Now().Add(r.timeout))
+	if err == nil {
+		err = json.NewEncoder(r.conn).Encode(req)
+	}
+	if err == nil {
+		err = r.decoder.Decode(&rep)
+	}
+	if err != nil {
+		r.conn.Close()
+		r.conn = nil
+		return nil, err
+	}
+	if rep.Error != "" {
+		return nil, fmt.Errorf("signer: %v", rep.Error)
+	}
+
+	return &rep, nil
+}
+
+// PublicIdentity satisfies the Signer interface.
+func (r *Remote) PublicIdentity() identity.PublicIdentity {
+	return r.public
+}
+
+// SignMessage satisfies the Signer interface.  The signature is verified
+// before it is returned.
+func (r *Remote) SignMessage(message []byte) ([identity.SignatureSize]byte, error) {
+	var signature [identity.SignatureSize]byte
+	if len(message) > MaxMessageSize {
+		return signature, ErrMessageSize
+	}
+
+	rep, err := r.call(request{Command: commandSign, Message: message})
+	if err != nil {
+		return signature, err
+	}
+	if len(rep.Signature) != identity.SignatureSize {
+		return signature, fmt.Errorf("invalid signature size")
+	}
+	copy(signature[:], rep.Signature)
+	if !r.public.VerifyMessage(message, signature) {
+		return signature, fmt.Errorf("invalid signature")
+	}
+
+	return signature, nil
+}
+
+// Close closes the connection to the signing daemon.
+func (r *Remote) Close() error {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn = nil
+	return err
+}
+
+// Serve answers the requests of Remote signers that connect to l with s.  It
+// returns when l is closed.
+func Serve(l net.Listener, s Signer) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go serveConn(conn, s)
+	}
+}
+
+// serveConn answers requests on conn until the client disconnects.
+func serveConn(conn net.Conn, s Signer) {
+	defer conn.Close()
+
+	decoder := json.NewDecoder(conn)
+	encoder := json.NewEncoder(conn)
+	for {
+		var req request
+		if err := decoder.Decode(&req); err != nil {
+			return
+		}
+
+		var rep reply
+		switch req.Command {
+		case commandIdentity:
+			pi := s.PublicIdentity()
+			rep.Identity = &pi
+		case commandSign:
+			if len(req.Message) > MaxMessageSize {
+				rep.Error = ErrMessageSize.Error()
+				break
+			}
+			signature, err := s.SignMessage(req.Message)
+			if err != nil {
+				rep.Error = err.Error()
+				break
+			}
+			rep.Signature = signature[:]
+		default:
+			rep.Error = fmt.Sprintf("invalid command: %v", req.Command)
+		}
+
+		if err := encoder.Encode(rep); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package signer
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/decred/politeia/politeiad/api/v1/identity"
+)
+
+// testSigner signs a few messages with s and verifies them with id.
+func testSigner(t *testing.T, s Signer, id *identity.FullIdentity) {
+	if s.PublicIdentity() != id.Public {
+		t.Fatalf("unexpected public identity")
+	}
+
+	for _, message := range [][]byte{
+		[]byte("challenge"),
+		make([]byte, MaxMessageSize),
+	} {
+		signature, err := s.SignMessage(message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !id.Public.VerifyMessage(message, signature) {
+			t.Fatalf("invalid signature")
+		}
+		if signature != id.SignMessage(message) {
+			t.Fatalf("unexpected signature")
+		}
+	}
+}
+
+func TestLocal(t *testing.T) {
+	id, err := identity.New("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSigner(t, NewLocal(id), id)
+}
+
+func TestRemote(t *testing.T) {
+	dir, err := ioutil.TempDir("", "politeia.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "signer.sock")
+
+	id, err := identity.New("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error)
+	go func() {
+		served <- Serve(l, NewLocal(id))
+	}()
+
+	r, err := Dial(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	testSigner(t, r, id)
+
+	// Messages that are too large are refused by both sides.
+	_, err = r.SignMessage(make([]byte, MaxMessageSize+1))
+	if err != ErrMessageSize {
+		t.Fatalf("expected ErrMessageSize got %v", err)
+	}
+	rep, err := r.call(request{Command: commandSign,
+		Message: make([]byte, MaxMessageSize+1)})
+	if err == nil {
+		t.Fatalf("expected oversized message to be refused %v", rep)
+	}
+	_, err = r.call(request{Command: "moo"})
+	if err == nil {
+		t.Fatalf("expected invalid command to be refused")
+	}
+
+	// A lost connection is reestablished.
+	r.Close()
+	testSigner(t, r, id)
+
+	// Signing fails once the daemon is gone.
+	l.Close()
+	<-served
+	r.Close()
+	_, err = r.SignMessage([]byte("challenge"))
+	if err == nil {
+		t.Fatalf("expected signing to fail without daemon")
+	}
+}
@@ -20,7 +20,13 @@ const (
 	RouteVerifyNewUserFailure = "/user/verify/failure"
 	RouteChangePassword       = "/user/password/change"
 	RouteResetPassword        = "/user/password/reset"
+	RouteUpdateUserKey        = "/user/key"
+	RouteVerifyUpdateUserKey  = "/user/key/verify"
+	RouteRevokeUserKey        = "/user/key/revoke"
+	RouteUserKeys             = "/user/keys"
 	RouteLogin                = "/login"
+	RouteLoginChallenge       = "/login/challenge"
+	RouteLoginSigned          = "/login/signed"
 	RouteLogout               = "/logout"
 	RouteSecret               = "/secret"
 	RouteAllVetted            = "/proposals/vetted"
@@ -28,9 +34,16 @@ const (
 	RouteNewProposal          = "/proposals/new"
 	RouteProposalDetails      = "/proposals/{token:[A-z0-9]{64}}"
 	RouteSetProposalStatus    = "/proposals/{token:[A-z0-9]{64}}/status"
+	RouteWithdrawProposal     = "/proposals/{token:[A-z0-9]{64}}/withdraw"
+	RouteEditProposal         = "/proposals/{token:[A-z0-9]{64}}/edit"
+	RouteProposalVersions     = "/proposals/{token:[A-z0-9]{64}}/versions"
+	RouteProposalDiff         = "/proposals/{token:[A-z0-9]{64}}/diff/{from:[0-9]+}/{to:[0-9]+}"
 	RoutePolicy               = "/policy"
 	RouteNewComment           = "/comments/new"
 	RouteCommentsGet          = "/proposals/{token:[A-z0-9]{64}}/comments"
+	RouteCensorComment        = "/comments/censor"
+	RouteLikeComment          = "/comments/like"
+	RouteUserCommentsLikes    = "/user/proposals/{token:[A-z0-9]{64}}/commentslikes"
 
 	// VerificationTokenSize is the size of verification token in bytes
 	VerificationTokenSize = 32
@@ -39,6 +52,10 @@ const (
 	// verification token expires
 	VerificationExpiryHours = 48
 
+	// LoginChallengeExpiryMinutes is the number of minutes before the
+	// login challenge expires
+	LoginChallengeExpiryMinutes = 5
+
 	// PolicyMaxImages is the maximum number of images accepted
 	// when creating a new proposal
 	PolicyMaxImages = 5
@@ -59,6 +76,10 @@ const (
 	// accepted for user passwords
 	PolicyPasswordMinChars = 8
 
+	// PolicyMaxCommentsPageSize is the maximum number of comments or
+	// threads returned per page of comments
+	PolicyMaxCommentsPageSize = 100
+
 	// ValidProposalNameRegExp is the regular expression of a valid
 	// proposal name
 	ValidProposalNameRegExp = `^[[:alnum:]\.\:\;\,\- \@\+\#]{8,}$`
@@ -85,6 +106,15 @@ const (
 	ErrorStatusInvalidMIMEType             ErrorStatusT = 18
 	ErrorStatusUnsupportedMIMEType         ErrorStatusT = 19
 	ErrorStatusInvalidPropStatusTransition ErrorStatusT = 20
+	ErrorStatusNoProposalChanges           ErrorStatusT = 21
+	ErrorStatusInvalidPublicKey            ErrorStatusT = 22
+	ErrorStatusInvalidSignature            ErrorStatusT = 23
+	ErrorStatusNoPublicKey                 ErrorStatusT = 24
+	ErrorStatusCommentCensored             ErrorStatusT = 25
+	ErrorStatusInvalidLikeCommentAction    ErrorStatusT = 26
+	ErrorStatusInvalidCommentsQuery        ErrorStatusT = 27
+	ErrorStatusUserNotAuthor               ErrorStatusT = 28
+	ErrorStatusCommentEmpty                ErrorStatusT = 29
 
 	// Proposal status codes (set and get)
 	PropStatusInvalid     PropStatusT = 0 // Invalid status
@@ -92,6 +122,14 @@ const (
 	PropStatusNotReviewed PropStatusT = 2 // Proposal has not been reviewed
 	PropStatusCensored    PropStatusT = 3 // Proposal has been censored
 	PropStatusPublic      PropStatusT = 4 // Proposal is publicly visible
+	PropStatusAbandoned   PropStatusT = 5 // Public, abandoned by its author
+	PropStatusArchived    PropStatusT = 6 // Public, no longer relevant
+	PropStatusWithdrawn   PropStatusT = 7 // Withdrawn by its author
+
+	// Comment vote actions
+	LikeActionDown  = -1 // Downvote
+	LikeActionReset = 0  // Remove the vote
+	LikeActionUp    = 1  // Upvote
 
 	// Error contexts
 	ErrorContextProposalInvalidTitle = ValidProposalNameRegExp
@@ -129,13 +167,30 @@ type CensorshipRecord struct {
 	Signature string `json:"signature"` // Signature of merkle+token
 }
 
+// UserSignature contains the proof that a user submitted a proposal.  The
+// Signature field contains the signature of the Merkle field, the merkle root
+// of the files as they were submitted, by the user's ed25519 public key.
+type UserSignature struct {
+	PublicKey string `json:"publickey"` // User public key
+	Merkle    string `json:"merkle"`    // Merkle root of submitted files
+	Signature string `json:"signature"` // Signature of merkle
+}
+
 // ProposalRecord is an entire proposal and it's content.
 type ProposalRecord struct {
 	Name      string      `json:"name"`      // Suggested short proposal name
 	Status    PropStatusT `json:"status"`    // Current status of proposal
+	Version   uint        `json:"version"`   // Iteration count of proposal
 	Timestamp int64       `json:"timestamp"` // Last update of proposal
 	Files     []File      `json:"files"`     // Files that make up the proposal
 
+	// Reason is the optional reason of the last status change.
+	Reason string `json:"reason,omitempty"`
+
+	// UserSignature is set if the proposal was signed by the user that
+	// submitted it.
+	UserSignature *UserSignature `json:"usersignature,omitempty"`
+
 	CensorshipRecord CensorshipRecord `json:"censorshiprecord"`
 }
 
@@ -196,8 +251,9 @@ type VersionReply struct {
 // NewUser is used to request that a new user be created within the db.
 // If successful, the user will require verification before being able to login.
 type NewUser struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email     string `json:"email"`
+	Password  string `json:"password"`
+	PublicKey string `json:"publickey,omitempty"` // Optional ed25519 public key
 }
 
 // NewUserReply is used to reply to the NewUser command with an error
@@ -207,10 +263,14 @@ type NewUserReply struct {
 }
 
 // VerifyNewUser is used to perform verification for the user created through
-// the NewUser command using the token provided in NewUserReply.
+// the NewUser command using the token provided in NewUserReply.  Users that
+// registered a public key may also provide the signature of the verification
+// token by that key in order to activate it.  Without the signature the key
+// remains pending until it is verified with UpdateUserKey.
 type VerifyNewUser struct {
 	Email             string `json:"email"`
 	VerificationToken string `json:"verificationtoken"`
+	Signature         string `json:"signature,omitempty"` // Optional signature of verification token
 }
 
 //XXX missing VerifyNewUserReply
@@ -240,14 +300,81 @@ type ResetPasswordReply struct {
 	VerificationToken string `json:"verificationtoken"`
 }
 
-// Login attempts to login the user.  Note that by necessity the password
-// travels in the clear.
+// UpdateUserKey registers a new public key for the logged in user.  The key
+// remains pending until the challenge returned in UpdateUserKeyReply is
+// signed by it.
+type UpdateUserKey struct {
+	PublicKey string `json:"publickey"` // ed25519 public key
+}
+
+// UpdateUserKeyReply contains the challenge that must be signed by the new
+// public key.
+type UpdateUserKeyReply struct {
+	Challenge string `json:"challenge"`
+}
+
+// VerifyUpdateUserKey activates the pending public key of the logged in user.
+// The previously active key, if any, is deactivated.
+type VerifyUpdateUserKey struct {
+	Signature string `json:"signature"` // Signature of challenge
+}
+
+// VerifyUpdateUserKeyReply is used to reply to the VerifyUpdateUserKey
+// command.
+type VerifyUpdateUserKeyReply struct{}
+
+// RevokeUserKey deactivates the active public key of the logged in user
+// without replacing it.
+type RevokeUserKey struct{}
+
+// RevokeUserKeyReply is used to reply to the RevokeUserKey command.
+type RevokeUserKeyReply struct{}
+
+// UserKeys retrieves all public keys of the logged in user.
+type UserKeys struct{}
+
+// UserIdentity describes a public key of a user.  Keys that were deactivated
+// are kept so that the signatures they made remain verifiable.
+type UserIdentity struct {
+	PublicKey   string `json:"publickey"`   // ed25519 public key
+	Activated   int64  `json:"activated"`   // Unix time of activation, 0 if pending
+	Deactivated int64  `json:"deactivated"` // Unix time of deactivation, 0 if in use
+}
+
+// UserKeysReply returns the public keys of the user, oldest first.
+type UserKeysReply struct {
+	Identities []UserIdentity `json:"identities"`
+}
+
+// Login attempts to login the user.  Note that the password travels in the
+// clear.  Users with an active public key can avoid that by using
+// LoginChallenge and LoginSigned instead.
 type Login struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
 }
 
-// LoginReply is used to reply to the Login command.
+// LoginChallenge requests a challenge that must be signed by the active public
+// key of the user in order to login with LoginSigned.
+type LoginChallenge struct {
+	Email string `json:"email"`
+}
+
+// LoginChallengeReply contains the challenge.  It expires after
+// LoginChallengeExpiryMinutes, can only be used once and only by the session
+// that requested it.
+type LoginChallengeReply struct {
+	Challenge string `json:"challenge"`
+}
+
+// LoginSigned attempts to login the user with the signature of the challenge
+// returned in LoginChallengeReply.  It is answered with a LoginReply.
+type LoginSigned struct {
+	Email     string `json:"email"`
+	Signature string `json:"signature"` // Signature of challenge
+}
+
+// LoginReply is used to reply to the Login and LoginSigned commands.
 type LoginReply struct {
 	UserID  uint64 `json:"userid"`  // User id
 	IsAdmin bool   `json:"isadmin"` // Set to true when user is admin
@@ -270,9 +397,12 @@ type MeReply struct {
 	IsAdmin bool   `json:"isadmin"`
 }
 
-// NewProposal attempts to submit a new proposal.
+// NewProposal attempts to submit a new proposal.  Users with an active public
+// key must provide the signature of the merkle root of the files.
 type NewProposal struct {
-	Files []File `json:"files"` // XXX layer violation.
+	Files     []File `json:"files"`               // XXX layer violation.
+	PublicKey string `json:"publickey,omitempty"` // User public key
+	Signature string `json:"signature,omitempty"` // Signature of merkle root
 }
 
 // NewProposalReply is used to reply to the NewProposal command.
@@ -280,6 +410,21 @@ type NewProposalReply struct {
 	CensorshipRecord CensorshipRecord `json:"censorshiprecord"`
 }
 
+// EditProposal attempts to replace the files of an existing unreviewed or
+// public proposal.  It must include all files that are part of the proposal.
+// Only the author of the proposal and admins can edit it.
+type EditProposal struct {
+	Token string `json:"token"`
+	Files []File `json:"files"`
+}
+
+// EditProposalReply is used to reply to the EditProposal command.  The
+// censorship token remains the same but the merkle root and signature are
+// updated to reflect the new files.
+type EditProposalReply struct {
+	CensorshipRecord CensorshipRecord `json:"censorshiprecord"`
+}
+
 // ProposalsDetails is used to retrieve a proposal.
 // XXX clarify URL vs Direct
 type ProposalsDetails struct {
@@ -291,10 +436,56 @@ type ProposalDetailsReply struct {
 	Proposal ProposalRecord `json:"proposal"`
 }
 
-// SetProposalStatus is used to publish or censor an unreviewed proposal.
+// ProposalVersions is used to retrieve the revision history of a proposal.
+type ProposalVersions struct {
+	Token string `json:"token"`
+}
+
+// ProposalVersion describes a single revision of a proposal.  Status changes
+// are recorded as well so multiple entries may share the same Version.
+type ProposalVersion struct {
+	Commit    string      `json:"commit"`    // Backend commit digest
+	Version   uint        `json:"version"`   // Iteration count of proposal
+	Status    PropStatusT `json:"status"`    // Status at this revision
+	Timestamp int64       `json:"timestamp"` // Last update of proposal
+	Merkle    string      `json:"merkle"`    // Merkle root of proposal
+}
+
+// ProposalVersionsReply is used to reply to a proposal versions command.  The
+// versions are sorted newest first.
+type ProposalVersionsReply struct {
+	Versions []ProposalVersion `json:"versions"`
+}
+
+// ProposalDiff is used to retrieve the differences between two versions of a
+// proposal.
+type ProposalDiff struct {
+	Token string `json:"token"`
+	From  uint   `json:"from"` // Old version
+	To    uint   `json:"to"`   // New version
+}
+
+// FileDiff contains the unified diff of a single proposal file.  Files that
+// did not change between the two versions are omitted.  Binary files are not
+// diffed; the diff only notes that they differ.
+type FileDiff struct {
+	Name string `json:"name"` // File name
+	Diff string `json:"diff"` // Unified diff
+}
+
+// ProposalDiffReply is used to reply to a proposal diff command.
+type ProposalDiffReply struct {
+	From  uint       `json:"from"`  // Old version
+	To    uint       `json:"to"`    // New version
+	Files []FileDiff `json:"files"` // Per file differences
+}
+
+// SetProposalStatus is used to publish or censor an unreviewed proposal.  The
+// optional reason is stored with the proposal.
 type SetProposalStatus struct {
 	Token          string      `json:"token"`
 	ProposalStatus PropStatusT `json:"proposalstatus"`
+	Reason         string      `json:"reason,omitempty"`
 }
 
 // SetProposalStatusReply is used to reply to a SetProposalStatus command.
@@ -302,6 +493,18 @@ type SetProposalStatusReply struct {
 	ProposalStatus PropStatusT `json:"proposalstatus"`
 }
 
+// WithdrawProposal is used by the author of an unreviewed proposal to
+// withdraw it.
+type WithdrawProposal struct {
+	Token  string `json:"token"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// WithdrawProposalReply is used to reply to a WithdrawProposal command.
+type WithdrawProposalReply struct {
+	ProposalStatus PropStatusT `json:"proposalstatus"`
+}
+
 // GetAllUnvetted retrieves all unvetted proposals.  This call requires admin
 // privileges.
 type GetAllUnvetted struct{}
@@ -326,12 +529,13 @@ type Policy struct{}
 // PolicyReply is used to reply to the policy command. It returns
 // the file upload restrictions set for Politeia.
 type PolicyReply struct {
-	PasswordMinChars uint     `json:"passwordminchars"`
-	MaxImages        uint     `json:"maximages"`
-	MaxImageSize     uint     `json:"maximagesize"`
-	MaxMDs           uint     `json:"maxmds"`
-	MaxMDSize        uint     `json:"maxmdsize"`
-	ValidMIMETypes   []string `json:"validmimetypes"`
+	PasswordMinChars    uint     `json:"passwordminchars"`
+	MaxImages           uint     `json:"maximages"`
+	MaxImageSize        uint     `json:"maximagesize"`
+	MaxMDs              uint     `json:"maxmds"`
+	MaxMDSize           uint     `json:"maxmdsize"`
+	ValidMIMETypes      []string `json:"validmimetypes"`
+	MaxCommentsPageSize uint     `json:"maxcommentspagesize"`
 }
 
 // NewComment sends a comment from a user to a specific proposal.  Note that
@@ -348,21 +552,102 @@ type NewCommentReply struct {
 	CommentID uint64 `json:"commentid"` // Comment ID
 }
 
-// GetComments retrieve all comments for a given proposal.
-type GetComments struct{}
+// CommentSortT is the order in which comments are returned.
+type CommentSortT string
+
+const (
+	CommentSortOldest CommentSortT = "oldest" // Oldest first, the default
+	CommentSortNewest CommentSortT = "newest" // Newest first
+	CommentSortTop    CommentSortT = "top"    // Highest vote result first
+)
+
+// GetComments retrieves the comments of a given proposal.  The options are
+// passed as URL query parameters of the same name and are all optional.  By
+// default all comments are returned as a flat list, oldest first.
+//
+// ParentID and Depth limit the result to the replies of a comment, Depth
+// levels down.  Pages hold at most PolicyMaxCommentsPageSize comments.  Pages
+// are requested by passing the NextCursor of the previous reply with the same
+// options.  Threads are paginated by their top comment.
+type GetComments struct {
+	Sort     CommentSortT `json:"sort,omitempty"`     // Sort order
+	PageSize uint         `json:"pagesize,omitempty"` // 0 is the maximum
+	Cursor   string       `json:"cursor,omitempty"`   // Start of the page
+	ParentID uint64       `json:"parentid,omitempty"` // 0 is the proposal
+	Depth    uint         `json:"depth,omitempty"`    // 0 is unlimited
+	Thread   bool         `json:"thread,omitempty"`   // Reply with threads
+}
 
 // Comment is the structure that describes the full server side content.  It
-// includes server side meta-data as well.
+// includes server side meta-data as well.  The text of a censored comment is
+// blank but its digest remains.
 type Comment struct {
-	CommentID uint64 `json:"commentid"` // Comment ID
-	UserID    uint64 `json:"userid"`    // Originating user
-	ParentID  uint64 `json:"parentid"`  // Parent comment ID
-	Timestamp int64  `json:"timestamp"` // Received UNIX timestamp
+	CommentID uint64 `json:"commentid"`        // Comment ID
+	UserID    uint64 `json:"userid"`           // Originating user
+	ParentID  uint64 `json:"parentid"`         // Parent comment ID
+	Timestamp int64  `json:"timestamp"`        // Received UNIX timestamp
+	Token     string `json:"token"`            // Censorship token
+	Comment   string `json:"comment"`          // Comment
+	Digest    string `json:"digest"`           // SHA-256 of the comment text
+	Censored  bool   `json:"censored"`         // Set when censored by an admin
+	Reason    string `json:"reason,omitempty"` // Censorship reason
+	Result    int64  `json:"resultvotes"`      // Sum of the votes
+	Total     uint64 `json:"totalvotes"`       // Number of votes
+}
+
+// CommentThread is a comment with its nested replies.
+type CommentThread struct {
+	Comment
+	Replies []CommentThread `json:"replies"` // Sorted replies
+}
+
+// GetCommentsReply returns the requested comments.  Comments holds the flat
+// list and is empty when threads were requested.  NextCursor is set when more
+// comments follow.
+type GetCommentsReply struct {
+	Comments   []Comment       `json:"comments"`             // Comments
+	Threads    []CommentThread `json:"threads,omitempty"`    // Threads
+	NextCursor string          `json:"nextcursor,omitempty"` // Next page
+}
+
+// CensorComment removes the text of an abusive comment.  The optional reason
+// is returned with the comment.  This call requires admin privileges.
+type CensorComment struct {
+	Token     string `json:"token"`            // Censorship token
+	CommentID uint64 `json:"commentid"`        // Comment ID
+	Reason    string `json:"reason,omitempty"` // Censorship reason
+}
+
+// CensorCommentReply is used to reply to a CensorComment command.
+type CensorCommentReply struct{}
+
+// LikeComment casts the vote of the user on a comment.  Action is one of
+// LikeActionUp, LikeActionDown or LikeActionReset.  A user has at most one
+// vote per comment, a new vote replaces the previous one.  Note that the user
+// is implied by the session.
+type LikeComment struct {
 	Token     string `json:"token"`     // Censorship token
-	Comment   string `json:"comment"`   // Comment
+	CommentID uint64 `json:"commentid"` // Comment ID
+	Action    int64  `json:"action"`    // Vote action
 }
 
-// GetCommentsReply returns the provided number of comments.
-type GetCommentsReply struct {
-	Comments []Comment `json:"comments"` // Comments
+// LikeCommentReply returns the votes on the comment after the vote was cast.
+type LikeCommentReply struct {
+	Result int64  `json:"resultvotes"` // Sum of the votes
+	Total  uint64 `json:"totalvotes"`  // Number of votes
+}
+
+// UserCommentsLikes retrieves the votes of the user on the comments of a
+// proposal.  Note that the user is implied by the session.
+type UserCommentsLikes struct{}
+
+// CommentLike is the vote of the user on a comment.
+type CommentLike struct {
+	CommentID uint64 `json:"commentid"` // Comment ID
+	Action    int64  `json:"action"`    // Vote action
+}
+
+// UserCommentsLikesReply returns the votes of the user sorted by comment ID.
+type UserCommentsLikesReply struct {
+	CommentsLikes []CommentLike `json:"commentslikes"` // Votes
 }
@@ -19,16 +19,23 @@ import (
 
 	"github.com/dajohi/goemail"
 	pd "github.com/decred/politeia/politeiad/api/v1"
+	"github.com/decred/politeia/politeiad/api/v1/identity"
 	"github.com/decred/politeia/politeiad/api/v1/mime"
 	www "github.com/decred/politeia/politeiawww/api/v1"
 	"github.com/decred/politeia/politeiawww/database"
 	"github.com/decred/politeia/politeiawww/database/localdb"
+	"github.com/decred/politeia/politeiawww/journal"
 	"github.com/decred/politeia/util"
+	"github.com/pmezard/go-difflib/difflib"
 )
 
 const (
 	// indexFile contains the file name of the index file
 	indexFile = "index.md"
+
+	// inventoryPageSize is the number of vetted and unvetted proposals
+	// that are requested from politeiad at a time.
+	inventoryPageSize = 100
 )
 
 // politeiawww backend construct
@@ -37,16 +44,47 @@ type backend struct {
 	cfg                *config
 	commentJournalDir  string
 	commentJournalFile string
+	likeJournalFile    string
+	commentJournal     *journal.Journal
+	likeJournal        *journal.Journal
 
 	// Following entries require locks
 	inventory    []www.ProposalRecord
-	comments     map[string]map[uint64]BackendComment // [token][parent]comment
-	commentID    uint64                               // current comment id
-	sync.RWMutex                                      // lock for inventory and comments
+	comments     map[string]map[uint64]BackendComment         // [token][parent]comment
+	likes        map[string]map[uint64]map[uint64]BackendLike // [token][comment][user]like
+	commentID    uint64                                       // current comment id
+	purge        bool                                         // censored text awaits compaction
+	sync.RWMutex                                              // lock for inventory and comments
+
+	flushC chan struct{} // Wakes up the comment flusher
 
 	// These properties are only used for testing.
 	test                   bool
 	verificationExpiryTime time.Duration
+	testVersions           map[string][][]www.File // [token][version-1]files
+}
+
+// addTestVersion records files as the next version of proposal token.  It
+// stands in for the version history of politeiad in test mode.
+// This call must be called with the lock held.
+func (b *backend) addTestVersion(token string, files []www.File) {
+	if b.testVersions == nil {
+		b.testVersions = make(map[string][][]www.File)
+	}
+	b.testVersions[token] = append(b.testVersions[token], files)
+}
+
+// getTestVersion returns the files of a version of proposal token that was
+// recorded in test mode.
+// This call must be called WITHOUT the lock held.
+func (b *backend) getTestVersion(token string, version uint) ([]www.File, bool) {
+	b.RLock()
+	defer b.RUnlock()
+	versions := b.testVersions[token]
+	if version < 1 || version > uint(len(versions)) {
+		return nil, false
+	}
+	return versions[version-1], true
 }
 
 func (b *backend) getVerificationExpiryTime() time.Duration {
@@ -184,16 +222,63 @@ func (b *backend) makeRequest(method string, route string, v interface{}) ([]byt
 }
 
 // remoteInventory fetches the entire inventory of proposals from politeiad.
+// The inventory is fetched page by page by following the cursors that
+// politeiad returns.
 func (b *backend) remoteInventory() (*pd.InventoryReply, error) {
+	var (
+		inv                          pd.InventoryReply
+		vettedCursor, branchesCursor string
+		vettedDone, branchesDone     bool
+	)
+	for !vettedDone || !branchesDone {
+		ir, err := b.remoteInventoryPage(vettedCursor, branchesCursor,
+			vettedDone, branchesDone)
+		if err != nil {
+			return nil, err
+		}
+
+		// A listing without a cursor is complete.
+		if !vettedDone {
+			inv.Vetted = append(inv.Vetted, ir.Vetted...)
+			vettedCursor = ir.VettedCursor
+			vettedDone = vettedCursor == ""
+		}
+		if !branchesDone {
+			inv.Branches = append(inv.Branches, ir.Branches...)
+			branchesCursor = ir.BranchesCursor
+			branchesDone = branchesCursor == ""
+		}
+	}
+
+	return &inv, nil
+}
+
+// remoteInventoryPage fetches the page of vetted and unvetted proposals that
+// follows the provided cursors from politeiad.  A listing that is done is not
+// requested again.
+func (b *backend) remoteInventoryPage(vettedCursor, branchesCursor string, vettedDone, branchesDone bool) (*pd.InventoryReply, error) {
 	challenge, err := util.Random(pd.ChallengeSize)
 	if err != nil {
 		return nil, err
 	}
 	inv := pd.Inventory{
-		Challenge:     hex.EncodeToString(challenge),
-		IncludeFiles:  false,
-		VettedCount:   0,
-		BranchesCount: 0,
+		Challenge:      hex.EncodeToString(challenge),
+		IncludeFiles:   false,
+		VettedCount:    inventoryPageSize,
+		BranchesCount:  inventoryPageSize,
+		VettedCursor:   vettedCursor,
+		BranchesCursor: branchesCursor,
+	}
+
+	// No record is stored with the invalid status so filtering on it
+	// skips a listing.
+	if vettedDone {
+		inv.VettedCount = 0
+		inv.VettedStatus = []pd.PropStatusT{pd.PropStatusInvalid}
+	}
+	if branchesDone {
+		inv.BranchesCount = 0
+		inv.BranchesStatus = []pd.PropStatusT{pd.PropStatusInvalid}
 	}
 
 	responseBody, err := b.makeRequest(http.MethodPost, pd.InventoryRoute, inv)
@@ -216,6 +301,153 @@ func (b *backend) remoteInventory() (*pd.InventoryReply, error) {
 	return &ir, nil
 }
 
+// statusVetted returns true if a proposal with the provided status lives in
+// the vetted repo.
+func statusVetted(s www.PropStatusT) bool {
+	return s == www.PropStatusPublic || s == www.PropStatusAbandoned ||
+		s == www.PropStatusArchived
+}
+
+// validStatusTransition returns true if a proposal may move from status from
+// to status to.  These are the transitions that politeiad allows.
+func validStatusTransition(from, to www.PropStatusT) bool {
+	switch from {
+	case www.PropStatusNotReviewed:
+		return to == www.PropStatusPublic ||
+			to == www.PropStatusCensored ||
+			to == www.PropStatusWithdrawn
+	case www.PropStatusPublic:
+		return to == www.PropStatusAbandoned ||
+			to == www.PropStatusArchived
+	}
+	return false
+}
+
+// activeIdentity returns the public key the user currently signs with or nil
+// if the user has no active key.
+func activeIdentity(user *database.User) *database.Identity {
+	for i := range user.Identities {
+		id := &user.Identities[i]
+		if id.Activated != 0 && id.Deactivated == 0 {
+			return id
+		}
+	}
+	return nil
+}
+
+// pendingIdentity returns the public key that awaits verification or nil if
+// there is none.
+func pendingIdentity(user *database.User) *database.Identity {
+	for i := range user.Identities {
+		id := &user.Identities[i]
+		if id.Activated == 0 && id.Deactivated == 0 {
+			return id
+		}
+	}
+	return nil
+}
+
+// addPendingIdentity replaces the pending public key of the user with the
+// provided hex encoded key.  Keys that were active before can not be
+// registered again.
+func addPendingIdentity(user *database.User, key string) error {
+	pi, err := identity.PublicIdentityFromString(key)
+	if err != nil {
+		return www.UserError{
+			ErrorCode: www.ErrorStatusInvalidPublicKey,
+		}
+	}
+
+	ids := make([]database.Identity, 0, len(user.Identities)+1)
+	for _, id := range user.Identities {
+		if id.Activated == 0 && id.Deactivated == 0 {
+			// Drop the old pending key, it never signed anything.
+			continue
+		}
+		if id.Key == pi.Key {
+			return www.UserError{
+				ErrorCode: www.ErrorStatusInvalidPublicKey,
+			}
+		}
+		ids = append(ids, id)
+	}
+	user.Identities = append(ids, database.Identity{
+		Key: pi.Key,
+	})
+
+	return nil
+}
+
+// activatePendingIdentity verifies that msg was signed by the pending public
+// key of the user and makes it the active key.  The previously active key is
+// deactivated.
+func activatePendingIdentity(user *database.User, msg []byte, signature string) error {
+	pending := pendingIdentity(user)
+	if pending == nil {
+		return www.UserError{
+			ErrorCode: www.ErrorStatusNoPublicKey,
+		}
+	}
+
+	sig, err := identity.SignatureFromString(signature)
+	if err != nil {
+		return www.UserError{
+			ErrorCode: www.ErrorStatusInvalidSignature,
+		}
+	}
+	pi := identity.PublicIdentity{
+		Key: pending.Key,
+	}
+	if !pi.VerifyMessage(msg, *sig) {
+		return www.UserError{
+			ErrorCode: www.ErrorStatusInvalidSignature,
+		}
+	}
+
+	now := time.Now().Unix()
+	if active := activeIdentity(user); active != nil {
+		active.Deactivated = now
+	}
+	pending.Activated = now
+
+	return nil
+}
+
+// validateUserSignature verifies that the proposal files were signed by the
+// active public key of the user.  It returns nil if the user has no active
+// public key and did not sign the proposal.
+func validateUserSignature(user *database.User, np www.NewProposal) (*pd.UserSignature, error) {
+	active := activeIdentity(user)
+	if active == nil && np.PublicKey == "" && np.Signature == "" {
+		return nil, nil
+	}
+	if active == nil || np.PublicKey != hex.EncodeToString(active.Key[:]) {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusInvalidPublicKey,
+		}
+	}
+
+	root, err := pd.MerkleRoot(convertPropFilesFromWWW(np.Files))
+	if err != nil {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusInvalidSignature,
+		}
+	}
+	us := pd.UserSignature{
+		PublicKey: np.PublicKey,
+		Merkle:    hex.EncodeToString(root[:]),
+		Signature: np.Signature,
+	}
+	err = pd.VerifyUserSignature(us, nil)
+	if err != nil {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusInvalidSignature,
+		}
+	}
+
+	return &us, nil
+}
+
 func (b *backend) validatePassword(password string) error {
 	if len(password) < www.PolicyPasswordMinChars {
 		return www.UserError{
@@ -429,7 +661,7 @@ func (b *backend) LoadInventory() error {
 		unvetted := make([]www.ProposalRecord, 0)
 
 		for _, v := range b.inventory {
-			if v.Status == www.PropStatusPublic {
+			if statusVetted(v.Status) {
 				vetted = append(vetted, v)
 			} else {
 				unvetted = append(unvetted, v)
@@ -543,6 +775,15 @@ func (b *backend) ProcessNewUser(u www.NewUser) (*www.NewUserReply, error) {
 			NewUserVerificationExpiry: expiry,
 		}
 
+		// The optional public key is activated once the user signs the
+		// verification token.
+		if u.PublicKey != "" {
+			err = addPendingIdentity(&newUser, u.PublicKey)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		err = b.db.UserNew(newUser)
 		if err != nil {
 			if err == database.ErrInvalidEmail {
@@ -606,6 +847,17 @@ func (b *backend) ProcessVerifyNewUser(u www.VerifyNewUser) error {
 		}
 	}
 
+	// Users that registered a public key prove they own it by signing the
+	// verification token.  The e-mailed link carries no signature, in
+	// which case the key remains pending until it is verified through
+	// ProcessUpdateUserKey.
+	if pendingIdentity(user) != nil && u.Signature != "" {
+		err = activatePendingIdentity(user, token, u.Signature)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Clear out the verification token fields in the db.
 	user.NewUserVerificationToken = nil
 	user.NewUserVerificationExpiry = 0
@@ -649,6 +901,90 @@ func (b *backend) ProcessLogin(l www.Login) (*www.LoginReply, error) {
 	return &reply, nil
 }
 
+// loginChallenge is a challenge that was issued by ProcessLoginChallenge.  It
+// is kept with the session that requested it instead of with the user, so
+// that requesting a challenge does not replace the challenge that was issued
+// to somebody else.
+type loginChallenge struct {
+	Email     string
+	Challenge []byte
+	Expiry    int64
+}
+
+// ProcessLoginChallenge generates the challenge a user must sign in order to
+// login with ProcessLoginSigned.  The user is not looked up, so the reply does
+// not reveal which users exist.  The returned loginChallenge must be kept by
+// the caller and passed to ProcessLoginSigned.
+func (b *backend) ProcessLoginChallenge(lc www.LoginChallenge) (*www.LoginChallengeReply, *loginChallenge, error) {
+	challenge, err := util.Random(www.VerificationTokenSize)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reply := www.LoginChallengeReply{
+		Challenge: hex.EncodeToString(challenge),
+	}
+	expiry := time.Now().Add(time.Duration(www.LoginChallengeExpiryMinutes) *
+		time.Minute).Unix()
+
+	return &reply, &loginChallenge{
+		Email:     lc.Email,
+		Challenge: challenge,
+		Expiry:    expiry,
+	}, nil
+}
+
+// ProcessLoginSigned checks that the challenge lc generated by
+// ProcessLoginChallenge was signed by the active public key of the user.  The
+// caller must discard lc so that it can only be used once.
+func (b *backend) ProcessLoginSigned(l www.LoginSigned, lc *loginChallenge) (*www.LoginReply, error) {
+	// Check that there is a challenge for this user and that it hasn't
+	// expired.
+	if lc == nil || lc.Email != l.Email || time.Now().Unix() > lc.Expiry {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusInvalidEmailOrPassword,
+		}
+	}
+
+	// Get user from db.
+	user, err := b.db.UserGet(l.Email)
+	if err != nil {
+		if err == database.ErrUserNotFound {
+			return nil, www.UserError{
+				ErrorCode: www.ErrorStatusInvalidEmailOrPassword,
+			}
+		}
+		return nil, err
+	}
+
+	// Only verified users with an active key can login with a signature.
+	active := activeIdentity(user)
+	if user.NewUserVerificationToken != nil || active == nil {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusInvalidEmailOrPassword,
+		}
+	}
+	sig, err := identity.SignatureFromString(l.Signature)
+	if err != nil {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusInvalidSignature,
+		}
+	}
+	pi := identity.PublicIdentity{
+		Key: active.Key,
+	}
+	if !pi.VerifyMessage(lc.Challenge, *sig) {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusInvalidSignature,
+		}
+	}
+
+	return &www.LoginReply{
+		UserID:  user.ID,
+		IsAdmin: user.Admin,
+	}, nil
+}
+
 // ProcessChangePassword checks that the current password matches the one
 // in the database, then changes it to the new password.
 func (b *backend) ProcessChangePassword(email string, cp www.ChangePassword) (*www.ChangePasswordReply, error) {
@@ -727,6 +1063,126 @@ func (b *backend) ProcessResetPassword(rp www.ResetPassword) (*www.ResetPassword
 	return &reply, nil
 }
 
+// ProcessUpdateUserKey registers a new public key for the user.  The key
+// remains pending, and the active key remains in use, until the returned
+// challenge is signed by the new key.
+func (b *backend) ProcessUpdateUserKey(email string, uk www.UpdateUserKey) (*www.UpdateUserKeyReply, error) {
+	// Get user from db.
+	user, err := b.db.UserGet(email)
+	if err != nil {
+		return nil, err
+	}
+
+	err = addPendingIdentity(user, uk.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate the challenge and expiry.
+	challenge, expiry, err := b.generateVerificationTokenAndExpiry()
+	if err != nil {
+		return nil, err
+	}
+
+	// Add the updated user information to the db.
+	user.UpdateKeyChallenge = challenge
+	user.UpdateKeyChallengeExpiry = expiry
+	err = b.db.UserUpdate(*user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &www.UpdateUserKeyReply{
+		Challenge: hex.EncodeToString(challenge),
+	}, nil
+}
+
+// ProcessVerifyUpdateUserKey checks that the challenge generated by
+// ProcessUpdateUserKey was signed by the pending public key and makes it the
+// active key of the user.
+func (b *backend) ProcessVerifyUpdateUserKey(email string, v www.VerifyUpdateUserKey) (*www.VerifyUpdateUserKeyReply, error) {
+	// Get user from db.
+	user, err := b.db.UserGet(email)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check that there is a challenge and that it hasn't expired.
+	if user.UpdateKeyChallenge == nil {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusVerificationTokenInvalid,
+		}
+	}
+	if time.Now().Unix() > user.UpdateKeyChallengeExpiry {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusVerificationTokenExpired,
+		}
+	}
+
+	err = activatePendingIdentity(user, user.UpdateKeyChallenge, v.Signature)
+	if err != nil {
+		return nil, err
+	}
+
+	// Clear out the challenge fields in the db.
+	user.UpdateKeyChallenge = nil
+	user.UpdateKeyChallengeExpiry = 0
+	err = b.db.UserUpdate(*user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &www.VerifyUpdateUserKeyReply{}, nil
+}
+
+// ProcessRevokeUserKey deactivates the active public key of the user.  The
+// key is kept in the database so that its signatures remain verifiable.
+func (b *backend) ProcessRevokeUserKey(email string, rk www.RevokeUserKey) (*www.RevokeUserKeyReply, error) {
+	// Get user from db.
+	user, err := b.db.UserGet(email)
+	if err != nil {
+		return nil, err
+	}
+
+	active := activeIdentity(user)
+	if active == nil {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusNoPublicKey,
+		}
+	}
+	active.Deactivated = time.Now().Unix()
+
+	err = b.db.UserUpdate(*user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &www.RevokeUserKeyReply{}, nil
+}
+
+// ProcessUserKeys returns all public keys the user ever registered, oldest
+// first.
+func (b *backend) ProcessUserKeys(email string, uk www.UserKeys) (*www.UserKeysReply, error) {
+	// Get user from db.
+	user, err := b.db.UserGet(email)
+	if err != nil {
+		return nil, err
+	}
+
+	reply := www.UserKeysReply{
+		Identities: make([]www.UserIdentity, 0, len(user.Identities)),
+	}
+	for _, id := range user.Identities {
+		reply.Identities = append(reply.Identities, www.UserIdentity{
+			PublicKey:   hex.EncodeToString(id.Key[:]),
+			Activated:   id.Activated,
+			Deactivated: id.Deactivated,
+		})
+	}
+
+	return &reply, nil
+}
+
 // ProcessAllVetted returns an array of all vetted proposals in reverse order,
 // because they're sorted by oldest timestamp first.
 func (b *backend) ProcessAllVetted(v www.GetAllVetted) *www.GetAllVettedReply {
@@ -735,7 +1191,7 @@ func (b *backend) ProcessAllVetted(v www.GetAllVetted) *www.GetAllVettedReply {
 
 	proposals := make([]www.ProposalRecord, 0)
 	for i := len(b.inventory) - 1; i >= 0; i-- {
-		if b.inventory[i].Status == www.PropStatusPublic {
+		if statusVetted(b.inventory[i].Status) {
 			proposals = append(proposals, b.inventory[i])
 		}
 	}
@@ -753,8 +1209,7 @@ func (b *backend) ProcessAllUnvetted(u www.GetAllUnvetted) *www.GetAllUnvettedRe
 
 	proposals := make([]www.ProposalRecord, 0)
 	for i := len(b.inventory) - 1; i >= 0; i-- {
-		if b.inventory[i].Status == www.PropStatusNotReviewed ||
-			b.inventory[i].Status == www.PropStatusCensored {
+		if !statusVetted(b.inventory[i].Status) {
 			proposals = append(proposals, b.inventory[i])
 		}
 	}
@@ -764,8 +1219,10 @@ func (b *backend) ProcessAllUnvetted(u www.GetAllUnvetted) *www.GetAllUnvettedRe
 	}
 }
 
-// ProcessNewProposal tries to submit a new proposal to politeiad.
-func (b *backend) ProcessNewProposal(np www.NewProposal) (*www.NewProposalReply, error) {
+// ProcessNewProposal tries to submit a new proposal to politeiad on behalf of
+// the user with the provided email.  The proposal must be signed if the user
+// registered a public key.
+func (b *backend) ProcessNewProposal(np www.NewProposal, email string) (*www.NewProposalReply, error) {
 	var reply www.NewProposalReply
 
 	err := b.validateProposal(np)
@@ -773,6 +1230,15 @@ func (b *backend) ProcessNewProposal(np www.NewProposal) (*www.NewProposalReply,
 		return nil, err
 	}
 
+	user, err := b.db.UserGet(email)
+	if err != nil {
+		return nil, err
+	}
+	us, err := validateUserSignature(user, np)
+	if err != nil {
+		return nil, err
+	}
+
 	challenge, err := util.Random(pd.ChallengeSize)
 	if err != nil {
 		return nil, err
@@ -784,9 +1250,10 @@ func (b *backend) ProcessNewProposal(np www.NewProposal) (*www.NewProposalReply,
 	}
 
 	n := pd.New{
-		Name:      name,
-		Challenge: hex.EncodeToString(challenge),
-		Files:     convertPropFilesFromWWW(np.Files),
+		Name:          name,
+		Challenge:     hex.EncodeToString(challenge),
+		Files:         convertPropFilesFromWWW(np.Files),
+		UserSignature: us,
 	}
 
 	var pdReply pd.NewReply
@@ -806,10 +1273,13 @@ func (b *backend) ProcessNewProposal(np www.NewProposal) (*www.NewProposalReply,
 		b.inventory = append(b.inventory, www.ProposalRecord{
 			Name:             name,
 			Status:           www.PropStatusNotReviewed,
+			Version:          1,
 			Timestamp:        pdReply.Timestamp,
 			Files:            np.Files,
+			UserSignature:    convertPropUserSignatureFromPD(us),
 			CensorshipRecord: convertPropCensorFromPD(pdReply.CensorshipRecord),
 		})
+		b.addTestVersion(pdReply.CensorshipRecord.Token, np.Files)
 		b.initComment(pdReply.CensorshipRecord.Token)
 		b.Unlock()
 	} else {
@@ -839,8 +1309,10 @@ func (b *backend) ProcessNewProposal(np www.NewProposal) (*www.NewProposalReply,
 		r := www.ProposalRecord{
 			Name:             name,
 			Status:           www.PropStatusNotReviewed,
+			Version:          1,
 			Timestamp:        pdReply.Timestamp,
 			Files:            make([]www.File, 0),
+			UserSignature:    convertPropUserSignatureFromPD(us),
 			CensorshipRecord: convertPropCensorFromPD(pdReply.CensorshipRecord),
 		}
 		b.Lock()
@@ -849,40 +1321,254 @@ func (b *backend) ProcessNewProposal(np www.NewProposal) (*www.NewProposalReply,
 		b.Unlock()
 	}
 
+	// Record the author so that the user can edit the proposal later on.
+	// The proposal exists at this point so a failure is only logged.
+	// Reload the user since it may have changed in the meantime.
+	token := pdReply.CensorshipRecord.Token
+	user, err = b.db.UserGet(email)
+	if err == nil {
+		user.Proposals = append(user.Proposals, token)
+		err = b.db.UserUpdate(*user)
+	}
+	if err != nil {
+		log.Errorf("ProcessNewProposal: could not record author of %v: %v",
+			token, err)
+	}
+
 	reply.CensorshipRecord = convertPropCensorFromPD(pdReply.CensorshipRecord)
 	return &reply, nil
 }
 
-// ProcessSetProposalStatus changes the status of an existing proposal
-// from unreviewed to either published or censored.
-func (b *backend) ProcessSetProposalStatus(sps www.SetProposalStatus) (*www.SetProposalStatusReply, error) {
-	var reply www.SetProposalStatusReply
-	var pdReply pd.SetUnvettedStatusReply
-	if b.test {
-		pdReply.Status = convertPropStatusFromWWW(sps.ProposalStatus)
-	} else {
-		challenge, err := util.Random(pd.ChallengeSize)
-		if err != nil {
-			return nil, err
-		}
-
-		sus := pd.SetUnvettedStatus{
-			Token:     sps.Token,
-			Status:    convertPropStatusFromWWW(sps.ProposalStatus),
-			Challenge: hex.EncodeToString(challenge),
+// isProposalAuthor returns true if user submitted the proposal with the
+// provided token.
+func isProposalAuthor(user *database.User, token string) bool {
+	for _, v := range user.Proposals {
+		if v == token {
+			return true
 		}
+	}
+	return false
+}
 
-		responseBody, err := b.makeRequest(http.MethodPost,
-			pd.SetUnvettedStatusRoute, sus)
-		if err != nil {
-			return nil, err
-		}
+// ProcessEditProposal replaces the files of an existing proposal in
+// politeiad on behalf of the user with the provided email.  Only unreviewed
+// and public proposals can be edited and only by their author or an admin.
+func (b *backend) ProcessEditProposal(ep www.EditProposal, email string) (*www.EditProposalReply, error) {
+	var reply www.EditProposalReply
 
-		err = json.Unmarshal(responseBody, &pdReply)
-		if err != nil {
-			return nil, fmt.Errorf("Could not unmarshal SetUnvettedStatusReply: %v",
-				err)
-		}
+	err := b.validateProposal(www.NewProposal{Files: ep.Files})
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := getProposalName(ep.Files)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the proposal so that we know which repo it lives in.
+	var status www.PropStatusT
+	var found bool
+	b.RLock()
+	for _, v := range b.inventory {
+		if v.CensorshipRecord.Token == ep.Token {
+			status = v.Status
+			found = true
+			break
+		}
+	}
+	b.RUnlock()
+	if !found {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusProposalNotFound,
+		}
+	}
+	if status != www.PropStatusNotReviewed &&
+		status != www.PropStatusPublic {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusInvalidPropStatusTransition,
+		}
+	}
+
+	user, err := b.db.UserGet(email)
+	if err != nil {
+		return nil, err
+	}
+	if !user.Admin && !isProposalAuthor(user, ep.Token) {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusUserNotAuthor,
+		}
+	}
+
+	var pdReply pd.UpdateUnvettedReply
+	if b.test {
+		pdReply.Timestamp = time.Now().Unix()
+		pdReply.CensorshipRecord = pd.CensorshipRecord{
+			Token: ep.Token,
+		}
+	} else {
+		challenge, err := util.Random(pd.ChallengeSize)
+		if err != nil {
+			return nil, err
+		}
+
+		var (
+			route         string
+			requestObject interface{}
+		)
+		if status == www.PropStatusPublic {
+			route = pd.UpdateVettedRoute
+			requestObject = pd.UpdateVetted{
+				Challenge: hex.EncodeToString(challenge),
+				Token:     ep.Token,
+				Files:     convertPropFilesFromWWW(ep.Files),
+			}
+		} else {
+			route = pd.UpdateUnvettedRoute
+			requestObject = pd.UpdateUnvetted{
+				Challenge: hex.EncodeToString(challenge),
+				Token:     ep.Token,
+				Files:     convertPropFilesFromWWW(ep.Files),
+			}
+		}
+
+		responseBody, err := b.makeRequest(http.MethodPost, route,
+			requestObject)
+		if err != nil {
+			return nil, err
+		}
+
+		// The vetted and unvetted replies are identical.
+		err = json.Unmarshal(responseBody, &pdReply)
+		if err != nil {
+			return nil, fmt.Errorf("Unmarshal UpdateReply: %v",
+				err)
+		}
+
+		// Verify the challenge.
+		err = util.VerifyChallenge(b.cfg.Identity, challenge,
+			pdReply.Response)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Update the cached proposal.
+	b.Lock()
+	defer b.Unlock()
+	for k, v := range b.inventory {
+		if v.CensorshipRecord.Token != ep.Token {
+			continue
+		}
+		b.inventory[k].Name = name
+		b.inventory[k].Version++
+		b.inventory[k].Timestamp = pdReply.Timestamp
+		b.inventory[k].CensorshipRecord =
+			convertPropCensorFromPD(pdReply.CensorshipRecord)
+		if b.test {
+			b.inventory[k].Files = ep.Files
+			b.addTestVersion(ep.Token, ep.Files)
+		}
+		break
+	}
+
+	reply.CensorshipRecord = convertPropCensorFromPD(pdReply.CensorshipRecord)
+	return &reply, nil
+}
+
+// ProcessSetProposalStatus changes the status of an existing proposal.
+// Unreviewed proposals are published or censored; public proposals are
+// abandoned or archived.  Only the author withdraws a proposal, see
+// ProcessWithdrawProposal.
+func (b *backend) ProcessSetProposalStatus(sps www.SetProposalStatus) (*www.SetProposalStatusReply, error) {
+	if sps.ProposalStatus == www.PropStatusWithdrawn {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusInvalidPropStatusTransition,
+		}
+	}
+
+	return b.setProposalStatus(sps)
+}
+
+// setProposalStatus is the generic implementation of ProcessSetProposalStatus
+// and ProcessWithdrawProposal.  It changes the status in politeiad and
+// updates the cached proposal.
+func (b *backend) setProposalStatus(sps www.SetProposalStatus) (*www.SetProposalStatusReply, error) {
+	// Reject transitions that politeiad would reject so that test mode
+	// behaves the same.
+	var status www.PropStatusT
+	var version uint
+	var found bool
+	b.RLock()
+	for _, v := range b.inventory {
+		if v.CensorshipRecord.Token == sps.Token {
+			status = v.Status
+			version = v.Version
+			found = true
+			break
+		}
+	}
+	b.RUnlock()
+	if !found {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusProposalNotFound,
+		}
+	}
+	if !validStatusTransition(status, sps.ProposalStatus) {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusInvalidPropStatusTransition,
+		}
+	}
+
+	var reply www.SetProposalStatusReply
+	var pdReply pd.SetUnvettedStatusReply
+	if b.test {
+		// Every status change is a new version, like in politeiad.
+		pdReply.Status = convertPropStatusFromWWW(sps.ProposalStatus)
+		pdReply.Version = version + 1
+		pdReply.StatusRecord.Timestamp = time.Now().Unix()
+	} else {
+		challenge, err := util.Random(pd.ChallengeSize)
+		if err != nil {
+			return nil, err
+		}
+
+		// The terminal public statuses are set on the vetted repo.
+		var (
+			route         string
+			requestObject interface{}
+		)
+		if sps.ProposalStatus == www.PropStatusAbandoned ||
+			sps.ProposalStatus == www.PropStatusArchived {
+			route = pd.SetVettedStatusRoute
+			requestObject = pd.SetVettedStatus{
+				Token:     sps.Token,
+				Status:    convertPropStatusFromWWW(sps.ProposalStatus),
+				Reason:    sps.Reason,
+				Challenge: hex.EncodeToString(challenge),
+			}
+		} else {
+			route = pd.SetUnvettedStatusRoute
+			requestObject = pd.SetUnvettedStatus{
+				Token:     sps.Token,
+				Status:    convertPropStatusFromWWW(sps.ProposalStatus),
+				Reason:    sps.Reason,
+				Challenge: hex.EncodeToString(challenge),
+			}
+		}
+
+		responseBody, err := b.makeRequest(http.MethodPost, route,
+			requestObject)
+		if err != nil {
+			return nil, err
+		}
+
+		// The vetted and unvetted replies are identical.
+		err = json.Unmarshal(responseBody, &pdReply)
+		if err != nil {
+			return nil, fmt.Errorf("Could not unmarshal SetUnvettedStatusReply: %v",
+				err)
+		}
 
 		// Verify the challenge.
 		err = util.VerifyChallenge(b.cfg.Identity, challenge, pdReply.Response)
@@ -891,14 +1577,26 @@ func (b *backend) ProcessSetProposalStatus(sps www.SetProposalStatus) (*www.SetP
 		}
 	}
 
-	// Update the cached proposal with the new status and return the reply.
+	// Update the cached proposal with the new status and version and
+	// return the reply.
 	b.Lock()
 	defer b.Unlock()
 	for k, v := range b.inventory {
 		if v.CensorshipRecord.Token == sps.Token {
 			s := convertPropStatusFromPD(pdReply.Status)
 			b.inventory[k].Status = s
+			b.inventory[k].Version = pdReply.Version
+			b.inventory[k].Timestamp = pdReply.StatusRecord.Timestamp
+			b.inventory[k].Reason = sps.Reason
+			if b.test {
+				b.addTestVersion(sps.Token, v.Files)
+			}
 			reply.ProposalStatus = s
+
+			// Comments made before publishing can now be sent.
+			if s == www.PropStatusPublic {
+				b.triggerCommentFlush()
+			}
 			return &reply, nil
 		}
 	}
@@ -908,6 +1606,33 @@ func (b *backend) ProcessSetProposalStatus(sps www.SetProposalStatus) (*www.SetP
 	}
 }
 
+// ProcessWithdrawProposal withdraws an unreviewed proposal on behalf of its
+// author.
+func (b *backend) ProcessWithdrawProposal(wp www.WithdrawProposal, email string) (*www.WithdrawProposalReply, error) {
+	user, err := b.db.UserGet(email)
+	if err != nil {
+		return nil, err
+	}
+	if !isProposalAuthor(user, wp.Token) {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusUserNotAuthor,
+		}
+	}
+
+	spsr, err := b.setProposalStatus(www.SetProposalStatus{
+		Token:          wp.Token,
+		ProposalStatus: www.PropStatusWithdrawn,
+		Reason:         wp.Reason,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &www.WithdrawProposalReply{
+		ProposalStatus: spsr.ProposalStatus,
+	}, nil
+}
+
 // ProcessProposalDetails tries to fetch the full details of a proposal from politeiad.
 func (b *backend) ProcessProposalDetails(propDetails www.ProposalsDetails, isUserAdmin bool) (*www.ProposalDetailsReply, error) {
 	var reply www.ProposalDetailsReply
@@ -933,7 +1658,7 @@ func (b *backend) ProcessProposalDetails(propDetails www.ProposalsDetails, isUse
 
 	var isVettedProposal bool
 	var requestObject interface{}
-	if cachedProposal.Status == www.PropStatusPublic {
+	if statusVetted(cachedProposal.Status) {
 		isVettedProposal = true
 		requestObject = pd.GetVetted{
 			Token:     propDetails.Token,
@@ -959,6 +1684,8 @@ func (b *backend) ProcessProposalDetails(propDetails www.ProposalsDetails, isUse
 		reply.Proposal = www.ProposalRecord{
 			Status:           cachedProposal.Status,
 			Timestamp:        cachedProposal.Timestamp,
+			Reason:           cachedProposal.Reason,
+			UserSignature:    cachedProposal.UserSignature,
 			CensorshipRecord: cachedProposal.CensorshipRecord,
 		}
 		return &reply, nil
@@ -1010,10 +1737,308 @@ func (b *backend) ProcessProposalDetails(propDetails www.ProposalsDetails, isUse
 	return &reply, nil
 }
 
+// getCachedProposal returns a copy of the cached proposal that matches the
+// provided censorship token.
+func (b *backend) getCachedProposal(token string) (*www.ProposalRecord, bool) {
+	b.RLock()
+	defer b.RUnlock()
+	for _, v := range b.inventory {
+		if v.CensorshipRecord.Token == token {
+			p := v
+			return &p, true
+		}
+	}
+	return nil, false
+}
+
+// getProposalVersion fetches a specific version of a proposal from politeiad.
+func (b *backend) getProposalVersion(token string, vetted bool, version uint) (*pd.ProposalRecord, error) {
+	challenge, err := util.Random(pd.ChallengeSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		route         string
+		requestObject interface{}
+	)
+	if vetted {
+		route = pd.GetVettedRoute
+		requestObject = pd.GetVetted{
+			Challenge: hex.EncodeToString(challenge),
+			Token:     token,
+			Version:   version,
+		}
+	} else {
+		route = pd.GetUnvettedRoute
+		requestObject = pd.GetUnvetted{
+			Challenge: hex.EncodeToString(challenge),
+			Token:     token,
+			Version:   version,
+		}
+	}
+
+	responseBody, err := b.makeRequest(http.MethodPost, route, requestObject)
+	if err != nil {
+		return nil, err
+	}
+
+	// The vetted and unvetted replies are identical.
+	var pdReply pd.GetVettedReply
+	err = json.Unmarshal(responseBody, &pdReply)
+	if err != nil {
+		return nil, fmt.Errorf("Could not unmarshal GetReply: %v", err)
+	}
+
+	// Verify the challenge.
+	err = util.VerifyChallenge(b.cfg.Identity, challenge, pdReply.Response)
+	if err != nil {
+		return nil, err
+	}
+
+	if pdReply.Proposal.Status == pd.PropStatusNotFound {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusProposalNotFound,
+		}
+	}
+
+	return &pdReply.Proposal, nil
+}
+
+// splitLines splits s into newline terminated lines.  A missing newline on
+// the last line is added so that it diffs like any other line.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+	lines[len(lines)-1] += "\n"
+	return lines
+}
+
+// diffProposalFiles returns a unified diff for every file that differs
+// between the two provided sets of files.  Files that are only present in one
+// of the sets are diffed against an empty file.  Binary files are not diffed.
+func diffProposalFiles(from, to []www.File) ([]www.FileDiff, error) {
+	fromFiles := make(map[string]www.File, len(from))
+	toFiles := make(map[string]www.File, len(to))
+	names := make([]string, 0, len(from)+len(to))
+	for _, v := range from {
+		fromFiles[v.Name] = v
+		names = append(names, v.Name)
+	}
+	for _, v := range to {
+		toFiles[v.Name] = v
+		if _, ok := fromFiles[v.Name]; !ok {
+			names = append(names, v.Name)
+		}
+	}
+	sort.Strings(names)
+
+	diffs := make([]www.FileDiff, 0, len(names))
+	for _, name := range names {
+		a, inFrom := fromFiles[name]
+		b, inTo := toFiles[name]
+		if inFrom && inTo && a.Payload == b.Payload {
+			continue
+		}
+
+		fromName := "/dev/null"
+		if inFrom {
+			fromName = "a/" + name
+		}
+		toName := "/dev/null"
+		if inTo {
+			toName = "b/" + name
+		}
+
+		// Only text files are diffed.
+		if (inFrom && !strings.HasPrefix(a.MIME, "text/")) ||
+			(inTo && !strings.HasPrefix(b.MIME, "text/")) {
+			diffs = append(diffs, www.FileDiff{
+				Name: name,
+				Diff: fmt.Sprintf("Binary files %v and %v differ\n",
+					fromName, toName),
+			})
+			continue
+		}
+
+		var aLines, bLines []string
+		if inFrom {
+			aText, err := base64.StdEncoding.DecodeString(a.Payload)
+			if err != nil {
+				return nil, err
+			}
+			aLines = splitLines(string(aText))
+		}
+		if inTo {
+			bText, err := base64.StdEncoding.DecodeString(b.Payload)
+			if err != nil {
+				return nil, err
+			}
+			bLines = splitLines(string(bText))
+		}
+
+		d, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        aLines,
+			B:        bLines,
+			FromFile: fromName,
+			ToFile:   toName,
+			Context:  3,
+		})
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, www.FileDiff{
+			Name: name,
+			Diff: d,
+		})
+	}
+
+	return diffs, nil
+}
+
+// ProcessProposalVersions retrieves the revision history of a proposal from
+// politeiad.  The history of unvetted proposals is only available to admins.
+func (b *backend) ProcessProposalVersions(pv www.ProposalVersions, isUserAdmin bool) (*www.ProposalVersionsReply, error) {
+	var reply www.ProposalVersionsReply
+
+	cachedProposal, ok := b.getCachedProposal(pv.Token)
+	if !ok {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusProposalNotFound,
+		}
+	}
+	isVettedProposal := statusVetted(cachedProposal.Status)
+	if !isVettedProposal && !isUserAdmin {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusProposalNotFound,
+		}
+	}
+
+	if b.test {
+		reply.Versions = []www.ProposalVersion{{
+			Version:   cachedProposal.Version,
+			Status:    cachedProposal.Status,
+			Timestamp: cachedProposal.Timestamp,
+			Merkle:    cachedProposal.CensorshipRecord.Merkle,
+		}}
+		return &reply, nil
+	}
+
+	challenge, err := util.Random(pd.ChallengeSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		route         string
+		requestObject interface{}
+	)
+	if isVettedProposal {
+		route = pd.GetVettedVersionsRoute
+		requestObject = pd.GetVettedVersions{
+			Challenge: hex.EncodeToString(challenge),
+			Token:     pv.Token,
+		}
+	} else {
+		route = pd.GetUnvettedVersionsRoute
+		requestObject = pd.GetUnvettedVersions{
+			Challenge: hex.EncodeToString(challenge),
+			Token:     pv.Token,
+		}
+	}
+
+	responseBody, err := b.makeRequest(http.MethodPost, route, requestObject)
+	if err != nil {
+		return nil, err
+	}
+
+	// The vetted and unvetted replies are identical.
+	var pdReply pd.GetVettedVersionsReply
+	err = json.Unmarshal(responseBody, &pdReply)
+	if err != nil {
+		return nil, fmt.Errorf("Could not unmarshal "+
+			"GetVersionsReply: %v", err)
+	}
+
+	// Verify the challenge.
+	err = util.VerifyChallenge(b.cfg.Identity, challenge, pdReply.Response)
+	if err != nil {
+		return nil, err
+	}
+
+	reply.Versions = convertPropVersionsFromPD(pdReply.Versions)
+	return &reply, nil
+}
+
+// ProcessProposalDiff returns the per file unified diff between two versions
+// of a proposal.  Unvetted proposals can only be diffed by admins.
+func (b *backend) ProcessProposalDiff(pdiff www.ProposalDiff, isUserAdmin bool) (*www.ProposalDiffReply, error) {
+	cachedProposal, ok := b.getCachedProposal(pdiff.Token)
+	if !ok {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusProposalNotFound,
+		}
+	}
+	isVettedProposal := statusVetted(cachedProposal.Status)
+	if !isVettedProposal && !isUserAdmin {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusProposalNotFound,
+		}
+	}
+
+	var from, to []www.File
+	if b.test {
+		var fromOK, toOK bool
+		from, fromOK = b.getTestVersion(pdiff.Token, pdiff.From)
+		to, toOK = b.getTestVersion(pdiff.Token, pdiff.To)
+		if !fromOK || !toOK {
+			return nil, www.UserError{
+				ErrorCode: www.ErrorStatusProposalNotFound,
+			}
+		}
+	} else {
+		f, err := b.getProposalVersion(pdiff.Token, isVettedProposal,
+			pdiff.From)
+		if err != nil {
+			return nil, err
+		}
+		t, err := b.getProposalVersion(pdiff.Token, isVettedProposal,
+			pdiff.To)
+		if err != nil {
+			return nil, err
+		}
+		from = convertPropFilesFromPD(f.Files)
+		to = convertPropFilesFromPD(t.Files)
+	}
+
+	files, err := diffProposalFiles(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return &www.ProposalDiffReply{
+		From:  pdiff.From,
+		To:    pdiff.To,
+		Files: files,
+	}, nil
+}
+
 // ProcessComment processes a submitted comment.  It ensures the proposal and
 // the parent exists.  A parent ID of 0 indicates that it is a comment on the
 // proposal whereas non-zero indicates that it is a reply to a comment.
 func (b *backend) ProcessComment(c www.NewComment, userID uint64) (*www.NewCommentReply, error) {
+	// politeiad does not store comments without text.
+	if strings.TrimSpace(c.Comment) == "" {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusCommentEmpty,
+		}
+	}
+
 	b.Lock()
 	defer b.Unlock()
 	m, ok := b.comments[c.Token]
@@ -1036,9 +2061,78 @@ func (b *backend) ProcessComment(c www.NewComment, userID uint64) (*www.NewComme
 	return b.addComment(c, userID)
 }
 
-// ProcessCommentGet returns all comments for a given proposal.
-func (b *backend) ProcessCommentGet(token string) (*www.GetCommentsReply, error) {
-	c, err := b.getComments(token)
+// ProcessCensorComment censors a comment.  The comment remains in place with
+// its metadata and digest but without its text.
+func (b *backend) ProcessCensorComment(cc www.CensorComment) (*www.CensorCommentReply, error) {
+	b.Lock()
+	defer b.Unlock()
+	m, ok := b.comments[cc.Token]
+	if !ok {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusProposalNotFound,
+		}
+	}
+	c, ok := m[cc.CommentID]
+	if !ok {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusCommentNotFound,
+		}
+	}
+	if c.Censored {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusCommentCensored,
+		}
+	}
+
+	err := b.censorComment(cc.Token, cc.CommentID, cc.Reason)
+	if err != nil {
+		return nil, err
+	}
+
+	return &www.CensorCommentReply{}, nil
+}
+
+// ProcessLikeComment casts the vote of a user on a comment.  Censored comments
+// can no longer be voted on.
+func (b *backend) ProcessLikeComment(lc www.LikeComment, userID uint64) (*www.LikeCommentReply, error) {
+	if !validLikeAction(lc.Action) {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusInvalidLikeCommentAction,
+		}
+	}
+
+	b.Lock()
+	defer b.Unlock()
+	m, ok := b.comments[lc.Token]
+	if !ok {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusProposalNotFound,
+		}
+	}
+	c, ok := m[lc.CommentID]
+	if !ok {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusCommentNotFound,
+		}
+	}
+	if c.Censored {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusCommentCensored,
+		}
+	}
+
+	return b.addLike(lc, userID)
+}
+
+// ProcessUserCommentsLikes returns the votes of a user on the comments of a
+// proposal.
+func (b *backend) ProcessUserCommentsLikes(token string, userID uint64) (*www.UserCommentsLikesReply, error) {
+	return b.getUserLikes(token, userID)
+}
+
+// ProcessCommentGet returns the comments of a given proposal.
+func (b *backend) ProcessCommentGet(token string, gc www.GetComments) (*www.GetCommentsReply, error) {
+	c, err := b.getComments(token, gc)
 	if err != nil {
 		return nil, err
 	}
@@ -1048,12 +2142,13 @@ func (b *backend) ProcessCommentGet(token string) (*www.GetCommentsReply, error)
 // ProcessPolicy returns the details of Politeia's restrictions on file uploads.
 func (b *backend) ProcessPolicy(p www.Policy) *www.PolicyReply {
 	return &www.PolicyReply{
-		PasswordMinChars: www.PolicyPasswordMinChars,
-		MaxImages:        www.PolicyMaxImages,
-		MaxImageSize:     www.PolicyMaxImageSize,
-		MaxMDs:           www.PolicyMaxMDs,
-		MaxMDSize:        www.PolicyMaxMDSize,
-		ValidMIMETypes:   mime.ValidMimeTypes(),
+		PasswordMinChars:    www.PolicyPasswordMinChars,
+		MaxImages:           www.PolicyMaxImages,
+		MaxImageSize:        www.PolicyMaxImageSize,
+		MaxMDs:              www.PolicyMaxMDs,
+		MaxMDSize:           www.PolicyMaxMDSize,
+		ValidMIMETypes:      mime.ValidMimeTypes(),
+		MaxCommentsPageSize: www.PolicyMaxCommentsPageSize,
 	}
 }
 
@@ -1061,6 +2156,7 @@ func (b *backend) ProcessPolicy(p www.Policy) *www.PolicyReply {
 func NewBackend(cfg *config) (*backend, error) {
 	// Setup database.
 	localdb.UseLogger(localdbLog)
+	journal.UseLogger(journalLog)
 	db, err := localdb.New(cfg.DataDir)
 	if err != nil {
 		return nil, err
@@ -1071,23 +2167,52 @@ func NewBackend(cfg *config) (*backend, error) {
 		db:       db,
 		cfg:      cfg,
 		comments: make(map[string]map[uint64]BackendComment),
+		likes:    make(map[string]map[uint64]map[uint64]BackendLike),
 		commentJournalDir: filepath.Join(cfg.DataDir,
 			defaultCommentJournalDir),
 		commentID: 1, // Replay will set this value
+		flushC:    make(chan struct{}, 1),
 	}
 	b.commentJournalFile = filepath.Join(b.commentJournalDir,
 		defaultCommentJournalFile)
+	b.likeJournalFile = filepath.Join(b.commentJournalDir,
+		defaultLikeJournalFile)
 
 	// Setup comments
 	os.MkdirAll(b.commentJournalDir, 0744)
 	err = b.replayCommentJournal()
 	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	err = b.replayLikeJournal()
+	if err != nil {
+		b.commentJournal.Close()
+		db.Close()
 		return nil, err
 	}
 
 	return b, nil
 }
 
+// Close closes the comment and like journals and the database.
+// This call must be called WITHOUT the lock held.
+func (b *backend) Close() {
+	b.Lock()
+	defer b.Unlock()
+
+	for _, j := range []*journal.Journal{b.commentJournal, b.likeJournal} {
+		err := j.Close()
+		if err != nil {
+			log.Errorf("close journal: %v", err)
+		}
+	}
+	err := b.db.Close()
+	if err != nil {
+		log.Errorf("close database: %v", err)
+	}
+}
+
 // getProposalName returns the proposal name based on the index markdown file.
 func getProposalName(files []www.File) (string, error) {
 	for _, file := range files {
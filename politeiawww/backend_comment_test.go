@@ -0,0 +1,930 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	pd "github.com/decred/politeia/politeiad/api/v1"
+	"github.com/decred/politeia/politeiad/api/v1/identity"
+	www "github.com/decred/politeia/politeiawww/api/v1"
+	"github.com/decred/politeia/util"
+)
+
+// expectFlushed fails the test if the flushed state of the comments of token
+// does not match flushed.
+func expectFlushed(t *testing.T, b *backend, token string, flushed bool) {
+	b.RLock()
+	defer b.RUnlock()
+
+	if len(b.comments[token]) == 0 {
+		t.Fatalf("no comments for %v", token)
+	}
+	for _, c := range b.comments[token] {
+		if c.Flushed != flushed {
+			t.Fatalf("comment %v flushed %v, expected %v",
+				c.CommentID, c.Flushed, flushed)
+		}
+	}
+}
+
+// newCommentBackend returns a test backend that keeps its data in dir so that
+// the comment journal can be replayed.
+func newCommentBackend(t *testing.T, dir string) *backend {
+	b, err := NewBackend(&config{
+		DataDir: filepath.Join(dir, "data"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.test = true
+	b.inventory = make([]www.ProposalRecord, 0)
+	return b
+}
+
+// getComment returns the comment with the given id.
+func getComment(t *testing.T, b *backend, token string, commentID uint64) www.Comment {
+	gcr, err := b.ProcessCommentGet(token, www.GetComments{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range gcr.Comments {
+		if c.CommentID == commentID {
+			return c
+		}
+	}
+	t.Fatalf("comment %v not found", commentID)
+	return www.Comment{}
+}
+
+// Tests that only comments on vetted proposals are flushed and that the
+// flushed state survives a restart.
+func TestFlushComments(t *testing.T) {
+	dir, err := ioutil.TempDir("", "politeiawww.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	b := newCommentBackend(t, dir)
+
+	_, unvetted, err := createNewProposal(b, t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, vetted, err := createNewProposal(b, t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publishProposal(b, vetted.CensorshipRecord.Token, t)
+
+	for _, token := range []string{
+		unvetted.CensorshipRecord.Token,
+		vetted.CensorshipRecord.Token,
+	} {
+		_, err = b.ProcessComment(www.NewComment{
+			Token:   token,
+			Comment: "comment",
+		}, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = b.flushComments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectFlushed(t, b, vetted.CensorshipRecord.Token, true)
+	expectFlushed(t, b, unvetted.CensorshipRecord.Token, false)
+
+	// Comments made before publishing are flushed once public.
+	publishProposal(b, unvetted.CensorshipRecord.Token, t)
+	err = b.flushComments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectFlushed(t, b, unvetted.CensorshipRecord.Token, true)
+
+	// The journal remembers what was flushed.
+	b.Close()
+	b = newCommentBackend(t, dir)
+	defer b.Close()
+	expectFlushed(t, b, vetted.CensorshipRecord.Token, true)
+	expectFlushed(t, b, unvetted.CensorshipRecord.Token, true)
+}
+
+// fakePoliteiad answers AppendComments, CensorComment and Inventory requests
+// the way politeiad does.  It fails with a server error for tokens in fail and
+// rejects comments whose text is reject.  It records the ids of the comments
+// it was sent and of the comments it censored, and the inventory requests.
+type fakePoliteiad struct {
+	sync.Mutex
+	id          *identity.FullIdentity
+	fail        map[string]bool
+	reject      string
+	sent        []uint64
+	censored    []uint64
+	vetted      []pd.ProposalRecord
+	branches    []pd.ProposalRecord
+	inventories []pd.Inventory
+}
+
+// startFakePoliteiad serves f over TLS and points b at it.  The returned
+// server must be closed by the caller.
+func startFakePoliteiad(t *testing.T, b *backend, dir string, f *fakePoliteiad) *httptest.Server {
+	srv := httptest.NewTLSServer(f)
+	certFile := filepath.Join(dir, "rpc.cert")
+	err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: srv.Certificate().Raw,
+	}), 0600)
+	if err != nil {
+		srv.Close()
+		t.Fatal(err)
+	}
+	b.cfg.RPCHost = srv.URL
+	b.cfg.RPCCert = certFile
+	b.cfg.Identity = &f.id.Public
+	return srv
+}
+
+func (f *fakePoliteiad) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case pd.CensorCommentRoute:
+		f.censorComment(w, r)
+		return
+	case pd.InventoryRoute:
+		f.inventory(w, r)
+		return
+	}
+
+	var ac pd.AppendComments
+	err := json.NewDecoder(r.Body).Decode(&ac)
+	if err != nil || r.URL.Path != pd.AppendCommentsRoute {
+		util.RespondWithJSON(w, http.StatusBadRequest, pd.UserErrorReply{
+			ErrorCode: pd.ErrorStatusInvalidRequestPayload,
+		})
+		return
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	for _, c := range ac.Comments {
+		f.sent = append(f.sent, c.CommentID)
+	}
+	if f.fail[ac.Token] {
+		util.RespondWithJSON(w, http.StatusInternalServerError,
+			pd.ServerErrorReply{})
+		return
+	}
+	for _, c := range ac.Comments {
+		if c.Comment == f.reject {
+			util.RespondWithJSON(w, http.StatusBadRequest,
+				pd.UserErrorReply{
+					ErrorCode:    pd.ErrorStatusInvalidComment,
+					ErrorContext: []string{fmt.Sprintf("%v", c.CommentID)},
+				})
+			return
+		}
+	}
+
+	challenge, err := hex.DecodeString(ac.Challenge)
+	if err != nil {
+		util.RespondWithJSON(w, http.StatusBadRequest, pd.UserErrorReply{
+			ErrorCode: pd.ErrorStatusInvalidChallenge,
+		})
+		return
+	}
+	signature := f.id.SignMessage(challenge)
+	util.RespondWithJSON(w, http.StatusOK, pd.AppendCommentsReply{
+		Response: hex.EncodeToString(signature[:]),
+		Appended: uint(len(ac.Comments)),
+	})
+}
+
+func (f *fakePoliteiad) censorComment(w http.ResponseWriter, r *http.Request) {
+	var cc pd.CensorComment
+	err := json.NewDecoder(r.Body).Decode(&cc)
+	if err != nil {
+		util.RespondWithJSON(w, http.StatusBadRequest, pd.UserErrorReply{
+			ErrorCode: pd.ErrorStatusInvalidRequestPayload,
+		})
+		return
+	}
+	challenge, err := hex.DecodeString(cc.Challenge)
+	if err != nil {
+		util.RespondWithJSON(w, http.StatusBadRequest, pd.UserErrorReply{
+			ErrorCode: pd.ErrorStatusInvalidChallenge,
+		})
+		return
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	f.censored = append(f.censored, cc.CommentID)
+	signature := f.id.SignMessage(challenge)
+	util.RespondWithJSON(w, http.StatusOK, pd.CensorCommentReply{
+		Response: hex.EncodeToString(signature[:]),
+	})
+}
+
+// inventoryPage returns the records that follow cursor, which is the index
+// of the next record, and the cursor of the next page.
+func inventoryPage(records []pd.ProposalRecord, count uint, cursor string, status []pd.PropStatusT) ([]pd.ProposalRecord, string) {
+	for _, v := range status {
+		if v == pd.PropStatusInvalid {
+			return []pd.ProposalRecord{}, ""
+		}
+	}
+	var start int
+	if cursor != "" {
+		fmt.Sscanf(cursor, "%d", &start)
+	}
+	end := len(records)
+	if count != 0 && start+int(count) < end {
+		end = start + int(count)
+	}
+	if count == 0 || end-start < int(count) {
+		return records[start:end], ""
+	}
+	return records[start:end], fmt.Sprintf("%d", end)
+}
+
+func (f *fakePoliteiad) inventory(w http.ResponseWriter, r *http.Request) {
+	var i pd.Inventory
+	err := json.NewDecoder(r.Body).Decode(&i)
+	if err != nil {
+		util.RespondWithJSON(w, http.StatusBadRequest, pd.UserErrorReply{
+			ErrorCode: pd.ErrorStatusInvalidRequestPayload,
+		})
+		return
+	}
+	challenge, err := hex.DecodeString(i.Challenge)
+	if err != nil {
+		util.RespondWithJSON(w, http.StatusBadRequest, pd.UserErrorReply{
+			ErrorCode: pd.ErrorStatusInvalidChallenge,
+		})
+		return
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	f.inventories = append(f.inventories, i)
+	signature := f.id.SignMessage(challenge)
+	reply := pd.InventoryReply{
+		Response: hex.EncodeToString(signature[:]),
+	}
+	reply.Vetted, reply.VettedCursor = inventoryPage(f.vetted,
+		i.VettedCount, i.VettedCursor, i.VettedStatus)
+	reply.Branches, reply.BranchesCursor = inventoryPage(f.branches,
+		i.BranchesCount, i.BranchesCursor, i.BranchesStatus)
+	util.RespondWithJSON(w, http.StatusOK, reply)
+}
+
+// takeSent returns and clears the ids of the comments that were sent.
+func (f *fakePoliteiad) takeSent() []uint64 {
+	f.Lock()
+	defer f.Unlock()
+	sent := f.sent
+	f.sent = nil
+	sort.Slice(sent, func(i, j int) bool {
+		return sent[i] < sent[j]
+	})
+	return sent
+}
+
+// Tests flushing comments to politeiad.  A failing proposal does not hold up
+// the others and comments that politeiad rejects are not sent again.
+func TestFlushCommentsRemote(t *testing.T) {
+	dir, err := ioutil.TempDir("", "politeiawww.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	b := newCommentBackend(t, dir)
+	defer b.Close()
+
+	id, err := identity.New("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakePoliteiad{
+		id:     id,
+		fail:   make(map[string]bool),
+		reject: "rejected",
+	}
+	srv := startFakePoliteiad(t, b, dir, f)
+	defer srv.Close()
+
+	comment := func(token, text string) uint64 {
+		cr, err := b.ProcessComment(www.NewComment{
+			Token:   token,
+			Comment: text,
+		}, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cr.CommentID
+	}
+	tokens := make([]string, 0, 2)
+	for i := 0; i < 2; i++ {
+		_, npr, err := createNewProposal(b, t)
+		if err != nil {
+			t.Fatal(err)
+		}
+		token := npr.CensorshipRecord.Token
+		publishProposal(b, token, t)
+		tokens = append(tokens, token)
+	}
+	failing, rejecting := tokens[0], tokens[1]
+	c1 := comment(failing, "comment")
+	c2 := comment(rejecting, "comment")
+	c3 := comment(rejecting, "rejected")
+	c4 := comment(rejecting, "comment")
+
+	// Empty comments are refused up front.
+	_, err = b.ProcessComment(www.NewComment{
+		Token:   rejecting,
+		Comment: " \n",
+	}, 1)
+	assertError(t, err, www.ErrorStatusCommentEmpty)
+
+	// The server error is reported but the other proposal is flushed,
+	// except for the comment that politeiad rejects.
+	b.test = false
+	f.fail[failing] = true
+	err = b.flushComments()
+	if err == nil {
+		t.Fatalf("expected flush error")
+	}
+	expectFlushed(t, b, failing, false)
+	if !reflect.DeepEqual(f.takeSent(), []uint64{c1, c2, c3, c4}) {
+		t.Fatalf("unexpected comments sent")
+	}
+	err = b.flushComments()
+	if err == nil {
+		t.Fatalf("expected flush error")
+	}
+	if !reflect.DeepEqual(f.takeSent(), []uint64{c1, c2, c4}) {
+		t.Fatalf("rejected comment was sent again")
+	}
+	b.RLock()
+	rejected := b.comments[rejecting][c3]
+	flushed := b.comments[rejecting][c2].Flushed &&
+		b.comments[rejecting][c4].Flushed
+	b.RUnlock()
+	if !rejected.Rejected || rejected.Flushed || !flushed {
+		t.Fatalf("unexpected flush state")
+	}
+
+	// The failing proposal is retried until it succeeds.
+	f.fail[failing] = false
+	err = b.flushComments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectFlushed(t, b, failing, true)
+	if !reflect.DeepEqual(f.takeSent(), []uint64{c1}) {
+		t.Fatalf("unexpected comments sent")
+	}
+
+	// The tombstone of a flushed comment is sent once.
+	_, err = b.ProcessCensorComment(www.CensorComment{
+		Token:     failing,
+		CommentID: c1,
+		Reason:    "abuse",
+	})
+	assertSuccess(t, err)
+	for i := 0; i < 2; i++ {
+		err = b.flushComments()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !reflect.DeepEqual(f.censored, []uint64{c1}) ||
+		len(f.takeSent()) != 0 {
+		t.Fatalf("unexpected censored comments %v", f.censored)
+	}
+}
+
+// Tests that censored comments keep their metadata and digest, are not
+// flushed and stay censored after a restart.
+func TestCensorComment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "politeiawww.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	b := newCommentBackend(t, dir)
+
+	_, npr, err := createNewProposal(b, t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := npr.CensorshipRecord.Token
+	abusive, err := b.ProcessComment(www.NewComment{
+		Token:   token,
+		Comment: "abusive",
+	}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, err := b.ProcessComment(www.NewComment{
+		Token:    token,
+		ParentID: abusive.CommentID,
+		Comment:  "reply",
+	}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = b.ProcessCensorComment(www.CensorComment{
+		Token:     token,
+		CommentID: abusive.CommentID,
+		Reason:    "abuse",
+	})
+	assertSuccess(t, err)
+	_, err = b.ProcessCensorComment(www.CensorComment{
+		Token:     token,
+		CommentID: abusive.CommentID,
+	})
+	assertError(t, err, www.ErrorStatusCommentCensored)
+	_, err = b.ProcessCensorComment(www.CensorComment{
+		Token:     token,
+		CommentID: reply.CommentID + 1,
+	})
+	assertError(t, err, www.ErrorStatusCommentNotFound)
+	_, err = b.ProcessCensorComment(www.CensorComment{
+		Token:     generateRandomString(64),
+		CommentID: abusive.CommentID,
+	})
+	assertError(t, err, www.ErrorStatusProposalNotFound)
+
+	// Only the text of the censored comment is gone.
+	verify := func(b *backend) {
+		c := getComment(t, b, token, abusive.CommentID)
+		if !c.Censored || c.Comment != "" || c.Reason != "abuse" ||
+			c.UserID != 1 || c.Digest != commentDigest("abusive") {
+			t.Fatalf("invalid censored comment %v", c)
+		}
+		c = getComment(t, b, token, reply.CommentID)
+		if c.Censored || c.Comment != "reply" ||
+			c.ParentID != abusive.CommentID ||
+			c.Digest != commentDigest("reply") {
+			t.Fatalf("invalid reply %v", c)
+		}
+	}
+	verify(b)
+
+	// Censored comments are not sent to politeiad.
+	publishProposal(b, token, t)
+	err = b.flushComments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.RLock()
+	flushed := b.comments[token][abusive.CommentID].Flushed
+	b.RUnlock()
+	if flushed {
+		t.Fatalf("censored comment was flushed")
+	}
+	verify(b)
+
+	// The tombstone is applied on replay.
+	b.Close()
+	b = newCommentBackend(t, dir)
+	defer b.Close()
+	verify(b)
+}
+
+// Tests that users have one vote per comment and that votes survive a
+// restart.
+func TestLikeComment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "politeiawww.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	b := newCommentBackend(t, dir)
+
+	_, npr, err := createNewProposal(b, t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := npr.CensorshipRecord.Token
+	ncr, err := b.ProcessComment(www.NewComment{
+		Token:   token,
+		Comment: "comment",
+	}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		userID uint64
+		action int64
+		result int64
+		total  uint64
+	}{
+		{2, www.LikeActionUp, 1, 1},
+		{3, www.LikeActionDown, 0, 2},
+		{2, www.LikeActionUp, 0, 2},
+		{2, www.LikeActionDown, -2, 2},
+		{3, www.LikeActionReset, -1, 1},
+		{4, www.LikeActionReset, -1, 1},
+	}
+	for i, test := range tests {
+		lcr, err := b.ProcessLikeComment(www.LikeComment{
+			Token:     token,
+			CommentID: ncr.CommentID,
+			Action:    test.action,
+		}, test.userID)
+		assertSuccess(t, err)
+		if lcr.Result != test.result || lcr.Total != test.total {
+			t.Fatalf("%v: got %v/%v wanted %v/%v", i, lcr.Result,
+				lcr.Total, test.result, test.total)
+		}
+	}
+
+	_, err = b.ProcessLikeComment(www.LikeComment{
+		Token:     token,
+		CommentID: ncr.CommentID,
+		Action:    2,
+	}, 2)
+	assertError(t, err, www.ErrorStatusInvalidLikeCommentAction)
+	_, err = b.ProcessLikeComment(www.LikeComment{
+		Token:     token,
+		CommentID: ncr.CommentID + 1,
+		Action:    www.LikeActionUp,
+	}, 2)
+	assertError(t, err, www.ErrorStatusCommentNotFound)
+	_, err = b.ProcessUserCommentsLikes(generateRandomString(64), 2)
+	assertError(t, err, www.ErrorStatusProposalNotFound)
+
+	verify := func(b *backend) {
+		c := getComment(t, b, token, ncr.CommentID)
+		if c.Result != -1 || c.Total != 1 {
+			t.Fatalf("invalid votes %v/%v", c.Result, c.Total)
+		}
+		ucl, err := b.ProcessUserCommentsLikes(token, 2)
+		assertSuccess(t, err)
+		if len(ucl.CommentsLikes) != 1 ||
+			ucl.CommentsLikes[0].CommentID != ncr.CommentID ||
+			ucl.CommentsLikes[0].Action != www.LikeActionDown {
+			t.Fatalf("invalid user votes %v", ucl.CommentsLikes)
+		}
+		ucl, err = b.ProcessUserCommentsLikes(token, 3)
+		assertSuccess(t, err)
+		if len(ucl.CommentsLikes) != 0 {
+			t.Fatalf("unexpected user votes %v", ucl.CommentsLikes)
+		}
+	}
+	verify(b)
+
+	// Votes are replayed.
+	b.Close()
+	b = newCommentBackend(t, dir)
+	defer b.Close()
+	verify(b)
+
+	// Censored comments can't be voted on.
+	_, err = b.ProcessCensorComment(www.CensorComment{
+		Token:     token,
+		CommentID: ncr.CommentID,
+	})
+	assertSuccess(t, err)
+	_, err = b.ProcessLikeComment(www.LikeComment{
+		Token:     token,
+		CommentID: ncr.CommentID,
+		Action:    www.LikeActionUp,
+	}, 2)
+	assertError(t, err, www.ErrorStatusCommentCensored)
+}
+
+// commentIDs returns the ids of comments in order.
+func commentIDs(comments []www.Comment) []uint64 {
+	ids := make([]uint64, 0, len(comments))
+	for _, c := range comments {
+		ids = append(ids, c.CommentID)
+	}
+	return ids
+}
+
+// threadIDs returns the ids of the thread and its replies depth first.
+func threadIDs(threads []www.CommentThread) []uint64 {
+	ids := make([]uint64, 0)
+	for _, t := range threads {
+		ids = append(ids, t.CommentID)
+		ids = append(ids, threadIDs(t.Replies)...)
+	}
+	return ids
+}
+
+// Tests sorting, pagination, subtrees and threads of comments.
+func TestGetCommentsQuery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "politeiawww.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	b := newCommentBackend(t, dir)
+	defer b.Close()
+
+	_, npr, err := createNewProposal(b, t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := npr.CensorshipRecord.Token
+
+	// 1 <- 2 <- 4, 1 <- 3 and 5
+	ids := make(map[uint64]uint64)
+	for i, parent := range []uint64{0, 1, 1, 2, 0} {
+		ncr, err := b.ProcessComment(www.NewComment{
+			Token:    token,
+			ParentID: ids[parent],
+			Comment:  "comment",
+		}, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[uint64(i+1)] = ncr.CommentID
+	}
+	for _, v := range []struct {
+		comment uint64
+		user    uint64
+		action  int64
+	}{
+		{5, 1, www.LikeActionUp},
+		{5, 2, www.LikeActionUp},
+		{3, 1, www.LikeActionUp},
+		{1, 1, www.LikeActionDown},
+	} {
+		_, err = b.ProcessLikeComment(www.LikeComment{
+			Token:     token,
+			CommentID: ids[v.comment],
+			Action:    v.action,
+		}, v.user)
+		assertSuccess(t, err)
+	}
+
+	// expect maps the expected comments to their ids.
+	expect := func(expected ...uint64) []uint64 {
+		r := make([]uint64, 0, len(expected))
+		for _, v := range expected {
+			r = append(r, ids[v])
+		}
+		return r
+	}
+
+	tests := []struct {
+		gc       www.GetComments
+		expected []uint64
+	}{
+		{www.GetComments{}, expect(1, 2, 3, 4, 5)},
+		{www.GetComments{Sort: www.CommentSortNewest},
+			expect(5, 4, 3, 2, 1)},
+		{www.GetComments{Sort: www.CommentSortTop},
+			expect(5, 3, 2, 4, 1)},
+		{www.GetComments{ParentID: ids[1]}, expect(2, 3, 4)},
+		{www.GetComments{ParentID: ids[1], Depth: 1}, expect(2, 3)},
+		{www.GetComments{Depth: 1}, expect(1, 5)},
+		{www.GetComments{Thread: true}, expect(1, 2, 4, 3, 5)},
+		{www.GetComments{Thread: true, Sort: www.CommentSortTop},
+			expect(5, 1, 3, 2, 4)},
+		{www.GetComments{Thread: true, Depth: 2}, expect(1, 2, 3, 5)},
+		{www.GetComments{Thread: true, ParentID: ids[2]}, expect(4)},
+	}
+	for i, test := range tests {
+		gcr, err := b.ProcessCommentGet(token, test.gc)
+		assertSuccess(t, err)
+		got := commentIDs(gcr.Comments)
+		if test.gc.Thread {
+			got = threadIDs(gcr.Threads)
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Fatalf("%v: got %v wanted %v", i, got, test.expected)
+		}
+	}
+
+	// Pages continue where the previous one ended.
+	for _, test := range []struct {
+		gc    www.GetComments
+		pages [][]uint64
+	}{
+		{www.GetComments{PageSize: 2},
+			[][]uint64{expect(1, 2), expect(3, 4), expect(5)}},
+		{www.GetComments{PageSize: 2, Sort: www.CommentSortTop},
+			[][]uint64{expect(5, 3), expect(2, 4), expect(1)}},
+		{www.GetComments{PageSize: 1, Thread: true},
+			[][]uint64{expect(1, 2, 4, 3), expect(5)}},
+	} {
+		gc := test.gc
+		for i, page := range test.pages {
+			gcr, err := b.ProcessCommentGet(token, gc)
+			assertSuccess(t, err)
+			got := commentIDs(gcr.Comments)
+			if gc.Thread {
+				got = threadIDs(gcr.Threads)
+			}
+			if !reflect.DeepEqual(got, page) {
+				t.Fatalf("page %v: got %v wanted %v", i, got,
+					page)
+			}
+			last := i == len(test.pages)-1
+			if (gcr.NextCursor == "") != last {
+				t.Fatalf("page %v: unexpected cursor %q", i,
+					gcr.NextCursor)
+			}
+			gc.Cursor = gcr.NextCursor
+		}
+	}
+
+	_, err = b.ProcessCommentGet(token, www.GetComments{ParentID: 99})
+	assertError(t, err, www.ErrorStatusCommentNotFound)
+	_, err = b.ProcessCommentGet(token, www.GetComments{Sort: "random"})
+	assertError(t, err, www.ErrorStatusInvalidCommentsQuery)
+	_, err = b.ProcessCommentGet(token, www.GetComments{Cursor: "1"})
+	assertError(t, err, www.ErrorStatusInvalidCommentsQuery)
+
+	// Cursors only continue the query they were returned for.
+	gcr, err := b.ProcessCommentGet(token, www.GetComments{PageSize: 1})
+	assertSuccess(t, err)
+	for _, gc := range []www.GetComments{
+		{Sort: www.CommentSortTop},
+		{ParentID: ids[1]},
+		{Depth: 1},
+		{Thread: true},
+	} {
+		gc.Cursor = gcr.NextCursor
+		_, err = b.ProcessCommentGet(token, gc)
+		assertError(t, err, www.ErrorStatusInvalidCommentsQuery)
+	}
+	_, err = b.ProcessCommentGet(token, www.GetComments{
+		Sort:   www.CommentSortOldest,
+		Cursor: gcr.NextCursor,
+	})
+	assertSuccess(t, err)
+
+	// Pages never exceed the policy maximum.
+	for _, v := range []struct {
+		pageSize uint
+		expected uint
+	}{
+		{0, www.PolicyMaxCommentsPageSize},
+		{1, 1},
+		{www.PolicyMaxCommentsPageSize + 1, www.PolicyMaxCommentsPageSize},
+	} {
+		got := commentPageSize(www.GetComments{PageSize: v.pageSize})
+		if got != v.expected {
+			t.Fatalf("page size %v: got %v wanted %v", v.pageSize,
+				got, v.expected)
+		}
+	}
+
+	// The options are read from the URL query.
+	query, err := url.ParseQuery("sort=top&pagesize=10&parentid=3&" +
+		"depth=2&thread=true&cursor=1:2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gc, err := parseGetComments(query)
+	assertSuccess(t, err)
+	if !reflect.DeepEqual(gc, www.GetComments{
+		Sort:     www.CommentSortTop,
+		PageSize: 10,
+		ParentID: 3,
+		Depth:    2,
+		Thread:   true,
+		Cursor:   "1:2",
+	}) {
+		t.Fatalf("invalid query options %v", gc)
+	}
+	_, err = parseGetComments(url.Values{"depth": []string{"-1"}})
+	assertError(t, err, www.ErrorStatusInvalidCommentsQuery)
+}
+
+// Tests that comment IDs are not reused after a restart and that compacted
+// journals replay to the same state without the text of censored comments.
+func TestCompactCommentJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "politeiawww.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	b := newCommentBackend(t, dir)
+
+	_, npr, err := createNewProposal(b, t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := npr.CensorshipRecord.Token
+	newComment := func(b *backend, comment string) uint64 {
+		ncr, err := b.ProcessComment(www.NewComment{
+			Token:   token,
+			Comment: comment,
+		}, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ncr.CommentID
+	}
+
+	// expectPurged fails the test if text is still on disk.
+	expectPurged := func(b *backend, text string) {
+		files, err := filepath.Glob(filepath.Join(b.commentJournalDir,
+			"*"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, filename := range files {
+			data, err := ioutil.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(data), text) {
+				t.Fatalf("censored comment found in %v",
+					filename)
+			}
+		}
+	}
+
+	// The abusive comment makes it into a snapshot before it is censored.
+	abusive := newComment(b, "abusive")
+	liked := newComment(b, "liked")
+	err = b.compactJournals()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = b.ProcessCensorComment(www.CensorComment{
+		Token:     token,
+		CommentID: abusive,
+	})
+	assertSuccess(t, err)
+	_, err = b.ProcessLikeComment(www.LikeComment{
+		Token:     token,
+		CommentID: liked,
+		Action:    www.LikeActionUp,
+	}, 2)
+	assertSuccess(t, err)
+
+	err = b.compactJournals()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.commentJournal.Records() != 0 || b.likeJournal.Records() != 0 {
+		t.Fatalf("journals not compacted")
+	}
+	late := newComment(b, "late")
+
+	// The censored text is gone from disk.
+	expectPurged(b, "abusive")
+
+	// Text that was censored before a restart is purged as well.
+	restarted := newComment(b, "censored before restart")
+	err = b.compactJournals()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = b.ProcessCensorComment(www.CensorComment{
+		Token:     token,
+		CommentID: restarted,
+	})
+	assertSuccess(t, err)
+	b.Close()
+	b = newCommentBackend(t, dir)
+	defer b.Close()
+	err = b.compactJournals()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectPurged(b, "censored before restart")
+
+	if id := newComment(b, "after restart"); id != restarted+1 {
+		t.Fatalf("invalid comment id got %v wanted %v", id,
+			restarted+1)
+	}
+	c := getComment(t, b, token, abusive)
+	if !c.Censored || c.Comment != "" {
+		t.Fatalf("invalid censored comment %v", c)
+	}
+	c = getComment(t, b, token, liked)
+	if c.Comment != "liked" || c.Result != 1 || c.Total != 1 {
+		t.Fatalf("invalid liked comment %v", c)
+	}
+	c = getComment(t, b, token, late)
+	if c.Comment != "late" {
+		t.Fatalf("invalid late comment %v", c)
+	}
+}
@@ -2,11 +2,18 @@ package main
 
 import (
 	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
 	"testing"
 	"time"
 
 	pd "github.com/decred/politeia/politeiad/api/v1"
+	"github.com/decred/politeia/politeiad/api/v1/identity"
 	www "github.com/decred/politeia/politeiawww/api/v1"
+	"github.com/decred/politeia/util"
 )
 
 func createNewProposal(b *backend, t *testing.T) (*www.NewProposal, *www.NewProposalReply, error) {
@@ -51,7 +58,8 @@ func createNewProposalWithFileSizes(b *backend, t *testing.T, numMDFiles, numIma
 		Files: convertPropFilesFromPD(files),
 	}
 
-	npr, err := b.ProcessNewProposal(np)
+	nu := createAndVerifyUser(t, b)
+	npr, err := b.ProcessNewProposal(np, nu.Email)
 	return &np, npr, err
 }
 
@@ -74,7 +82,8 @@ func createNewProposalWithInvalidTitle(b *backend, t *testing.T) (*www.NewPropos
 		Files: convertPropFilesFromPD(files),
 	}
 
-	npr, err := b.ProcessNewProposal(np)
+	nu := createAndVerifyUser(t, b)
+	npr, err := b.ProcessNewProposal(np, nu.Email)
 	return &np, npr, err
 }
 
@@ -99,7 +108,8 @@ func createNewProposalWithDuplicateFiles(b *backend, t *testing.T) (*www.NewProp
 		Files: convertPropFilesFromPD(files),
 	}
 
-	npr, err := b.ProcessNewProposal(np)
+	nu := createAndVerifyUser(t, b)
+	npr, err := b.ProcessNewProposal(np, nu.Email)
 	return &np, npr, err
 }
 
@@ -116,7 +126,8 @@ func createNewProposalWithoutIndexFile(b *backend, t *testing.T) (*www.NewPropos
 		Files: convertPropFilesFromPD(files),
 	}
 
-	npr, err := b.ProcessNewProposal(np)
+	nu := createAndVerifyUser(t, b)
+	npr, err := b.ProcessNewProposal(np, nu.Email)
 	return &np, npr, err
 }
 
@@ -234,7 +245,7 @@ func TestUnreviewedProposal(t *testing.T) {
 	pdr := getProposalDetails(b, npr.CensorshipRecord.Token, t)
 	verifyProposalDetails(np, pdr.Proposal, t)
 
-	b.db.Close()
+	b.Close()
 }
 
 // Tests censoring a proposal and then fetching its details.
@@ -248,7 +259,30 @@ func TestCensoredProposal(t *testing.T) {
 	pdr := getProposalDetails(b, npr.CensorshipRecord.Token, t)
 	verifyProposalDetails(np, pdr.Proposal, t)
 
-	b.db.Close()
+	b.Close()
+}
+
+func TestCensoredProposalReason(t *testing.T) {
+	b := createBackend(t)
+	np, npr, err := createNewProposal(b, t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = b.ProcessSetProposalStatus(www.SetProposalStatus{
+		Token:          npr.CensorshipRecord.Token,
+		ProposalStatus: www.PropStatusCensored,
+		Reason:         "spam",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdr := getProposalDetails(b, npr.CensorshipRecord.Token, t)
+	verifyProposalDetails(np, pdr.Proposal, t)
+	if pdr.Proposal.Reason != "spam" {
+		t.Fatalf("invalid reason got %q", pdr.Proposal.Reason)
+	}
+
+	b.Close()
 }
 
 // Tests publishing a proposal and then fetching its details.
@@ -262,7 +296,206 @@ func TestPublishedProposal(t *testing.T) {
 	pdr := getProposalDetails(b, npr.CensorshipRecord.Token, t)
 	verifyProposalDetails(np, pdr.Proposal, t)
 
-	b.db.Close()
+	b.Close()
+}
+
+// Tests archiving a published proposal.  It remains vetted but can no longer
+// be edited.
+func TestArchivedProposal(t *testing.T) {
+	b := createBackend(t)
+	np, npr, err := createNewProposal(b, t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := npr.CensorshipRecord.Token
+	publishProposal(b, token, t)
+	_, err = b.ProcessSetProposalStatus(www.SetProposalStatus{
+		Token:          token,
+		ProposalStatus: www.PropStatusArchived,
+		Reason:         "superseded",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdr := getProposalDetails(b, token, t)
+	verifyProposalDetails(np, pdr.Proposal, t)
+	if pdr.Proposal.Status != www.PropStatusArchived {
+		t.Fatalf("invalid status got %v", pdr.Proposal.Status)
+	}
+
+	// Publishing and archiving each created a new version.
+	if pdr.Proposal.Version != 3 {
+		t.Fatalf("invalid version got %v", pdr.Proposal.Version)
+	}
+	_, err = b.ProcessProposalDiff(www.ProposalDiff{
+		Token: token,
+		From:  1,
+		To:    3,
+	}, false)
+	assertSuccess(t, err)
+
+	allVettedReply := b.ProcessAllVetted(www.GetAllVetted{})
+	if len(allVettedReply.Proposals) != 1 {
+		t.Fatalf("archived proposal not vetted")
+	}
+
+	_, err = b.ProcessEditProposal(www.EditProposal{
+		Token: token,
+		Files: np.Files,
+	}, "")
+	assertError(t, err, www.ErrorStatusInvalidPropStatusTransition)
+
+	b.Close()
+}
+
+// Tests submitting proposals as a user that registered a public key.
+func TestWithdrawProposal(t *testing.T) {
+	b := createBackend(t)
+	np, _, err := createNewProposal(b, t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	author := createAndVerifyUser(t, b)
+	npr, err := b.ProcessNewProposal(*np, author.Email)
+	assertSuccess(t, err)
+	token := npr.CensorshipRecord.Token
+
+	// Only the author can withdraw the proposal.
+	other := createAndVerifyUser(t, b)
+	wp := www.WithdrawProposal{
+		Token:  token,
+		Reason: "duplicate",
+	}
+	_, err = b.ProcessWithdrawProposal(wp, other.Email)
+	assertError(t, err, www.ErrorStatusUserNotAuthor)
+
+	// Admins can't withdraw proposals through a status change.
+	_, err = b.ProcessSetProposalStatus(www.SetProposalStatus{
+		Token:          token,
+		ProposalStatus: www.PropStatusWithdrawn,
+	})
+	assertError(t, err, www.ErrorStatusInvalidPropStatusTransition)
+
+	reply, err := b.ProcessWithdrawProposal(wp, author.Email)
+	assertSuccess(t, err)
+	if reply.ProposalStatus != www.PropStatusWithdrawn {
+		t.Fatalf("invalid status got %v", reply.ProposalStatus)
+	}
+	pdr := getProposalDetails(b, token, t)
+	if pdr.Proposal.Status != www.PropStatusWithdrawn ||
+		pdr.Proposal.Reason != "duplicate" {
+		t.Fatalf("unexpected proposal %v %q", pdr.Proposal.Status,
+			pdr.Proposal.Reason)
+	}
+
+	// Withdrawn proposals can't be withdrawn again or published.
+	_, err = b.ProcessWithdrawProposal(wp, author.Email)
+	assertError(t, err, www.ErrorStatusInvalidPropStatusTransition)
+	_, err = b.ProcessSetProposalStatus(www.SetProposalStatus{
+		Token:          token,
+		ProposalStatus: www.PropStatusPublic,
+	})
+	assertError(t, err, www.ErrorStatusInvalidPropStatusTransition)
+
+	// Published proposals can't be withdrawn.
+	npr, err = b.ProcessNewProposal(*np, author.Email)
+	assertSuccess(t, err)
+	publishProposal(b, npr.CensorshipRecord.Token, t)
+	wp.Token = npr.CensorshipRecord.Token
+	_, err = b.ProcessWithdrawProposal(wp, author.Email)
+	assertError(t, err, www.ErrorStatusInvalidPropStatusTransition)
+
+	b.Close()
+}
+
+func TestSignedProposal(t *testing.T) {
+	b := createBackend(t)
+	id, err := identity.New("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Invalid public keys are rejected.
+	_, err = b.ProcessNewUser(www.NewUser{
+		Email:     generateRandomEmail(),
+		Password:  generateRandomPassword(),
+		PublicKey: "00",
+	})
+	assertError(t, err, www.ErrorStatusInvalidPublicKey)
+
+	nu := www.NewUser{
+		Email:     generateRandomEmail(),
+		Password:  generateRandomPassword(),
+		PublicKey: hex.EncodeToString(id.Public.Key[:]),
+	}
+	nur, err := b.ProcessNewUser(nu)
+	assertSuccess(t, err)
+	token, err := hex.DecodeString(nur.VerificationToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := id.SignMessage(token)
+	err = b.ProcessVerifyNewUser(www.VerifyNewUser{
+		Email:             nu.Email,
+		VerificationToken: nur.VerificationToken,
+		Signature:         hex.EncodeToString(signature[:]),
+	})
+	assertSuccess(t, err)
+
+	np, _, err := createNewProposal(b, t)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The proposal must be signed.
+	_, err = b.ProcessNewProposal(*np, nu.Email)
+	assertError(t, err, www.ErrorStatusInvalidPublicKey)
+
+	// By the registered key.
+	other, err := identity.New("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := pd.MerkleRoot(convertPropFilesFromWWW(np.Files))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature = other.SignMessage(root[:])
+	np.PublicKey = hex.EncodeToString(other.Public.Key[:])
+	np.Signature = hex.EncodeToString(signature[:])
+	_, err = b.ProcessNewProposal(*np, nu.Email)
+	assertError(t, err, www.ErrorStatusInvalidPublicKey)
+
+	// Over the merkle root of the files.
+	signature = id.SignMessage([]byte("not the merkle root"))
+	np.PublicKey = hex.EncodeToString(id.Public.Key[:])
+	np.Signature = hex.EncodeToString(signature[:])
+	_, err = b.ProcessNewProposal(*np, nu.Email)
+	assertError(t, err, www.ErrorStatusInvalidSignature)
+
+	signature = id.SignMessage(root[:])
+	np.Signature = hex.EncodeToString(signature[:])
+	npr, err := b.ProcessNewProposal(*np, nu.Email)
+	assertSuccess(t, err)
+
+	// Anyone can verify the user signature.
+	pdr, err := b.ProcessProposalDetails(www.ProposalsDetails{
+		Token: npr.CensorshipRecord.Token,
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	us := pdr.Proposal.UserSignature
+	if us == nil {
+		t.Fatalf("missing user signature")
+	}
+	err = pd.VerifyUserSignature(*convertPropUserSignatureFromWWW(us),
+		convertPropFilesFromWWW(np.Files))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b.Close()
 }
 
 // Tests that the inventory is always sorted by timestamp.
@@ -320,5 +553,248 @@ func TestInventorySorted(t *testing.T) {
 	// Verify that the proposals are still sorted correctly.
 	verifyProposalsSorted(b, vettedProposals, unvettedProposals, t)
 
-	b.db.Close()
+	b.Close()
+}
+
+// Tests fetching the inventory page by page.  A listing that is complete is
+// not fetched again while the other one is still being paged through.
+func TestRemoteInventory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "politeiawww.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	b := newCommentBackend(t, dir)
+	defer b.Close()
+
+	id, err := identity.New("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakePoliteiad{id: id}
+	records := func(n int, prefix string) []pd.ProposalRecord {
+		prs := make([]pd.ProposalRecord, 0, n)
+		for i := 0; i < n; i++ {
+			prs = append(prs, pd.ProposalRecord{
+				CensorshipRecord: pd.CensorshipRecord{
+					Token: fmt.Sprintf("%v%v", prefix, i),
+				},
+			})
+		}
+		return prs
+	}
+	f.vetted = records(2*inventoryPageSize+inventoryPageSize/2, "v")
+	f.branches = records(inventoryPageSize/2, "b")
+	srv := startFakePoliteiad(t, b, dir, f)
+	defer srv.Close()
+
+	inv, err := b.remoteInventory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(inv.Vetted, f.vetted) ||
+		!reflect.DeepEqual(inv.Branches, f.branches) {
+		t.Fatalf("unexpected inventory %v vetted %v branches",
+			len(inv.Vetted), len(inv.Branches))
+	}
+	if len(f.inventories) != 3 {
+		t.Fatalf("got %v inventory requests, want 3",
+			len(f.inventories))
+	}
+	for _, i := range f.inventories[1:] {
+		if i.BranchesCount != 0 || len(i.BranchesStatus) != 1 ||
+			i.BranchesStatus[0] != pd.PropStatusInvalid {
+			t.Fatalf("complete branches requested again: %v", i)
+		}
+	}
+}
+
+// Tests editing a proposal and then fetching its details.
+func TestEditProposal(t *testing.T) {
+	b := createBackend(t)
+	np, _, err := createNewProposal(b, t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	author := createAndVerifyUser(t, b)
+	npr, err := b.ProcessNewProposal(*np, author.Email)
+	assertSuccess(t, err)
+	token := npr.CensorshipRecord.Token
+
+	// Edit the unreviewed proposal.
+	np, _, err = createNewProposal(b, t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ep := www.EditProposal{
+		Token: token,
+		Files: np.Files,
+	}
+	_, err = b.ProcessEditProposal(ep, author.Email)
+	assertSuccess(t, err)
+	pdr := getProposalDetails(b, token, t)
+	verifyProposalDetails(np, pdr.Proposal, t)
+
+	// Only the author can edit the proposal.
+	other := createAndVerifyUser(t, b)
+	_, err = b.ProcessEditProposal(ep, other.Email)
+	assertError(t, err, www.ErrorStatusUserNotAuthor)
+
+	// Edit the published proposal as an admin.
+	publishProposal(b, token, t)
+	np, _, err = createNewProposal(b, t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ep.Files = np.Files
+	admin, err := b.db.UserGet(other.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin.Admin = true
+	err = b.db.UserUpdate(*admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = b.ProcessEditProposal(ep, other.Email)
+	assertSuccess(t, err)
+	pdr = getProposalDetails(b, token, t)
+	verifyProposalDetails(np, pdr.Proposal, t)
+
+	// Censored proposals can't be edited.
+	_, npr, err = createNewProposal(b, t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	censorProposal(b, npr.CensorshipRecord.Token, t)
+	ep.Token = npr.CensorshipRecord.Token
+	_, err = b.ProcessEditProposal(ep, author.Email)
+	assertError(t, err, www.ErrorStatusInvalidPropStatusTransition)
+
+	// Unknown proposal.
+	ep.Token = generateRandomString(64)
+	_, err = b.ProcessEditProposal(ep, author.Email)
+	assertError(t, err, www.ErrorStatusProposalNotFound)
+
+	b.Close()
+}
+
+// Tests the unified diff of proposal files.
+func TestDiffProposalFiles(t *testing.T) {
+	newFile := func(name, mime, content string) www.File {
+		return www.File{
+			Name:    name,
+			MIME:    mime,
+			Digest:  hex.EncodeToString(util.Digest([]byte(content))),
+			Payload: base64.StdEncoding.EncodeToString([]byte(content)),
+		}
+	}
+	text := "text/plain; charset=utf-8"
+
+	from := []www.File{
+		newFile(indexFile, text, "title\nline 1\nline 2\n"),
+		newFile("same.md", text, "unchanged\n"),
+		newFile("removed.md", text, "gone\n"),
+		newFile("image.png", "image/png", "png 1"),
+	}
+	to := []www.File{
+		newFile(indexFile, text, "title\nline 1\nline 2 changed\n"),
+		newFile("same.md", text, "unchanged\n"),
+		newFile("added.md", text, "new\n"),
+		newFile("image.png", "image/png", "png 2"),
+	}
+
+	diffs, err := diffProposalFiles(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"added.md": "--- /dev/null\n+++ b/added.md\n@@ -0,0 +1 @@\n" +
+			"+new\n",
+		"image.png": "Binary files a/image.png and b/image.png " +
+			"differ\n",
+		indexFile: "--- a/index.md\n+++ b/index.md\n@@ -1,3 +1,3 @@\n" +
+			" title\n line 1\n-line 2\n+line 2 changed\n",
+		"removed.md": "--- a/removed.md\n+++ /dev/null\n" +
+			"@@ -1 +0,0 @@\n-gone\n",
+	}
+	if len(diffs) != len(expected) {
+		t.Fatalf("unexpected number of diffs got %v wanted %v",
+			len(diffs), len(expected))
+	}
+	for _, v := range diffs {
+		if v.Diff != expected[v.Name] {
+			t.Fatalf("unexpected diff for %v got %q wanted %q",
+				v.Name, v.Diff, expected[v.Name])
+		}
+	}
+
+	// No changes results in no diffs.
+	diffs, err = diffProposalFiles(to, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 0 {
+		t.Fatalf("unexpected diffs: %v", diffs)
+	}
+}
+
+// Tests diffing two versions of an edited proposal.
+func TestProposalDiff(t *testing.T) {
+	b := createBackend(t)
+	np, _, err := createNewProposal(b, t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	author := createAndVerifyUser(t, b)
+	npr, err := b.ProcessNewProposal(*np, author.Email)
+	assertSuccess(t, err)
+	token := npr.CensorshipRecord.Token
+
+	edited, _, err := createNewProposal(b, t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = b.ProcessEditProposal(www.EditProposal{
+		Token: token,
+		Files: edited.Files,
+	}, author.Email)
+	assertSuccess(t, err)
+
+	pdr, err := b.ProcessProposalDiff(www.ProposalDiff{
+		Token: token,
+		From:  1,
+		To:    2,
+	}, true)
+	assertSuccess(t, err)
+	expected, err := diffProposalFiles(np.Files, edited.Files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pdr.Files) != 1 || !reflect.DeepEqual(pdr.Files, expected) {
+		t.Fatalf("unexpected diff got %v wanted %v", pdr.Files,
+			expected)
+	}
+
+	// A version is identical to itself.
+	pdr, err = b.ProcessProposalDiff(www.ProposalDiff{
+		Token: token,
+		From:  2,
+		To:    2,
+	}, true)
+	assertSuccess(t, err)
+	if len(pdr.Files) != 0 {
+		t.Fatalf("unexpected diffs: %v", pdr.Files)
+	}
+
+	// Unknown version.
+	_, err = b.ProcessProposalDiff(www.ProposalDiff{
+		Token: token,
+		From:  1,
+		To:    3,
+	}, true)
+	assertError(t, err, www.ErrorStatusProposalNotFound)
+
+	b.Close()
 }
@@ -10,10 +10,21 @@ import (
 	"testing"
 	"time"
 
+	"github.com/btcsuite/btclog"
+	"github.com/decred/politeia/politeiad/api/v1/identity"
 	www "github.com/decred/politeia/politeiawww/api/v1"
 	"github.com/decred/politeia/util"
 )
 
+// TestMain disables the loggers.  The log rotator that they write to is only
+// initialized by the daemon.
+func TestMain(m *testing.M) {
+	log = btclog.Disabled
+	localdbLog = btclog.Disabled
+	journalLog = btclog.Disabled
+	os.Exit(m.Run())
+}
+
 const letterBytes = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 
 func generateRandomString(n int) string {
@@ -136,7 +147,7 @@ func TestProcessNewUserWithUnverifiedToken(t *testing.T) {
 	_, err = b.ProcessNewUser(u)
 	assertSuccess(t, err)
 
-	b.db.Close()
+	b.Close()
 }
 
 // Tests creating a new user which has an expired token.
@@ -167,7 +178,7 @@ func TestProcessNewUserWithExpiredToken(t *testing.T) {
 		t.Fatalf("ProcessNewUser did not return a new verification token.")
 	}
 
-	b.db.Close()
+	b.Close()
 }
 
 // Tests creating a new user with a malformed email.
@@ -182,7 +193,7 @@ func TestProcessNewUserWithMalformedEmail(t *testing.T) {
 	_, err := b.ProcessNewUser(u)
 	assertError(t, err, www.ErrorStatusMalformedEmail)
 
-	b.db.Close()
+	b.Close()
 }
 
 // Tests creating a new user with a malformed password.
@@ -197,7 +208,7 @@ func TestProcessNewUserWithMalformedPassword(t *testing.T) {
 	_, err := b.ProcessNewUser(u)
 	assertError(t, err, www.ErrorStatusMalformedPassword)
 
-	b.db.Close()
+	b.Close()
 }
 
 // Tests verifying a non-existing user.
@@ -212,7 +223,7 @@ func TestProcessVerifyNewUserWithNonExistingUser(t *testing.T) {
 	err := b.ProcessVerifyNewUser(u)
 	assertError(t, err, www.ErrorStatusVerificationTokenInvalid)
 
-	b.db.Close()
+	b.Close()
 }
 
 // Tests verifying a new user with an invalid verification token.
@@ -240,7 +251,7 @@ func TestProcessVerifyNewUserWithInvalidToken(t *testing.T) {
 	err = b.ProcessVerifyNewUser(vu)
 	assertError(t, err, www.ErrorStatusVerificationTokenInvalid)
 
-	b.db.Close()
+	b.Close()
 }
 
 // Tests logging in with a non-existing user.
@@ -255,7 +266,7 @@ func TestProcessLoginWithNonExistingUser(t *testing.T) {
 	_, err := b.ProcessLogin(l)
 	assertError(t, err, www.ErrorStatusInvalidEmailOrPassword)
 
-	b.db.Close()
+	b.Close()
 }
 
 // Tests logging in with an unverified user.
@@ -270,11 +281,11 @@ func TestProcessLoginWithUnverifiedUser(t *testing.T) {
 	_, err := b.ProcessNewUser(u)
 	assertSuccess(t, err)
 
-	l := www.Login(u)
+	l := www.Login{Email: u.Email, Password: u.Password}
 	_, err = b.ProcessLogin(l)
 	assertError(t, err, www.ErrorStatusInvalidEmailOrPassword)
 
-	b.db.Close()
+	b.Close()
 }
 
 // Tests the regular login flow without errors: ProcessNewUser,
@@ -283,11 +294,11 @@ func TestLoginWithVerifiedUser(t *testing.T) {
 	b := createBackend(t)
 	u := createAndVerifyUser(t, b)
 
-	l := www.Login(u)
+	l := www.Login{Email: u.Email, Password: u.Password}
 	_, err := b.ProcessLogin(l)
 	assertSuccess(t, err)
 
-	b.db.Close()
+	b.Close()
 }
 
 // Tests changing a user's password with an incorrect current password
@@ -296,7 +307,7 @@ func TestProcessChangePasswordWithBadPasswords(t *testing.T) {
 	b := createBackend(t)
 	u := createAndVerifyUser(t, b)
 
-	l := www.Login(u)
+	l := www.Login{Email: u.Email, Password: u.Password}
 	_, err := b.ProcessLogin(l)
 	assertSuccess(t, err)
 
@@ -316,7 +327,7 @@ func TestProcessChangePasswordWithBadPasswords(t *testing.T) {
 	_, err = b.ProcessChangePassword(u.Email, cp)
 	assertError(t, err, www.ErrorStatusMalformedPassword)
 
-	b.db.Close()
+	b.Close()
 }
 
 // Tests changing a user's password without errors.
@@ -324,7 +335,7 @@ func TestProcessChangePassword(t *testing.T) {
 	b := createBackend(t)
 	u := createAndVerifyUser(t, b)
 
-	l := www.Login(u)
+	l := www.Login{Email: u.Email, Password: u.Password}
 	_, err := b.ProcessLogin(l)
 	assertSuccess(t, err)
 
@@ -344,7 +355,7 @@ func TestProcessChangePassword(t *testing.T) {
 	_, err = b.ProcessChangePassword(u.Email, cp)
 	assertSuccess(t, err)
 
-	b.db.Close()
+	b.Close()
 }
 
 // Tests resetting a user's password with an invalid token.
@@ -366,7 +377,7 @@ func TestProcessResetPasswordWithInvalidToken(t *testing.T) {
 	_, err = b.ProcessResetPassword(rp)
 	assertError(t, err, www.ErrorStatusVerificationTokenInvalid)
 
-	b.db.Close()
+	b.Close()
 }
 
 // Tests resetting a user's password with an expired token.
@@ -396,7 +407,7 @@ func TestProcessResetPasswordWithExpiredToken(t *testing.T) {
 	rpr, err = b.ProcessResetPassword(rp)
 	assertError(t, err, www.ErrorStatusVerificationTokenExpired)
 
-	b.db.Close()
+	b.Close()
 }
 
 // Tests resetting a user's password without errors.
@@ -428,5 +439,235 @@ func TestProcessResetPassword(t *testing.T) {
 	_, err = b.ProcessLogin(l)
 	assertSuccess(t, err)
 
-	b.db.Close()
+	b.Close()
+}
+
+// Tests registering, rotating and revoking the public key of a user.
+func TestProcessUserKeys(t *testing.T) {
+	b := createBackend(t)
+	first, err := identity.New("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := identity.New("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The key registered with the user must sign the verification token.
+	u := www.NewUser{
+		Email:     generateRandomEmail(),
+		Password:  generateRandomPassword(),
+		PublicKey: hex.EncodeToString(first.Public.Key[:]),
+	}
+	nur, err := b.ProcessNewUser(u)
+	assertSuccess(t, err)
+	token, err := hex.DecodeString(nur.VerificationToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := second.SignMessage(token)
+	v := www.VerifyNewUser{
+		Email:             u.Email,
+		VerificationToken: nur.VerificationToken,
+		Signature:         hex.EncodeToString(signature[:]),
+	}
+	err = b.ProcessVerifyNewUser(v)
+	assertError(t, err, www.ErrorStatusInvalidSignature)
+	signature = first.SignMessage(token)
+	v.Signature = hex.EncodeToString(signature[:])
+	err = b.ProcessVerifyNewUser(v)
+	assertSuccess(t, err)
+
+	// Active keys can not be registered again.
+	_, err = b.ProcessUpdateUserKey(u.Email, www.UpdateUserKey{
+		PublicKey: u.PublicKey,
+	})
+	assertError(t, err, www.ErrorStatusInvalidPublicKey)
+
+	// Rotate to the second key.
+	ukr, err := b.ProcessUpdateUserKey(u.Email, www.UpdateUserKey{
+		PublicKey: hex.EncodeToString(second.Public.Key[:]),
+	})
+	assertSuccess(t, err)
+	challenge, err := hex.DecodeString(ukr.Challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature = first.SignMessage(challenge)
+	_, err = b.ProcessVerifyUpdateUserKey(u.Email, www.VerifyUpdateUserKey{
+		Signature: hex.EncodeToString(signature[:]),
+	})
+	assertError(t, err, www.ErrorStatusInvalidSignature)
+	signature = second.SignMessage(challenge)
+	_, err = b.ProcessVerifyUpdateUserKey(u.Email, www.VerifyUpdateUserKey{
+		Signature: hex.EncodeToString(signature[:]),
+	})
+	assertSuccess(t, err)
+
+	// The challenge can only be used once.
+	_, err = b.ProcessVerifyUpdateUserKey(u.Email, www.VerifyUpdateUserKey{
+		Signature: hex.EncodeToString(signature[:]),
+	})
+	assertError(t, err, www.ErrorStatusVerificationTokenInvalid)
+
+	// Revoke the second key.
+	_, err = b.ProcessRevokeUserKey(u.Email, www.RevokeUserKey{})
+	assertSuccess(t, err)
+	_, err = b.ProcessRevokeUserKey(u.Email, www.RevokeUserKey{})
+	assertError(t, err, www.ErrorStatusNoPublicKey)
+
+	// Both keys remain in the history.
+	ukeys, err := b.ProcessUserKeys(u.Email, www.UserKeys{})
+	assertSuccess(t, err)
+	if len(ukeys.Identities) != 2 {
+		t.Fatalf("expected 2 identities, got %v", len(ukeys.Identities))
+	}
+	for i, id := range []*identity.FullIdentity{first, second} {
+		ui := ukeys.Identities[i]
+		if ui.PublicKey != hex.EncodeToString(id.Public.Key[:]) ||
+			ui.Activated == 0 || ui.Deactivated == 0 {
+			t.Fatalf("unexpected identity %v: %v", i, ui)
+		}
+	}
+
+	b.Close()
+}
+
+// Tests verifying a user that registered a public key through the e-mailed
+// link, which does not carry a signature.
+func TestProcessVerifyNewUserWithoutSignature(t *testing.T) {
+	b := createBackend(t)
+	id, err := identity.New("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := www.NewUser{
+		Email:     generateRandomEmail(),
+		Password:  generateRandomPassword(),
+		PublicKey: hex.EncodeToString(id.Public.Key[:]),
+	}
+	nur, err := b.ProcessNewUser(u)
+	assertSuccess(t, err)
+	err = b.ProcessVerifyNewUser(www.VerifyNewUser{
+		Email:             u.Email,
+		VerificationToken: nur.VerificationToken,
+	})
+	assertSuccess(t, err)
+	_, err = b.ProcessLogin(www.Login{
+		Email:    u.Email,
+		Password: u.Password,
+	})
+	assertSuccess(t, err)
+
+	// The key remains pending until it is verified.
+	ukeys, err := b.ProcessUserKeys(u.Email, www.UserKeys{})
+	assertSuccess(t, err)
+	if len(ukeys.Identities) != 1 || ukeys.Identities[0].Activated != 0 {
+		t.Fatalf("unexpected identities %v", ukeys.Identities)
+	}
+	ukr, err := b.ProcessUpdateUserKey(u.Email, www.UpdateUserKey{
+		PublicKey: u.PublicKey,
+	})
+	assertSuccess(t, err)
+	challenge, err := hex.DecodeString(ukr.Challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := id.SignMessage(challenge)
+	_, err = b.ProcessVerifyUpdateUserKey(u.Email, www.VerifyUpdateUserKey{
+		Signature: hex.EncodeToString(signature[:]),
+	})
+	assertSuccess(t, err)
+	ukeys, err = b.ProcessUserKeys(u.Email, www.UserKeys{})
+	assertSuccess(t, err)
+	if len(ukeys.Identities) != 1 || ukeys.Identities[0].Activated == 0 {
+		t.Fatalf("unexpected identities %v", ukeys.Identities)
+	}
+
+	b.Close()
+}
+
+// Tests logging in with a signed challenge instead of a password.
+func TestProcessLoginSigned(t *testing.T) {
+	b := createBackend(t)
+	id, err := identity.New("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := createAndVerifyUser(t, b)
+
+	challenge := func(email string) *loginChallenge {
+		lcr, lc, err := b.ProcessLoginChallenge(www.LoginChallenge{
+			Email: email,
+		})
+		assertSuccess(t, err)
+		if lcr.Challenge != hex.EncodeToString(lc.Challenge) {
+			t.Fatalf("unexpected challenge %v", lcr.Challenge)
+		}
+		return lc
+	}
+	login := func(email string, id *identity.FullIdentity, lc *loginChallenge) error {
+		signature := id.SignMessage(lc.Challenge)
+		_, err := b.ProcessLoginSigned(www.LoginSigned{
+			Email:     email,
+			Signature: hex.EncodeToString(signature[:]),
+		}, lc)
+		return err
+	}
+
+	// Users without a key and unknown users can not login.
+	err = login(u.Email, id, challenge(u.Email))
+	assertError(t, err, www.ErrorStatusInvalidEmailOrPassword)
+	email := generateRandomEmail()
+	err = login(email, id, challenge(email))
+	assertError(t, err, www.ErrorStatusInvalidEmailOrPassword)
+
+	// Activate the key.
+	ukr, err := b.ProcessUpdateUserKey(u.Email, www.UpdateUserKey{
+		PublicKey: hex.EncodeToString(id.Public.Key[:]),
+	})
+	assertSuccess(t, err)
+	c, err := hex.DecodeString(ukr.Challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := id.SignMessage(c)
+	_, err = b.ProcessVerifyUpdateUserKey(u.Email, www.VerifyUpdateUserKey{
+		Signature: hex.EncodeToString(signature[:]),
+	})
+	assertSuccess(t, err)
+
+	// Only the active key can login.
+	other, err := identity.New("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = login(u.Email, other, challenge(u.Email))
+	assertError(t, err, www.ErrorStatusInvalidSignature)
+	err = login(u.Email, id, challenge(u.Email))
+	assertSuccess(t, err)
+
+	// Challenges of other sessions do not replace each other.
+	lc := challenge(u.Email)
+	challenge(u.Email)
+	err = login(u.Email, id, lc)
+	assertSuccess(t, err)
+
+	// A challenge is only valid for the user it was requested for and
+	// until it expires.
+	err = login(u.Email, id, challenge(email))
+	assertError(t, err, www.ErrorStatusInvalidEmailOrPassword)
+	lc = challenge(u.Email)
+	lc.Expiry = time.Now().Unix() - 1
+	err = login(u.Email, id, lc)
+	assertError(t, err, www.ErrorStatusInvalidEmailOrPassword)
+	_, err = b.ProcessLoginSigned(www.LoginSigned{
+		Email:     u.Email,
+		Signature: hex.EncodeToString(signature[:]),
+	}, nil)
+	assertError(t, err, www.ErrorStatusInvalidEmailOrPassword)
+
+	b.Close()
 }
@@ -16,6 +16,8 @@ import (
 
 	"golang.org/x/net/publicsuffix"
 
+	pd "github.com/decred/politeia/politeiad/api/v1"
+	"github.com/decred/politeia/politeiad/api/v1/identity"
 	"github.com/decred/politeia/politeiawww/api/v1"
 	"github.com/decred/politeia/util"
 )
@@ -151,10 +153,11 @@ func (c *ctx) policy() (*v1.PolicyReply, error) {
 	return &pr, nil
 }
 
-func (c *ctx) newUser(email, password string) (string, error) {
+func (c *ctx) newUser(email, password string, id *identity.FullIdentity) (string, error) {
 	u := v1.NewUser{
-		Email:    email,
-		Password: password,
+		Email:     email,
+		Password:  password,
+		PublicKey: hex.EncodeToString(id.Public.Key[:]),
 	}
 
 	responseBody, err := c.makeRequest("POST", v1.RouteNewUser, u)
@@ -173,12 +176,65 @@ func (c *ctx) newUser(email, password string) (string, error) {
 	return nur.VerificationToken, nil
 }
 
-func (c *ctx) verifyNewUser(email, token string) error {
-	_, err := c.makeRequest("GET", "/user/verify/?email="+email+
-		"&verificationtoken="+token, nil)
+func (c *ctx) verifyNewUser(email, token string, id *identity.FullIdentity) error {
+	t, err := hex.DecodeString(token)
+	if err != nil {
+		return err
+	}
+	signature := id.SignMessage(t)
+	_, err = c.makeRequest("GET", "/user/verify/?email="+email+
+		"&verificationtoken="+token+
+		"&signature="+hex.EncodeToString(signature[:]), nil)
+	return err
+}
+
+func (c *ctx) updateUserKey(id *identity.FullIdentity) error {
+	uk := v1.UpdateUserKey{
+		PublicKey: hex.EncodeToString(id.Public.Key[:]),
+	}
+
+	responseBody, err := c.makeRequest("POST", v1.RouteUpdateUserKey, uk)
+	if err != nil {
+		return err
+	}
+
+	var ukr v1.UpdateUserKeyReply
+	err = json.Unmarshal(responseBody, &ukr)
+	if err != nil {
+		return fmt.Errorf("Could not unmarshal UpdateUserKeyReply: %v",
+			err)
+	}
+
+	// Prove that we own the new key.
+	challenge, err := hex.DecodeString(ukr.Challenge)
+	if err != nil {
+		return err
+	}
+	signature := id.SignMessage(challenge)
+	_, err = c.makeRequest("POST", v1.RouteVerifyUpdateUserKey,
+		v1.VerifyUpdateUserKey{
+			Signature: hex.EncodeToString(signature[:]),
+		})
 	return err
 }
 
+func (c *ctx) userKeys() (*v1.UserKeysReply, error) {
+	responseBody, err := c.makeRequest("GET", v1.RouteUserKeys,
+		v1.UserKeys{})
+	if err != nil {
+		return nil, err
+	}
+
+	var ukr v1.UserKeysReply
+	err = json.Unmarshal(responseBody, &ukr)
+	if err != nil {
+		return nil, fmt.Errorf("Could not unmarshal UserKeysReply: %v",
+			err)
+	}
+
+	return &ukr, nil
+}
+
 func (c *ctx) login(email, password string) (*v1.LoginReply, error) {
 	l := v1.Login{
 		Email:    email,
@@ -200,6 +256,46 @@ func (c *ctx) login(email, password string) (*v1.LoginReply, error) {
 	return &lr, nil
 }
 
+func (c *ctx) loginSigned(email string, id *identity.FullIdentity) (*v1.LoginReply, error) {
+	responseBody, err := c.makeRequest("POST", v1.RouteLoginChallenge,
+		v1.LoginChallenge{
+			Email: email,
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	var lcr v1.LoginChallengeReply
+	err = json.Unmarshal(responseBody, &lcr)
+	if err != nil {
+		return nil, fmt.Errorf("Could not unmarshal LoginChallengeReply: %v",
+			err)
+	}
+
+	challenge, err := hex.DecodeString(lcr.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	signature := id.SignMessage(challenge)
+	responseBody, err = c.makeRequest("POST", v1.RouteLoginSigned,
+		v1.LoginSigned{
+			Email:     email,
+			Signature: hex.EncodeToString(signature[:]),
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	var lr v1.LoginReply
+	err = json.Unmarshal(responseBody, &lr)
+	if err != nil {
+		return nil, fmt.Errorf("Could not unmarshal LoginReply: %v",
+			err)
+	}
+
+	return &lr, nil
+}
+
 func (c *ctx) secret() error {
 	l := v1.Login{}
 	_, err := c.makeRequest("POST", v1.RouteSecret, l)
@@ -263,7 +359,7 @@ func (c *ctx) me() (*v1.MeReply, error) {
 	return &mr, nil
 }
 
-func (c *ctx) newProposal() (*v1.NewProposalReply, error) {
+func (c *ctx) newProposal(id *identity.FullIdentity) (*v1.NewProposalReply, error) {
 	np := v1.NewProposal{
 		Files: make([]v1.File, 0),
 	}
@@ -279,6 +375,20 @@ func (c *ctx) newProposal() (*v1.NewProposalReply, error) {
 		Payload: base64.StdEncoding.EncodeToString(payload),
 	})
 
+	// Sign the merkle root of the files.
+	root, err := pd.MerkleRoot([]pd.File{{
+		Name:    np.Files[0].Name,
+		MIME:    np.Files[0].MIME,
+		Digest:  np.Files[0].Digest,
+		Payload: np.Files[0].Payload,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	signature := id.SignMessage(root[:])
+	np.PublicKey = hex.EncodeToString(id.Public.Key[:])
+	np.Signature = hex.EncodeToString(signature[:])
+
 	responseBody, err := c.makeRequest("POST", v1.RouteNewProposal, np)
 	if err != nil {
 		return nil, err
@@ -482,14 +592,20 @@ func _main() error {
 	email := hex.EncodeToString(b) + "@example.com"
 	password := hex.EncodeToString(b)
 
+	// Generate the user key
+	id, err := identity.New("", "")
+	if err != nil {
+		return err
+	}
+
 	// New User
-	token, err := c.newUser(email, password)
+	token, err := c.newUser(email, password, id)
 	if err != nil {
 		return err
 	}
 
 	// Verify New User
-	err = c.verifyNewUser(email, token)
+	err = c.verifyNewUser(email, token, id)
 	if err != nil {
 		// ugly hack that ignores special redirect handling in verify
 		// user.  We assume we were redirected to the correct page and
@@ -500,7 +616,7 @@ func _main() error {
 	}
 
 	// New proposal
-	_, err = c.newProposal()
+	_, err = c.newProposal(id)
 	if err == nil {
 		return fmt.Errorf("/new should only be accessible by logged in users")
 	}
@@ -555,16 +671,61 @@ func _main() error {
 	}
 
 	// New proposal 1
-	myprop1, err := c.newProposal()
+	myprop1, err := c.newProposal(id)
 	if err != nil {
 		return err
 	}
 
+	// Rotate the user key
+	id2, err := identity.New("", "")
+	if err != nil {
+		return err
+	}
+	err = c.updateUserKey(id2)
+	if err != nil {
+		return err
+	}
+	ukr, err := c.userKeys()
+	if err != nil {
+		return err
+	}
+	if len(ukr.Identities) != 2 || ukr.Identities[0].Deactivated == 0 ||
+		ukr.Identities[1].PublicKey != hex.EncodeToString(id2.Public.Key[:]) {
+		return fmt.Errorf("unexpected user keys %v", ukr.Identities)
+	}
+
+	// The old key can no longer sign proposals
+	_, err = c.newProposal(id)
+	if err == nil {
+		return fmt.Errorf("newProposal with revoked key should fail")
+	}
+
 	// New proposal 2
-	myprop2, err := c.newProposal()
+	myprop2, err := c.newProposal(id2)
+	if err != nil {
+		return err
+	}
+
+	// Login with the new key instead of the password
+	err = c.logout()
 	if err != nil {
 		return err
 	}
+	_, err = c.loginSigned(email, id)
+	if err == nil {
+		return fmt.Errorf("loginSigned with revoked key should fail")
+	}
+	_, err = c.loginSigned(email, id2)
+	if err != nil {
+		return err
+	}
+	me, err = c.me()
+	if err != nil {
+		return err
+	}
+	if me.Email != email {
+		return fmt.Errorf("email got %v wanted %v", me.Email, email)
+	}
 
 	// Get props back out
 	pr1, err := c.getProp(myprop1.CensorshipRecord.Token)
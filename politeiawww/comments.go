@@ -1,18 +1,32 @@
 package main
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io"
-	"os"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	pd "github.com/decred/politeia/politeiad/api/v1"
 	www "github.com/decred/politeia/politeiawww/api/v1"
+	"github.com/decred/politeia/politeiawww/journal"
+	"github.com/decred/politeia/util"
 )
 
 const (
 	defaultCommentJournalDir  = "comments"
 	defaultCommentJournalFile = "journal.json"
+
+	// commentFlushInterval is how often comments that could not be sent
+	// to politeiad are retried.
+	commentFlushInterval = time.Minute
+
+	// journalCompactInterval is how often the comment and like journals
+	// are compacted into snapshots.
+	journalCompactInterval = time.Hour
 )
 
 // BackendComment wraps www.Comment into an internal usable structure.
@@ -25,7 +39,19 @@ type BackendComment struct {
 	Comment   string
 
 	// www additional fields
-	Flushed bool // Set to true when it has been sent to politeaid
+	Flushed  bool   // Set to true when it has been sent to politeaid
+	Digest   string // SHA-256 of Comment, retained when censored
+	Censored bool   // Tombstone, Comment is blank
+	Reason   string // Censorship reason
+	Rejected bool   // Set to true when politeiad refused to store it
+
+	// Set to true when the tombstone has been sent to politeiad
+	CensorFlushed bool
+}
+
+// commentDigest returns the hex encoded SHA-256 digest of comment.
+func commentDigest(comment string) string {
+	return hex.EncodeToString(util.Digest([]byte(comment)))
 }
 
 // backendCommentToComment converts BackendComment to www.Comment.
@@ -37,19 +63,196 @@ func backendCommentToComment(bec BackendComment) www.Comment {
 		Timestamp: bec.Timestamp,
 		Token:     bec.Token,
 		Comment:   bec.Comment,
+		Digest:    bec.Digest,
+		Censored:  bec.Censored,
+		Reason:    bec.Reason,
 	}
 }
 
+// convertCommentsToPD converts comments to the politeiad representation.
+func convertCommentsToPD(comments []BackendComment) []pd.Comment {
+	pc := make([]pd.Comment, 0, len(comments))
+	for _, v := range comments {
+		pc = append(pc, pd.Comment{
+			CommentID: v.CommentID,
+			UserID:    v.UserID,
+			ParentID:  v.ParentID,
+			Timestamp: v.Timestamp,
+			Comment:   v.Comment,
+		})
+	}
+	return pc
+}
+
 // initComment initializes the comment map for the given token.  This call must
 // be called with the lock held.
 func (b *backend) initComment(token string) {
 	b.comments[token] = make(map[uint64]BackendComment)
 }
 
-// getComments returns all comments for given proposal token.  Note that the
-// comments are not sorted.
+// commentLess returns the order of comments for the sort option.  Comment IDs
+// increase over time so they order comments by age.
+func commentLess(order www.CommentSortT) func(a, b www.Comment) bool {
+	switch order {
+	case www.CommentSortNewest:
+		return func(a, b www.Comment) bool {
+			return a.CommentID > b.CommentID
+		}
+	case www.CommentSortTop:
+		return func(a, b www.Comment) bool {
+			if a.Result != b.Result {
+				return a.Result > b.Result
+			}
+			return a.CommentID < b.CommentID
+		}
+	}
+	return func(a, b www.Comment) bool {
+		return a.CommentID < b.CommentID
+	}
+}
+
+// validCommentSort returns true if order is a valid sort option.
+func validCommentSort(order www.CommentSortT) bool {
+	switch order {
+	case "", www.CommentSortOldest, www.CommentSortNewest,
+		www.CommentSortTop:
+		return true
+	}
+	return false
+}
+
+// commentCursorPrefix returns the part of a cursor that records the query
+// options gc.  A cursor is only valid for the query it was returned for.
+func commentCursorPrefix(gc www.GetComments) string {
+	return fmt.Sprintf("%v:%d:%d:%t:", gc.Sort, gc.ParentID, gc.Depth,
+		gc.Thread)
+}
+
+// encodeCommentCursor returns the cursor of the page of query gc that follows
+// c.  The cursor records the position of c in any of the sort orders.
+func encodeCommentCursor(gc www.GetComments, c www.Comment) string {
+	return fmt.Sprintf("%v%d:%d", commentCursorPrefix(gc), c.Result,
+		c.CommentID)
+}
+
+// decodeCommentCursor returns the position the cursor of query gc points at.
+// It fails if the cursor was returned for a different query.
+func decodeCommentCursor(gc www.GetComments) (*www.Comment, bool) {
+	prefix := commentCursorPrefix(gc)
+	if !strings.HasPrefix(gc.Cursor, prefix) {
+		return nil, false
+	}
+	parts := strings.Split(strings.TrimPrefix(gc.Cursor, prefix), ":")
+	if len(parts) != 2 {
+		return nil, false
+	}
+	result, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	commentID, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	return &www.Comment{
+		CommentID: commentID,
+		Result:    result,
+	}, true
+}
+
+// commentPageSize returns the page size of query gc.  Pages never exceed the
+// policy maximum.
+func commentPageSize(gc www.GetComments) uint {
+	if gc.PageSize == 0 || gc.PageSize > www.PolicyMaxCommentsPageSize {
+		return www.PolicyMaxCommentsPageSize
+	}
+	return gc.PageSize
+}
+
+// paginateComments returns the page of query gc and the cursor of the next
+// page, if any.  comments must be sorted by less.
+func paginateComments(comments []www.Comment, less func(a, b www.Comment) bool, cursor *www.Comment, gc www.GetComments) ([]www.Comment, string) {
+	if cursor != nil {
+		start := sort.Search(len(comments), func(i int) bool {
+			return less(*cursor, comments[i])
+		})
+		comments = comments[start:]
+	}
+	pageSize := commentPageSize(gc)
+	if uint(len(comments)) <= pageSize {
+		return comments, ""
+	}
+	comments = comments[:pageSize]
+	return comments, encodeCommentCursor(gc, comments[len(comments)-1])
+}
+
+// commentSubtree returns the replies to parentID up to depth levels down.  A
+// depth of 0 is unlimited.
+func commentSubtree(replies map[uint64][]www.Comment, parentID uint64, depth uint) []www.Comment {
+	subtree := make([]www.Comment, 0)
+	seen := map[uint64]bool{parentID: true}
+	level := []uint64{parentID}
+	for d := uint(1); len(level) > 0 && (depth == 0 || d <= depth); d++ {
+		var next []uint64
+		for _, id := range level {
+			for _, r := range replies[id] {
+				if seen[r.CommentID] {
+					continue
+				}
+				seen[r.CommentID] = true
+				subtree = append(subtree, r)
+				next = append(next, r.CommentID)
+			}
+		}
+		level = next
+	}
+	return subtree
+}
+
+// commentThread returns the thread that starts at c.  level is the depth of c
+// below the requested parent.
+func commentThread(c www.Comment, replies map[uint64][]www.Comment, depth, level uint, seen map[uint64]bool) www.CommentThread {
+	seen[c.CommentID] = true
+	thread := www.CommentThread{
+		Comment: c,
+		Replies: make([]www.CommentThread, 0),
+	}
+	if depth != 0 && level >= depth {
+		return thread
+	}
+	for _, r := range replies[c.CommentID] {
+		if seen[r.CommentID] {
+			continue
+		}
+		thread.Replies = append(thread.Replies,
+			commentThread(r, replies, depth, level+1, seen))
+	}
+	return thread
+}
+
+// getComments returns the comments of the given proposal token that match
+// the query options gc.
 // This call must be called WITHOUT the lock held.
-func (b *backend) getComments(token string) (*www.GetCommentsReply, error) {
+func (b *backend) getComments(token string, gc www.GetComments) (*www.GetCommentsReply, error) {
+	if !validCommentSort(gc.Sort) {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusInvalidCommentsQuery,
+		}
+	}
+	if gc.Sort == "" {
+		gc.Sort = www.CommentSortOldest
+	}
+	var cursor *www.Comment
+	if gc.Cursor != "" {
+		var ok bool
+		cursor, ok = decodeCommentCursor(gc)
+		if !ok {
+			return nil, www.UserError{
+				ErrorCode: www.ErrorStatusInvalidCommentsQuery,
+			}
+		}
+	}
+
 	b.RLock()
 	defer b.RUnlock()
 
@@ -59,14 +262,49 @@ func (b *backend) getComments(token string) (*www.GetCommentsReply, error) {
 			ErrorCode: www.ErrorStatusProposalNotFound,
 		}
 	}
+	if _, ok := c[gc.ParentID]; gc.ParentID != 0 && !ok {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusCommentNotFound,
+		}
+	}
+
+	// Index the sorted replies of every comment.
+	less := commentLess(gc.Sort)
+	replies := make(map[uint64][]www.Comment)
+	for _, v := range c {
+		comment := backendCommentToComment(v)
+		comment.Result, comment.Total = b.commentVotes(token,
+			v.CommentID)
+		replies[v.ParentID] = append(replies[v.ParentID], comment)
+	}
+	for _, r := range replies {
+		sort.Slice(r, func(i, j int) bool {
+			return less(r[i], r[j])
+		})
+	}
 
 	gcr := &www.GetCommentsReply{
-		Comments: make([]www.Comment, 0, len(c)),
+		Comments: make([]www.Comment, 0),
 	}
-	for _, v := range c {
-		gcr.Comments = append(gcr.Comments,
-			backendCommentToComment(v))
+	if !gc.Thread {
+		subtree := commentSubtree(replies, gc.ParentID, gc.Depth)
+		sort.Slice(subtree, func(i, j int) bool {
+			return less(subtree[i], subtree[j])
+		})
+		gcr.Comments, gcr.NextCursor = paginateComments(subtree, less,
+			cursor, gc)
+		return gcr, nil
+	}
+
+	// Threads are paginated by their top comment.
+	top, next := paginateComments(replies[gc.ParentID], less, cursor, gc)
+	seen := map[uint64]bool{gc.ParentID: true}
+	gcr.Threads = make([]www.CommentThread, 0, len(top))
+	for _, v := range top {
+		gcr.Threads = append(gcr.Threads,
+			commentThread(v, replies, gc.Depth, 1, seen))
 	}
+	gcr.NextCursor = next
 
 	return gcr, nil
 }
@@ -82,18 +320,12 @@ func (b *backend) addComment(c www.NewComment, userID uint64) (*www.NewCommentRe
 		Token:     c.Token,
 		ParentID:  c.ParentID,
 		Comment:   c.Comment,
+		Digest:    commentDigest(c.Comment),
 	}
-	cb, err := json.Marshal(comment)
-	if err != nil {
-		return nil, fmt.Errorf("Marshal comment: %v", err)
-	}
-	f, err := os.OpenFile(b.commentJournalFile,
-		os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	err := b.journalComment(comment)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
-	fmt.Fprintf(f, "%s\n", cb)
 
 	// Store comment in memory for quick lookup
 	b.comments[c.Token][b.commentID] = comment
@@ -102,42 +334,442 @@ func (b *backend) addComment(c www.NewComment, userID uint64) (*www.NewCommentRe
 	}
 	b.commentID++
 
+	b.triggerCommentFlush()
+
 	return &cr, nil
 }
 
-// replayCommentJournal reads the comments journal and recreates the internal
-// memory map.
+// censorComment journals a tombstone for the comment and blanks its text in
+// memory.  The tombstone keeps the metadata and digest of the comment.  The
+// text remains in the earlier journal entry and snapshots until the journal is
+// compacted and purged.
 // This call must be called with the lock held.
-func (b *backend) replayCommentJournal() error {
-	// Replay journal
-	f, err := os.Open(b.commentJournalFile)
+func (b *backend) censorComment(token string, commentID uint64, reason string) error {
+	comment := b.comments[token][commentID]
+	comment.Comment = ""
+	comment.Censored = true
+	comment.Reason = reason
+	err := b.journalComment(comment)
 	if err != nil {
-		// See if there is something to do with the journal.
-		if os.IsNotExist(err) {
-			return nil
+		return err
+	}
+	b.comments[token][commentID] = comment
+	b.purge = true
+
+	b.triggerCommentFlush()
+
+	return nil
+}
+
+// journalComment appends comment to the journal.  A comment that is journaled
+// again supersedes the earlier entry when the journal is replayed.
+// This call must be called with the lock held.
+func (b *backend) journalComment(comment BackendComment) error {
+	return b.commentJournal.Append(comment)
+}
+
+// unflushedComments returns the comments that have not been sent to politeiad
+// grouped by proposal token and sorted by comment id.  politeiad only stores
+// comments of vetted proposals so comments on other proposals are skipped.
+// This call must be called with the lock held.
+func (b *backend) unflushedComments() map[string][]BackendComment {
+	unflushed := make(map[string][]BackendComment)
+	for _, v := range b.inventory {
+		if !statusVetted(v.Status) {
+			continue
 		}
+		token := v.CensorshipRecord.Token
+		for _, c := range b.comments[token] {
+			// There is nothing left to send of censored comments
+			// and politeiad will not take rejected ones.
+			if !c.Flushed && !c.Censored && !c.Rejected {
+				unflushed[token] = append(unflushed[token], c)
+			}
+		}
+		sort.Slice(unflushed[token], func(i, j int) bool {
+			return unflushed[token][i].CommentID <
+				unflushed[token][j].CommentID
+		})
+	}
+	return unflushed
+}
+
+// unflushedCensors returns the censored comments whose tombstone has not been
+// sent to politeiad grouped by proposal token and sorted by comment id.  The
+// tombstone is sent even if the comment was never journaled as flushed since
+// politeiad may have stored it anyway.
+// This call must be called with the lock held.
+func (b *backend) unflushedCensors() map[string][]BackendComment {
+	unflushed := make(map[string][]BackendComment)
+	for _, v := range b.inventory {
+		if !statusVetted(v.Status) {
+			continue
+		}
+		token := v.CensorshipRecord.Token
+		for _, c := range b.comments[token] {
+			if c.Censored && !c.CensorFlushed {
+				unflushed[token] = append(unflushed[token], c)
+			}
+		}
+		sort.Slice(unflushed[token], func(i, j int) bool {
+			return unflushed[token][i].CommentID <
+				unflushed[token][j].CommentID
+		})
+	}
+	return unflushed
+}
+
+// remoteAppendComments sends comments of the proposal token to politeiad.
+func (b *backend) remoteAppendComments(token string, comments []BackendComment) error {
+	challenge, err := util.Random(pd.ChallengeSize)
+	if err != nil {
 		return err
 	}
-	defer f.Close()
-	d := json.NewDecoder(f)
-	for {
-		var c BackendComment
-		if err := d.Decode(&c); err == io.EOF {
-			break // done decoding file
-		} else if err != nil {
+	ac := pd.AppendComments{
+		Challenge: hex.EncodeToString(challenge),
+		Token:     token,
+		Comments:  convertCommentsToPD(comments),
+	}
+
+	responseBody, err := b.makeRequest(http.MethodPost,
+		pd.AppendCommentsRoute, ac)
+	if err != nil {
+		return err
+	}
+
+	var reply pd.AppendCommentsReply
+	err = json.Unmarshal(responseBody, &reply)
+	if err != nil {
+		return fmt.Errorf("Could not unmarshal AppendCommentsReply: %v",
+			err)
+	}
+
+	return util.VerifyChallenge(b.cfg.Identity, challenge, reply.Response)
+}
+
+// remoteCensorComment sends the tombstone of comment c of the proposal token
+// to politeiad.
+func (b *backend) remoteCensorComment(token string, c BackendComment) error {
+	challenge, err := util.Random(pd.ChallengeSize)
+	if err != nil {
+		return err
+	}
+	cc := pd.CensorComment{
+		Challenge: hex.EncodeToString(challenge),
+		Token:     token,
+		CommentID: c.CommentID,
+		Reason:    c.Reason,
+	}
+
+	responseBody, err := b.makeRequest(http.MethodPost,
+		pd.CensorCommentRoute, cc)
+	if err != nil {
+		return err
+	}
+
+	var reply pd.CensorCommentReply
+	err = json.Unmarshal(responseBody, &reply)
+	if err != nil {
+		return fmt.Errorf("Could not unmarshal CensorCommentReply: %v",
+			err)
+	}
+
+	return util.VerifyChallenge(b.cfg.Identity, challenge, reply.Response)
+}
+
+// rejectedComments returns the ids of the comments that politeiad refused to
+// store, or nil if err is not a refusal.  politeiad names the offending comment
+// of a rejected batch; if it does not the whole batch is rejected.
+func rejectedComments(err error, comments []BackendComment) []uint64 {
+	pdErr, ok := err.(www.PDError)
+	if !ok || pdErr.HTTPCode != http.StatusBadRequest {
+		return nil
+	}
+	if pdErr.ErrorReply.ErrorCode == int(pd.ErrorStatusInvalidComment) &&
+		len(pdErr.ErrorReply.ErrorContext) == 1 {
+		id, err := strconv.ParseUint(pdErr.ErrorReply.ErrorContext[0],
+			10, 64)
+		if err == nil {
+			for _, v := range comments {
+				if v.CommentID == id {
+					return []uint64{id}
+				}
+			}
+		}
+	}
+	ids := make([]uint64, 0, len(comments))
+	for _, v := range comments {
+		ids = append(ids, v.CommentID)
+	}
+	return ids
+}
+
+// flushProposalComments sends comments of the proposal token to politeiad and
+// journals them as flushed.  Comments that politeiad refuses are journaled as
+// rejected instead since sending them again would not change the outcome.
+// This call must be called WITHOUT the lock held.
+func (b *backend) flushProposalComments(token string, comments []BackendComment) error {
+	var rejected []uint64
+	if !b.test {
+		err := b.remoteAppendComments(token, comments)
+		if err != nil {
+			rejected = rejectedComments(err, comments)
+			if rejected == nil {
+				return err
+			}
+			log.Errorf("politeiad rejected comments %v of %v: %v",
+				rejected, token, err)
+		}
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	// Journal the current comment, it may have been censored in the
+	// meantime.
+	done := comments
+	if rejected != nil {
+		done = make([]BackendComment, 0, len(rejected))
+		for _, id := range rejected {
+			done = append(done, b.comments[token][id])
+		}
+	}
+	for _, v := range done {
+		c := b.comments[token][v.CommentID]
+		if rejected != nil {
+			c.Rejected = true
+		} else {
+			c.Flushed = true
+		}
+		err := b.journalComment(c)
+		if err != nil {
 			return err
 		}
-		// Add to memory cache
-		if _, ok := b.comments[c.Token]; !ok {
-			b.comments[c.Token] = make(map[uint64]BackendComment)
+		b.comments[token][c.CommentID] = c
+	}
+
+	if rejected == nil {
+		log.Debugf("Flushed %v comments of %v", len(done), token)
+	} else if len(rejected) < len(comments) {
+		// The rest of a batch that was rejected because of a single
+		// comment goes out with the next flush.
+		b.triggerCommentFlush()
+	}
+
+	return nil
+}
+
+// flushProposalCensors sends the tombstones of the censored comments of the
+// proposal token to politeiad and journals them as flushed.  politeiad refuses
+// tombstones of comments it does not have, which leaves nothing to censor.
+// This call must be called WITHOUT the lock held.
+func (b *backend) flushProposalCensors(token string, comments []BackendComment) error {
+	for _, v := range comments {
+		if !b.test {
+			err := b.remoteCensorComment(token, v)
+			if pdErr, ok := err.(www.PDError); ok &&
+				pdErr.HTTPCode == http.StatusBadRequest {
+				log.Debugf("politeiad did not censor comment "+
+					"%v of %v: %v", v.CommentID, token, err)
+			} else if err != nil {
+				return err
+			}
+		}
+
+		b.Lock()
+		c := b.comments[token][v.CommentID]
+		c.CensorFlushed = true
+		err := b.journalComment(c)
+		if err == nil {
+			b.comments[token][c.CommentID] = c
 		}
-		b.comments[c.Token][c.CommentID] = c
+		b.Unlock()
+		if err != nil {
+			return err
+		}
+	}
 
-		// See if this is the last comment
-		if c.CommentID > b.commentID {
-			b.commentID = c.CommentID
+	log.Debugf("Flushed %v censored comments of %v", len(comments), token)
+
+	return nil
+}
+
+// flushComments sends the unflushed comments and tombstones to politeiad and
+// journals them as flushed.  politeiad ignores comments it already has so
+// comments that were sent but not journaled as flushed are simply sent again.
+// A proposal that fails does not hold up the others; its comments are retried
+// later.
+// This call must be called WITHOUT the lock held.
+func (b *backend) flushComments() error {
+	b.RLock()
+	unflushed := b.unflushedComments()
+	censors := b.unflushedCensors()
+	b.RUnlock()
+
+	var failed int
+	for token, comments := range unflushed {
+		err := b.flushProposalComments(token, comments)
+		if err != nil {
+			log.Errorf("flush comments %v: %v", token, err)
+			failed++
 		}
 	}
+	for token, comments := range censors {
+		err := b.flushProposalCensors(token, comments)
+		if err != nil {
+			log.Errorf("flush censored comments %v: %v", token, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("flush comments: %v of %v proposals failed",
+			failed, len(unflushed)+len(censors))
+	}
 
 	return nil
 }
+
+// triggerCommentFlush wakes up commentFlusher.  It does not block.
+func (b *backend) triggerCommentFlush() {
+	select {
+	case b.flushC <- struct{}{}:
+	default:
+	}
+}
+
+// commentFlusher flushes comments to politeiad when triggered and retries
+// failed flushes every commentFlushInterval.  It never returns.
+func (b *backend) commentFlusher() {
+	ticker := time.NewTicker(commentFlushInterval)
+	defer ticker.Stop()
+	for {
+		err := b.flushComments()
+		if err != nil {
+			log.Errorf("%v", err)
+		}
+
+		select {
+		case <-b.flushC:
+		case <-ticker.C:
+		}
+	}
+}
+
+// replayComment applies a journaled comment to the memory map.
+// This call must be called with the lock held.
+func (b *backend) replayComment(record json.RawMessage) error {
+	var c BackendComment
+	err := json.Unmarshal(record, &c)
+	if err != nil {
+		return err
+	}
+
+	// Journals that predate digests
+	if c.Digest == "" && !c.Censored {
+		c.Digest = commentDigest(c.Comment)
+	}
+
+	// Add to memory cache
+	if _, ok := b.comments[c.Token]; !ok {
+		b.comments[c.Token] = make(map[uint64]BackendComment)
+	}
+
+	// Apply tombstones.  A censored comment stays censored even if it is
+	// journaled again afterwards.  A tombstone that supersedes the text
+	// means that the text is still on disk and must be purged.
+	if old, ok := b.comments[c.Token][c.CommentID]; ok && old.Censored {
+		old.Flushed = old.Flushed || c.Flushed
+		old.CensorFlushed = old.CensorFlushed || c.CensorFlushed
+		c = old
+	} else if ok && c.Censored {
+		b.purge = true
+	}
+	b.comments[c.Token][c.CommentID] = c
+
+	// Continue after the last comment
+	if c.CommentID >= b.commentID {
+		b.commentID = c.CommentID + 1
+	}
+
+	return nil
+}
+
+// replayCommentJournal reads the comments snapshot and journal, recreates the
+// internal memory map and opens the journal for appending.
+// This call must be called with the lock held.
+func (b *backend) replayCommentJournal() error {
+	j, err := journal.Open(b.commentJournalFile, journal.DefaultKeep,
+		b.replayComment)
+	if err != nil {
+		return err
+	}
+	b.commentJournal = j
+
+	return nil
+}
+
+// compactJournals replaces the comment and like journals with snapshots of
+// the memory maps.  This drops superseded records, such as the text of
+// censored comments and replaced votes.  Older comment snapshots are purged
+// when comments were censored since they may still hold the text.
+// This call must be called WITHOUT the lock held.
+func (b *backend) compactJournals() error {
+	// Hold the write lock so that nothing is journaled in the meantime.
+	b.Lock()
+	defer b.Unlock()
+
+	if b.commentJournal.Records() > 0 {
+		comments := make([]BackendComment, 0)
+		for _, m := range b.comments {
+			for _, c := range m {
+				comments = append(comments, c)
+			}
+		}
+		sort.Slice(comments, func(i, j int) bool {
+			return comments[i].CommentID < comments[j].CommentID
+		})
+		records := make([]interface{}, 0, len(comments))
+		for _, c := range comments {
+			records = append(records, c)
+		}
+		compact := b.commentJournal.Compact
+		if b.purge {
+			compact = b.commentJournal.CompactPurge
+		}
+		err := compact(records)
+		if err != nil {
+			return fmt.Errorf("compact comments: %v", err)
+		}
+		b.purge = false
+	}
+
+	if b.likeJournal.Records() > 0 {
+		records := make([]interface{}, 0)
+		for _, comments := range b.likes {
+			for _, votes := range comments {
+				for _, l := range votes {
+					records = append(records, l)
+				}
+			}
+		}
+		err := b.likeJournal.Compact(records)
+		if err != nil {
+			return fmt.Errorf("compact likes: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// journalCompactor compacts the journals every journalCompactInterval.  It
+// never returns.
+func (b *backend) journalCompactor() {
+	ticker := time.NewTicker(journalCompactInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		err := b.compactJournals()
+		if err != nil {
+			log.Errorf("%v", err)
+		}
+	}
+}
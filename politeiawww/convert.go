@@ -15,6 +15,12 @@ func convertPropStatusFromWWW(s www.PropStatusT) pd.PropStatusT {
 		return pd.PropStatusCensored
 	case www.PropStatusPublic:
 		return pd.PropStatusPublic
+	case www.PropStatusAbandoned:
+		return pd.PropStatusAbandoned
+	case www.PropStatusArchived:
+		return pd.PropStatusArchived
+	case www.PropStatusWithdrawn:
+		return pd.PropStatusWithdrawn
 	}
 	return pd.PropStatusInvalid
 }
@@ -44,12 +50,26 @@ func convertPropCensorFromWWW(f www.CensorshipRecord) pd.CensorshipRecord {
 	}
 }
 
+func convertPropUserSignatureFromWWW(us *www.UserSignature) *pd.UserSignature {
+	if us == nil {
+		return nil
+	}
+	return &pd.UserSignature{
+		PublicKey: us.PublicKey,
+		Merkle:    us.Merkle,
+		Signature: us.Signature,
+	}
+}
+
 func convertPropFromWWW(p www.ProposalRecord) pd.ProposalRecord {
 	return pd.ProposalRecord{
 		Name:             p.Name,
 		Status:           convertPropStatusFromWWW(p.Status),
+		Version:          p.Version,
 		Timestamp:        p.Timestamp,
 		Files:            convertPropFilesFromWWW(p.Files),
+		Reason:           p.Reason,
+		UserSignature:    convertPropUserSignatureFromWWW(p.UserSignature),
 		CensorshipRecord: convertPropCensorFromWWW(p.CensorshipRecord),
 	}
 }
@@ -73,6 +93,12 @@ func convertPropStatusFromPD(s pd.PropStatusT) www.PropStatusT {
 		return www.PropStatusCensored
 	case pd.PropStatusPublic:
 		return www.PropStatusPublic
+	case pd.PropStatusAbandoned:
+		return www.PropStatusAbandoned
+	case pd.PropStatusArchived:
+		return www.PropStatusArchived
+	case pd.PropStatusWithdrawn:
+		return www.PropStatusWithdrawn
 	}
 	return www.PropStatusInvalid
 }
@@ -102,12 +128,26 @@ func convertPropCensorFromPD(f pd.CensorshipRecord) www.CensorshipRecord {
 	}
 }
 
+func convertPropUserSignatureFromPD(us *pd.UserSignature) *www.UserSignature {
+	if us == nil {
+		return nil
+	}
+	return &www.UserSignature{
+		PublicKey: us.PublicKey,
+		Merkle:    us.Merkle,
+		Signature: us.Signature,
+	}
+}
+
 func convertPropFromPD(p pd.ProposalRecord) www.ProposalRecord {
 	return www.ProposalRecord{
 		Name:             p.Name,
 		Status:           convertPropStatusFromPD(p.Status),
+		Version:          p.Version,
 		Timestamp:        p.Timestamp,
 		Files:            convertPropFilesFromPD(p.Files),
+		Reason:           p.Reason,
+		UserSignature:    convertPropUserSignatureFromPD(p.UserSignature),
 		CensorshipRecord: convertPropCensorFromPD(p.CensorshipRecord),
 	}
 }
@@ -120,6 +160,20 @@ func convertPropsFromPD(p []pd.ProposalRecord) []www.ProposalRecord {
 	return pr
 }
 
+func convertPropVersionsFromPD(v []pd.ProposalVersion) []www.ProposalVersion {
+	versions := make([]www.ProposalVersion, 0, len(v))
+	for _, pv := range v {
+		versions = append(versions, www.ProposalVersion{
+			Commit:    pv.Commit,
+			Version:   pv.Version,
+			Status:    convertPropStatusFromPD(pv.Status),
+			Timestamp: pv.Timestamp,
+			Merkle:    pv.Merkle,
+		})
+	}
+	return versions
+}
+
 func convertErrorStatusFromPD(s int) www.ErrorStatusT {
 	switch pd.ErrorStatusT(s) {
 	case pd.ErrorStatusInvalidProposalName:
@@ -134,6 +188,12 @@ func convertErrorStatusFromPD(s int) www.ErrorStatusT {
 		return www.ErrorStatusUnsupportedMIMEType
 	case pd.ErrorStatusInvalidPropStatusTransition:
 		return www.ErrorStatusInvalidPropStatusTransition
+	case pd.ErrorStatusProposalNotFound:
+		return www.ErrorStatusProposalNotFound
+	case pd.ErrorStatusNoChanges:
+		return www.ErrorStatusNoProposalChanges
+	case pd.ErrorStatusInvalidUserSignature:
+		return www.ErrorStatusInvalidSignature
 
 		// These cases are intentionally omitted because
 		// they are indicative of some internal server error,
@@ -6,6 +6,8 @@ package database
 
 import (
 	"errors"
+
+	"github.com/agl/ed25519"
 )
 
 var (
@@ -23,6 +25,18 @@ var (
 	ErrShutdown = errors.New("database is shutting down")
 )
 
+// Identity is an ed25519 public key of a user.  Identities are never removed
+// from the user record so that signatures made by keys that have since been
+// rotated or revoked remain verifiable.
+//
+// An identity that was neither activated nor deactivated is pending; it
+// becomes active once the user proves ownership of the key.
+type Identity struct {
+	Key         [ed25519.PublicKeySize]byte // ed25519 public key
+	Activated   int64                       // Unix time the key was activated
+	Deactivated int64                       // Unix time the key was deactivated
+}
+
 // User record.
 type User struct {
 	ID                              uint64 // Unique id
@@ -33,6 +47,10 @@ type User struct {
 	NewUserVerificationExpiry       int64  // Unix time representing the moment that the token expires.
 	ResetPasswordVerificationToken  []byte
 	ResetPasswordVerificationExpiry int64
+	UpdateKeyChallenge              []byte // Challenge that must be signed by the pending key
+	UpdateKeyChallengeExpiry        int64  // Unix time representing the moment that the challenge expires.
+	Identities                      []Identity
+	Proposals                       []string // Censorship tokens of the proposals submitted by the user
 }
 
 // Database interface that is required by the web server.
@@ -0,0 +1,419 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package journal implements a crash safe, append only journal of JSON
+// records.
+//
+// Every record is written as a single line that is prefixed with the CRC-32C
+// checksum of the record and it is synced to disk before Append returns.  A
+// record that was only partially written when the process died is detected by
+// its checksum and dropped when the journal is opened, provided that it is the
+// last record.  Damage anywhere else is reported as a CorruptError.
+//
+// A journal is compacted by writing the current state as records to a
+// numbered snapshot and truncating the journal.  The newest snapshot is
+// replayed before the journal.  Older snapshots are kept around for manual
+// recovery, unless the journal is compacted with CompactPurge because it
+// superseded records that must not be retained.  When the process dies
+// between the two steps the journal is replayed on top of the snapshot it was
+// compacted into, therefore replaying a record twice must not change the
+// state.
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultKeep is the default number of snapshots that are kept.
+	DefaultKeep = 3
+
+	checksumSize   = 8 // Hex encoded CRC-32C
+	snapshotInfix  = ".snapshot."
+	snapshotFormat = "%v" + snapshotInfix + "%08d"
+)
+
+var (
+	// ErrClosed is returned when a closed journal is used.
+	ErrClosed = errors.New("journal closed")
+
+	castagnoli = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// CorruptError is returned when a record of a snapshot or a record other than
+// the last record of a journal is damaged.
+type CorruptError struct {
+	Filename string // Damaged file
+	Offset   int64  // Offset of the damaged record
+}
+
+func (c CorruptError) Error() string {
+	return fmt.Sprintf("%v: corrupt record at offset %v", c.Filename,
+		c.Offset)
+}
+
+// Journal is an append only file of records.
+type Journal struct {
+	sync.Mutex
+	filename string
+	keep     int
+	f        *os.File
+	size     int64  // Size of the records in the journal
+	records  uint64 // Records in the journal
+	snapshot uint64 // Number of the newest snapshot
+	err      error  // Set when the journal can no longer be appended to
+}
+
+// encodeRecord returns the journal line of v.
+func encodeRecord(v interface{}) ([]byte, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	line := make([]byte, 0, checksumSize+1+len(payload)+1)
+	line = append(line, fmt.Sprintf("%08x ",
+		crc32.Checksum(payload, castagnoli))...)
+	line = append(line, payload...)
+	return append(line, '\n'), nil
+}
+
+// decodeRecord returns the record of a journal line.  Lines that are not
+// terminated, do not match their checksum or are not JSON are invalid.
+func decodeRecord(line []byte) (json.RawMessage, bool) {
+	if len(line) == 0 || line[len(line)-1] != '\n' {
+		return nil, false
+	}
+	line = line[:len(line)-1]
+
+	// Journals written before checksums were added contain plain JSON.
+	// A checksum never starts with a brace.
+	if len(line) > 0 && line[0] == '{' {
+		return line, json.Valid(line)
+	}
+
+	if len(line) <= checksumSize || line[checksumSize] != ' ' {
+		return nil, false
+	}
+	checksum, err := strconv.ParseUint(string(line[:checksumSize]), 16, 32)
+	if err != nil {
+		return nil, false
+	}
+	payload := line[checksumSize+1:]
+	if crc32.Checksum(payload, castagnoli) != uint32(checksum) {
+		return nil, false
+	}
+	return payload, true
+}
+
+// readRecords calls fn for every record of filename.  It returns the size and
+// the number of the valid records.  An invalid last record is ignored when
+// tornTail is set; the caller is expected to truncate it.
+func readRecords(filename string, tornTail bool, fn func(json.RawMessage) error) (int64, uint64, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	var (
+		size    int64
+		records uint64
+	)
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			return size, records, nil
+		} else if err != nil && err != io.EOF {
+			return size, records, err
+		}
+
+		record, ok := decodeRecord(line)
+		if !ok {
+			if _, err := r.Peek(1); tornTail && err == io.EOF {
+				log.Warnf("Dropping torn record at offset %v of %v",
+					size, filename)
+				return size, records, nil
+			}
+			return size, records, CorruptError{
+				Filename: filename,
+				Offset:   size,
+			}
+		}
+		err = fn(record)
+		if err != nil {
+			return size, records, fmt.Errorf("%v: record at "+
+				"offset %v: %v", filename, size, err)
+		}
+
+		size += int64(len(line))
+		records++
+	}
+}
+
+// snapshots returns the numbers of the snapshots of filename in ascending
+// order.
+func snapshots(filename string) ([]uint64, error) {
+	matches, err := filepath.Glob(filename + snapshotInfix + "*")
+	if err != nil {
+		return nil, err
+	}
+	numbers := make([]uint64, 0, len(matches))
+	for _, v := range matches {
+		n, err := strconv.ParseUint(strings.TrimPrefix(v,
+			filename+snapshotInfix), 10, 64)
+		if err != nil {
+			// Not a snapshot, e.g. one that is being written.
+			continue
+		}
+		numbers = append(numbers, n)
+	}
+	sort.Slice(numbers, func(i, j int) bool {
+		return numbers[i] < numbers[j]
+	})
+	return numbers, nil
+}
+
+// syncDir makes the creation and renaming of the files in dir durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// Open replays the newest snapshot and the journal filename by calling fn for
+// every record in order, and opens the journal for appending.  A torn record
+// at the end of the journal is removed.  keep is the number of snapshots that
+// Compact keeps.
+func Open(filename string, keep int, fn func(json.RawMessage) error) (*Journal, error) {
+	if keep < 1 {
+		keep = 1
+	}
+	j := &Journal{
+		filename: filename,
+		keep:     keep,
+	}
+
+	s, err := snapshots(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(s) > 0 {
+		j.snapshot = s[len(s)-1]
+		_, _, err = readRecords(fmt.Sprintf(snapshotFormat, filename,
+			j.snapshot), false, fn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	j.size, j.records, err = readRecords(filename, true, fn)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	created := os.IsNotExist(err)
+
+	f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_APPEND,
+		0644)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err == nil && fi.Size() > j.size {
+		err = f.Truncate(j.size)
+		if err == nil {
+			err = f.Sync()
+		}
+	}
+	if err == nil && created {
+		err = syncDir(filepath.Dir(filename))
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	j.f = f
+
+	return j, nil
+}
+
+// Append writes the record v to the journal and syncs it to disk.
+func (j *Journal) Append(v interface{}) error {
+	line, err := encodeRecord(v)
+	if err != nil {
+		return err
+	}
+
+	j.Lock()
+	defer j.Unlock()
+
+	if j.f == nil {
+		return ErrClosed
+	}
+	if j.err != nil {
+		return j.err
+	}
+
+	_, err = j.f.Write(line)
+	if err == nil {
+		err = j.f.Sync()
+	}
+	if err != nil {
+		// Remove what made it into the file so that the next record
+		// does not follow a damaged one.
+		if terr := j.f.Truncate(j.size); terr != nil {
+			j.err = fmt.Errorf("journal %v unusable: %v",
+				j.filename, terr)
+		}
+		return err
+	}
+	j.size += int64(len(line))
+	j.records++
+
+	return nil
+}
+
+// Records returns the number of records in the journal, that is since the
+// last compaction.
+func (j *Journal) Records() uint64 {
+	j.Lock()
+	defer j.Unlock()
+	return j.records
+}
+
+// writeSnapshot writes records to filename and syncs it to disk.
+func writeSnapshot(filename string, records []interface{}) error {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC,
+		0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	for _, v := range records {
+		line, err := encodeRecord(v)
+		if err != nil {
+			return err
+		}
+		if _, err = w.Write(line); err != nil {
+			return err
+		}
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// Compact replaces the journal with a snapshot of records, which must hold the
+// state that replaying the journal produces.  Only the newest keep snapshots
+// are retained.  The caller must ensure that no records are appended while the
+// state is collected and compacted.
+func (j *Journal) Compact(records []interface{}) error {
+	return j.compact(records, j.keep)
+}
+
+// CompactPurge compacts the journal like Compact and removes all older
+// snapshots.  It is used when the journal superseded records that must not
+// remain on disk, such as censored text.  The older snapshots are removed
+// before the journal is truncated so that a journal that still needs a purge
+// is never lost.
+func (j *Journal) CompactPurge(records []interface{}) error {
+	return j.compact(records, 1)
+}
+
+// compact is the generic implementation of Compact and CompactPurge.  It keeps
+// the newest keep snapshots.
+func (j *Journal) compact(records []interface{}, keep int) error {
+	j.Lock()
+	defer j.Unlock()
+
+	if j.f == nil {
+		return ErrClosed
+	}
+	if j.err != nil {
+		return j.err
+	}
+
+	// The snapshot is moved into place once it is complete.
+	tmp := j.filename + snapshotInfix + "tmp"
+	err := writeSnapshot(tmp, records)
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	n := j.snapshot + 1
+	err = os.Rename(tmp, fmt.Sprintf(snapshotFormat, j.filename, n))
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	err = syncDir(filepath.Dir(j.filename))
+	if err != nil {
+		return err
+	}
+	j.snapshot = n
+
+	// Only the newest snapshot is replayed, the older ones can go.
+	s, err := snapshots(j.filename)
+	if err != nil {
+		return err
+	}
+	for len(s) > keep {
+		err = os.Remove(fmt.Sprintf(snapshotFormat, j.filename, s[0]))
+		if err != nil {
+			return err
+		}
+		s = s[1:]
+	}
+	err = syncDir(filepath.Dir(j.filename))
+	if err != nil {
+		return err
+	}
+
+	// Up to here the journal is replayed on top of the new snapshot.
+	err = j.f.Truncate(0)
+	if err != nil {
+		return err
+	}
+	j.size = 0
+	j.records = 0
+	err = j.f.Sync()
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("Compacted %v into snapshot %v with %v records",
+		j.filename, n, len(records))
+
+	return nil
+}
+
+// Close closes the journal.
+func (j *Journal) Close() error {
+	j.Lock()
+	defer j.Unlock()
+
+	if j.f == nil {
+		return ErrClosed
+	}
+	err := j.f.Close()
+	j.f = nil
+	return err
+}
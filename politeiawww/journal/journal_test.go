@@ -0,0 +1,246 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type record struct {
+	ID    uint64
+	Value string
+}
+
+// replay opens the journal and returns the replayed records.
+func replay(t *testing.T, filename string) (*Journal, []record) {
+	records := make([]record, 0)
+	j, err := Open(filename, 2, func(r json.RawMessage) error {
+		var v record
+		err := json.Unmarshal(r, &v)
+		if err != nil {
+			return err
+		}
+		records = append(records, v)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return j, records
+}
+
+// expectRecords fails the test if the records do not match expected.
+func expectRecords(t *testing.T, records []record, expected ...record) {
+	if len(expected) == 0 {
+		expected = []record{}
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Fatalf("invalid records got %v wanted %v", records, expected)
+	}
+}
+
+// appendFile appends data to filename.
+func appendFile(t *testing.T, filename string, data string) {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	_, err = f.WriteString(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "politeiawww.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "journal.json")
+
+	j, records := replay(t, filename)
+	expectRecords(t, records)
+	r1 := record{1, "one"}
+	r2 := record{2, "line\nbreak"}
+	for _, r := range []record{r1, r2} {
+		if err := j.Append(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if j.Records() != 2 {
+		t.Fatalf("invalid record count %v", j.Records())
+	}
+	j.Close()
+	if err := j.Append(r1); err != ErrClosed {
+		t.Fatalf("expected ErrClosed got %v", err)
+	}
+
+	j, records = replay(t, filename)
+	expectRecords(t, records, r1, r2)
+	if j.Records() != 2 {
+		t.Fatalf("invalid record count %v", j.Records())
+	}
+	j.Close()
+}
+
+func TestTornTail(t *testing.T) {
+	dir, err := ioutil.TempDir("", "politeiawww.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "journal.json")
+
+	// Journals without checksums are still read.
+	err = ioutil.WriteFile(filename, []byte(`{"ID":1,"Value":"one"}`+"\n"),
+		0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r1 := record{1, "one"}
+	r2 := record{2, "two"}
+	line, err := encodeRecord(r2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, torn := range []string{
+		string(line[:len(line)-1]), // Missing newline
+		string(line[:len(line)/2]), // Partial record
+		"0" + string(line[1:]),     // Damaged checksum
+		`{"ID":3`,                  // Partial record without checksum
+	} {
+		appendFile(t, filename, torn)
+		j, records := replay(t, filename)
+		expectRecords(t, records, r1)
+
+		// The torn record is gone and appends follow the last
+		// good record.
+		err = j.Append(r2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		j.Close()
+		j, records = replay(t, filename)
+		expectRecords(t, records, r1, r2)
+		j.Close()
+
+		err = ioutil.WriteFile(filename, []byte(`{"ID":1,"Value":"one"}`+
+			"\n"), 0644)
+		if err != nil {
+			t.Fatalf("%v: %v", i, err)
+		}
+	}
+
+	// Damage before the last record is not a torn tail.
+	appendFile(t, filename, "garbage\n"+string(line))
+	_, err = Open(filename, 2, func(json.RawMessage) error {
+		return nil
+	})
+	if _, ok := err.(CorruptError); !ok {
+		t.Fatalf("expected CorruptError got %v", err)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	dir, err := ioutil.TempDir("", "politeiawww.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "journal.json")
+
+	j, _ := replay(t, filename)
+	state := make([]interface{}, 0)
+	expected := make([]record, 0)
+	for i := uint64(1); i <= 4; i++ {
+		r := record{i, fmt.Sprintf("%v", i)}
+		if err := j.Append(r); err != nil {
+			t.Fatal(err)
+		}
+		state = append(state, r)
+		expected = append(expected, r)
+		if err := j.Compact(state); err != nil {
+			t.Fatal(err)
+		}
+		if j.Records() != 0 {
+			t.Fatalf("journal not truncated")
+		}
+	}
+
+	// Only the newest snapshots are kept.
+	s, err := snapshots(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s, []uint64{3, 4}) {
+		t.Fatalf("invalid snapshots %v", s)
+	}
+
+	// Records appended after a compaction follow the snapshot.
+	r := record{5, "5"}
+	if err := j.Append(r); err != nil {
+		t.Fatal(err)
+	}
+	j.Close()
+	j, records := replay(t, filename)
+	expectRecords(t, records, append(expected, r)...)
+	j.Close()
+
+	// A damaged snapshot is never skipped.
+	appendFile(t, fmt.Sprintf(snapshotFormat, filename, 4), "garbage")
+	_, err = Open(filename, 2, func(json.RawMessage) error {
+		return nil
+	})
+	if _, ok := err.(CorruptError); !ok {
+		t.Fatalf("expected CorruptError got %v", err)
+	}
+}
+
+func TestCompactPurge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "politeiawww.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "journal.json")
+
+	j, _ := replay(t, filename)
+	secret := record{1, "secret"}
+	if err := j.Append(secret); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Compact([]interface{}{secret}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Superseded records are purged from all snapshots.
+	blank := record{1, ""}
+	if err := j.Append(blank); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.CompactPurge([]interface{}{blank}); err != nil {
+		t.Fatal(err)
+	}
+	s, err := snapshots(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s, []uint64{2}) {
+		t.Fatalf("invalid snapshots %v", s)
+	}
+	j.Close()
+	j, records := replay(t, filename)
+	expectRecords(t, records, blank)
+	j.Close()
+}
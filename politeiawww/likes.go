@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	www "github.com/decred/politeia/politeiawww/api/v1"
+	"github.com/decred/politeia/politeiawww/journal"
+)
+
+const (
+	defaultLikeJournalFile = "likes.json"
+)
+
+// BackendLike is the journaled vote of a user on a comment.  A later vote of
+// the same user on the same comment replaces the earlier one.
+type BackendLike struct {
+	Token     string
+	CommentID uint64
+	UserID    uint64
+	Action    int64
+	Timestamp int64
+}
+
+// validLikeAction returns true if action is a valid comment vote.
+func validLikeAction(action int64) bool {
+	return action == www.LikeActionUp || action == www.LikeActionDown ||
+		action == www.LikeActionReset
+}
+
+// commentVotes returns the sum and the number of the votes on a comment.
+// This call must be called with the lock held.
+func (b *backend) commentVotes(token string, commentID uint64) (int64, uint64) {
+	var result int64
+	votes := b.likes[token][commentID]
+	for _, l := range votes {
+		result += l.Action
+	}
+	return result, uint64(len(votes))
+}
+
+// setLike records the vote of a user in the memory map.  A reset vote removes
+// the vote.
+// This call must be called with the lock held.
+func (b *backend) setLike(l BackendLike) {
+	if _, ok := b.likes[l.Token]; !ok {
+		b.likes[l.Token] = make(map[uint64]map[uint64]BackendLike)
+	}
+	votes, ok := b.likes[l.Token][l.CommentID]
+	if !ok {
+		votes = make(map[uint64]BackendLike)
+		b.likes[l.Token][l.CommentID] = votes
+	}
+	if l.Action == www.LikeActionReset {
+		delete(votes, l.UserID)
+	} else {
+		votes[l.UserID] = l
+	}
+}
+
+// addLike journals and records the vote of userID on a comment.  Votes that do
+// not change anything are not journaled.
+// This call must be called with the lock held.
+func (b *backend) addLike(lc www.LikeComment, userID uint64) (*www.LikeCommentReply, error) {
+	if b.likes[lc.Token][lc.CommentID][userID].Action != lc.Action {
+		like := BackendLike{
+			Token:     lc.Token,
+			CommentID: lc.CommentID,
+			UserID:    userID,
+			Action:    lc.Action,
+			Timestamp: time.Now().Unix(),
+		}
+		err := b.likeJournal.Append(like)
+		if err != nil {
+			return nil, err
+		}
+
+		b.setLike(like)
+	}
+
+	result, total := b.commentVotes(lc.Token, lc.CommentID)
+	return &www.LikeCommentReply{
+		Result: result,
+		Total:  total,
+	}, nil
+}
+
+// getUserLikes returns the votes of userID on the comments of a proposal
+// sorted by comment id.
+// This call must be called WITHOUT the lock held.
+func (b *backend) getUserLikes(token string, userID uint64) (*www.UserCommentsLikesReply, error) {
+	b.RLock()
+	defer b.RUnlock()
+
+	if _, ok := b.comments[token]; !ok {
+		return nil, www.UserError{
+			ErrorCode: www.ErrorStatusProposalNotFound,
+		}
+	}
+
+	reply := &www.UserCommentsLikesReply{
+		CommentsLikes: make([]www.CommentLike, 0),
+	}
+	for commentID, votes := range b.likes[token] {
+		l, ok := votes[userID]
+		if !ok {
+			continue
+		}
+		reply.CommentsLikes = append(reply.CommentsLikes,
+			www.CommentLike{
+				CommentID: commentID,
+				Action:    l.Action,
+			})
+	}
+	sort.Slice(reply.CommentsLikes, func(i, j int) bool {
+		return reply.CommentsLikes[i].CommentID <
+			reply.CommentsLikes[j].CommentID
+	})
+
+	return reply, nil
+}
+
+// replayLikeJournal reads the likes snapshot and journal, recreates the
+// internal memory map and opens the journal for appending.
+// This call must be called with the lock held.
+func (b *backend) replayLikeJournal() error {
+	j, err := journal.Open(b.likeJournalFile, journal.DefaultKeep,
+		func(record json.RawMessage) error {
+			var l BackendLike
+			err := json.Unmarshal(record, &l)
+			if err != nil {
+				return err
+			}
+			b.setLike(l)
+			return nil
+		})
+	if err != nil {
+		return err
+	}
+	b.likeJournal = j
+
+	return nil
+}
@@ -43,12 +43,14 @@ var (
 
 	log        = backendLog.Logger("PWWW")
 	localdbLog = backendLog.Logger("LODB")
+	journalLog = backendLog.Logger("JRNL")
 )
 
 // subsystemLoggers maps each subsystem identifier to its associated logger.
 var subsystemLoggers = map[string]btclog.Logger{
 	"PWWW": log,
 	"LODB": localdbLog,
+	"JRNL": journalLog,
 }
 
 // initLogRotator initializes the logging rotater to write logs to logFile and
@@ -15,9 +15,11 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"syscall"
 	"time"
 
+	"github.com/decred/politeia/politeiad/api/v1/identity"
 	"github.com/decred/politeia/politeiawww/api/v1"
 	"github.com/decred/politeia/util"
 	"github.com/gorilla/csrf"
@@ -52,13 +54,28 @@ type resetPasswordEmailTemplateData struct {
 	Email string
 }
 
-// Fetch remote identity
+// Fetch remote identity.  If an identity was saved before the key chain of
+// politeiad must lead from it to the fetched identity.
 func (p *politeiawww) getIdentity() error {
-	id, err := util.RemoteIdentity(false, p.cfg.RPCHost, p.cfg.RPCCert)
+	id, chain, err := util.RemoteIdentity(false, p.cfg.RPCHost, p.cfg.RPCCert)
 	if err != nil {
 		return err
 	}
 
+	if _, err := os.Stat(p.cfg.RPCIdentityFile); err == nil {
+		pinned, err := identity.LoadPublicIdentity(p.cfg.RPCIdentityFile)
+		if err != nil {
+			return err
+		}
+		err = util.VerifyIdentityRotation(pinned, id, chain)
+		if err != nil {
+			return err
+		}
+		if pinned.Key != id.Key {
+			log.Infof("Identity rotated from key %x", pinned.Key)
+		}
+	}
+
 	// Pretty print identity.
 	log.Infof("Identity fetched from politeiad")
 	log.Infof("FQDN       : %v", id.Name)
@@ -66,6 +83,7 @@ func (p *politeiawww) getIdentity() error {
 	log.Infof("Key        : %x", id.Key)
 	log.Infof("Identity   : %x", id.Identity)
 	log.Infof("Fingerprint: %v", id.Fingerprint())
+	log.Infof("Rotations  : %v", len(chain))
 
 	// Ask user if we like this identity
 	log.Infof("Save to %v or ctrl-c to abort", p.cfg.RPCIdentityFile)
@@ -219,6 +237,9 @@ func (p *politeiawww) handleVerifyNewUser(w http.ResponseWriter, r *http.Request
 
 		vnu.Email = email[0]
 		vnu.VerificationToken = token[0]
+		if signature, ok := query["signature"]; ok {
+			vnu.Signature = signature[0]
+		}
 	}
 	defer r.Body.Close()
 
@@ -274,11 +295,7 @@ func (p *politeiawww) handleLogin(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Mark user as logged in if there's no error.
-	session.Values["email"] = l.Email
-	session.Values["id"] = reply.UserID
-	session.Values["authenticated"] = true
-	session.Values["admin"] = reply.IsAdmin
-	err = session.Save(r, w)
+	err = p.login(w, r, session, l.Email, reply)
 	if err != nil {
 		RespondWithError(w, r, 0,
 			"handleLogin: failed to save session: %v", err)
@@ -289,6 +306,112 @@ func (p *politeiawww) handleLogin(w http.ResponseWriter, r *http.Request) {
 	util.RespondWithJSON(w, http.StatusOK, reply)
 }
 
+// handleLoginChallenge handles the incoming login challenge command.  It
+// replies with the challenge that must be signed in order to login with
+// handleLoginSigned and keeps the challenge with the session.
+func (p *politeiawww) handleLoginChallenge(w http.ResponseWriter, r *http.Request) {
+	session, err := p.store.Get(r, v1.CookieSession)
+	if err != nil {
+		RespondWithError(w, r, 0,
+			"handleLoginChallenge: failed to get session: %v", err)
+		return
+	}
+
+	// Get the login challenge command.
+	var lc v1.LoginChallenge
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&lc); err != nil {
+		RespondWithError(w, r, 0,
+			"handleLoginChallenge: failed to decode: %v", err)
+		return
+	}
+	defer r.Body.Close()
+
+	reply, challenge, err := p.backend.ProcessLoginChallenge(lc)
+	if err != nil {
+		RespondWithError(w, r, 0,
+			"handleLoginChallenge: ProcessLoginChallenge %v", err)
+		return
+	}
+
+	// Keep the challenge with the session that requested it.
+	session.Values["loginemail"] = challenge.Email
+	session.Values["loginchallenge"] = challenge.Challenge
+	session.Values["loginchallengeexpiry"] = challenge.Expiry
+	err = session.Save(r, w)
+	if err != nil {
+		RespondWithError(w, r, 0,
+			"handleLoginChallenge: failed to save session: %v", err)
+		return
+	}
+
+	util.RespondWithJSON(w, http.StatusOK, reply)
+}
+
+// handleLoginSigned handles the incoming signed login command.  It verifies
+// that the user signed the login challenge with the active public key.  On
+// success the session is set up exactly like it is by handleLogin.
+func (p *politeiawww) handleLoginSigned(w http.ResponseWriter, r *http.Request) {
+	session, err := p.store.Get(r, v1.CookieSession)
+	if err != nil {
+		RespondWithError(w, r, 0,
+			"handleLoginSigned: failed to get session: %v", err)
+		return
+	}
+
+	// Get the signed login command.
+	var l v1.LoginSigned
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&l); err != nil {
+		RespondWithError(w, r, 0,
+			"handleLoginSigned: failed to decode: %v", err)
+		return
+	}
+	defer r.Body.Close()
+
+	// The challenge of the session can only be used once.
+	var challenge *loginChallenge
+	if c, ok := session.Values["loginchallenge"].([]byte); ok {
+		challenge = &loginChallenge{Challenge: c}
+		challenge.Email, _ = session.Values["loginemail"].(string)
+		challenge.Expiry, _ = session.Values["loginchallengeexpiry"].(int64)
+	}
+	delete(session.Values, "loginemail")
+	delete(session.Values, "loginchallenge")
+	delete(session.Values, "loginchallengeexpiry")
+
+	reply, err := p.backend.ProcessLoginSigned(l, challenge)
+	if err != nil {
+		if serr := session.Save(r, w); serr != nil {
+			log.Errorf("handleLoginSigned: failed to save session: %v",
+				serr)
+		}
+		RespondWithError(w, r, http.StatusForbidden,
+			"handleLoginSigned: ProcessLoginSigned %v", err)
+		return
+	}
+
+	// Mark user as logged in if there's no error.
+	err = p.login(w, r, session, l.Email, reply)
+	if err != nil {
+		RespondWithError(w, r, 0,
+			"handleLoginSigned: failed to save session: %v", err)
+		return
+	}
+
+	// Reply with the user information.
+	util.RespondWithJSON(w, http.StatusOK, reply)
+}
+
+// login marks the session as logged in as the provided user.
+func (p *politeiawww) login(w http.ResponseWriter, r *http.Request, session *sessions.Session, email string, reply *v1.LoginReply) error {
+	session.Values["email"] = email
+	session.Values["id"] = reply.UserID
+	session.Values["authenticated"] = true
+	session.Values["admin"] = reply.IsAdmin
+	return session.Save(r, w)
+}
+
 // handleLogout logs the user out.  A login will be required to resume sending
 // commands,
 func (p *politeiawww) handleLogout(w http.ResponseWriter, r *http.Request) {
@@ -394,6 +517,149 @@ func (p *politeiawww) handleChangePassword(w http.ResponseWriter, r *http.Reques
 	util.RespondWithJSON(w, http.StatusOK, reply)
 }
 
+// handleUpdateUserKey handles the incoming update user key command.  It
+// registers a pending public key for the logged in user and replies with the
+// challenge that must be signed by that key.
+func (p *politeiawww) handleUpdateUserKey(w http.ResponseWriter, r *http.Request) {
+	// Get the email for the current session.
+	session, err := p.store.Get(r, v1.CookieSession)
+	if err != nil {
+		RespondWithError(w, r, 0,
+			"handleUpdateUserKey: failed to get session: %v", err)
+		return
+	}
+
+	email, ok := session.Values["email"].(string)
+	if !ok {
+		RespondWithError(w, r, 0,
+			"handleUpdateUserKey: type assert ok %v", ok)
+		return
+	}
+
+	// Get the update user key command.
+	var uk v1.UpdateUserKey
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&uk); err != nil {
+		RespondWithError(w, r, 0,
+			"handleUpdateUserKey: Unmarshal %v", err)
+		return
+	}
+	defer r.Body.Close()
+
+	reply, err := p.backend.ProcessUpdateUserKey(email, uk)
+	if err != nil {
+		RespondWithError(w, r, 0,
+			"handleUpdateUserKey: ProcessUpdateUserKey %v", err)
+		return
+	}
+
+	util.RespondWithJSON(w, http.StatusOK, reply)
+}
+
+// handleVerifyUpdateUserKey handles the incoming verify update user key
+// command.  It activates the pending public key of the logged in user.
+func (p *politeiawww) handleVerifyUpdateUserKey(w http.ResponseWriter, r *http.Request) {
+	// Get the email for the current session.
+	session, err := p.store.Get(r, v1.CookieSession)
+	if err != nil {
+		RespondWithError(w, r, 0,
+			"handleVerifyUpdateUserKey: failed to get session: %v", err)
+		return
+	}
+
+	email, ok := session.Values["email"].(string)
+	if !ok {
+		RespondWithError(w, r, 0,
+			"handleVerifyUpdateUserKey: type assert ok %v", ok)
+		return
+	}
+
+	// Get the verify update user key command.
+	var vuk v1.VerifyUpdateUserKey
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&vuk); err != nil {
+		RespondWithError(w, r, 0,
+			"handleVerifyUpdateUserKey: Unmarshal %v", err)
+		return
+	}
+	defer r.Body.Close()
+
+	reply, err := p.backend.ProcessVerifyUpdateUserKey(email, vuk)
+	if err != nil {
+		RespondWithError(w, r, 0,
+			"handleVerifyUpdateUserKey: ProcessVerifyUpdateUserKey %v", err)
+		return
+	}
+
+	util.RespondWithJSON(w, http.StatusOK, reply)
+}
+
+// handleRevokeUserKey handles the incoming revoke user key command.  It
+// deactivates the active public key of the logged in user.
+func (p *politeiawww) handleRevokeUserKey(w http.ResponseWriter, r *http.Request) {
+	// Get the email for the current session.
+	session, err := p.store.Get(r, v1.CookieSession)
+	if err != nil {
+		RespondWithError(w, r, 0,
+			"handleRevokeUserKey: failed to get session: %v", err)
+		return
+	}
+
+	email, ok := session.Values["email"].(string)
+	if !ok {
+		RespondWithError(w, r, 0,
+			"handleRevokeUserKey: type assert ok %v", ok)
+		return
+	}
+
+	// Get the revoke user key command.
+	var rk v1.RevokeUserKey
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&rk); err != nil {
+		RespondWithError(w, r, 0,
+			"handleRevokeUserKey: Unmarshal %v", err)
+		return
+	}
+	defer r.Body.Close()
+
+	reply, err := p.backend.ProcessRevokeUserKey(email, rk)
+	if err != nil {
+		RespondWithError(w, r, 0,
+			"handleRevokeUserKey: ProcessRevokeUserKey %v", err)
+		return
+	}
+
+	util.RespondWithJSON(w, http.StatusOK, reply)
+}
+
+// handleUserKeys handles the incoming user keys command.  It replies with all
+// public keys of the logged in user.
+func (p *politeiawww) handleUserKeys(w http.ResponseWriter, r *http.Request) {
+	// Get the email for the current session.
+	session, err := p.store.Get(r, v1.CookieSession)
+	if err != nil {
+		RespondWithError(w, r, 0,
+			"handleUserKeys: failed to get session: %v", err)
+		return
+	}
+
+	email, ok := session.Values["email"].(string)
+	if !ok {
+		RespondWithError(w, r, 0,
+			"handleUserKeys: type assert ok %v", ok)
+		return
+	}
+
+	reply, err := p.backend.ProcessUserKeys(email, v1.UserKeys{})
+	if err != nil {
+		RespondWithError(w, r, 0,
+			"handleUserKeys: ProcessUserKeys %v", err)
+		return
+	}
+
+	util.RespondWithJSON(w, http.StatusOK, reply)
+}
+
 func (p *politeiawww) handleResetPassword(w http.ResponseWriter, r *http.Request) {
 	// Get the reset password command.
 	var rp v1.ResetPassword
@@ -418,6 +684,21 @@ func (p *politeiawww) handleResetPassword(w http.ResponseWriter, r *http.Request
 
 // handleNewProposal handles the incoming new proposal command.
 func (p *politeiawww) handleNewProposal(w http.ResponseWriter, r *http.Request) {
+	// Get the email for the current session.
+	session, err := p.store.Get(r, v1.CookieSession)
+	if err != nil {
+		RespondWithError(w, r, 0,
+			"handleNewProposal: failed to get session: %v", err)
+		return
+	}
+
+	email, ok := session.Values["email"].(string)
+	if !ok {
+		RespondWithError(w, r, 0,
+			"handleNewProposal: type assert ok %v", ok)
+		return
+	}
+
 	// Get the new proposal command.
 	var np v1.NewProposal
 	decoder := json.NewDecoder(r.Body)
@@ -428,7 +709,7 @@ func (p *politeiawww) handleNewProposal(w http.ResponseWriter, r *http.Request)
 	}
 	defer r.Body.Close()
 
-	reply, err := p.backend.ProcessNewProposal(np)
+	reply, err := p.backend.ProcessNewProposal(np, email)
 	if err != nil {
 		RespondWithError(w, r, 0,
 			"handleNewProposal: ProcessNewProposal %v", err)
@@ -463,6 +744,88 @@ func (p *politeiawww) handleSetProposalStatus(w http.ResponseWriter, r *http.Req
 	util.RespondWithJSON(w, http.StatusOK, reply)
 }
 
+// handleWithdrawProposal handles the incoming withdraw proposal command.  It
+// is used by authors to withdraw their unreviewed proposals.
+func (p *politeiawww) handleWithdrawProposal(w http.ResponseWriter, r *http.Request) {
+	// Get the email for the current session.
+	session, err := p.store.Get(r, v1.CookieSession)
+	if err != nil {
+		RespondWithError(w, r, 0,
+			"handleWithdrawProposal: failed to get session: %v", err)
+		return
+	}
+
+	email, ok := session.Values["email"].(string)
+	if !ok {
+		RespondWithError(w, r, 0,
+			"handleWithdrawProposal: type assert ok %v", ok)
+		return
+	}
+
+	// Get the withdraw proposal command.
+	var wp v1.WithdrawProposal
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&wp); err != nil {
+		RespondWithError(w, r, 0,
+			"handleWithdrawProposal: Unmarshal %v", err)
+		return
+	}
+	defer r.Body.Close()
+
+	reply, err := p.backend.ProcessWithdrawProposal(wp, email)
+	if err != nil {
+		RespondWithError(w, r, 0,
+			"handleWithdrawProposal: ProcessWithdrawProposal %v", err)
+		return
+	}
+
+	// Reply with the new proposal status.
+	util.RespondWithJSON(w, http.StatusOK, reply)
+}
+
+// handleEditProposal handles the incoming edit proposal command.  It replaces
+// the files of an existing proposal.
+func (p *politeiawww) handleEditProposal(w http.ResponseWriter, r *http.Request) {
+	// Get the email for the current session.
+	session, err := p.store.Get(r, v1.CookieSession)
+	if err != nil {
+		RespondWithError(w, r, 0,
+			"handleEditProposal: failed to get session: %v", err)
+		return
+	}
+
+	email, ok := session.Values["email"].(string)
+	if !ok {
+		RespondWithError(w, r, 0,
+			"handleEditProposal: type assert ok %v", ok)
+		return
+	}
+
+	// Get the edit proposal command.
+	var ep v1.EditProposal
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&ep); err != nil {
+		RespondWithError(w, r, 0,
+			"handleEditProposal: Unmarshal %v", err)
+		return
+	}
+	defer r.Body.Close()
+
+	// The path param is authoritative.
+	pathParams := mux.Vars(r)
+	ep.Token = pathParams["token"]
+
+	reply, err := p.backend.ProcessEditProposal(ep, email)
+	if err != nil {
+		RespondWithError(w, r, 0,
+			"handleEditProposal: ProcessEditProposal %v", err)
+		return
+	}
+
+	// Reply with the new censorship record.
+	util.RespondWithJSON(w, http.StatusOK, reply)
+}
+
 // handleProposalDetails handles the incoming proposal details command. It fetches
 // the complete details for an existing proposal.
 func (p *politeiawww) handleProposalDetails(w http.ResponseWriter, r *http.Request) {
@@ -500,6 +863,75 @@ func (p *politeiawww) handleProposalDetails(w http.ResponseWriter, r *http.Reque
 	util.RespondWithJSON(w, http.StatusOK, reply)
 }
 
+// handleProposalVersions handles the incoming proposal versions command.  It
+// returns the revision history of a proposal.
+func (p *politeiawww) handleProposalVersions(w http.ResponseWriter, r *http.Request) {
+	// Add the path param to the struct.
+	var pv v1.ProposalVersions
+	pathParams := mux.Vars(r)
+	pv.Token = pathParams["token"]
+
+	session, err := p.store.Get(r, v1.CookieSession)
+	if err != nil {
+		RespondWithError(w, r, 0,
+			"handleProposalVersions: failed to get session %v", err)
+		return
+	}
+
+	isAdmin, _ := session.Values["admin"].(bool)
+	reply, err := p.backend.ProcessProposalVersions(pv, isAdmin)
+	if err != nil {
+		RespondWithError(w, r, 0,
+			"handleProposalVersions: ProcessProposalVersions %v", err)
+		return
+	}
+
+	// Reply with the proposal versions.
+	util.RespondWithJSON(w, http.StatusOK, reply)
+}
+
+// handleProposalDiff handles the incoming proposal diff command.  It returns
+// the unified diff of every file that changed between two proposal versions.
+func (p *politeiawww) handleProposalDiff(w http.ResponseWriter, r *http.Request) {
+	// Add the path params to the struct.  The route only matches digits
+	// so parsing can only fail on overflow.
+	var pd v1.ProposalDiff
+	pathParams := mux.Vars(r)
+	pd.Token = pathParams["token"]
+	from, err := strconv.ParseUint(pathParams["from"], 10, 32)
+	if err != nil {
+		RespondWithError(w, r, 0,
+			"handleProposalDiff: ParseUint from %v", err)
+		return
+	}
+	to, err := strconv.ParseUint(pathParams["to"], 10, 32)
+	if err != nil {
+		RespondWithError(w, r, 0,
+			"handleProposalDiff: ParseUint to %v", err)
+		return
+	}
+	pd.From = uint(from)
+	pd.To = uint(to)
+
+	session, err := p.store.Get(r, v1.CookieSession)
+	if err != nil {
+		RespondWithError(w, r, 0,
+			"handleProposalDiff: failed to get session %v", err)
+		return
+	}
+
+	isAdmin, _ := session.Values["admin"].(bool)
+	reply, err := p.backend.ProcessProposalDiff(pd, isAdmin)
+	if err != nil {
+		RespondWithError(w, r, 0,
+			"handleProposalDiff: ProcessProposalDiff %v", err)
+		return
+	}
+
+	// Reply with the proposal diff.
+	util.RespondWithJSON(w, http.StatusOK, reply)
+}
+
 func (p *politeiawww) handlePolicy(w http.ResponseWriter, r *http.Request) {
 	// Get the policy command.
 	var policy v1.Policy
@@ -587,11 +1019,58 @@ func (p *politeiawww) handleNewComment(w http.ResponseWriter, r *http.Request) {
 	util.RespondWithJSON(w, http.StatusOK, cr)
 }
 
-// handleCommentsGet handles batched comments get.
+// parseGetComments returns the comment query options of the URL query.
+func parseGetComments(query url.Values) (v1.GetComments, error) {
+	invalid := v1.UserError{
+		ErrorCode: v1.ErrorStatusInvalidCommentsQuery,
+	}
+	gc := v1.GetComments{
+		Sort:   v1.CommentSortT(query.Get("sort")),
+		Cursor: query.Get("cursor"),
+	}
+	if v := query.Get("pagesize"); v != "" {
+		u, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return gc, invalid
+		}
+		gc.PageSize = uint(u)
+	}
+	if v := query.Get("parentid"); v != "" {
+		u, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return gc, invalid
+		}
+		gc.ParentID = u
+	}
+	if v := query.Get("depth"); v != "" {
+		u, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return gc, invalid
+		}
+		gc.Depth = uint(u)
+	}
+	if v := query.Get("thread"); v != "" {
+		thread, err := strconv.ParseBool(v)
+		if err != nil {
+			return gc, invalid
+		}
+		gc.Thread = thread
+	}
+	return gc, nil
+}
+
+// handleCommentsGet handles batched comments get.  The query options are read
+// from the URL query.
 func (p *politeiawww) handleCommentsGet(w http.ResponseWriter, r *http.Request) {
 	pathParams := mux.Vars(r)
 	defer r.Body.Close()
-	gcr, err := p.backend.ProcessCommentGet(pathParams["token"])
+	gc, err := parseGetComments(r.URL.Query())
+	if err != nil {
+		RespondWithError(w, r, 0,
+			"handleCommentsGet: parseGetComments %v", err)
+		return
+	}
+	gcr, err := p.backend.ProcessCommentGet(pathParams["token"], gc)
 	if err != nil {
 		RespondWithError(w, r, 0,
 			"handleCommentsGet: ProcessCommentGet %v", err)
@@ -601,6 +1080,94 @@ func (p *politeiawww) handleCommentsGet(w http.ResponseWriter, r *http.Request)
 	util.RespondWithJSON(w, http.StatusOK, gcr)
 }
 
+// handleLikeComment handles the vote of a user on a comment.
+func (p *politeiawww) handleLikeComment(w http.ResponseWriter, r *http.Request) {
+	var lc v1.LikeComment
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&lc); err != nil {
+		RespondWithError(w, r, 0,
+			"handleLikeComment: Unmarshal %v", err)
+		return
+	}
+	defer r.Body.Close()
+
+	// Get session to retrieve user id
+	session, err := p.store.Get(r, v1.CookieSession)
+	if err != nil {
+		RespondWithError(w, r, 0,
+			"handleLikeComment: failed to get session: %v", err)
+		return
+	}
+	userID, ok := session.Values["id"].(uint64)
+	if !ok {
+		RespondWithError(w, r, 0,
+			"handleLikeComment: invalid user ID: %v", err)
+		return
+	}
+
+	reply, err := p.backend.ProcessLikeComment(lc, userID)
+	if err != nil {
+		RespondWithError(w, r, 0,
+			"handleLikeComment: ProcessLikeComment %v", err)
+		return
+	}
+
+	util.RespondWithJSON(w, http.StatusOK, reply)
+}
+
+// handleUserCommentsLikes returns the votes of the logged in user on the
+// comments of a proposal.
+func (p *politeiawww) handleUserCommentsLikes(w http.ResponseWriter, r *http.Request) {
+	pathParams := mux.Vars(r)
+	defer r.Body.Close()
+
+	// Get session to retrieve user id
+	session, err := p.store.Get(r, v1.CookieSession)
+	if err != nil {
+		RespondWithError(w, r, 0,
+			"handleUserCommentsLikes: failed to get session: %v", err)
+		return
+	}
+	userID, ok := session.Values["id"].(uint64)
+	if !ok {
+		RespondWithError(w, r, 0,
+			"handleUserCommentsLikes: invalid user ID: %v", err)
+		return
+	}
+
+	reply, err := p.backend.ProcessUserCommentsLikes(pathParams["token"],
+		userID)
+	if err != nil {
+		RespondWithError(w, r, 0,
+			"handleUserCommentsLikes: ProcessUserCommentsLikes %v",
+			err)
+		return
+	}
+
+	util.RespondWithJSON(w, http.StatusOK, reply)
+}
+
+// handleCensorComment handles the censoring of a comment by an admin.
+func (p *politeiawww) handleCensorComment(w http.ResponseWriter, r *http.Request) {
+	var cc v1.CensorComment
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&cc); err != nil {
+		RespondWithError(w, r, 0,
+			"handleCensorComment: Unmarshal %v", err)
+		return
+	}
+	defer r.Body.Close()
+
+	reply, err := p.backend.ProcessCensorComment(cc)
+	if err != nil {
+		RespondWithError(w, r, 0,
+			"handleCensorComment: ProcessCensorComment %v", err)
+		return
+	}
+
+	util.RespondWithJSON(w, http.StatusOK, reply)
+}
+
 // handleNotFound is a generic handler for an invalid route.
 func (p *politeiawww) handleNotFound(w http.ResponseWriter, r *http.Request) {
 	// Log incoming connection
@@ -687,12 +1254,17 @@ func _main() error {
 	if err != nil {
 		return err
 	}
+	defer p.backend.Close()
 
 	// Fetch the inventory from politeiad and cache it.
 	if err := p.backend.LoadInventory(); err != nil {
 		return err
 	}
 
+	// Send journaled comments to politeiad and keep the journals small.
+	go p.backend.commentFlusher()
+	go p.backend.journalCompactor()
+
 	var csrfHandle func(http.Handler) http.Handler
 	if !p.cfg.Proxy {
 		// We don't persist connections to generate a new key every
@@ -722,6 +1294,10 @@ func _main() error {
 		p.handleVerifyNewUser, permissionPublic)
 	p.addRoute(http.MethodPost, v1.RouteLogin, p.handleLogin,
 		permissionPublic)
+	p.addRoute(http.MethodPost, v1.RouteLoginChallenge,
+		p.handleLoginChallenge, permissionPublic)
+	p.addRoute(http.MethodPost, v1.RouteLoginSigned, p.handleLoginSigned,
+		permissionPublic)
 	p.addRoute(http.MethodGet, v1.RouteLogout, p.handleLogout,
 		permissionPublic)
 	p.addRoute(http.MethodPost, v1.RouteLogout, p.handleLogout,
@@ -736,6 +1312,10 @@ func _main() error {
 		permissionPublic)
 	p.addRoute(http.MethodGet, v1.RouteCommentsGet, p.handleCommentsGet,
 		permissionPublic)
+	p.addRoute(http.MethodGet, v1.RouteProposalVersions,
+		p.handleProposalVersions, permissionPublic)
+	p.addRoute(http.MethodGet, v1.RouteProposalDiff, p.handleProposalDiff,
+		permissionPublic)
 
 	// Routes that require being logged in.
 	p.addRoute(http.MethodPost, v1.RouteSecret, p.handleSecret, permissionLogin)
@@ -744,14 +1324,32 @@ func _main() error {
 	p.addRoute(http.MethodGet, v1.RouteUserMe, p.handleMe, permissionLogin)
 	p.addRoute(http.MethodPost, v1.RouteChangePassword,
 		p.handleChangePassword, permissionLogin)
+	p.addRoute(http.MethodPost, v1.RouteUpdateUserKey,
+		p.handleUpdateUserKey, permissionLogin)
+	p.addRoute(http.MethodPost, v1.RouteVerifyUpdateUserKey,
+		p.handleVerifyUpdateUserKey, permissionLogin)
+	p.addRoute(http.MethodPost, v1.RouteRevokeUserKey,
+		p.handleRevokeUserKey, permissionLogin)
+	p.addRoute(http.MethodGet, v1.RouteUserKeys, p.handleUserKeys,
+		permissionLogin)
 	p.addRoute(http.MethodPost, v1.RouteNewComment,
 		p.handleNewComment, permissionLogin)
+	p.addRoute(http.MethodPost, v1.RouteLikeComment,
+		p.handleLikeComment, permissionLogin)
+	p.addRoute(http.MethodGet, v1.RouteUserCommentsLikes,
+		p.handleUserCommentsLikes, permissionLogin)
+	p.addRoute(http.MethodPost, v1.RouteEditProposal,
+		p.handleEditProposal, permissionLogin)
+	p.addRoute(http.MethodPost, v1.RouteWithdrawProposal,
+		p.handleWithdrawProposal, permissionLogin)
 
 	// Routes that require being logged in as an admin user.
 	p.addRoute(http.MethodGet, v1.RouteAllUnvetted, p.handleAllUnvetted,
 		permissionAdmin)
 	p.addRoute(http.MethodPost, v1.RouteSetProposalStatus,
 		p.handleSetProposalStatus, permissionAdmin)
+	p.addRoute(http.MethodPost, v1.RouteCensorComment,
+		p.handleCensorComment, permissionAdmin)
 
 	// Persist session cookies.
 	var cookieKey []byte
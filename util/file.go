@@ -75,6 +75,13 @@ func LoadFile(filename string) (mimeType string, digest string, payload string,
 		return
 	}
 
+	return LoadBytes(b)
+}
+
+// LoadBytes returns the MIME type, the sha256 digest and the base64 encoded
+// payload of the provided file content.  If any of the intermediary
+// operations fail the function will return an error instead.
+func LoadBytes(b []byte) (mimeType string, digest string, payload string, err error) {
 	// MIME
 	mimeType = http.DetectContentType(b)
 	if !mime.MimeValid(mimeType) {
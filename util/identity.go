@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"os"
 
 	"github.com/decred/politeia/politeiad/api/v1"
 	"github.com/decred/politeia/politeiad/api/v1/identity"
@@ -47,62 +48,67 @@ func ConvertRemoteIdentity(rid v1.IdentityReply) (*identity.PublicIdentity, erro
 	return &serverID, nil
 }
 
-// RemoteIdentity fetches the identity from politeiad.
-func RemoteIdentity(skipTLSVerify bool, host, cert string) (*identity.PublicIdentity, error) {
+// RemoteIdentity fetches the identity and the key chain from politeiad.  The
+// key chain is verified to end at the returned identity.
+func RemoteIdentity(skipTLSVerify bool, host, cert string) (*identity.PublicIdentity, []v1.KeyRotation, error) {
 	challenge, err := Random(v1.ChallengeSize)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	id, err := json.Marshal(v1.Identity{
 		Challenge: hex.EncodeToString(challenge),
 	})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	c, err := NewClient(skipTLSVerify, cert)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	r, err := c.Post(host+v1.IdentityRoute, "application/json",
 		bytes.NewReader(id))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer r.Body.Close()
 
 	if r.StatusCode != http.StatusOK {
 		e, err := GetErrorFromJSON(r.Body)
 		if err != nil {
-			return nil, fmt.Errorf("%v", r.Status)
+			return nil, nil, fmt.Errorf("%v", r.Status)
 		}
-		return nil, fmt.Errorf("%v: %v", r.Status, e)
+		return nil, nil, fmt.Errorf("%v: %v", r.Status, e)
 	}
 
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	var ir v1.IdentityReply
 	err = json.Unmarshal(body, &ir)
 	if err != nil {
-		return nil, fmt.Errorf("Could node unmarshal IdentityReply: %v",
+		return nil, nil, fmt.Errorf("Could node unmarshal IdentityReply: %v",
 			err)
 	}
 
 	// Convert and verify server identity
 	identity, err := ConvertRemoteIdentity(ir)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	err = VerifyChallenge(identity, challenge, ir.Response)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	err = v1.VerifyKeyChain(ir.KeyChain, ir.Key)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return identity, nil
+	return identity, ir.KeyChain, nil
 }
 
 // VerifyChallenge checks that the signature returned from politeiad is the
@@ -121,3 +127,66 @@ func VerifyChallenge(id *identity.PublicIdentity, challenge []byte, signature st
 
 	return nil
 }
+
+// VerifyIdentityRotation checks that the key chain leads from the pinned
+// identity to id.  The chain must have been verified with v1.VerifyKeyChain.
+func VerifyIdentityRotation(pinned, id *identity.PublicIdentity, chain []v1.KeyRotation) error {
+	if pinned.Key == id.Key {
+		return nil
+	}
+	key := hex.EncodeToString(pinned.Key[:])
+	for _, kr := range chain {
+		if kr.OldKey == key {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("identity %x is not part of the key chain",
+		pinned.Key)
+}
+
+// KeyChainIdentities returns the identities of every key in the chain, oldest
+// first, followed by id.  These are all the keys that may have signed records
+// of the server.
+func KeyChainIdentities(id *identity.PublicIdentity, chain []v1.KeyRotation) ([]identity.PublicIdentity, error) {
+	ids := make([]identity.PublicIdentity, 0, len(chain)+1)
+	for _, kr := range chain {
+		pid, err := identity.PublicIdentityFromString(kr.OldKey)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, *pid)
+	}
+
+	return append(ids, *id), nil
+}
+
+// SaveKeyChain writes the key chain to filename.
+func SaveKeyChain(filename string, chain []v1.KeyRotation) error {
+	b, err := json.Marshal(chain)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filename, b, 0600)
+}
+
+// LoadKeyChain reads a key chain that was written by SaveKeyChain.  A missing
+// file is an empty chain.
+func LoadKeyChain(filename string) ([]v1.KeyRotation, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var chain []v1.KeyRotation
+	err = json.Unmarshal(b, &chain)
+	if err != nil {
+		return nil, err
+	}
+
+	return chain, nil
+}
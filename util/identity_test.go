@@ -0,0 +1,138 @@
+package util_test
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/decred/politeia/politeiad/api/v1"
+	"github.com/decred/politeia/politeiad/api/v1/identity"
+	"github.com/decred/politeia/util"
+)
+
+// rotate returns the rotation from old to a new identity.
+func rotate(t *testing.T, old *identity.FullIdentity, timestamp int64) (*identity.FullIdentity, v1.KeyRotation) {
+	id, err := identity.New("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kr := v1.KeyRotation{
+		OldKey:    hex.EncodeToString(old.Public.Key[:]),
+		NewKey:    hex.EncodeToString(id.Public.Key[:]),
+		Timestamp: timestamp,
+	}
+	message, err := v1.KeyRotationMessage(kr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := old.SignMessage(message)
+	kr.Signature = hex.EncodeToString(signature[:])
+
+	return id, kr
+}
+
+func TestKeyChain(t *testing.T) {
+	first, err := identity.New("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, kr1 := rotate(t, first, 1)
+	third, kr2 := rotate(t, second, 2)
+	chain := []v1.KeyRotation{kr1, kr2}
+	key := hex.EncodeToString(third.Public.Key[:])
+
+	err = v1.VerifyKeyChain(chain, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v1.VerifyKeyChain(nil, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The chain must end at the key.
+	err = v1.VerifyKeyChain(chain[:1], key)
+	if err != v1.ErrInvalidChain {
+		t.Fatalf("expected ErrInvalidChain got %v", err)
+	}
+
+	// The rotations must link up.
+	err = v1.VerifyKeyChain([]v1.KeyRotation{kr2, kr1},
+		hex.EncodeToString(second.Public.Key[:]))
+	if err != v1.ErrInvalidChain {
+		t.Fatalf("expected ErrInvalidChain got %v", err)
+	}
+
+	// Every rotation must be signed by the old key.
+	forged := kr2
+	forged.Timestamp = 3
+	err = v1.VerifyKeyChain([]v1.KeyRotation{kr1, forged}, key)
+	if err != v1.ErrCorrupt {
+		t.Fatalf("expected ErrCorrupt got %v", err)
+	}
+
+	// Any key in the chain leads to the last one.
+	for _, pinned := range []*identity.FullIdentity{first, second, third} {
+		err = util.VerifyIdentityRotation(&pinned.Public, &third.Public,
+			chain)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	other, err := identity.New("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = util.VerifyIdentityRotation(&other.Public, &third.Public, chain)
+	if err == nil {
+		t.Fatalf("expected unknown identity to be rejected")
+	}
+
+	ids, err := util.KeyChainIdentities(&third.Public, chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, id := range []*identity.FullIdentity{first, second, third} {
+		if ids[i].Key != id.Public.Key {
+			t.Fatalf("unexpected identity %v", i)
+		}
+	}
+}
+
+func TestSaveKeyChain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "politeia.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "keychain.json")
+
+	// A missing file is an empty chain.
+	chain, err := util.LoadKeyChain(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chain) != 0 {
+		t.Fatalf("expected empty chain got %v", chain)
+	}
+
+	first, err := identity.New("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, kr := rotate(t, first, 1)
+	err = util.SaveKeyChain(filename, []v1.KeyRotation{kr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, err = util.LoadKeyChain(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(chain, []v1.KeyRotation{kr}) {
+		t.Fatalf("unexpected chain %v", chain)
+	}
+}
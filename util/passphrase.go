@@ -0,0 +1,92 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/decred/politeia/politeiad/api/v1/identity"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// PassphraseFromEnv returns a PassphraseFunc that reads the passphrase from
+// the environment variable name.
+func PassphraseFromEnv(name string) identity.PassphraseFunc {
+	return func() ([]byte, error) {
+		p := os.Getenv(name)
+		if p == "" {
+			return nil, fmt.Errorf("environment variable %v is not set",
+				name)
+		}
+		return []byte(p), nil
+	}
+}
+
+// PassphraseFromFile returns a PassphraseFunc that reads the passphrase from
+// filename.  A single trailing newline is ignored.
+func PassphraseFromFile(filename string) identity.PassphraseFunc {
+	return func() ([]byte, error) {
+		b, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		b = bytes.TrimSuffix(b, []byte("\n"))
+		b = bytes.TrimSuffix(b, []byte("\r"))
+		if len(b) == 0 {
+			return nil, fmt.Errorf("empty passphrase file %v", filename)
+		}
+		return b, nil
+	}
+}
+
+// stdin is shared by all prompts that read from a stdin that is not a
+// terminal.  A reader per prompt would buffer, and then drop, the input of
+// the prompts that follow.
+var stdin = bufio.NewReader(os.Stdin)
+
+// PassphrasePrompt returns a PassphraseFunc that prints prompt and reads the
+// passphrase from stdin.  Echo is turned off while reading when stdin is a
+// terminal.
+func PassphrasePrompt(prompt string) identity.PassphraseFunc {
+	return func() ([]byte, error) {
+		fmt.Fprint(os.Stderr, prompt)
+
+		var (
+			line []byte
+			err  error
+		)
+		fd := int(os.Stdin.Fd())
+		if terminal.IsTerminal(fd) {
+			line, err = terminal.ReadPassword(fd)
+			fmt.Fprintln(os.Stderr)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			line, err = stdin.ReadBytes('\n')
+			if err != nil && len(line) == 0 {
+				return nil, err
+			}
+			line = bytes.TrimRight(line, "\r\n")
+		}
+		if len(line) == 0 {
+			return nil, fmt.Errorf("empty passphrase")
+		}
+		return line, nil
+	}
+}
+
+// IdentityPassphrase returns the PassphraseFunc for an identity.  The
+// passphrase is read from filename if it is set, otherwise from the
+// environment variable env if it is set, otherwise the user is prompted.
+func IdentityPassphrase(env, filename string) identity.PassphraseFunc {
+	switch {
+	case filename != "":
+		return PassphraseFromFile(filename)
+	case env != "":
+		return PassphraseFromEnv(env)
+	}
+	return PassphrasePrompt("Identity passphrase: ")
+}
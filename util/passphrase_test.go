@@ -0,0 +1,94 @@
+package util
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIdentityPassphrase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "passphrase")
+	err = ioutil.WriteFile(filename, []byte("from file\r\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty := filepath.Join(dir, "empty")
+	err = ioutil.WriteFile(empty, []byte("\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const env = "POLITEIA_TEST_PASSPHRASE"
+	os.Setenv(env, "from env")
+	defer os.Unsetenv(env)
+
+	tests := []struct {
+		env      string
+		filename string
+		want     string // Empty if an error is expected
+	}{
+		{env, filename, "from file"}, // The file takes precedence
+		{"", filename, "from file"},
+		{env, "", "from env"},
+		{env, empty, ""},
+		{env, filepath.Join(dir, "missing"), ""},
+		{env + "_UNSET", "", ""},
+	}
+	for i, test := range tests {
+		p, err := IdentityPassphrase(test.env, test.filename)()
+		if test.want == "" {
+			if err == nil {
+				t.Fatalf("%v: expected error got %q", i, p)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%v: %v", i, err)
+		}
+		if string(p) != test.want {
+			t.Fatalf("%v: got %q wanted %q", i, p, test.want)
+		}
+	}
+}
+
+func TestPassphrasePrompt(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	_, err = w.WriteString("first\nsecond\r\n")
+	w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Consecutive prompts read consecutive lines of a stdin that is not
+	// a terminal.
+	oldStdin, oldReader := os.Stdin, stdin
+	os.Stdin = r
+	stdin = bufio.NewReader(r)
+	defer func() {
+		os.Stdin, stdin = oldStdin, oldReader
+	}()
+	for _, want := range []string{"first", "second"} {
+		p, err := PassphrasePrompt("")()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(p) != want {
+			t.Fatalf("got %q wanted %q", p, want)
+		}
+	}
+	_, err = PassphrasePrompt("")()
+	if err == nil {
+		t.Fatalf("expected error at end of input")
+	}
+}